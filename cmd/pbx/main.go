@@ -9,11 +9,14 @@ import (
 	"syscall"
 	"time"
 
+	"SipServer/internal/acl"
 	httpserver "SipServer/internal/http_server"
 	"SipServer/internal/metrics"
 	"SipServer/internal/registrar"
+	aclrule "SipServer/internal/repository/acl_rule"
 	"SipServer/internal/router"
 	"SipServer/internal/sipserver"
+	"SipServer/internal/usecase"
 	"SipServer/pkg/dbconnecter"
 
 	"github.com/emiago/sipgo"
@@ -53,9 +56,20 @@ func main() {
 	metrics.MustRegister(regM)
 	// ---------------- HTTP -------------------
 
-	sh := httpserver.NewHttpServer(db)
+	// ---------------- ACL -------------------
+	acls := acl.New(aclrule.NewACLRuleRepo(db))
+	if err := acls.Reload(context.Background()); err != nil {
+		log.Printf("[ACL] load rules: %v", err)
+	}
+
+	// первый администратор из ADMIN_LOGIN / ADMIN_PASSWORD
+	if err := usecase.NewAuthUsecase(db).Bootstrap(); err != nil {
+		log.Printf("[AUTH] bootstrap admin: %v", err)
+	}
+
+	sh := httpserver.NewHttpServer(db, reg)
 	r := router.NewRouter(sh, regM)
-	handler := httpserver.MetricsMiddleware(r)
+	handler := httpserver.MetricsMiddleware(httpserver.ACLMiddleware(acls, r))
 	port := os.Getenv("HTTP_PORT")
 	if port == "" {
 		port = defaultHttpPort
@@ -68,7 +82,7 @@ func main() {
 	}()
 
 	// ------------------- SIP -------------------
-	sip, err := sipserver.New(ua, reg, db)
+	sip, err := sipserver.New(ua, reg, db, acls)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -78,6 +92,13 @@ func main() {
 		stop()
 	}()
 
+	go acls.Run(ctx)
+	go sip.RunTrunkRegistrations(ctx)
+	go sip.RunRecordingRetention(ctx)
+	go sip.RunPresence(ctx)
+	go sip.RunMWI(ctx)
+	go sip.RunFloodGuard(ctx)
+
 	go func() {
 		log.Println("SIP server listening on udp://0.0.0.0:5060")
 		if err := sip.ListenAndServe(ctx, "udp", "0.0.0.0:5060"); err != nil {
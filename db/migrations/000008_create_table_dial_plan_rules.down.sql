@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS trg_dial_plan_rules_touch ON dial_plan_rules;
+
+DROP TABLE IF EXISTS dial_plan_rules;
+
+DROP TYPE IF EXISTS dial_action;
+DROP TYPE IF EXISTS dial_match_type;
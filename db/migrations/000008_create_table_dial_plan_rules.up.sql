@@ -0,0 +1,61 @@
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'dial_match_type') THEN
+    CREATE TYPE dial_match_type AS ENUM (
+      'prefix',   -- Request-URI user начинается с pattern
+      'regex'     -- Request-URI user совпадает с регулярным выражением
+    );
+  END IF;
+
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'dial_action') THEN
+    CREATE TYPE dial_action AS ENUM (
+      'local_user', -- звонок зарегистрированному пользователю
+      'group',      -- звонок в группу
+      'trunk',      -- звонок через внешний транк
+      'reject'      -- отбой с кодом reject_code
+    );
+  END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS dial_plan_rules (
+  id            BIGSERIAL PRIMARY KEY,
+
+  name          TEXT NOT NULL,
+  priority      INTEGER NOT NULL DEFAULT 100,  -- меньше = раньше
+  enabled       BOOLEAN NOT NULL DEFAULT true,
+
+  -- Условие по номеру
+  match_type    dial_match_type NOT NULL DEFAULT 'prefix',
+  pattern       TEXT NOT NULL,
+
+  -- Действие
+  action        dial_action NOT NULL,
+  target        TEXT,               -- login / группа / транк (пусто = номер после перезаписи)
+  reject_code   INTEGER,
+
+  -- Перезапись номера: сначала strip, потом prepend
+  strip_digits  INTEGER NOT NULL DEFAULT 0,
+  prepend       TEXT NOT NULL DEFAULT '',
+
+  -- Условие по времени (локальное время сервера)
+  time_from     TIME,
+  time_to       TIME,
+  weekdays      SMALLINT[],         -- 0 = воскресенье ... 6 = суббота, NULL = любой день
+
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT dial_plan_rules_strip_chk CHECK (strip_digits >= 0),
+  CONSTRAINT dial_plan_rules_reject_chk CHECK (
+    action <> 'reject' OR (reject_code BETWEEN 400 AND 699)
+  )
+);
+
+CREATE INDEX IF NOT EXISTS dial_plan_rules_priority_idx
+  ON dial_plan_rules(priority, id)
+  WHERE enabled;
+
+DROP TRIGGER IF EXISTS trg_dial_plan_rules_touch ON dial_plan_rules;
+CREATE TRIGGER trg_dial_plan_rules_touch
+BEFORE UPDATE ON dial_plan_rules
+FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
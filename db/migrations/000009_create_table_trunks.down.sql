@@ -0,0 +1,5 @@
+ALTER TABLE call_journals DROP COLUMN IF EXISTS trunk_name;
+
+DROP TRIGGER IF EXISTS trg_trunks_touch ON trunks;
+
+DROP TABLE IF EXISTS trunks;
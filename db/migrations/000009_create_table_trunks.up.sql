@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS trunks (
+  id                BIGSERIAL PRIMARY KEY,
+
+  name              TEXT NOT NULL,
+  enabled           BOOLEAN NOT NULL DEFAULT true,
+
+  -- Адрес провайдера
+  host              TEXT NOT NULL,
+  port              INTEGER NOT NULL DEFAULT 5060,
+  transport         TEXT NOT NULL DEFAULT 'udp',
+
+  -- Учётные данные (digest)
+  username          TEXT,
+  password          TEXT,
+  from_user         TEXT,              -- user в From исходящих INVITE (пусто = caller)
+
+  -- Исходящая регистрация
+  register          BOOLEAN NOT NULL DEFAULT false,
+  register_expires  INTEGER NOT NULL DEFAULT 300,
+
+  max_channels      INTEGER NOT NULL DEFAULT 0,  -- 0 = без ограничения
+
+  created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT trunks_name_uniq UNIQUE (name),
+  CONSTRAINT trunks_transport_chk CHECK (transport IN ('udp','tcp')),
+  CONSTRAINT trunks_port_chk CHECK (port BETWEEN 1 AND 65535),
+  CONSTRAINT trunks_max_channels_chk CHECK (max_channels >= 0)
+);
+
+DROP TRIGGER IF EXISTS trg_trunks_touch ON trunks;
+CREATE TRIGGER trg_trunks_touch
+BEFORE UPDATE ON trunks
+FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+
+ALTER TABLE call_journals ADD COLUMN IF NOT EXISTS trunk_name TEXT;
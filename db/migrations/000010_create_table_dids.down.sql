@@ -0,0 +1,7 @@
+ALTER TABLE call_journals DROP COLUMN IF EXISTS did;
+
+DROP TRIGGER IF EXISTS trg_dids_touch ON dids;
+
+DROP TABLE IF EXISTS dids;
+
+DROP TYPE IF EXISTS did_destination;
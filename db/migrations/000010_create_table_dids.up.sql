@@ -0,0 +1,34 @@
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'did_destination') THEN
+    CREATE TYPE did_destination AS ENUM (
+      'user',   -- локальный пользователь (login)
+      'group',  -- группа
+      'ivr'     -- голосовое меню
+    );
+  END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS dids (
+  id                BIGSERIAL PRIMARY KEY,
+
+  number            TEXT NOT NULL,     -- внешний номер как в Request-URI (+74950000000)
+  trunk_id          BIGINT REFERENCES trunks(id) ON DELETE SET NULL,  -- NULL = с любого транка
+  enabled           BOOLEAN NOT NULL DEFAULT true,
+
+  destination_type  did_destination NOT NULL DEFAULT 'user',
+  destination       TEXT NOT NULL,
+  description       TEXT,
+
+  created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT dids_number_uniq UNIQUE (number)
+);
+
+DROP TRIGGER IF EXISTS trg_dids_touch ON dids;
+CREATE TRIGGER trg_dids_touch
+BEFORE UPDATE ON dids
+FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+
+ALTER TABLE call_journals ADD COLUMN IF NOT EXISTS did TEXT;
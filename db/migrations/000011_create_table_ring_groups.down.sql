@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS trg_ring_groups_touch ON ring_groups;
+
+DROP TABLE IF EXISTS ring_group_members;
+DROP TABLE IF EXISTS ring_groups;
+
+DROP TYPE IF EXISTS overflow_type;
+DROP TYPE IF EXISTS ring_strategy;
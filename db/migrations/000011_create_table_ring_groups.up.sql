@@ -0,0 +1,57 @@
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'ring_strategy') THEN
+    CREATE TYPE ring_strategy AS ENUM (
+      'ringall',     -- звонят все сразу
+      'linear',      -- по порядку position
+      'roundrobin',  -- по кругу, начиная со следующего после прошлого звонка
+      'leastrecent'  -- первым тот, кому дольше всех не звонили
+    );
+  END IF;
+
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'overflow_type') THEN
+    CREATE TYPE overflow_type AS ENUM ('none','user','group','trunk');
+  END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS ring_groups (
+  id               BIGSERIAL PRIMARY KEY,
+
+  name             TEXT NOT NULL,
+  extension        TEXT NOT NULL,            -- номер группы ("600")
+  enabled          BOOLEAN NOT NULL DEFAULT true,
+
+  strategy         ring_strategy NOT NULL DEFAULT 'ringall',
+  member_timeout   INTEGER NOT NULL DEFAULT 20,  -- секунд на участника (для ringall — на всех)
+
+  overflow_type    overflow_type NOT NULL DEFAULT 'none',
+  overflow_target  TEXT,
+
+  rr_position      INTEGER NOT NULL DEFAULT 0,   -- для roundrobin
+
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT ring_groups_extension_uniq UNIQUE (extension),
+  CONSTRAINT ring_groups_timeout_chk CHECK (member_timeout BETWEEN 5 AND 300)
+);
+
+CREATE TABLE IF NOT EXISTS ring_group_members (
+  id            BIGSERIAL PRIMARY KEY,
+
+  group_id      BIGINT NOT NULL REFERENCES ring_groups(id) ON DELETE CASCADE,
+  user_id       BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  position      INTEGER NOT NULL DEFAULT 0,
+
+  last_call_at  TIMESTAMPTZ,                   -- для leastrecent
+
+  CONSTRAINT ring_group_members_uniq UNIQUE (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS ring_group_members_group_idx
+  ON ring_group_members(group_id, position);
+
+DROP TRIGGER IF EXISTS trg_ring_groups_touch ON ring_groups;
+CREATE TRIGGER trg_ring_groups_touch
+BEFORE UPDATE ON ring_groups
+FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
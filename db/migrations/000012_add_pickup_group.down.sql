@@ -0,0 +1,5 @@
+ALTER TABLE call_journals DROP COLUMN IF EXISTS picked_up_by;
+
+DROP INDEX IF EXISTS user_configs_pickup_group_idx;
+
+ALTER TABLE user_configs DROP COLUMN IF EXISTS pickup_group;
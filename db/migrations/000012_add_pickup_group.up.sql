@@ -0,0 +1,7 @@
+ALTER TABLE user_configs ADD COLUMN IF NOT EXISTS pickup_group TEXT;
+
+CREATE INDEX IF NOT EXISTS user_configs_pickup_group_idx
+  ON user_configs(pickup_group)
+  WHERE pickup_group IS NOT NULL;
+
+ALTER TABLE call_journals ADD COLUMN IF NOT EXISTS picked_up_by TEXT;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS call_journals_parent_id_idx;
+
+ALTER TABLE call_journals
+  DROP COLUMN IF EXISTS transfer_type,
+  DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,8 @@
+-- Перевод звонка (REFER): новая попытка ссылается на исходный звонок
+ALTER TABLE call_journals
+  ADD COLUMN IF NOT EXISTS parent_id     BIGINT REFERENCES call_journals(id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS transfer_type TEXT CHECK (transfer_type IN ('blind','attended'));
+
+CREATE INDEX IF NOT EXISTS call_journals_parent_id_idx
+  ON call_journals(parent_id)
+  WHERE parent_id IS NOT NULL;
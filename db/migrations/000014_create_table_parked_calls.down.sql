@@ -0,0 +1,5 @@
+ALTER TABLE call_journals
+  DROP COLUMN IF EXISTS parked_ms,
+  DROP COLUMN IF EXISTS park_slot;
+
+DROP TABLE IF EXISTS parked_calls;
@@ -0,0 +1,14 @@
+-- Занятые слоты парковки (пишет SIP-сервер, читает API)
+CREATE TABLE IF NOT EXISTS parked_calls (
+  slot         TEXT PRIMARY KEY,              -- "701"
+  journal_id   BIGINT REFERENCES call_journals(id) ON DELETE SET NULL,
+  call_id      TEXT NOT NULL,
+  caller_user  TEXT NOT NULL,                 -- кто ждёт на парковке
+  parked_by    TEXT,                          -- кто припарковал (REFER)
+  parked_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+  timeout_at   TIMESTAMPTZ NOT NULL           -- когда вернётся к parked_by
+);
+
+ALTER TABLE call_journals
+  ADD COLUMN IF NOT EXISTS park_slot TEXT,
+  ADD COLUMN IF NOT EXISTS parked_ms INTEGER;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS voicemail_messages;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS voicemail_messages (
+  id            BIGSERIAL PRIMARY KEY,
+
+  user_id       BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,  -- чей ящик
+  journal_id    BIGINT REFERENCES call_journals(id) ON DELETE SET NULL,  -- звонок, оставивший сообщение
+
+  caller_user   TEXT NOT NULL,
+  file_path     TEXT NOT NULL,        -- WAV PCM16 8 кГц
+  duration_ms   INTEGER NOT NULL DEFAULT 0,
+  heard         BOOLEAN NOT NULL DEFAULT false,
+
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS voicemail_messages_user_idx
+  ON voicemail_messages(user_id, created_at DESC);
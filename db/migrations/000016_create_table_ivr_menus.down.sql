@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS trg_ivr_menus_touch ON ivr_menus;
+
+DROP TABLE IF EXISTS ivr_menus;
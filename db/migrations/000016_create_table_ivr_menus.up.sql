@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS ivr_menus (
+  id              BIGSERIAL PRIMARY KEY,
+
+  name            TEXT NOT NULL,
+  extension       TEXT NOT NULL,             -- номер меню ("500"), на него же ссылаются DID с destination_type = 'ivr'
+  enabled         BOOLEAN NOT NULL DEFAULT true,
+
+  prompt          TEXT,                      -- WAV приглашения; NULL — сгенерированный сигнал
+  digit_timeout   INTEGER NOT NULL DEFAULT 5,  -- секунд ждём цифру после приглашения
+  max_retries     INTEGER NOT NULL DEFAULT 3,  -- сколько раз повторяем меню при тишине/ошибке
+
+  -- [{"digit":"1","action":"extension","target":"1001"}, ...]
+  -- digit: 0-9 * #, "t" — куда идти, когда попытки кончились
+  -- action: extension | group | voicemail | menu | hangup
+  options         JSONB NOT NULL DEFAULT '[]',
+
+  created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT ivr_menus_extension_uniq UNIQUE (extension),
+  CONSTRAINT ivr_menus_timeout_chk CHECK (digit_timeout BETWEEN 1 AND 60),
+  CONSTRAINT ivr_menus_retries_chk CHECK (max_retries BETWEEN 1 AND 10)
+);
+
+DROP TRIGGER IF EXISTS trg_ivr_menus_touch ON ivr_menus;
+CREATE TRIGGER trg_ivr_menus_touch
+BEFORE UPDATE ON ivr_menus
+FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
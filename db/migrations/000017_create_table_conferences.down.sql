@@ -0,0 +1,7 @@
+ALTER TABLE call_journals DROP COLUMN IF EXISTS conference;
+
+DROP TABLE IF EXISTS conference_participants;
+
+DROP TRIGGER IF EXISTS trg_conference_rooms_touch ON conference_rooms;
+
+DROP TABLE IF EXISTS conference_rooms;
@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS conference_rooms (
+  id                BIGSERIAL PRIMARY KEY,
+
+  name              TEXT NOT NULL,
+  number            TEXT NOT NULL,             -- номер комнаты ("8001")
+  pin               TEXT,                      -- NULL — без PIN
+  max_participants  INTEGER NOT NULL DEFAULT 10,
+  enabled           BOOLEAN NOT NULL DEFAULT true,
+
+  created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT conference_rooms_number_uniq UNIQUE (number),
+  CONSTRAINT conference_rooms_max_chk CHECK (max_participants BETWEEN 2 AND 100)
+);
+
+DROP TRIGGER IF EXISTS trg_conference_rooms_touch ON conference_rooms;
+CREATE TRIGGER trg_conference_rooms_touch
+BEFORE UPDATE ON conference_rooms
+FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+
+-- Участники идущих конференций (пишет SIP-сервер, muted/kicked меняет API)
+CREATE TABLE IF NOT EXISTS conference_participants (
+  id           BIGSERIAL PRIMARY KEY,
+  room_id      BIGINT NOT NULL REFERENCES conference_rooms(id) ON DELETE CASCADE,
+  journal_id   BIGINT REFERENCES call_journals(id) ON DELETE SET NULL,
+  call_id      TEXT NOT NULL,
+  caller_user  TEXT NOT NULL,
+  muted        BOOLEAN NOT NULL DEFAULT false,
+  kicked       BOOLEAN NOT NULL DEFAULT false,   -- API попросил отключить, SIP-сервер кладёт трубку
+  joined_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS conference_participants_room_idx
+  ON conference_participants(room_id);
+
+ALTER TABLE call_journals ADD COLUMN IF NOT EXISTS conference TEXT;
@@ -0,0 +1,3 @@
+ALTER TABLE call_sessions
+  DROP COLUMN IF EXISTS hold_ms,
+  DROP COLUMN IF EXISTS hold_count;
@@ -0,0 +1,4 @@
+-- Удержание (hold): сколько раз и сколько всего; talk_ms в call_journals его не включает
+ALTER TABLE call_sessions
+  ADD COLUMN IF NOT EXISTS hold_count INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS hold_ms    INTEGER NOT NULL DEFAULT 0;
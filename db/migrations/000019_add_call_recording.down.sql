@@ -0,0 +1,6 @@
+ALTER TABLE call_journals
+  DROP COLUMN IF EXISTS recording_ms,
+  DROP COLUMN IF EXISTS recording_path;
+
+ALTER TABLE user_configs
+  DROP COLUMN IF EXISTS record_calls;
@@ -0,0 +1,7 @@
+-- Запись разговоров: флаг у пользователя, файл и длительность в журнале
+ALTER TABLE user_configs
+  ADD COLUMN IF NOT EXISTS record_calls BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE call_journals
+  ADD COLUMN IF NOT EXISTS recording_path TEXT,
+  ADD COLUMN IF NOT EXISTS recording_ms   INTEGER;
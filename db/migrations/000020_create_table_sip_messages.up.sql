@@ -0,0 +1,25 @@
+-- SIP MESSAGE (RFC 3428): история и сообщения, ждущие получателя офлайн
+CREATE TABLE IF NOT EXISTS sip_messages (
+  id            BIGSERIAL PRIMARY KEY,
+
+  from_user     TEXT NOT NULL,
+  to_user       TEXT NOT NULL,
+  content_type  TEXT NOT NULL DEFAULT 'text/plain',
+  body          TEXT NOT NULL,
+
+  -- pending — ждёт REGISTER получателя, delivered — 2xx, failed — отказ
+  status        TEXT NOT NULL DEFAULT 'pending'
+                CHECK (status IN ('pending', 'delivered', 'failed')),
+  final_code    INTEGER,
+  attempts      INTEGER NOT NULL DEFAULT 0,
+
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+  delivered_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS sip_messages_pending_idx
+  ON sip_messages(to_user, created_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS sip_messages_from_idx
+  ON sip_messages(from_user, created_at DESC);
+CREATE INDEX IF NOT EXISTS sip_messages_to_idx
+  ON sip_messages(to_user, created_at DESC);
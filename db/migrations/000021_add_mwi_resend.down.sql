@@ -0,0 +1,2 @@
+ALTER TABLE user_configs
+  DROP COLUMN IF EXISTS mwi_resend;
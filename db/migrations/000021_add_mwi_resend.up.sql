@@ -0,0 +1,3 @@
+-- MWI: запрос из API переотправить NOTIFY message-summary (SIP-сервер сбрасывает флаг)
+ALTER TABLE user_configs
+  ADD COLUMN IF NOT EXISTS mwi_resend BOOLEAN NOT NULL DEFAULT false;
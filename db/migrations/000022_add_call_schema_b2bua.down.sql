@@ -0,0 +1,10 @@
+-- значение из enum не удалить: пересоздаём тип без b2bua
+UPDATE user_configs SET call_schema = 'proxy' WHERE call_schema = 'b2bua';
+
+ALTER TABLE user_configs ALTER COLUMN call_schema DROP DEFAULT;
+ALTER TYPE call_schema RENAME TO call_schema_old;
+CREATE TYPE call_schema AS ENUM ('redirect', 'proxy');
+ALTER TABLE user_configs
+  ALTER COLUMN call_schema TYPE call_schema USING call_schema::text::call_schema;
+ALTER TABLE user_configs ALTER COLUMN call_schema SET DEFAULT 'proxy';
+DROP TYPE call_schema_old;
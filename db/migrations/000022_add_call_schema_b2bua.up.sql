@@ -0,0 +1,2 @@
+-- b2bua: сервер держит оба диалога звонка сам
+ALTER TYPE call_schema ADD VALUE IF NOT EXISTS 'b2bua';
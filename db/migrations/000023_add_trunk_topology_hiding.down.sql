@@ -0,0 +1,4 @@
+ALTER TABLE trunks
+  DROP COLUMN IF EXISTS topology_hiding,
+  DROP COLUMN IF EXISTS header_allow,
+  DROP COLUMN IF EXISTS header_deny;
@@ -0,0 +1,5 @@
+-- Topology hiding и фильтр заголовков для транка
+ALTER TABLE trunks
+  ADD COLUMN IF NOT EXISTS topology_hiding BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS header_allow    TEXT[]  NOT NULL DEFAULT '{}',  -- заголовки caller'а, которые пропускаем в транк
+  ADD COLUMN IF NOT EXISTS header_deny     TEXT[]  NOT NULL DEFAULT '{}';  -- заголовки, которые в транк не уходят
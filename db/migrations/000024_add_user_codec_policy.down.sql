@@ -0,0 +1,7 @@
+ALTER TABLE call_journals
+  DROP COLUMN IF EXISTS codec;
+
+ALTER TABLE user_configs
+  DROP COLUMN IF EXISTS force_pcma,
+  DROP COLUMN IF EXISTS strip_video,
+  DROP COLUMN IF EXISTS codecs;
@@ -0,0 +1,8 @@
+-- Политика кодеков пользователя и согласованный кодек звонка
+ALTER TABLE user_configs
+  ADD COLUMN IF NOT EXISTS codecs      TEXT[]  NOT NULL DEFAULT '{}',     -- разрешённые кодеки в порядке предпочтения, пусто — любые
+  ADD COLUMN IF NOT EXISTS strip_video BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS force_pcma  BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE call_journals
+  ADD COLUMN IF NOT EXISTS codec TEXT;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS call_journals_open_idx;
+
+ALTER TABLE user_configs
+  DROP COLUMN IF EXISTS max_calls,
+  DROP COLUMN IF EXISTS max_inbound_calls,
+  DROP COLUMN IF EXISTS max_outbound_calls;
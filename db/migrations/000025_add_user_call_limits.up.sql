@@ -0,0 +1,9 @@
+-- Лимиты одновременных звонков пользователя (0 — без ограничения)
+ALTER TABLE user_configs
+  ADD COLUMN IF NOT EXISTS max_outbound_calls INTEGER NOT NULL DEFAULT 0 CHECK (max_outbound_calls >= 0),
+  ADD COLUMN IF NOT EXISTS max_inbound_calls  INTEGER NOT NULL DEFAULT 0 CHECK (max_inbound_calls >= 0),
+  ADD COLUMN IF NOT EXISTS max_calls          INTEGER NOT NULL DEFAULT 0 CHECK (max_calls >= 0);
+
+-- незавершённые звонки пользователя (API лимитов)
+CREATE INDEX IF NOT EXISTS call_journals_open_idx
+  ON call_journals(caller_user, callee_user) WHERE end_at IS NULL;
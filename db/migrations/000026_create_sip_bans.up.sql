@@ -0,0 +1,11 @@
+-- Заблокированные адреса SIP (вручную или после серии 401/404)
+CREATE TABLE IF NOT EXISTS sip_bans (
+  id         BIGSERIAL PRIMARY KEY,
+  ip         TEXT NOT NULL,
+  reason     TEXT,
+  banned_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  expires_at TIMESTAMPTZ -- NULL — бессрочно
+);
+
+CREATE INDEX IF NOT EXISTS sip_bans_expires_idx
+  ON sip_bans(expires_at);
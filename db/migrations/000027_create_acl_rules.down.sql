@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS trg_acl_rules_touch ON acl_rules;
+
+DROP TABLE IF EXISTS acl_rules;
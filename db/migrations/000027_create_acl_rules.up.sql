@@ -0,0 +1,19 @@
+-- Сетевые ACL: кому можно REGISTER, с каких адресов транков принимать звонки,
+-- кому открыты /api и /metrics
+CREATE TABLE IF NOT EXISTS acl_rules (
+  id          BIGSERIAL PRIMARY KEY,
+  scope       TEXT NOT NULL CHECK (scope IN ('register', 'trunk', 'api', 'metrics')),
+  network     CIDR NOT NULL,
+  action      TEXT NOT NULL DEFAULT 'allow' CHECK (action IN ('allow', 'deny')),
+  description TEXT,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS acl_rules_scope_idx
+  ON acl_rules(scope);
+
+DROP TRIGGER IF EXISTS trg_acl_rules_touch ON acl_rules;
+CREATE TRIGGER trg_acl_rules_touch
+BEFORE UPDATE ON acl_rules
+FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS api_tokens;
+
+ALTER TABLE users ALTER COLUMN password_hash TYPE VARCHAR(50) USING left(password_hash, 50);
@@ -0,0 +1,19 @@
+-- bcrypt-хеш не помещается в VARCHAR(50)
+ALTER TABLE users ALTER COLUMN password_hash TYPE TEXT;
+
+-- Токены HTTP API: сессии после входа и ключи для автоматизации
+CREATE TABLE IF NOT EXISTS api_tokens (
+  id           BIGSERIAL PRIMARY KEY,
+  user_id      BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  kind         TEXT NOT NULL CHECK (kind IN ('session', 'api_key')),
+  name         TEXT,
+  token_hash   TEXT NOT NULL, -- sha256 токена, сам токен не храним
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  expires_at   TIMESTAMPTZ, -- NULL — бессрочно
+  last_used_at TIMESTAMPTZ,
+
+  CONSTRAINT api_tokens_hash_uniq UNIQUE (token_hash)
+);
+
+CREATE INDEX IF NOT EXISTS api_tokens_user_id_idx
+  ON api_tokens(user_id);
@@ -0,0 +1,3 @@
+ALTER TABLE users
+  DROP COLUMN IF EXISTS sip_ha1,
+  DROP COLUMN IF EXISTS enabled;
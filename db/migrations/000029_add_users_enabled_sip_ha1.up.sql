@@ -0,0 +1,4 @@
+-- выключенный пользователь не регистрируется и не звонит, история остаётся
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS enabled BOOLEAN NOT NULL DEFAULT TRUE,
+  ADD COLUMN IF NOT EXISTS sip_ha1 TEXT; -- MD5(login:realm:password) для SIP digest, NULL — без проверки
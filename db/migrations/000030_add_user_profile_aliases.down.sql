@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS user_aliases;
+
+ALTER TABLE users
+  DROP COLUMN IF EXISTS email,
+  DROP COLUMN IF EXISTS caller_id_name,
+  DROP COLUMN IF EXISTS caller_id_number,
+  DROP COLUMN IF EXISTS display_name;
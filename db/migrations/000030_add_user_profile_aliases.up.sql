@@ -0,0 +1,17 @@
+-- профиль: как пользователя видят в звонках и куда писать
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS display_name     TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS caller_id_number TEXT NOT NULL DEFAULT '', -- номер для звонков через транк
+  ADD COLUMN IF NOT EXISTS caller_id_name   TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS email            TEXT NOT NULL DEFAULT '';
+
+-- дополнительные номера пользователя: короткий внутренний, DID и т.п.
+CREATE TABLE IF NOT EXISTS user_aliases (
+  id         BIGSERIAL PRIMARY KEY,
+  user_id    BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  alias      TEXT NOT NULL UNIQUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS user_aliases_user_id_idx
+  ON user_aliases(user_id);
@@ -0,0 +1,151 @@
+// Package acl — сетевые ACL по CIDR: кто может регистрироваться, с каких
+// адресов принимать звонки транков, кому открыты /api и /metrics.
+// Правила лежат в БД и перечитываются на лету.
+package acl
+
+import (
+	"context"
+	"log"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	aclrule "SipServer/internal/repository/acl_rule"
+)
+
+const reloadInterval = 5 * time.Second
+
+// RuleSource — откуда берутся правила (обычно ACLRuleRepo).
+type RuleSource interface {
+	List(ctx context.Context) ([]*aclrule.Rule, error)
+}
+
+type rule struct {
+	prefix netip.Prefix
+	allow  bool
+}
+
+// scopeRules — правила одной области, от самой узкой сети к самой широкой.
+type scopeRules struct {
+	rules    []rule
+	hasAllow bool
+}
+
+type List struct {
+	src RuleSource
+
+	mu     sync.RWMutex
+	scopes map[aclrule.Scope]*scopeRules
+}
+
+func New(src RuleSource) *List {
+	return &List{
+		src:    src,
+		scopes: make(map[aclrule.Scope]*scopeRules),
+	}
+}
+
+// Allowed решает по самой узкой сети, в которую попал ip; при равных —
+// запрет. Не попал ни в одну: запрещено, если в области есть разрешающие
+// правила (белый список), иначе разрешено. Пустая область открыта всем.
+func (l *List) Allowed(scope aclrule.Scope, ip string) bool {
+	l.mu.RLock()
+	sr := l.scopes[scope]
+	l.mu.RUnlock()
+	if sr == nil {
+		return true
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return !sr.hasAllow
+	}
+	addr = addr.Unmap()
+
+	for _, r := range sr.rules {
+		if r.prefix.Contains(addr) {
+			return r.allow
+		}
+	}
+	return !sr.hasAllow
+}
+
+// Reload перечитывает правила; при ошибке остаются прежние.
+func (l *List) Reload(ctx context.Context) error {
+	rules, err := l.src.List(ctx)
+	if err != nil {
+		return err
+	}
+	scopes := compile(rules)
+
+	l.mu.Lock()
+	l.scopes = scopes
+	l.mu.Unlock()
+	return nil
+}
+
+// Run перечитывает правила, пока не отменён ctx.
+func (l *List) Run(ctx context.Context) {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := l.Reload(ctx); err != nil {
+			log.Printf("[ACL] reload: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// compile раскладывает правила по областям и сортирует: длинная маска
+// раньше короткой, при равной — deny раньше allow.
+func compile(rules []*aclrule.Rule) map[aclrule.Scope]*scopeRules {
+	scopes := make(map[aclrule.Scope]*scopeRules)
+	for _, r := range rules {
+		if r == nil {
+			continue
+		}
+		prefix, err := parsePrefix(r.Network)
+		if err != nil {
+			log.Printf("[ACL] rule %d: bad network %q: %v", r.Id, r.Network, err)
+			continue
+		}
+		sr := scopes[r.Scope]
+		if sr == nil {
+			sr = &scopeRules{}
+			scopes[r.Scope] = sr
+		}
+		allow := r.Action == aclrule.ActionAllow
+		sr.rules = append(sr.rules, rule{prefix: prefix, allow: allow})
+		sr.hasAllow = sr.hasAllow || allow
+	}
+
+	for _, sr := range scopes {
+		sort.SliceStable(sr.rules, func(i, j int) bool {
+			a, b := sr.rules[i], sr.rules[j]
+			if a.prefix.Bits() != b.prefix.Bits() {
+				return a.prefix.Bits() > b.prefix.Bits()
+			}
+			return !a.allow && b.allow
+		})
+	}
+	return scopes
+}
+
+// parsePrefix принимает и сеть, и одиночный адрес.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
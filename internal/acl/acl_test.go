@@ -0,0 +1,129 @@
+package acl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	aclrule "SipServer/internal/repository/acl_rule"
+)
+
+type staticRules struct {
+	rules []*aclrule.Rule
+	err   error
+}
+
+func (s *staticRules) List(context.Context) ([]*aclrule.Rule, error) {
+	return s.rules, s.err
+}
+
+func allow(scope aclrule.Scope, network string) *aclrule.Rule {
+	return &aclrule.Rule{Scope: scope, Network: network, Action: aclrule.ActionAllow}
+}
+
+func deny(scope aclrule.Scope, network string) *aclrule.Rule {
+	return &aclrule.Rule{Scope: scope, Network: network, Action: aclrule.ActionDeny}
+}
+
+func TestAllowed(t *testing.T) {
+	src := &staticRules{rules: []*aclrule.Rule{
+		// api: белый список офиса, но одна машина в нём закрыта
+		allow(aclrule.ScopeAPI, "10.0.0.0/8"),
+		deny(aclrule.ScopeAPI, "10.1.2.3"),
+		allow(aclrule.ScopeAPI, "10.1.0.0/16"),
+		// register: только запреты — остальные открыты
+		deny(aclrule.ScopeRegister, "203.0.113.0/24"),
+		// trunk: узкий allow внутри запрещённой сети; битая сеть пропускается
+		deny(aclrule.ScopeTrunk, "198.51.100.0/24"),
+		allow(aclrule.ScopeTrunk, "198.51.100.7/32"),
+		allow(aclrule.ScopeTrunk, "not-a-network"),
+		// metrics: одна сеть и allow, и deny — запрет важнее
+		allow(aclrule.ScopeMetrics, "192.168.0.0/24"),
+		deny(aclrule.ScopeMetrics, "192.168.0.0/24"),
+		allow(aclrule.ScopeMetrics, "2001:db8::/32"),
+		nil,
+	}}
+
+	l := New(src)
+	if err := l.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		scope aclrule.Scope
+		ip    string
+		want  bool
+	}{
+		{"inside allow network", aclrule.ScopeAPI, "10.200.0.1", true},
+		{"host deny beats wider allows", aclrule.ScopeAPI, "10.1.2.3", false},
+		{"neighbour of denied host", aclrule.ScopeAPI, "10.1.2.4", true},
+		{"outside whitelist", aclrule.ScopeAPI, "8.8.8.8", false},
+		{"ipv4-mapped ipv6", aclrule.ScopeAPI, "::ffff:10.0.0.5", true},
+		{"unparsable ip with whitelist", aclrule.ScopeAPI, "garbage", false},
+		{"denied network", aclrule.ScopeRegister, "203.0.113.9", false},
+		{"deny-only scope is open", aclrule.ScopeRegister, "198.51.100.1", true},
+		{"unparsable ip without whitelist", aclrule.ScopeRegister, "garbage", true},
+		{"deny before allow at same mask", aclrule.ScopeMetrics, "192.168.0.10", false},
+		{"ipv6 allow", aclrule.ScopeMetrics, "2001:db8::1", true},
+		{"ipv6 outside whitelist", aclrule.ScopeMetrics, "2001:db9::1", false},
+		{"narrower allow inside deny", aclrule.ScopeTrunk, "198.51.100.7", true},
+		{"denied around narrower allow", aclrule.ScopeTrunk, "198.51.100.8", false},
+		{"allow rule makes scope a whitelist", aclrule.ScopeTrunk, "1.2.3.4", false},
+	}
+	for _, tt := range tests {
+		if got := l.Allowed(tt.scope, tt.ip); got != tt.want {
+			t.Errorf("%s: Allowed(%s, %s) = %v, want %v", tt.name, tt.scope, tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestReloadKeepsRulesOnError(t *testing.T) {
+	src := &staticRules{rules: []*aclrule.Rule{allow(aclrule.ScopeAPI, "10.0.0.0/8")}}
+	l := New(src)
+	if err := l.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	src.rules, src.err = nil, errors.New("db down")
+	if err := l.Reload(context.Background()); err == nil {
+		t.Fatal("expected reload error")
+	}
+	if l.Allowed(aclrule.ScopeAPI, "8.8.8.8") {
+		t.Error("rules dropped after failed reload")
+	}
+
+	// успешная перезагрузка с пустым списком открывает область
+	src.err = nil
+	if err := l.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !l.Allowed(aclrule.ScopeAPI, "8.8.8.8") {
+		t.Error("empty rule set must allow everything")
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8", false},
+		{"10.1.2.3/8", "10.0.0.0/8", false}, // хостовые биты отбрасываются
+		{"10.1.2.3", "10.1.2.3/32", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"10.0.0.0/33", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		p, err := parsePrefix(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePrefix(%q) error = %v", tt.in, err)
+			continue
+		}
+		if err == nil && p.String() != tt.want {
+			t.Errorf("parsePrefix(%q) = %s, want %s", tt.in, p, tt.want)
+		}
+	}
+}
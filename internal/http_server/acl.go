@@ -0,0 +1,99 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	"SipServer/internal/acl"
+	"SipServer/internal/metrics"
+	aclrule "SipServer/internal/repository/acl_rule"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *HttpServer) ListACLRules(w http.ResponseWriter, _ *http.Request) {
+	rules, err := s.aclUsecase.List()
+	buildResponse(rules, w, err)
+}
+
+func (s *HttpServer) GetACLRule(w http.ResponseWriter, r *http.Request) {
+	rule, err := s.aclUsecase.Get(mux.Vars(r)["id"])
+	buildResponse(rule, w, err)
+}
+
+func (s *HttpServer) CreateACLRule(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	rule := aclrule.NewRule()
+	if err := json.NewDecoder(r.Body).Decode(rule); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	if err := s.validator.Struct(rule); err != nil {
+		buildResponse(rule, w, err)
+		return
+	}
+
+	rule, err := s.aclUsecase.Create(rule)
+	buildResponse(rule, w, err)
+}
+
+func (s *HttpServer) UpdateACLRule(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	id := mux.Vars(r)["id"]
+
+	rule := aclrule.NewRule()
+	if err := json.NewDecoder(r.Body).Decode(rule); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	if err := s.validator.Struct(rule); err != nil {
+		buildResponse(rule, w, err)
+		return
+	}
+
+	rule, err := s.aclUsecase.Update(id, rule)
+	buildResponse(rule, w, err)
+}
+
+func (s *HttpServer) DeleteACLRule(w http.ResponseWriter, r *http.Request) {
+	err := s.aclUsecase.Delete(mux.Vars(r)["id"])
+	buildResponse(struct{}{}, w, err)
+}
+
+// ACLMiddleware закрывает /api и /metrics для сетей, не прошедших ACL.
+// Адрес берётся из соединения: X-Forwarded-For не доверяем.
+func ACLMiddleware(acls *acl.List, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var scope aclrule.Scope
+		switch {
+		case r.URL.Path == "/metrics":
+			scope = aclrule.ScopeMetrics
+		case r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/"):
+			scope = aclrule.ScopeAPI
+		}
+
+		if scope != "" && !acls.Allowed(scope, remoteIP(r)) {
+			metrics.ACLDenied.WithLabelValues(string(scope)).Inc()
+			writeJSON(w, http.StatusForbidden, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"acl": "access denied",
+				},
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
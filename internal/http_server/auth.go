@@ -0,0 +1,179 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	apitoken "SipServer/internal/repository/api_token"
+	"SipServer/internal/repository/user"
+
+	"github.com/gorilla/mux"
+)
+
+type ctxKey int
+
+const principalKey ctxKey = 0
+
+type loginRequest struct {
+	Login    string `json:"login" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// Login — вход по логину и паролю из users, в ответе токен сессии.
+func (s *HttpServer) Login(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	req := &loginRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+	if err := s.validator.Struct(req); err != nil {
+		buildResponse(req, w, err)
+		return
+	}
+
+	session, err := s.authUsecase.Login(req.Login, req.Password)
+	buildResponse(session, w, err)
+}
+
+func (s *HttpServer) Logout(w http.ResponseWriter, r *http.Request) {
+	err := s.authUsecase.Logout(currentUser(r))
+	buildResponse(struct{}{}, w, err)
+}
+
+// Me — кто вошёл.
+func (s *HttpServer) Me(w http.ResponseWriter, r *http.Request) {
+	buildResponse(currentUser(r), w, nil)
+}
+
+func (s *HttpServer) ListAPIKeys(w http.ResponseWriter, _ *http.Request) {
+	keys, err := s.authUsecase.ListAPIKeys()
+	buildResponse(keys, w, err)
+}
+
+// CreateAPIKey — ключ для автоматизации; токен в ответе показывается один раз.
+func (s *HttpServer) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	item := apitoken.NewToken()
+	if err := json.NewDecoder(r.Body).Decode(item); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+	if err := s.validator.Struct(item); err != nil {
+		buildResponse(item, w, err)
+		return
+	}
+
+	key, err := s.authUsecase.CreateAPIKey(item)
+	buildResponse(key, w, err)
+}
+
+func (s *HttpServer) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	err := s.authUsecase.DeleteAPIKey(mux.Vars(r)["id"])
+	buildResponse(struct{}{}, w, err)
+}
+
+// Authenticated пускает любого вошедшего пользователя.
+func (s *HttpServer) Authenticated(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := s.authenticate(w, r)
+		if !ok {
+			return
+		}
+		h(w, withUser(r, p))
+	}
+}
+
+// Admin пускает только администраторов.
+func (s *HttpServer) Admin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := s.authenticate(w, r)
+		if !ok {
+			return
+		}
+		if p.Role != user.RoleAdmin {
+			forbidden(w)
+			return
+		}
+		h(w, withUser(r, p))
+	}
+}
+
+// Self пускает администратора и пользователя к его собственной записи /users/{id}.
+func (s *HttpServer) Self(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := s.authenticate(w, r)
+		if !ok {
+			return
+		}
+		if p.Role != user.RoleAdmin && mux.Vars(r)["id"] != strconv.Itoa(p.UserId) {
+			forbidden(w)
+			return
+		}
+		h(w, withUser(r, p))
+	}
+}
+
+// authenticate проверяет токен из "Authorization: Bearer" или X-API-Key;
+// false — ответ 401 уже отправлен.
+func (s *HttpServer) authenticate(w http.ResponseWriter, r *http.Request) (*apitoken.Principal, bool) {
+	token := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); token == "" && auth != "" {
+		if scheme, value, ok := strings.Cut(auth, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			token = strings.TrimSpace(value)
+		}
+	}
+	if token == "" {
+		unauthorized(w)
+		return nil, false
+	}
+
+	p, err := s.authUsecase.Authenticate(token)
+	if err != nil {
+		if errors.Is(err, apitoken.ErrTokenNotFound) {
+			unauthorized(w)
+		} else {
+			buildResponse(struct{}{}, w, err)
+		}
+		return nil, false
+	}
+	return p, true
+}
+
+func withUser(r *http.Request, p *apitoken.Principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalKey, p))
+}
+
+// currentUser — кто сделал запрос (после Authenticated / Admin / Self).
+func currentUser(r *http.Request) *apitoken.Principal {
+	p, _ := r.Context().Value(principalKey).(*apitoken.Principal)
+	return p
+}
+
+func isAdmin(r *http.Request) bool {
+	p := currentUser(r)
+	return p != nil && p.Role == user.RoleAdmin
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	writeJSON(w, http.StatusUnauthorized, map[string]interface{}{
+		"errors": map[string]interface{}{
+			"auth": "unauthorized",
+		},
+	})
+}
+
+func forbidden(w http.ResponseWriter) {
+	writeJSON(w, http.StatusForbidden, map[string]interface{}{
+		"errors": map[string]interface{}{
+			"auth": "forbidden",
+		},
+	})
+}
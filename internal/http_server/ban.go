@@ -0,0 +1,40 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"SipServer/internal/repository/ban"
+
+	"github.com/gorilla/mux"
+)
+
+// ListBans — действующие баны SIP по IP.
+func (s *HttpServer) ListBans(w http.ResponseWriter, _ *http.Request) {
+	items, err := s.banUsecase.List()
+	buildResponse(items, w, err)
+}
+
+func (s *HttpServer) CreateBan(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	item := ban.NewBan()
+	if err := json.NewDecoder(r.Body).Decode(item); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	if err := s.validator.Struct(item); err != nil {
+		buildResponse(item, w, err)
+		return
+	}
+
+	item, err := s.banUsecase.Create(item)
+	buildResponse(item, w, err)
+}
+
+// DeleteBan — снять бан досрочно.
+func (s *HttpServer) DeleteBan(w http.ResponseWriter, r *http.Request) {
+	err := s.banUsecase.Delete(mux.Vars(r)["id"])
+	buildResponse(struct{}{}, w, err)
+}
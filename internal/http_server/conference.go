@@ -0,0 +1,97 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"SipServer/internal/repository/conference"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *HttpServer) ListConferences(w http.ResponseWriter, _ *http.Request) {
+	rooms, err := s.conferenceUsecase.List()
+	buildResponse(rooms, w, err)
+}
+
+func (s *HttpServer) GetConference(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	room, err := s.conferenceUsecase.Get(id)
+	buildResponse(room, w, err)
+}
+
+func (s *HttpServer) CreateConference(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	room := conference.NewRoom()
+	if err := json.NewDecoder(r.Body).Decode(room); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	if err := s.validator.Struct(room); err != nil {
+		buildResponse(room, w, err)
+		return
+	}
+
+	room, err := s.conferenceUsecase.Create(room)
+	buildResponse(room, w, err)
+}
+
+func (s *HttpServer) UpdateConference(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	id := mux.Vars(r)["id"]
+
+	room := conference.NewRoom()
+	if err := json.NewDecoder(r.Body).Decode(room); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	if err := s.validator.Struct(room); err != nil {
+		buildResponse(room, w, err)
+		return
+	}
+
+	room, err := s.conferenceUsecase.Update(id, room)
+	buildResponse(room, w, err)
+}
+
+func (s *HttpServer) DeleteConference(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	err := s.conferenceUsecase.Delete(id)
+	buildResponse(struct{}{}, w, err)
+}
+
+// ListConferenceParticipants — кто сейчас в комнате.
+func (s *HttpServer) ListConferenceParticipants(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	items, err := s.conferenceUsecase.Participants(id)
+	buildResponse(items, w, err)
+}
+
+// UpdateConferenceParticipant — mute/unmute.
+func (s *HttpServer) UpdateConferenceParticipant(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+
+	req := &conference.UpdateParticipantRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	item, err := s.conferenceUsecase.UpdateParticipant(vars["id"], vars["participantId"], req)
+	buildResponse(item, w, err)
+}
+
+// KickConferenceParticipant отключает участника: трубку кладёт SIP-сервер.
+func (s *HttpServer) KickConferenceParticipant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	err := s.conferenceUsecase.Kick(vars["id"], vars["participantId"])
+	buildResponse(struct{}{}, w, err)
+}
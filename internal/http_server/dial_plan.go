@@ -0,0 +1,66 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	dialplan "SipServer/internal/repository/dial_plan"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *HttpServer) ListDialPlan(w http.ResponseWriter, _ *http.Request) {
+	rules, err := s.dialPlanUsecase.List()
+	buildResponse(rules, w, err)
+}
+
+func (s *HttpServer) GetDialPlanRule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	rule, err := s.dialPlanUsecase.Get(id)
+	buildResponse(rule, w, err)
+}
+
+func (s *HttpServer) CreateDialPlanRule(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	rule := dialplan.NewRule()
+	if err := json.NewDecoder(r.Body).Decode(rule); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	if err := s.validator.Struct(rule); err != nil {
+		buildResponse(rule, w, err)
+		return
+	}
+
+	rule, err := s.dialPlanUsecase.Create(rule)
+	buildResponse(rule, w, err)
+}
+
+func (s *HttpServer) UpdateDialPlanRule(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	id := mux.Vars(r)["id"]
+
+	rule := dialplan.NewRule()
+	if err := json.NewDecoder(r.Body).Decode(rule); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	if err := s.validator.Struct(rule); err != nil {
+		buildResponse(rule, w, err)
+		return
+	}
+
+	rule, err := s.dialPlanUsecase.Update(id, rule)
+	buildResponse(rule, w, err)
+}
+
+func (s *HttpServer) DeleteDialPlanRule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	err := s.dialPlanUsecase.Delete(id)
+	buildResponse(struct{}{}, w, err)
+}
@@ -0,0 +1,66 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"SipServer/internal/repository/did"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *HttpServer) ListDIDs(w http.ResponseWriter, _ *http.Request) {
+	dids, err := s.didUsecase.List()
+	buildResponse(dids, w, err)
+}
+
+func (s *HttpServer) GetDID(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	item, err := s.didUsecase.Get(id)
+	buildResponse(item, w, err)
+}
+
+func (s *HttpServer) CreateDID(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	item := did.NewDID()
+	if err := json.NewDecoder(r.Body).Decode(item); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	if err := s.validator.Struct(item); err != nil {
+		buildResponse(item, w, err)
+		return
+	}
+
+	item, err := s.didUsecase.Create(item)
+	buildResponse(item, w, err)
+}
+
+func (s *HttpServer) UpdateDID(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	id := mux.Vars(r)["id"]
+
+	item := did.NewDID()
+	if err := json.NewDecoder(r.Body).Decode(item); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	if err := s.validator.Struct(item); err != nil {
+		buildResponse(item, w, err)
+		return
+	}
+
+	item, err := s.didUsecase.Update(id, item)
+	buildResponse(item, w, err)
+}
+
+func (s *HttpServer) DeleteDID(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	err := s.didUsecase.Delete(id)
+	buildResponse(struct{}{}, w, err)
+}
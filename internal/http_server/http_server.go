@@ -6,12 +6,25 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
 	"SipServer/internal/metrics"
+	aclrule "SipServer/internal/repository/acl_rule"
+	apitoken "SipServer/internal/repository/api_token"
+	"SipServer/internal/repository/ban"
+	calljournal "SipServer/internal/repository/call_journal"
+	"SipServer/internal/repository/conference"
+	dialplan "SipServer/internal/repository/dial_plan"
+	"SipServer/internal/repository/did"
+	"SipServer/internal/repository/ivr"
+	ringgroup "SipServer/internal/repository/ring_group"
+	"SipServer/internal/repository/trunk"
 	"SipServer/internal/repository/user"
+	"SipServer/internal/repository/voicemail"
+	"SipServer/internal/routing"
 	"SipServer/internal/usecase"
 
 	"github.com/go-playground/validator/v10"
@@ -22,6 +35,18 @@ type HttpServer struct {
 	userUsecase        *usecase.UserUsecase
 	sessionUsecase     *usecase.SessionUsecase
 	callJournalUsecase *usecase.CallJournalUsecase
+	dialPlanUsecase    *usecase.DialPlanUsecase
+	trunkUsecase       *usecase.TrunkUsecase
+	didUsecase         *usecase.DIDUsecase
+	ringGroupUsecase   *usecase.RingGroupUsecase
+	parkingUsecase     *usecase.ParkingUsecase
+	voicemailUsecase   *usecase.VoicemailUsecase
+	ivrUsecase         *usecase.IVRUsecase
+	conferenceUsecase  *usecase.ConferenceUsecase
+	messageUsecase     *usecase.MessageUsecase
+	banUsecase         *usecase.BanUsecase
+	aclUsecase         *usecase.ACLUsecase
+	authUsecase        *usecase.AuthUsecase
 	validator          *validator.Validate
 }
 
@@ -35,11 +60,24 @@ func (w *statusWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
-func NewHttpServer(db *sql.DB) *HttpServer {
+// bindings — регистрации SIP: при удалении и выключении пользователя их снимаем сразу.
+func NewHttpServer(db *sql.DB, bindings usecase.Bindings) *HttpServer {
 	return &HttpServer{
-		userUsecase:        usecase.NewUserUseCase(db),
+		userUsecase:        usecase.NewUserUseCase(db, bindings),
 		sessionUsecase:     usecase.NewSessionUsecase(db),
 		callJournalUsecase: usecase.NewCallJournalUsecase(db),
+		dialPlanUsecase:    usecase.NewDialPlanUsecase(db),
+		trunkUsecase:       usecase.NewTrunkUsecase(db),
+		didUsecase:         usecase.NewDIDUsecase(db),
+		ringGroupUsecase:   usecase.NewRingGroupUsecase(db),
+		parkingUsecase:     usecase.NewParkingUsecase(db),
+		voicemailUsecase:   usecase.NewVoicemailUsecase(db),
+		ivrUsecase:         usecase.NewIVRUsecase(db),
+		conferenceUsecase:  usecase.NewConferenceUsecase(db),
+		messageUsecase:     usecase.NewMessageUsecase(db),
+		banUsecase:         usecase.NewBanUsecase(db),
+		aclUsecase:         usecase.NewACLUsecase(db),
+		authUsecase:        usecase.NewAuthUsecase(db),
 		validator:          validator.New(),
 	}
 }
@@ -100,20 +138,108 @@ func (s *HttpServer) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// пользователь сам меняет только имя и почту, остальное — администратор
+	if !isAdmin(r) && !selfEditable(req) {
+		forbidden(w)
+		return
+	}
+
 	err = s.userUsecase.UpdateUser(id, req)
 	buildResponse(req, w, err)
 }
 
+// selfEditable — в запросе нет ничего, кроме display_name и email.
+func selfEditable(req *user.UpdateUserRequest) bool {
+	allowed := user.UpdateUserRequest{
+		Id:          req.Id,
+		DisplayName: req.DisplayName,
+		Email:       req.Email,
+		Config:      &user.UpdateUserConfigRequest{},
+	}
+	if req.Config == nil {
+		allowed.Config = nil
+	}
+	return reflect.DeepEqual(*req, allowed)
+}
+
+// DeleteUser удаляет пользователя и снимает его регистрацию; журнал звонков остаётся.
+func (s *HttpServer) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	err := s.userUsecase.DeleteUser(mux.Vars(r)["id"])
+	buildResponse(struct{}{}, w, err)
+}
+
+type passwordRequest struct {
+	Password        string `json:"password" validate:"required,min=8,max=72"`
+	CurrentPassword string `json:"current_password,omitempty"`
+}
+
+// SetUserPassword — пароль для входа в API и SIP digest. Свой пароль
+// пользователь меняет, указав текущий.
+func (s *HttpServer) SetUserPassword(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	req := &passwordRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+	if err := s.validator.Struct(req); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	err := s.userUsecase.SetPassword(mux.Vars(r)["id"], req.Password, req.CurrentPassword, !isAdmin(r))
+	buildResponse(struct{}{}, w, err)
+}
+
+// ResetUserPassword задаёт случайный пароль; он показывается только в этом ответе.
+func (s *HttpServer) ResetUserPassword(w http.ResponseWriter, r *http.Request) {
+	password, err := s.userUsecase.ResetPassword(mux.Vars(r)["id"])
+	buildResponse(map[string]string{"password": password}, w, err)
+}
+
+// GetUserCalls — лимиты одновременных звонков пользователя и текущая загрузка.
+func (s *HttpServer) GetUserCalls(w http.ResponseWriter, r *http.Request) {
+	calls, err := s.userUsecase.Calls(mux.Vars(r)["id"])
+	buildResponse(calls, w, err)
+}
+
 func (s *HttpServer) ListSession(w http.ResponseWriter, _ *http.Request) {
 	session, err := s.sessionUsecase.List()
 	buildResponse(session, w, err)
 }
 
-func (s *HttpServer) ListCallJournal(w http.ResponseWriter, _ *http.Request) {
+// ListCallJournal — весь журнал для администратора, свои звонки для пользователя.
+func (s *HttpServer) ListCallJournal(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r) {
+		call_journals, err := s.callJournalUsecase.ListByUser(currentUser(r).Login)
+		buildResponse(call_journals, w, err)
+		return
+	}
 	call_journals, err := s.callJournalUsecase.List()
 	buildResponse(call_journals, w, err)
 }
 
+// DownloadCallRecording отдаёт WAV записи разговора.
+func (s *HttpServer) DownloadCallRecording(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	path, err := s.callJournalUsecase.Recording(id)
+	if err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "audio/wav")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="call-%s.wav"`, id))
+	http.ServeFile(w, r, path)
+}
+
+func (s *HttpServer) DeleteCallRecording(w http.ResponseWriter, r *http.Request) {
+	err := s.callJournalUsecase.DeleteRecording(mux.Vars(r)["id"])
+	buildResponse(struct{}{}, w, err)
+}
+
 func buildResponse(entity interface{}, w http.ResponseWriter, err error) {
 	if err != nil {
 		if errors.Is(err, user.ErrUserNotFound) {
@@ -124,25 +250,178 @@ func buildResponse(entity interface{}, w http.ResponseWriter, err error) {
 			})
 			return
 		}
+		if errors.Is(err, dialplan.ErrRuleNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"dial_plan": "rule not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, trunk.ErrTrunkNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"trunk": "trunk not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, did.ErrDIDNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"did": "did not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, ringgroup.ErrGroupNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"ring_group": "ring group not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, conference.ErrRoomNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"conference": "conference room not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, conference.ErrParticipantNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"participant": "participant not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, ivr.ErrMenuNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"ivr": "ivr menu not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, calljournal.ErrNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"call_journal": "call not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, calljournal.ErrRecordingNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"recording": "recording not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, voicemail.ErrMessageNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"voicemail": "message not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, ban.ErrBanNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"ban": "ban not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, aclrule.ErrRuleNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"acl": "acl rule not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, apitoken.ErrTokenNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"api_key": "api key not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, usecase.ErrInvalidCredentials) {
+			writeJSON(w, http.StatusUnauthorized, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"auth": "invalid login or password",
+				},
+			})
+			return
+		}
+		if errors.Is(err, usecase.ErrWrongPassword) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"current_password": err.Error(),
+				},
+			})
+			return
+		}
+		if errors.Is(err, usecase.ErrLoginNeedsPassword) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"login": err.Error(),
+				},
+			})
+			return
+		}
+		if errors.Is(err, user.ErrAliasTaken) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"aliases": err.Error(),
+				},
+			})
+			return
+		}
+		if errors.Is(err, user.ErrLoginTaken) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"login": err.Error(),
+				},
+			})
+			return
+		}
+		var importErr *usecase.ImportError
+		if errors.As(err, &importErr) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"errors": importErr.Rows,
+			})
+			return
+		}
+		if errors.Is(err, ringgroup.ErrMemberNotFound) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"members": err.Error(),
+				},
+			})
+			return
+		}
+		if errors.Is(err, routing.ErrInvalidPattern) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"errors": map[string]interface{}{
+					"pattern": err.Error(),
+				},
+			})
+			return
+		}
 		errors, ok := err.(validator.ValidationErrors)
 		if ok {
 			errorsMap := map[string]interface{}{}
 			for _, e := range errors {
-				var errText string
-				switch e.Tag() {
-				case "required":
-					errText = "field is required"
-				case "oneof":
-					errText = fmt.Sprintf("fiels is oneof %s", e.Param())
-				case "min":
-					errText = fmt.Sprintf("field min len %s", e.Param())
-				case "max":
-					errText = fmt.Sprintf("field min len %s", e.Param())
-				default:
-					errText = "invalid value"
-				}
-
-				errorsMap[e.Field()] = errText
+				errorsMap[e.Field()] = validationText(e)
 			}
 			writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
 				"errors": errorsMap,
@@ -163,6 +442,21 @@ func buildResponse(entity interface{}, w http.ResponseWriter, err error) {
 	})
 }
 
+func validationText(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required", "required_if", "required_unless", "required_with":
+		return "field is required"
+	case "oneof":
+		return fmt.Sprintf("fiels is oneof %s", e.Param())
+	case "min":
+		return fmt.Sprintf("field min len %s", e.Param())
+	case "max":
+		return fmt.Sprintf("field min len %s", e.Param())
+	default:
+		return "invalid value"
+	}
+}
+
 func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
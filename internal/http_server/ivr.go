@@ -0,0 +1,66 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"SipServer/internal/repository/ivr"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *HttpServer) ListIVRMenus(w http.ResponseWriter, _ *http.Request) {
+	menus, err := s.ivrUsecase.List()
+	buildResponse(menus, w, err)
+}
+
+func (s *HttpServer) GetIVRMenu(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	menu, err := s.ivrUsecase.Get(id)
+	buildResponse(menu, w, err)
+}
+
+func (s *HttpServer) CreateIVRMenu(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	menu := ivr.NewMenu()
+	if err := json.NewDecoder(r.Body).Decode(menu); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	if err := s.validator.Struct(menu); err != nil {
+		buildResponse(menu, w, err)
+		return
+	}
+
+	menu, err := s.ivrUsecase.Create(menu)
+	buildResponse(menu, w, err)
+}
+
+func (s *HttpServer) UpdateIVRMenu(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	id := mux.Vars(r)["id"]
+
+	menu := ivr.NewMenu()
+	if err := json.NewDecoder(r.Body).Decode(menu); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	if err := s.validator.Struct(menu); err != nil {
+		buildResponse(menu, w, err)
+		return
+	}
+
+	menu, err := s.ivrUsecase.Update(id, menu)
+	buildResponse(menu, w, err)
+}
+
+func (s *HttpServer) DeleteIVRMenu(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	err := s.ivrUsecase.Delete(id)
+	buildResponse(struct{}{}, w, err)
+}
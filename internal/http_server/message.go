@@ -0,0 +1,18 @@
+package httpserver
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ListUserMessages — история SIP MESSAGE пользователя.
+// ?with=<login> — переписка с одним собеседником, ?limit= — сколько последних.
+func (s *HttpServer) ListUserMessages(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+
+	items, err := s.messageUsecase.History(mux.Vars(r)["id"], q.Get("with"), limit)
+	buildResponse(items, w, err)
+}
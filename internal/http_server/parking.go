@@ -0,0 +1,9 @@
+package httpserver
+
+import "net/http"
+
+// ListParkedCalls — занятые слоты парковки.
+func (s *HttpServer) ListParkedCalls(w http.ResponseWriter, _ *http.Request) {
+	items, err := s.parkingUsecase.List()
+	buildResponse(items, w, err)
+}
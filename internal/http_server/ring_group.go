@@ -0,0 +1,66 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	ringgroup "SipServer/internal/repository/ring_group"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *HttpServer) ListRingGroups(w http.ResponseWriter, _ *http.Request) {
+	groups, err := s.ringGroupUsecase.List()
+	buildResponse(groups, w, err)
+}
+
+func (s *HttpServer) GetRingGroup(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	group, err := s.ringGroupUsecase.Get(id)
+	buildResponse(group, w, err)
+}
+
+func (s *HttpServer) CreateRingGroup(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	group := ringgroup.NewGroup()
+	if err := json.NewDecoder(r.Body).Decode(group); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	if err := s.validator.Struct(group); err != nil {
+		buildResponse(group, w, err)
+		return
+	}
+
+	group, err := s.ringGroupUsecase.Create(group)
+	buildResponse(group, w, err)
+}
+
+func (s *HttpServer) UpdateRingGroup(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	id := mux.Vars(r)["id"]
+
+	group := ringgroup.NewGroup()
+	if err := json.NewDecoder(r.Body).Decode(group); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	if err := s.validator.Struct(group); err != nil {
+		buildResponse(group, w, err)
+		return
+	}
+
+	group, err := s.ringGroupUsecase.Update(id, group)
+	buildResponse(group, w, err)
+}
+
+func (s *HttpServer) DeleteRingGroup(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	err := s.ringGroupUsecase.Delete(id)
+	buildResponse(struct{}{}, w, err)
+}
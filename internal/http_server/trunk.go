@@ -0,0 +1,66 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"SipServer/internal/repository/trunk"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *HttpServer) ListTrunks(w http.ResponseWriter, _ *http.Request) {
+	trunks, err := s.trunkUsecase.List()
+	buildResponse(trunks, w, err)
+}
+
+func (s *HttpServer) GetTrunk(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	tr, err := s.trunkUsecase.Get(id)
+	buildResponse(tr, w, err)
+}
+
+func (s *HttpServer) CreateTrunk(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	tr := trunk.NewTrunk()
+	if err := json.NewDecoder(r.Body).Decode(tr); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	if err := s.validator.Struct(tr); err != nil {
+		buildResponse(tr, w, err)
+		return
+	}
+
+	tr, err := s.trunkUsecase.Create(tr)
+	buildResponse(tr, w, err)
+}
+
+func (s *HttpServer) UpdateTrunk(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	id := mux.Vars(r)["id"]
+
+	tr := trunk.NewTrunk()
+	if err := json.NewDecoder(r.Body).Decode(tr); err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	if err := s.validator.Struct(tr); err != nil {
+		buildResponse(tr, w, err)
+		return
+	}
+
+	tr, err := s.trunkUsecase.Update(id, tr)
+	buildResponse(tr, w, err)
+}
+
+func (s *HttpServer) DeleteTrunk(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	err := s.trunkUsecase.Delete(id)
+	buildResponse(struct{}{}, w, err)
+}
@@ -0,0 +1,182 @@
+package httpserver
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"SipServer/internal/repository/user"
+	"SipServer/internal/usecase"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// колонки CSV; обязательна только login, остальные — со значениями по умолчанию
+var importColumns = []string{
+	"login", "password", "role", "call_schema", "pickup_group", "enabled", "record_calls",
+	"display_name", "caller_id_number", "caller_id_name", "email", "aliases",
+}
+
+// ImportUsers — массовое создание: JSON-массив пользователей или CSV
+// (Content-Type: text/csv, первая строка — заголовок). Все строки создаются
+// одной транзакцией; при ошибках в ответе 422 с причиной по каждой строке.
+func (s *HttpServer) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var (
+		rows []usecase.ImportRow
+		err  error
+	)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		rows, err = parseImportCSV(r.Body)
+	} else {
+		rows, err = parseImportJSON(r.Body)
+	}
+	if err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	rowErrors := make(map[string]string)
+	for _, row := range rows {
+		if err := s.validator.Struct(row.User); err != nil {
+			var verrs validator.ValidationErrors
+			if !errors.As(err, &verrs) {
+				buildResponse(struct{}{}, w, err)
+				return
+			}
+			rowErrors[fmt.Sprintf("row %d", row.Line)] = verrs[0].Field() + ": " + validationText(verrs[0])
+		}
+	}
+	if len(rowErrors) > 0 {
+		buildResponse(struct{}{}, w, &usecase.ImportError{Rows: rowErrors})
+		return
+	}
+
+	users, err := s.userUsecase.Import(rows)
+	buildResponse(users, w, err)
+}
+
+func parseImportJSON(body io.Reader) ([]usecase.ImportRow, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return nil, err
+	}
+	rows := make([]usecase.ImportRow, 0, len(raw))
+	for i, item := range raw {
+		u := newImportUser()
+		if err := json.Unmarshal(item, u); err != nil {
+			return nil, &usecase.ImportError{Rows: map[string]string{fmt.Sprintf("row %d", i+1): err.Error()}}
+		}
+		rows = append(rows, usecase.ImportRow{Line: i + 1, User: u})
+	}
+	return rows, nil
+}
+
+func parseImportCSV(body io.Reader) ([]usecase.ImportRow, error) {
+	cr := csv.NewReader(body)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, &usecase.ImportError{Rows: map[string]string{"row 1": "csv header is required"}}
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := index["login"]; !ok {
+		return nil, &usecase.ImportError{Rows: map[string]string{"row 1": "login column is required"}}
+	}
+	for name := range index {
+		if !knownImportColumn(name) {
+			return nil, &usecase.ImportError{Rows: map[string]string{"row 1": "unknown column " + name}}
+		}
+	}
+
+	var rows []usecase.ImportRow
+	rowErrors := make(map[string]string)
+	// строки считаем как в файле: заголовок — первая
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rowErrors[fmt.Sprintf("row %d", line)] = err.Error()
+			break
+		}
+
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		u := newImportUser()
+		u.Login = field("login")
+		u.Password = field("password")
+		if v := field("role"); v != "" {
+			u.Role = v
+		}
+		if v := field("call_schema"); v != "" {
+			u.Config.CallSchema = v
+		}
+		u.Config.PickupGroup = field("pickup_group")
+		u.DisplayName = field("display_name")
+		u.CallerIDNumber = field("caller_id_number")
+		u.CallerIDName = field("caller_id_name")
+		u.Email = field("email")
+		// несколько алиасов в одной ячейке — через ";"
+		for _, alias := range strings.Split(field("aliases"), ";") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				u.Aliases = append(u.Aliases, alias)
+			}
+		}
+
+		var bad string
+		if v := field("enabled"); v != "" {
+			if u.Enabled, err = strconv.ParseBool(v); err != nil {
+				bad = "enabled: invalid value"
+			}
+		}
+		if v := field("record_calls"); v != "" {
+			if u.Config.RecordCalls, err = strconv.ParseBool(v); err != nil {
+				bad = "record_calls: invalid value"
+			}
+		}
+		if bad != "" {
+			rowErrors[fmt.Sprintf("row %d", line)] = bad
+			continue
+		}
+		rows = append(rows, usecase.ImportRow{Line: line, User: u})
+	}
+	if len(rowErrors) > 0 {
+		return nil, &usecase.ImportError{Rows: rowErrors}
+	}
+	return rows, nil
+}
+
+func newImportUser() *user.User {
+	u := user.NewUser()
+	u.Role = user.RoleUser
+	u.Config.CallSchema = "proxy"
+	return u
+}
+
+func knownImportColumn(name string) bool {
+	for _, c := range importColumns {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
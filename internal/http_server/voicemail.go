@@ -0,0 +1,41 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// ListVoicemail — сообщения голосовой почты пользователя, новые сверху.
+func (s *HttpServer) ListVoicemail(w http.ResponseWriter, r *http.Request) {
+	items, err := s.voicemailUsecase.List(mux.Vars(r)["id"])
+	buildResponse(items, w, err)
+}
+
+// DownloadVoicemail отдаёт WAV сообщения.
+func (s *HttpServer) DownloadVoicemail(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	msg, err := s.voicemailUsecase.Audio(vars["id"], vars["msgId"])
+	if err != nil {
+		buildResponse(struct{}{}, w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "audio/wav")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="voicemail-%d.wav"`, msg.Id))
+	http.ServeFile(w, r, msg.FilePath)
+}
+
+// GetMWI — счётчики сообщений пользователя (как в NOTIFY message-summary).
+func (s *HttpServer) GetMWI(w http.ResponseWriter, r *http.Request) {
+	c, err := s.voicemailUsecase.Counts(mux.Vars(r)["id"])
+	buildResponse(c, w, err)
+}
+
+// TriggerMWI — переотправить MWI на телефон (уйдёт в течение нескольких секунд).
+func (s *HttpServer) TriggerMWI(w http.ResponseWriter, r *http.Request) {
+	c, err := s.voicemailUsecase.TriggerMWI(mux.Vars(r)["id"])
+	buildResponse(c, w, err)
+}
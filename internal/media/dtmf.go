@@ -0,0 +1,44 @@
+package media
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrBadEvent = errors.New("rtp: bad telephone-event payload")
+
+// коды событий RFC 4733 для DTMF
+const dtmfEvents = "0123456789*#ABCD"
+
+// TelephoneEvent — payload RTP telephone-event (RFC 4733).
+type TelephoneEvent struct {
+	Event    uint8
+	End      bool
+	Volume   uint8
+	Duration uint16
+}
+
+func ParseTelephoneEvent(payload []byte) (TelephoneEvent, error) {
+	if len(payload) < 4 {
+		return TelephoneEvent{}, ErrBadEvent
+	}
+	return TelephoneEvent{
+		Event:    payload[0],
+		End:      payload[1]&0x80 != 0,
+		Volume:   payload[1] & 0x3f,
+		Duration: uint16(payload[2])<<8 | uint16(payload[3]),
+	}, nil
+}
+
+// Digit — символ DTMF для события, false — событие не DTMF.
+func (e TelephoneEvent) Digit() (string, bool) {
+	if int(e.Event) >= len(dtmfEvents) {
+		return "", false
+	}
+	return dtmfEvents[e.Event : e.Event+1], true
+}
+
+// IsDTMFDigit — s ровно один символ DTMF.
+func IsDTMFDigit(s string) bool {
+	return len(s) == 1 && strings.Contains(dtmfEvents, s)
+}
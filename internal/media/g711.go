@@ -0,0 +1,137 @@
+// Package media — минимальный медиа-стек сервера: G.711, RTP, WAV.
+// Нужен там, где сервер сам отвечает на звонок (парковка, voicemail, IVR).
+package media
+
+type Codec struct {
+	PayloadType uint8
+	Name        string
+}
+
+var (
+	PCMU = Codec{PayloadType: 0, Name: "PCMU"}
+	PCMA = Codec{PayloadType: 8, Name: "PCMA"}
+)
+
+const (
+	SampleRate = 8000
+	// 20 мс на пакет
+	FrameSamples = 160
+)
+
+// CodecByPayloadType — только статические G.711.
+func CodecByPayloadType(pt uint8) (Codec, bool) {
+	switch pt {
+	case PCMU.PayloadType:
+		return PCMU, true
+	case PCMA.PayloadType:
+		return PCMA, true
+	}
+	return Codec{}, false
+}
+
+func (c Codec) Encode(samples []int16) []byte {
+	out := make([]byte, len(samples))
+	for i, s := range samples {
+		if c.PayloadType == PCMA.PayloadType {
+			out[i] = EncodeALaw(s)
+		} else {
+			out[i] = EncodeULaw(s)
+		}
+	}
+	return out
+}
+
+func (c Codec) Decode(payload []byte) []int16 {
+	out := make([]int16, len(payload))
+	for i, b := range payload {
+		if c.PayloadType == PCMA.PayloadType {
+			out[i] = DecodeALaw(b)
+		} else {
+			out[i] = DecodeULaw(b)
+		}
+	}
+	return out
+}
+
+const (
+	ulawBias = 0x84
+	ulawClip = 32635
+)
+
+func EncodeULaw(sample int16) byte {
+	s := int(sample)
+	sign := 0
+	if s < 0 {
+		s = -s
+		sign = 0x80
+	}
+	if s > ulawClip {
+		s = ulawClip
+	}
+	s += ulawBias
+
+	exponent := 7
+	for mask := 0x4000; s&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := (s >> (exponent + 3)) & 0x0F
+
+	return ^byte(sign | exponent<<4 | mantissa)
+}
+
+func DecodeULaw(b byte) int16 {
+	u := ^b
+	sign := u & 0x80
+	exponent := int(u>>4) & 0x07
+	mantissa := int(u & 0x0F)
+
+	s := ((mantissa << 3) + ulawBias) << exponent
+	s -= ulawBias
+	if sign != 0 {
+		return int16(-s)
+	}
+	return int16(s)
+}
+
+func EncodeALaw(sample int16) byte {
+	s := int(sample) >> 3
+	sign := 0x80
+	if s < 0 {
+		s = -s - 1
+		sign = 0
+	}
+	if s > 0xFFF {
+		s = 0xFFF
+	}
+
+	var out int
+	if s < 32 {
+		out = s >> 1
+	} else {
+		exponent := 1
+		for v := s >> 5; v > 1; v >>= 1 {
+			exponent++
+		}
+		out = exponent<<4 | (s>>exponent)&0x0F
+	}
+
+	return byte(out|sign) ^ 0x55
+}
+
+func DecodeALaw(b byte) int16 {
+	a := b ^ 0x55
+	sign := a & 0x80
+	exponent := int(a>>4) & 0x07
+	mantissa := int(a & 0x0F)
+
+	var s int
+	if exponent == 0 {
+		s = mantissa<<4 + 8
+	} else {
+		s = (mantissa<<4 + 0x108) << (exponent - 1)
+	}
+	if sign == 0 {
+		return int16(-s)
+	}
+	return int16(s)
+}
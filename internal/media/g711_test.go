@@ -0,0 +1,122 @@
+package media
+
+import "testing"
+
+// эталонные значения — таблицы G.711 (ITU-T), как в g711.c от Sun
+func TestULaw(t *testing.T) {
+	encode := []struct {
+		sample int16
+		want   byte
+	}{
+		{0, 0xFF},
+		{-1, 0x7F},
+		{8, 0xFE},
+		{-8, 0x7E},
+		{32767, 0x80},
+		{-32768, 0x00},
+		{32124, 0x80},
+		{-15996, 0x10},
+	}
+	for _, tt := range encode {
+		if got := EncodeULaw(tt.sample); got != tt.want {
+			t.Errorf("EncodeULaw(%d) = %#02x, want %#02x", tt.sample, got, tt.want)
+		}
+	}
+
+	decode := []struct {
+		b    byte
+		want int16
+	}{
+		{0xFF, 0},
+		{0x7F, 0},
+		{0xFE, 8},
+		{0x7E, -8},
+		{0x80, 32124},
+		{0x00, -32124},
+		{0x01, -31100},
+		{0x10, -15996},
+	}
+	for _, tt := range decode {
+		if got := DecodeULaw(tt.b); got != tt.want {
+			t.Errorf("DecodeULaw(%#02x) = %d, want %d", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestALaw(t *testing.T) {
+	encode := []struct {
+		sample int16
+		want   byte
+	}{
+		{0, 0xD5},
+		{-1, 0x55},
+		{32767, 0xAA},
+		{-32768, 0x2A},
+		{5504, 0x80},
+		{-5504, 0x00},
+	}
+	for _, tt := range encode {
+		if got := EncodeALaw(tt.sample); got != tt.want {
+			t.Errorf("EncodeALaw(%d) = %#02x, want %#02x", tt.sample, got, tt.want)
+		}
+	}
+
+	decode := []struct {
+		b    byte
+		want int16
+	}{
+		{0xD5, 8},
+		{0x55, -8},
+		{0xAA, 32256},
+		{0x2A, -32256},
+		{0x80, 5504},
+		{0x00, -5504},
+	}
+	for _, tt := range decode {
+		if got := DecodeALaw(tt.b); got != tt.want {
+			t.Errorf("DecodeALaw(%#02x) = %d, want %d", tt.b, got, tt.want)
+		}
+	}
+}
+
+// каждый код G.711 после декодирования и повторного кодирования остаётся собой
+// (у µ-law 0x7F — «минус ноль», он кодируется обратно как 0xFF)
+func TestG711Idempotent(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		b := byte(i)
+		if got := EncodeALaw(DecodeALaw(b)); got != b {
+			t.Errorf("A-law %#02x -> %d -> %#02x", b, DecodeALaw(b), got)
+		}
+		want := b
+		if b == 0x7F {
+			want = 0xFF
+		}
+		if got := EncodeULaw(DecodeULaw(b)); got != want {
+			t.Errorf("µ-law %#02x -> %d -> %#02x", b, DecodeULaw(b), got)
+		}
+	}
+}
+
+func TestCodec(t *testing.T) {
+	samples := []int16{0, 1000, -1000, 32767, -32768}
+	for _, c := range []Codec{PCMU, PCMA} {
+		got, ok := CodecByPayloadType(c.PayloadType)
+		if !ok || got != c {
+			t.Errorf("CodecByPayloadType(%d) = %v, %v", c.PayloadType, got, ok)
+		}
+		payload := c.Encode(samples)
+		decoded := c.Decode(payload)
+		if len(payload) != len(samples) || len(decoded) != len(samples) {
+			t.Fatalf("%s: lengths %d/%d, want %d", c.Name, len(payload), len(decoded), len(samples))
+		}
+		for i, s := range samples {
+			// погрешность G.711 — до половины шага сегмента, на краю шкалы около 3%
+			if diff := int(decoded[i]) - int(s); diff > 1024 || diff < -1024 {
+				t.Errorf("%s: %d decoded as %d", c.Name, s, decoded[i])
+			}
+		}
+	}
+	if _, ok := CodecByPayloadType(18); ok {
+		t.Error("G.729 must not be supported")
+	}
+}
@@ -0,0 +1,146 @@
+package media
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// сколько звука участника копим: больше — только лишняя задержка
+const mixerMaxBuffer = 8 * FrameSamples
+
+// Mixer — конференция: каждый участник слышит сумму всех остальных.
+type Mixer struct {
+	mu      sync.Mutex
+	members []*MixMember
+}
+
+// MixMember — участник: в Push приходит его звук, микс уходит в out.
+type MixMember struct {
+	out   *Session
+	muted atomic.Bool
+
+	mu  sync.Mutex
+	buf []int16
+}
+
+func NewMixer() *Mixer {
+	return &Mixer{}
+}
+
+func (m *Mixer) Join(out *Session) *MixMember {
+	mm := &MixMember{out: out}
+	m.mu.Lock()
+	m.members = append(m.members, mm)
+	m.mu.Unlock()
+	return mm
+}
+
+func (m *Mixer) Leave(mm *MixMember) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, cur := range m.members {
+		if cur == mm {
+			m.members = append(m.members[:i], m.members[i+1:]...)
+			return
+		}
+	}
+}
+
+func (m *Mixer) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.members)
+}
+
+// Run микширует кадр каждые 20 мс до отмены ctx.
+func (m *Mixer) Run(ctx context.Context) {
+	ticker := time.NewTicker(FrameSamples * time.Second / SampleRate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.mix()
+		}
+	}
+}
+
+func (m *Mixer) mix() {
+	m.mu.Lock()
+	members := append([]*MixMember(nil), m.members...)
+	m.mu.Unlock()
+
+	sum := make([]int32, FrameSamples)
+	frames := make([][]int16, len(members))
+	for i, mm := range members {
+		frame := mm.frame()
+		if mm.Muted() {
+			continue
+		}
+		frames[i] = frame
+		for j, v := range frame {
+			sum[j] += int32(v)
+		}
+	}
+
+	out := make([]int16, FrameSamples)
+	for i, mm := range members {
+		// свой голос участнику не возвращаем
+		own := frames[i]
+		for j := range out {
+			v := sum[j]
+			if own != nil {
+				v -= int32(own[j])
+			}
+			out[j] = clip16(v)
+		}
+		_ = mm.out.WriteFrame(out)
+	}
+}
+
+// Push добавляет звук участника (декодированный G.711).
+func (mm *MixMember) Push(samples []int16) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	mm.buf = append(mm.buf, samples...)
+	if over := len(mm.buf) - mixerMaxBuffer; over > 0 {
+		mm.buf = mm.buf[over:]
+	}
+}
+
+// frame забирает один кадр; не хватает звука — добивает тишиной.
+func (mm *MixMember) frame() []int16 {
+	f := make([]int16, FrameSamples)
+
+	mm.mu.Lock()
+	n := copy(f, mm.buf)
+	mm.buf = mm.buf[n:]
+	mm.mu.Unlock()
+
+	return f
+}
+
+func (mm *MixMember) SetMuted(muted bool) {
+	mm.muted.Store(muted)
+}
+
+func (mm *MixMember) Muted() bool {
+	return mm.muted.Load()
+}
+
+func clip16(v int32) int16 {
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
@@ -0,0 +1,82 @@
+package media
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Recorder — стерео-запись разговора: левый канал — звонящий, правый — вызываемый.
+// Звук сторон приходит неравномерно, поэтому кадры пишутся по таймеру.
+type Recorder struct {
+	mu  sync.Mutex
+	wav *WAVWriter
+	buf [2][]int16
+}
+
+func NewRecorder(path string) (*Recorder, error) {
+	wav, err := CreateWAV(path, 2)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{wav: wav}, nil
+}
+
+// Push добавляет декодированный звук стороны (RelayA / RelayB).
+func (r *Recorder) Push(side int, samples []int16) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.buf[side] = append(r.buf[side], samples...)
+	if over := len(r.buf[side]) - mixerMaxBuffer; over > 0 {
+		r.buf[side] = r.buf[side][over:]
+	}
+}
+
+// Run пишет кадр каждые 20 мс до отмены ctx.
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(FrameSamples * time.Second / SampleRate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.writeFrame()
+		}
+	}
+}
+
+func (r *Recorder) writeFrame() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.wav == nil {
+		return
+	}
+
+	out := make([]int16, 2*FrameSamples)
+	for side := range r.buf {
+		n := min(len(r.buf[side]), FrameSamples)
+		for i := 0; i < n; i++ {
+			out[2*i+side] = r.buf[side][i]
+		}
+		r.buf[side] = r.buf[side][n:]
+	}
+	_ = r.wav.Write(out)
+}
+
+// Close закрывает файл и возвращает длительность записи.
+func (r *Recorder) Close() (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.wav == nil {
+		return 0, nil
+	}
+	d := r.wav.Duration()
+	err := r.wav.Close()
+	r.wav = nil
+	return d, err
+}
@@ -0,0 +1,86 @@
+package media
+
+import (
+	"net"
+	"sync"
+)
+
+// стороны Relay
+const (
+	RelayA = 0 // звонящий
+	RelayB = 1 // вызываемый
+)
+
+// Relay — RTP между двумя телефонами через сервер: каждому телефону свой порт,
+// пакеты с порта одной стороны уходят с порта другой.
+type Relay struct {
+	conns  [2]*net.UDPConn
+	remote [2]peer
+
+	closeOnce sync.Once
+}
+
+func NewRelay(host string) (*Relay, error) {
+	r := &Relay{}
+	for i := range r.conns {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(host)})
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.conns[i] = conn
+	}
+	return r, nil
+}
+
+// Port — порт, который сервер отдаёт в SDP телефону стороны side.
+func (r *Relay) Port(side int) int {
+	return r.conns[side].LocalAddr().(*net.UDPAddr).Port
+}
+
+// SetRemote — куда слать RTP телефону стороны side.
+func (r *Relay) SetRemote(side int, addr *net.UDPAddr) {
+	r.remote[side].set(addr)
+}
+
+// Run пересылает RTP в обе стороны до Close; tap видит каждый пакет.
+// Пересылается только RTP телефона стороны из SDP (см. peer).
+func (r *Relay) Run(tap func(side int, p *Packet)) {
+	for side := range r.conns {
+		go r.forward(side, tap)
+	}
+}
+
+func (r *Relay) forward(side int, tap func(side int, p *Packet)) {
+	other := 1 - side
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := r.conns[side].ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		var p Packet
+		if err := p.Unmarshal(buf[:n]); err != nil || !r.remote[side].accept(addr) {
+			continue
+		}
+
+		if dst := r.remote[other].get(); dst != nil {
+			_, _ = r.conns[other].WriteToUDP(buf[:n], dst)
+		}
+
+		if tap != nil {
+			p.Payload = append([]byte(nil), p.Payload...)
+			tap(side, &p)
+		}
+	}
+}
+
+func (r *Relay) Close() {
+	r.closeOnce.Do(func() {
+		for _, c := range r.conns {
+			if c != nil {
+				_ = c.Close()
+			}
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var ErrShortPacket = errors.New("rtp: short packet")
+
+const rtpHeaderLen = 12
+
+type Packet struct {
+	PayloadType uint8
+	Marker      bool
+	Seq         uint16
+	Timestamp   uint32
+	SSRC        uint32
+	Payload     []byte
+}
+
+func (p *Packet) Marshal() []byte {
+	b := make([]byte, rtpHeaderLen+len(p.Payload))
+	b[0] = 0x80 // V=2
+	b[1] = p.PayloadType & 0x7F
+	if p.Marker {
+		b[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(b[2:], p.Seq)
+	binary.BigEndian.PutUint32(b[4:], p.Timestamp)
+	binary.BigEndian.PutUint32(b[8:], p.SSRC)
+	copy(b[rtpHeaderLen:], p.Payload)
+	return b
+}
+
+// Unmarshal разбирает пакет; CSRC и extension пропускаются.
+func (p *Packet) Unmarshal(b []byte) error {
+	if len(b) < rtpHeaderLen || b[0]>>6 != 2 {
+		return ErrShortPacket
+	}
+
+	p.Marker = b[1]&0x80 != 0
+	p.PayloadType = b[1] & 0x7F
+	p.Seq = binary.BigEndian.Uint16(b[2:])
+	p.Timestamp = binary.BigEndian.Uint32(b[4:])
+	p.SSRC = binary.BigEndian.Uint32(b[8:])
+
+	off := rtpHeaderLen + int(b[0]&0x0F)*4
+	if b[0]&0x10 != 0 {
+		if len(b) < off+4 {
+			return ErrShortPacket
+		}
+		off += 4 + int(binary.BigEndian.Uint16(b[off+2:]))*4
+	}
+	end := len(b)
+	if b[0]&0x20 != 0 && end > off {
+		end -= int(b[end-1])
+	}
+	if off > end {
+		return ErrShortPacket
+	}
+
+	p.Payload = b[off:end]
+	return nil
+}
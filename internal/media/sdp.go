@@ -0,0 +1,140 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"SipServer/internal/sdp"
+)
+
+var ErrNoAudio = errors.New("sdp: no audio stream")
+
+// DTMF по RFC 4733
+const TelephoneEventPT = 101
+
+// направление медиа (RFC 3264)
+const (
+	DirSendRecv = sdp.SendRecv
+	DirSendOnly = sdp.SendOnly
+	DirRecvOnly = sdp.RecvOnly
+	DirInactive = sdp.Inactive
+)
+
+// RemoteAudio — куда телефон ждёт RTP и какие payload type предлагает.
+type RemoteAudio struct {
+	Addr         *net.UDPAddr
+	PayloadTypes []uint8
+	EventPT      uint8 // payload type telephone-event телефона, 0 — не предложен
+}
+
+// ParseRemoteAudio достаёт из SDP первый m=audio и адрес для него.
+func ParseRemoteAudio(body []byte) (*RemoteAudio, error) {
+	sess, err := sdp.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	m := sess.FirstMedia("audio")
+	if m == nil {
+		return nil, ErrNoAudio
+	}
+	conn := sess.ConnectionFor(m)
+	if conn == nil {
+		return nil, ErrNoAudio
+	}
+
+	remote := &RemoteAudio{Addr: &net.UDPAddr{IP: net.ParseIP(conn.Address), Port: m.Port}}
+	for _, f := range m.RTPFormats() {
+		remote.PayloadTypes = append(remote.PayloadTypes, f.PayloadType)
+		if strings.EqualFold(f.Name, "telephone-event") {
+			remote.EventPT = f.PayloadType
+		}
+	}
+	return remote, nil
+}
+
+// ChooseCodec — первый G.711 из предложенных.
+func (r *RemoteAudio) ChooseCodec() (Codec, bool) {
+	for _, pt := range r.PayloadTypes {
+		if c, ok := CodecByPayloadType(pt); ok {
+			return c, true
+		}
+	}
+	return Codec{}, false
+}
+
+// BuildSDP — SDP сервера: один кодек + telephone-event.
+func BuildSDP(host string, port int, codec Codec, sessionID int64) []byte {
+	return BuildSDPDirection(host, port, codec, sessionID, DirSendRecv)
+}
+
+// BuildSDPDirection — BuildSDP с заданным направлением (удержание и т.п.).
+func BuildSDPDirection(host string, port int, codec Codec, sessionID int64, dir string) []byte {
+	id := strconv.FormatInt(sessionID, 10)
+	conn := &sdp.Connection{NetType: "IN", AddrType: "IP4", Address: host}
+	sess := &sdp.Session{
+		Origin:     sdp.Origin{Username: "SipServer", SessionID: id, SessionVersion: id, NetType: "IN", AddrType: "IP4", Address: host},
+		Name:       "SipServer",
+		Connection: conn,
+		Fields:     []sdp.Field{{Type: 't', Value: "0 0"}},
+		Media: []*sdp.Media{{
+			Type:    "audio",
+			Port:    port,
+			Proto:   "RTP/AVP",
+			Formats: []string{strconv.Itoa(int(codec.PayloadType)), strconv.Itoa(TelephoneEventPT)},
+			Attributes: []sdp.Attribute{
+				{Key: "rtpmap", Value: fmt.Sprintf("%d %s/%d", codec.PayloadType, codec.Name, SampleRate)},
+				{Key: "rtpmap", Value: fmt.Sprintf("%d telephone-event/%d", TelephoneEventPT, SampleRate)},
+				{Key: "fmtp", Value: fmt.Sprintf("%d 0-16", TelephoneEventPT)},
+				{Key: "ptime", Value: "20"},
+				{Key: dir},
+			},
+		}},
+	}
+	return sess.Marshal()
+}
+
+// IsHold — SDP ставит звонок на удержание: a=sendonly / a=inactive
+// (на уровне сессии или audio) или c=0.0.0.0 (RFC 2543).
+func IsHold(body []byte) bool {
+	sess, err := sdp.Parse(body)
+	if err != nil {
+		return false
+	}
+	m := sess.FirstMedia("audio")
+	if m == nil {
+		return false
+	}
+	if conn := sess.ConnectionFor(m); conn != nil && conn.Address == "0.0.0.0" {
+		return true
+	}
+	dir := sess.Direction(m)
+	return dir == DirSendOnly || dir == DirInactive
+}
+
+// RewriteSDP подменяет адрес (c=) и порт первого m=audio на адрес сервера —
+// медиа пойдёт через него. Кодеки и атрибуты остаются как у телефона.
+func RewriteSDP(body []byte, host string, port int) []byte {
+	sess, err := sdp.Parse(body)
+	if err != nil {
+		return body
+	}
+	conn := &sdp.Connection{NetType: "IN", AddrType: "IP4", Address: host}
+	if sess.Connection != nil {
+		sess.Connection = conn
+	}
+	for _, m := range sess.Media {
+		if m.Connection != nil {
+			m.Connection = conn
+		}
+	}
+	if m := sess.FirstMedia("audio"); m != nil {
+		m.Port = port
+		if m.Connection == nil && sess.Connection == nil {
+			m.Connection = conn
+		}
+	}
+	return sess.Marshal()
+}
@@ -0,0 +1,197 @@
+package media
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// peer — адрес телефона из SDP. Порт мог смениться на NAT, поэтому первый RTP
+// с IP из c= фиксирует источник; всё остальное — чужие пакеты (перехват RTP).
+type peer struct {
+	mu      sync.Mutex
+	addr    *net.UDPAddr
+	latched bool
+}
+
+// set — новый адрес из SDP (в том числе re-INVITE): источник фиксируется заново.
+func (p *peer) set(addr *net.UDPAddr) {
+	p.mu.Lock()
+	p.addr, p.latched = addr, false
+	p.mu.Unlock()
+}
+
+func (p *peer) get() *net.UDPAddr {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.addr
+}
+
+// accept — пакет от src принимать; первый пакет с IP из SDP фиксирует порт.
+func (p *peer) accept(src *net.UDPAddr) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.addr == nil || !p.addr.IP.Equal(src.IP) {
+		return false
+	}
+	if p.addr.Port == src.Port {
+		p.latched = true
+		return true
+	}
+	if p.latched {
+		return false
+	}
+	p.addr, p.latched = src, true
+	return true
+}
+
+// Session — один RTP-поток между сервером и телефоном.
+type Session struct {
+	conn   *net.UDPConn
+	remote peer
+
+	mu    sync.Mutex
+	codec Codec
+	seq   uint16
+	ts    uint32
+	ssrc  uint32
+	mark  bool
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Listen открывает RTP-порт на host (порт выбирает ОС).
+func Listen(host string) (*Session, error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(host)})
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		conn:   conn,
+		codec:  PCMU,
+		seq:    uint16(rand.Intn(1 << 16)),
+		ts:     rand.Uint32(),
+		ssrc:   rand.Uint32(),
+		mark:   true,
+		closed: make(chan struct{}),
+	}, nil
+}
+
+func (s *Session) LocalPort() int {
+	return s.conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func (s *Session) Codec() Codec {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.codec
+}
+
+// SetRemote задаёт, куда слать RTP и каким кодеком.
+func (s *Session) SetRemote(addr *net.UDPAddr, codec Codec) {
+	s.remote.set(addr)
+	s.mu.Lock()
+	s.codec = codec
+	s.mu.Unlock()
+}
+
+// WriteFrame кодирует и отправляет один кадр.
+func (s *Session) WriteFrame(samples []int16) error {
+	remote := s.remote.get()
+	if remote == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	p := Packet{
+		PayloadType: s.codec.PayloadType,
+		Marker:      s.mark,
+		Seq:         s.seq,
+		Timestamp:   s.ts,
+		SSRC:        s.ssrc,
+		Payload:     s.codec.Encode(samples),
+	}
+	s.seq++
+	s.ts += uint32(len(samples))
+	s.mark = false
+	s.mu.Unlock()
+
+	_, err := s.conn.WriteToUDP(p.Marshal(), remote)
+	return err
+}
+
+// Play отправляет samples с темпом 20 мс на кадр. loop — по кругу до отмены ctx.
+func (s *Session) Play(ctx context.Context, samples []int16, loop bool) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(FrameSamples * time.Second / SampleRate)
+	defer ticker.Stop()
+
+	frame := make([]int16, FrameSamples)
+	for pos := 0; ; {
+		if pos >= len(samples) {
+			if !loop {
+				return nil
+			}
+			pos = 0
+		}
+
+		n := copy(frame, samples[pos:])
+		for i := n; i < len(frame); i++ {
+			frame[i] = 0
+		}
+		pos += n
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.closed:
+			return net.ErrClosed
+		case <-ticker.C:
+		}
+
+		if err := s.WriteFrame(frame); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadLoop отдаёт входящие пакеты handler'у, пока сессия не закрыта.
+// Принимается только RTP телефона из SDP (см. peer), остальное отбрасывается.
+func (s *Session) ReadLoop(handler func(p *Packet)) {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		var p Packet
+		if err := p.Unmarshal(buf[:n]); err != nil {
+			continue
+		}
+		if !s.remote.accept(addr) {
+			continue
+		}
+
+		p.Payload = append([]byte(nil), p.Payload...)
+		handler(&p)
+	}
+}
+
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		_ = s.conn.Close()
+	})
+}
+
+func (s *Session) Done() <-chan struct{} {
+	return s.closed
+}
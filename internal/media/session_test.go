@@ -0,0 +1,171 @@
+package media
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func udpAddr(s string) *net.UDPAddr {
+	a, err := net.ResolveUDPAddr("udp", s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func TestPeerAccept(t *testing.T) {
+	steps := []struct {
+		src  string
+		want bool
+		addr string // адрес после шага
+	}{
+		{"198.51.100.9:4000", false, "192.0.2.10:4000"}, // чужой IP
+		{"192.0.2.10:5000", true, "192.0.2.10:5000"},    // NAT сменил порт: фиксируем
+		{"192.0.2.10:4000", false, "192.0.2.10:5000"},   // второй источник с того же IP
+		{"192.0.2.10:5000", true, "192.0.2.10:5000"},
+		{"198.51.100.9:5000", false, "192.0.2.10:5000"},
+	}
+
+	var p peer
+	if p.accept(udpAddr("192.0.2.10:4000")) {
+		t.Fatal("packet accepted before SDP")
+	}
+	p.set(udpAddr("192.0.2.10:4000"))
+	for i, st := range steps {
+		if got := p.accept(udpAddr(st.src)); got != st.want {
+			t.Errorf("step %d: accept(%s) = %v, want %v", i, st.src, got, st.want)
+		}
+		if got := p.get().String(); got != st.addr {
+			t.Errorf("step %d: addr = %s, want %s", i, got, st.addr)
+		}
+	}
+
+	// re-INVITE с новым адресом: источник фиксируется заново
+	p.set(udpAddr("192.0.2.10:6000"))
+	if !p.accept(udpAddr("192.0.2.10:6002")) || p.accept(udpAddr("192.0.2.10:5000")) {
+		t.Error("latch not reset by set")
+	}
+
+	// пакет ровно с адреса из SDP фиксирует его, NAT-порт после этого не принимается
+	p.set(udpAddr("192.0.2.10:7000"))
+	if !p.accept(udpAddr("192.0.2.10:7000")) || p.accept(udpAddr("192.0.2.10:7002")) {
+		t.Error("SDP address not latched")
+	}
+}
+
+func TestSessionDropsForeignRTP(t *testing.T) {
+	sess, err := Listen("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	phone, err := net.ListenUDP("udp", udpAddr("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer phone.Close()
+	attacker, err := net.ListenUDP("udp", udpAddr("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer attacker.Close()
+
+	// в SDP — порт телефона до NAT, пакеты приходят с другого
+	sess.SetRemote(udpAddr("127.0.0.1:9"), PCMU)
+
+	got := make(chan uint32, 10)
+	go sess.ReadLoop(func(p *Packet) { got <- p.SSRC })
+
+	local := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: sess.LocalPort()}
+	send := func(c *net.UDPConn, ssrc uint32) {
+		p := Packet{PayloadType: 0, SSRC: ssrc, Payload: make([]byte, 160)}
+		if _, err := c.WriteToUDP(p.Marshal(), local); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send(phone, 1)
+	if ssrc := <-got; ssrc != 1 {
+		t.Fatalf("first packet ssrc=%d", ssrc)
+	}
+	send(attacker, 2)
+	_, _ = attacker.WriteToUDP([]byte("not rtp"), local)
+	send(phone, 3)
+	if ssrc := <-got; ssrc != 3 {
+		t.Errorf("accepted packet ssrc=%d, want 3", ssrc)
+	}
+
+	// звук уходит на зафиксированный адрес телефона, не атакующему
+	if err := sess.WriteFrame(make([]int16, FrameSamples)); err != nil {
+		t.Fatal(err)
+	}
+	_ = attacker.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, _, err := attacker.ReadFromUDP(make([]byte, 1500)); err == nil {
+		t.Error("attacker received session audio")
+	}
+	_ = phone.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := phone.ReadFromUDP(make([]byte, 1500)); err != nil {
+		t.Errorf("phone got no audio: %v", err)
+	}
+}
+
+func TestRelayForwardsOnlyPhoneRTP(t *testing.T) {
+	r, err := NewRelay("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	listen := func(ip string) *net.UDPConn {
+		c, err := net.ListenUDP("udp", udpAddr(ip+":0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = c.Close() })
+		return c
+	}
+	// атакующий — с другого IP: с адреса из SDP первым пакетом фиксируется любой порт
+	caller, callee, attacker := listen("127.0.0.1"), listen("127.0.0.1"), listen("127.0.0.2")
+	r.SetRemote(RelayA, caller.LocalAddr().(*net.UDPAddr))
+	r.SetRemote(RelayB, callee.LocalAddr().(*net.UDPAddr))
+
+	tapped := make(chan uint32, 10)
+	r.Run(func(side int, p *Packet) { tapped <- p.SSRC })
+
+	portA := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: r.Port(RelayA)}
+	rtp := func(ssrc uint32) []byte {
+		p := Packet{SSRC: ssrc, Payload: make([]byte, 160)}
+		return p.Marshal()
+	}
+	// чужой RTP и не-RTP на порт звонящего не пересылаются
+	_, _ = attacker.WriteToUDP(rtp(2), portA)
+	_, _ = caller.WriteToUDP([]byte("not rtp"), portA)
+	_, _ = caller.WriteToUDP(rtp(1), portA)
+
+	buf := make([]byte, 1500)
+	_ = callee.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := callee.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p Packet
+	if err := p.Unmarshal(buf[:n]); err != nil || p.SSRC != 1 {
+		t.Errorf("callee got ssrc=%d err=%v, want caller's packet", p.SSRC, err)
+	}
+	if ssrc := <-tapped; ssrc != 1 {
+		t.Errorf("tap saw ssrc=%d, want 1", ssrc)
+	}
+
+	// атакующий не стал стороной A: ответ вызываемого уходит звонящему
+	portB := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: r.Port(RelayB)}
+	_, _ = callee.WriteToUDP(rtp(3), portB)
+	_ = attacker.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, _, err := attacker.ReadFromUDP(buf); err == nil {
+		t.Error("attacker received callee audio")
+	}
+	_ = caller.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := caller.ReadFromUDP(buf); err != nil {
+		t.Errorf("caller got no audio: %v", err)
+	}
+}
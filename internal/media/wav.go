@@ -0,0 +1,177 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+var ErrUnsupportedWAV = errors.New("unsupported wav format")
+
+const (
+	wavFormatPCM  = 1
+	wavFormatALaw = 6
+	wavFormatULaw = 7
+)
+
+// ReadWAV читает моно WAV 8 кГц: PCM 16 бит, A-law или µ-law.
+func ReadWAV(path string) ([]int16, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var riff [12]byte
+	if _, err := io.ReadFull(f, riff[:]); err != nil {
+		return nil, err
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, ErrUnsupportedWAV
+	}
+
+	var format, channels, bits uint16
+	var rate uint32
+
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(f, hdr[:]); err != nil {
+			return nil, err
+		}
+		size := binary.LittleEndian.Uint32(hdr[4:])
+
+		switch string(hdr[0:4]) {
+		case "fmt ":
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(f, buf); err != nil {
+				return nil, err
+			}
+			if len(buf) < 16 {
+				return nil, ErrUnsupportedWAV
+			}
+			format = binary.LittleEndian.Uint16(buf[0:])
+			channels = binary.LittleEndian.Uint16(buf[2:])
+			rate = binary.LittleEndian.Uint32(buf[4:])
+			bits = binary.LittleEndian.Uint16(buf[14:])
+
+		case "data":
+			if channels != 1 || rate != SampleRate {
+				return nil, fmt.Errorf("%w: %d ch, %d Hz", ErrUnsupportedWAV, channels, rate)
+			}
+			data := make([]byte, size)
+			n, err := io.ReadFull(f, data)
+			if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, err
+			}
+			return decodeWAVData(data[:n], format, bits)
+
+		default:
+			if _, err := f.Seek(int64(size+size%2), io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func decodeWAVData(data []byte, format, bits uint16) ([]int16, error) {
+	switch {
+	case format == wavFormatPCM && bits == 16:
+		out := make([]int16, len(data)/2)
+		for i := range out {
+			out[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+		}
+		return out, nil
+	case format == wavFormatULaw:
+		return PCMU.Decode(data), nil
+	case format == wavFormatALaw:
+		return PCMA.Decode(data), nil
+	}
+	return nil, fmt.Errorf("%w: format=%d bits=%d", ErrUnsupportedWAV, format, bits)
+}
+
+// WAVWriter пишет PCM 16 бит 8 кГц; размеры в заголовке правятся в Close.
+type WAVWriter struct {
+	f        *os.File
+	channels int
+	samples  int
+}
+
+func CreateWAV(path string, channels int) (*WAVWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &WAVWriter{f: f, channels: channels}
+	if err := w.writeHeader(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *WAVWriter) writeHeader() error {
+	dataSize := uint32(w.samples * 2)
+	blockAlign := uint16(w.channels * 2)
+
+	hdr := make([]byte, 44)
+	copy(hdr[0:], "RIFF")
+	binary.LittleEndian.PutUint32(hdr[4:], 36+dataSize)
+	copy(hdr[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(hdr[16:], 16)
+	binary.LittleEndian.PutUint16(hdr[20:], wavFormatPCM)
+	binary.LittleEndian.PutUint16(hdr[22:], uint16(w.channels))
+	binary.LittleEndian.PutUint32(hdr[24:], SampleRate)
+	binary.LittleEndian.PutUint32(hdr[28:], SampleRate*uint32(blockAlign))
+	binary.LittleEndian.PutUint16(hdr[32:], blockAlign)
+	binary.LittleEndian.PutUint16(hdr[34:], 16)
+	copy(hdr[36:], "data")
+	binary.LittleEndian.PutUint32(hdr[40:], dataSize)
+
+	_, err := w.f.WriteAt(hdr, 0)
+	return err
+}
+
+// Write пишет сэмплы; для стерео — чередуя каналы (L, R, L, R...).
+func (w *WAVWriter) Write(samples []int16) error {
+	buf := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(buf[i*2:], uint16(s))
+	}
+	if _, err := w.f.WriteAt(buf, int64(44+w.samples*2)); err != nil {
+		return err
+	}
+	w.samples += len(samples)
+	return nil
+}
+
+// Duration — длительность записанного.
+func (w *WAVWriter) Duration() time.Duration {
+	frames := w.samples / w.channels
+	return time.Duration(frames) * time.Second / SampleRate
+}
+
+func (w *WAVWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// Tone — синус freq Гц длительностью d.
+func Tone(freq float64, d time.Duration, amplitude int16) []int16 {
+	n := int(d * SampleRate / time.Second)
+	out := make([]int16, n)
+	for i := range out {
+		out[i] = int16(float64(amplitude) * math.Sin(2*math.Pi*freq*float64(i)/SampleRate))
+	}
+	return out
+}
+
+func Silence(d time.Duration) []int16 {
+	return make([]int16, int(d*SampleRate/time.Second))
+}
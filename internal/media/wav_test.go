@@ -0,0 +1,129 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWAVRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tone.wav")
+	samples := append(Tone(440, 100*time.Millisecond, 8000), Silence(20*time.Millisecond)...)
+	samples = append(samples, 32767, -32768)
+
+	w, err := CreateWAV(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// пишем частями, как запись разговора
+	for chunk := range slices.Chunk(samples, FrameSamples) {
+		if err := w.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d, want := w.Duration(), time.Duration(len(samples))*time.Second/SampleRate; d != want {
+		t.Errorf("Duration = %v, want %v", d, want)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadWAV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, samples) {
+		t.Errorf("read %d samples, want %d equal samples", len(got), len(samples))
+	}
+}
+
+func TestReadWAVRejectsStereo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stereo.wav")
+	w, err := CreateWAV(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]int16{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	if d := w.Duration(); d != 2*time.Second/SampleRate {
+		t.Errorf("stereo Duration = %v", d)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadWAV(path); !errors.Is(err, ErrUnsupportedWAV) {
+		t.Errorf("ReadWAV(stereo) error = %v, want ErrUnsupportedWAV", err)
+	}
+}
+
+func TestReadWAVG711(t *testing.T) {
+	samples := []int16{0, 1000, -1000, 20000}
+	tests := []struct {
+		name   string
+		format uint16
+		codec  Codec
+	}{
+		{"alaw", wavFormatALaw, PCMA},
+		{"ulaw", wavFormatULaw, PCMU},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.codec.Encode(samples)
+			path := filepath.Join(t.TempDir(), tt.name+".wav")
+			if err := os.WriteFile(path, g711WAV(tt.format, data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadWAV(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tt.codec.Decode(data); !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadWAVNotRIFF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.wav")
+	if err := os.WriteFile(path, []byte("definitely not a wav"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadWAV(path); !errors.Is(err, ErrUnsupportedWAV) {
+		t.Errorf("error = %v, want ErrUnsupportedWAV", err)
+	}
+}
+
+// g711WAV — моно 8 кГц файл с 8-битными G.711 сэмплами и лишним чанком перед data.
+func g711WAV(format uint16, data []byte) []byte {
+	fmtChunk := make([]byte, 16)
+	binary.LittleEndian.PutUint16(fmtChunk[0:], format)
+	binary.LittleEndian.PutUint16(fmtChunk[2:], 1)
+	binary.LittleEndian.PutUint32(fmtChunk[4:], SampleRate)
+	binary.LittleEndian.PutUint32(fmtChunk[8:], SampleRate)
+	binary.LittleEndian.PutUint16(fmtChunk[12:], 1)
+	binary.LittleEndian.PutUint16(fmtChunk[14:], 8)
+
+	chunk := func(id string, body []byte) []byte {
+		out := make([]byte, 8, 8+len(body)+1)
+		copy(out, id)
+		binary.LittleEndian.PutUint32(out[4:], uint32(len(body)))
+		out = append(out, body...)
+		if len(body)%2 == 1 {
+			out = append(out, 0)
+		}
+		return out
+	}
+
+	body := []byte("WAVE")
+	body = append(body, chunk("fmt ", fmtChunk)...)
+	body = append(body, chunk("LIST", []byte("odd"))...)
+	body = append(body, chunk("data", data)...)
+	return append(chunk("RIFF", body)[:8], body...)
+}
@@ -54,6 +54,56 @@ var (
 		Name: "sip_dialog_entries",
 		Help: "Number of dialog entries stored in server.dialogs (usually 2 per dialog).",
 	})
+
+	TrunkRegistered = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sip_trunk_registered",
+		Help: "Outbound registration state per trunk (1 = registered).",
+	}, []string{"trunk"})
+
+	TrunkCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sip_trunk_calls_total",
+		Help: "Outbound call attempts per trunk by result.",
+	}, []string{"trunk", "result"}) // answered/rejected/failover/busy_channels
+
+	TrunkActiveChannels = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sip_trunk_active_channels",
+		Help: "Number of calls currently using a trunk.",
+	}, []string{"trunk"})
+
+	SIPPendingInvites = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sip_pending_invites",
+		Help: "Number of admitted INVITEs without a final response.",
+	})
+
+	SIPCallLimit = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sip_call_limit",
+		Help: "Global limit of concurrent calls (0 = unlimited).",
+	})
+
+	SIPCallsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sip_calls_rejected_total",
+		Help: "INVITEs rejected by call admission control.",
+	}, []string{"limit"}) // global/outbound/inbound/total
+
+	SIPDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sip_dropped_messages_total",
+		Help: "SIP requests dropped by flood protection.",
+	}, []string{"method", "reason"}) // banned/rate_ip/rate_aor
+
+	SIPBans = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sip_banned_ips",
+		Help: "Number of currently banned source IPs.",
+	})
+
+	ACLDenied = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "acl_denied_total",
+		Help: "Requests rejected by network ACLs.",
+	}, []string{"scope"}) // register/trunk/api/metrics
+
+	SIPAuthFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sip_auth_failures_total",
+		Help: "SIP digest responses that did not match the stored credentials.",
+	}, []string{"method"})
 )
 
 func MustRegister(reg prometheus.Registerer) {
@@ -62,5 +112,9 @@ func MustRegister(reg prometheus.Registerer) {
 		SIPMessages, SIPResponses, SIPHandlerDuration,
 		SIPActiveDialogs, SIPRegistrations, SIPTransactionsInFlight,
 		SIPDialogEntries,
+		TrunkRegistered, TrunkCalls, TrunkActiveChannels,
+		SIPPendingInvites, SIPCallLimit, SIPCallsRejected,
+		SIPDropped, SIPBans,
+		ACLDenied, SIPAuthFailures,
 	)
 }
@@ -0,0 +1,166 @@
+package aclrule
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"SipServer/internal/repository"
+)
+
+type Scope string
+
+const (
+	ScopeRegister Scope = "register" // кто может регистрироваться
+	ScopeTrunk    Scope = "trunk"    // с каких адресов транков принимать звонки без проверки пользователя
+	ScopeAPI      Scope = "api"      // доступ к /api
+	ScopeMetrics  Scope = "metrics"  // доступ к /metrics
+)
+
+type Action string
+
+const (
+	ActionAllow Action = "allow"
+	ActionDeny  Action = "deny"
+)
+
+var ErrRuleNotFound = errors.New("acl rule not found")
+
+const queryRule string = `
+SELECT
+	id,
+	scope,
+	network::text,
+	action,
+	COALESCE(description, ''),
+	created_at,
+	updated_at
+FROM acl_rules
+`
+
+type Rule struct {
+	Id          int       `json:"id"`
+	Scope       Scope     `json:"scope" validate:"required,oneof=register trunk api metrics"`
+	Network     string    `json:"network" validate:"required,cidr|ip"` // 10.0.0.0/8 или один адрес
+	Action      Action    `json:"action" validate:"required,oneof=allow deny"`
+	Description string    `json:"description,omitempty" validate:"max=255"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func NewRule() *Rule {
+	return &Rule{Action: ActionAllow}
+}
+
+type ACLRuleRepo struct {
+	DB *sql.DB
+}
+
+func NewACLRuleRepo(db *sql.DB) *ACLRuleRepo {
+	return &ACLRuleRepo{DB: db}
+}
+
+func (r *ACLRuleRepo) List(ctx context.Context) ([]*Rule, error) {
+	return r.query(ctx, queryRule+" ORDER BY scope, masklen(network) DESC, id")
+}
+
+func (r *ACLRuleRepo) FindByID(id string) (*Rule, error) {
+	rules, err := r.query(context.Background(), queryRule+" WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, ErrRuleNotFound
+	}
+	return rules[0], nil
+}
+
+// Create сохраняет правило; адрес с битами хоста (10.1.2.3/24) приводится к сети.
+func (r *ACLRuleRepo) Create(rule *Rule) (*Rule, error) {
+	const q = `
+		INSERT INTO acl_rules (scope, network, action, description)
+		VALUES ($1, network($2::inet), $3, $4)
+		RETURNING id, network::text, created_at, updated_at
+	`
+
+	err := r.DB.QueryRow(q, r.args(rule)...).Scan(&rule.Id, &rule.Network, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *ACLRuleRepo) Update(id string, rule *Rule) (*Rule, error) {
+	const q = `
+		UPDATE acl_rules
+		SET
+			scope       = $1,
+			network     = network($2::inet),
+			action      = $3,
+			description = $4
+		WHERE id = $5
+		RETURNING id, network::text, created_at, updated_at
+	`
+
+	args := append(r.args(rule), id)
+	err := r.DB.QueryRow(q, args...).Scan(&rule.Id, &rule.Network, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRuleNotFound
+		}
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *ACLRuleRepo) Delete(id string) error {
+	res, err := r.DB.Exec("DELETE FROM acl_rules WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+func (r *ACLRuleRepo) args(rule *Rule) []any {
+	return []any{
+		rule.Scope,
+		rule.Network,
+		rule.Action,
+		repository.NullIfEmpty(rule.Description),
+	}
+}
+
+func (r *ACLRuleRepo) query(ctx context.Context, query string, args ...any) ([]*Rule, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]*Rule, 0)
+	for rows.Next() {
+		rule := NewRule()
+		err := rows.Scan(
+			&rule.Id,
+			&rule.Scope,
+			&rule.Network,
+			&rule.Action,
+			&rule.Description,
+			&rule.CreatedAt,
+			&rule.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
@@ -0,0 +1,140 @@
+package apitoken
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"SipServer/internal/repository"
+)
+
+type Kind string
+
+const (
+	KindSession Kind = "session" // выдан при входе по логину и паролю
+	KindAPIKey  Kind = "api_key" // для автоматизации, создаёт администратор
+)
+
+var ErrTokenNotFound = errors.New("api token not found")
+
+type Token struct {
+	Id         int        `json:"id"`
+	UserId     int        `json:"user_id" validate:"required,min=1"`
+	Login      string     `json:"login,omitempty"`
+	Kind       Kind       `json:"kind"`
+	Name       string     `json:"name" validate:"max=128"`
+	TTLDays    int        `json:"ttl_days,omitempty" validate:"min=0"` // срок при создании, 0 — бессрочно
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+func NewToken() *Token {
+	return &Token{Kind: KindAPIKey}
+}
+
+// Principal — владелец действующего токена.
+type Principal struct {
+	TokenId int    `json:"-"`
+	Kind    Kind   `json:"kind"`
+	UserId  int    `json:"id"`
+	Login   string `json:"login"`
+	Role    string `json:"role"`
+}
+
+type APITokenRepo struct {
+	DB *sql.DB
+}
+
+func NewAPITokenRepo(db *sql.DB) *APITokenRepo {
+	return &APITokenRepo{DB: db}
+}
+
+// Create сохраняет токен по его хешу; expiresAt nil — бессрочно.
+func (r *APITokenRepo) Create(ctx context.Context, t *Token, hash string, expiresAt *time.Time) (*Token, error) {
+	const q = `
+		INSERT INTO api_tokens (user_id, kind, name, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, expires_at
+	`
+	err := r.DB.QueryRowContext(ctx, q, t.UserId, t.Kind, repository.NullIfEmpty(t.Name), hash, expiresAt).
+		Scan(&t.Id, &t.CreatedAt, &t.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Use находит действующий токен по хешу и отмечает время использования.
+func (r *APITokenRepo) Use(ctx context.Context, hash string) (*Principal, error) {
+	const q = `
+		UPDATE api_tokens t
+		SET last_used_at = now()
+		FROM users u
+		WHERE t.token_hash = $1
+		  AND u.id = t.user_id
+		  AND u.enabled
+		  AND (t.expires_at IS NULL OR t.expires_at > now())
+		RETURNING t.id, t.kind, u.id, u.login, u.role
+	`
+	p := &Principal{}
+	err := r.DB.QueryRowContext(ctx, q, hash).Scan(&p.TokenId, &p.Kind, &p.UserId, &p.Login, &p.Role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// ListAPIKeys — ключи API всех пользователей (без самих токенов).
+func (r *APITokenRepo) ListAPIKeys(ctx context.Context) ([]*Token, error) {
+	const q = `
+		SELECT t.id, t.user_id, u.login, t.kind, COALESCE(t.name, ''), t.created_at, t.expires_at, t.last_used_at
+		FROM api_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.kind = $1
+		ORDER BY t.id
+	`
+	rows, err := r.DB.QueryContext(ctx, q, KindAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]*Token, 0)
+	for rows.Next() {
+		t := NewToken()
+		err := rows.Scan(&t.Id, &t.UserId, &t.Login, &t.Kind, &t.Name, &t.CreatedAt, &t.ExpiresAt, &t.LastUsedAt)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// Delete отзывает токен; kind "" — любого вида.
+func (r *APITokenRepo) Delete(ctx context.Context, id string, kind Kind) error {
+	res, err := r.DB.ExecContext(ctx,
+		"DELETE FROM api_tokens WHERE id = $1 AND ($2 = '' OR kind = $2)", id, kind)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// DeleteExpired убирает истёкшие токены.
+func (r *APITokenRepo) DeleteExpired(ctx context.Context) error {
+	_, err := r.DB.ExecContext(ctx, "DELETE FROM api_tokens WHERE expires_at <= now()")
+	return err
+}
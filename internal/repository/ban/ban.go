@@ -0,0 +1,104 @@
+package ban
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"SipServer/internal/repository"
+)
+
+var ErrBanNotFound = errors.New("ban not found")
+
+const queryBan string = `
+SELECT
+	id,
+	ip,
+	COALESCE(reason, ''),
+	banned_at,
+	expires_at
+FROM sip_bans
+`
+
+// действующие баны
+const activeBan = ` WHERE expires_at IS NULL OR expires_at > now()`
+
+type Ban struct {
+	Id        int        `json:"id"`
+	IP        string     `json:"ip" validate:"required,ip"`
+	Reason    string     `json:"reason,omitempty" validate:"max=255"`
+	Duration  int        `json:"duration,omitempty" validate:"min=0"` // секунды при создании, 0 — бессрочно
+	BannedAt  time.Time  `json:"banned_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func NewBan() *Ban {
+	return &Ban{}
+}
+
+type BanRepo struct {
+	DB *sql.DB
+}
+
+func NewBanRepo(db *sql.DB) *BanRepo {
+	return &BanRepo{DB: db}
+}
+
+// List — действующие баны, новые сверху.
+func (r *BanRepo) List(ctx context.Context) ([]*Ban, error) {
+	return r.query(ctx, queryBan+activeBan+" ORDER BY banned_at DESC")
+}
+
+func (r *BanRepo) Create(ctx context.Context, b *Ban) (*Ban, error) {
+	const q = `
+		INSERT INTO sip_bans (ip, reason, expires_at)
+		VALUES ($1, $2, CASE WHEN $3::int > 0 THEN now() + make_interval(secs => $3::int) END)
+		RETURNING id, banned_at, expires_at
+	`
+	err := r.DB.QueryRowContext(ctx, q, b.IP, repository.NullIfEmpty(b.Reason), b.Duration).
+		Scan(&b.Id, &b.BannedAt, &b.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (r *BanRepo) Delete(ctx context.Context, id string) error {
+	res, err := r.DB.ExecContext(ctx, "DELETE FROM sip_bans WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrBanNotFound
+	}
+	return nil
+}
+
+// DeleteExpired убирает истёкшие баны.
+func (r *BanRepo) DeleteExpired(ctx context.Context) error {
+	_, err := r.DB.ExecContext(ctx, "DELETE FROM sip_bans WHERE expires_at <= now()")
+	return err
+}
+
+func (r *BanRepo) query(ctx context.Context, query string, args ...any) ([]*Ban, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := make([]*Ban, 0)
+	for rows.Next() {
+		b := NewBan()
+		if err := rows.Scan(&b.Id, &b.IP, &b.Reason, &b.BannedAt, &b.ExpiresAt); err != nil {
+			return nil, err
+		}
+		bans = append(bans, b)
+	}
+	return bans, rows.Err()
+}
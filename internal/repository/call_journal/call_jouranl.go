@@ -19,7 +19,10 @@ const (
 	CallResultFailed    CallResult = "failed"
 )
 
-var ErrNotFound = errors.New("cdr: not found")
+var (
+	ErrNotFound          = errors.New("cdr: not found")
+	ErrRecordingNotFound = errors.New("cdr: recording not found")
+)
 
 type CallJournal struct {
 	Id         int     `json:"id"`
@@ -31,6 +34,20 @@ type CallJournal struct {
 	CalleeUser *string `json:"callee_user,omitempty"`
 	CallerURI  *string `json:"caller_uri,omitempty"`
 	CalleeURI  *string `json:"callee_uri,omitempty"`
+	TrunkName  *string `json:"trunk_name,omitempty"`
+	DID        *string `json:"did,omitempty"`
+	PickedUpBy *string `json:"picked_up_by,omitempty"`
+
+	ParentID     *int64  `json:"parent_id,omitempty"`
+	TransferType *string `json:"transfer_type,omitempty"`
+	ParkSlot     *string `json:"park_slot,omitempty"`
+	ParkedMs     *int    `json:"parked_ms,omitempty"`
+	Conference   *string `json:"conference,omitempty"`
+
+	RecordingPath *string `json:"recording_path,omitempty"`
+	RecordingMs   *int    `json:"recording_ms,omitempty"`
+
+	Codec *string `json:"codec,omitempty"` // согласованный аудиокодек
 
 	InviteAt   time.Time  `json:"invite_at"`
 	First18xAt *time.Time `json:"first_18x_at,omitempty"`
@@ -132,6 +149,250 @@ func (r *CallJournalRepo) MarkCancelled(
 	return err
 }
 
+func (r *CallJournalRepo) MarkNoAnswer(
+	ctx context.Context,
+	journalID int64,
+	endAt time.Time,
+) error {
+
+	const q = `
+		UPDATE call_journals
+		SET
+			end_at       = COALESCE(end_at, $2),
+			result       = COALESCE(result, 'no_answer'),
+			final_code   = COALESCE(final_code, 480),
+			final_reason = COALESCE(final_reason, 'Temporarily Unavailable'),
+			ended_by     = COALESCE(ended_by, 'system')
+		WHERE id = $1
+	`
+	_, err := r.DB.ExecContext(ctx, q, journalID, endAt)
+	return err
+}
+
+func (r *CallJournalRepo) MarkFailed(
+	ctx context.Context,
+	journalID int64,
+	code int,
+	reason string,
+	endAt time.Time,
+) error {
+
+	const q = `
+		UPDATE call_journals
+		SET
+			end_at       = COALESCE(end_at, $2),
+			result       = COALESCE(result, 'failed'),
+			final_code   = COALESCE(final_code, $3),
+			final_reason = COALESCE(final_reason, $4),
+			ended_by     = COALESCE(ended_by, 'system')
+		WHERE id = $1
+	`
+	_, err := r.DB.ExecContext(ctx, q, journalID, endAt, code, repository.NullIfEmpty(reason))
+	return err
+}
+
+// SetTrunk запоминает транк, через который ушёл звонок.
+func (r *CallJournalRepo) SetTrunk(ctx context.Context, journalID int64, trunkName string) error {
+	const q = `UPDATE call_journals SET trunk_name = $2 WHERE id = $1`
+	_, err := r.DB.ExecContext(ctx, q, journalID, trunkName)
+	return err
+}
+
+// SetInbound отмечает входящий звонок с транка на DID.
+func (r *CallJournalRepo) SetInbound(ctx context.Context, journalID int64, trunkName, did string) error {
+	const q = `UPDATE call_journals SET trunk_name = $2, did = $3 WHERE id = $1`
+	_, err := r.DB.ExecContext(ctx, q, journalID, trunkName, did)
+	return err
+}
+
+// SetPickedUp отмечает, что звонок перехватил другой пользователь.
+func (r *CallJournalRepo) SetPickedUp(ctx context.Context, journalID int64, login string) error {
+	const q = `UPDATE call_journals SET picked_up_by = $2 WHERE id = $1`
+	_, err := r.DB.ExecContext(ctx, q, journalID, login)
+	return err
+}
+
+// SetParent связывает попытку, созданную переводом, с исходным звонком.
+func (r *CallJournalRepo) SetParent(ctx context.Context, journalID, parentID int64, transferType string) error {
+	const q = `UPDATE call_journals SET parent_id = $2, transfer_type = $3 WHERE id = $1`
+	_, err := r.DB.ExecContext(ctx, q, journalID, parentID, transferType)
+	return err
+}
+
+// MarkTransferred помечает активную сессию как переведённую:
+// последующий BYE уже не перезапишет term_reason.
+func (r *CallJournalRepo) MarkTransferred(ctx context.Context, callID, fromTag, toTag string) error {
+	const q = `
+		UPDATE call_sessions
+		SET term_reason = 'REFER'
+		WHERE call_id = $1
+		  AND ((from_tag = $2 AND to_tag = $3) OR (from_tag = $3 AND to_tag = $2))
+		  AND state <> 'terminated'
+	`
+	_, err := r.DB.ExecContext(ctx, q, callID, fromTag, toTag)
+	return err
+}
+
+// AddHold учитывает интервал удержания в сессии диалога.
+func (r *CallJournalRepo) AddHold(ctx context.Context, callID, fromTag, toTag string, heldMs int) error {
+	const q = `
+		UPDATE call_sessions
+		SET hold_count = hold_count + 1,
+		    hold_ms    = hold_ms + $4
+		WHERE call_id = $1
+		  AND ((from_tag = $2 AND to_tag = $3) OR (from_tag = $3 AND to_tag = $2))
+	`
+	_, err := r.DB.ExecContext(ctx, q, callID, fromTag, toTag, heldMs)
+	return err
+}
+
+// SetParked отмечает, что звонок стоял на парковке в slot.
+func (r *CallJournalRepo) SetParked(ctx context.Context, journalID int64, slot string) error {
+	const q = `UPDATE call_journals SET park_slot = $2 WHERE id = $1`
+	_, err := r.DB.ExecContext(ctx, q, journalID, slot)
+	return err
+}
+
+// AddParkedTime прибавляет время на парковке (звонок могут парковать несколько раз).
+func (r *CallJournalRepo) AddParkedTime(ctx context.Context, journalID int64, parkedMs int) error {
+	const q = `UPDATE call_journals SET parked_ms = COALESCE(parked_ms, 0) + $2 WHERE id = $1`
+	_, err := r.DB.ExecContext(ctx, q, journalID, parkedMs)
+	return err
+}
+
+// SetConference отмечает, что звонок был участником конференции room.
+func (r *CallJournalRepo) SetConference(ctx context.Context, journalID int64, room string) error {
+	const q = `UPDATE call_journals SET conference = $2 WHERE id = $1`
+	_, err := r.DB.ExecContext(ctx, q, journalID, room)
+	return err
+}
+
+// SetRecording — файл записи разговора и его длительность.
+func (r *CallJournalRepo) SetRecording(ctx context.Context, journalID int64, path string, durationMs int) error {
+	const q = `UPDATE call_journals SET recording_path = $2, recording_ms = $3 WHERE id = $1`
+	_, err := r.DB.ExecContext(ctx, q, journalID, path, durationMs)
+	return err
+}
+
+// ActiveCalls — незавершённые звонки пользователя (в том числе ещё не отвеченные).
+type ActiveCalls struct {
+	Outbound int `json:"outbound"`
+	Inbound  int `json:"inbound"`
+	Total    int `json:"total"`
+}
+
+// ActiveCalls считает звонки login без end_at.
+func (r *CallJournalRepo) ActiveCalls(ctx context.Context, login string) (ActiveCalls, error) {
+	const q = `
+		SELECT
+			COUNT(*) FILTER (WHERE caller_user = $1),
+			COUNT(*) FILTER (WHERE callee_user = $1),
+			COUNT(*)
+		FROM call_journals
+		WHERE end_at IS NULL AND (caller_user = $1 OR callee_user = $1)`
+	var c ActiveCalls
+	err := r.DB.QueryRowContext(ctx, q, login).Scan(&c.Outbound, &c.Inbound, &c.Total)
+	return c, err
+}
+
+// SetCodec — аудиокодек из SDP ответа.
+func (r *CallJournalRepo) SetCodec(ctx context.Context, journalID int64, codec string) error {
+	const q = `UPDATE call_journals SET codec = $2 WHERE id = $1`
+	_, err := r.DB.ExecContext(ctx, q, journalID, codec)
+	return err
+}
+
+// RecordingPath — путь к записи звонка.
+func (r *CallJournalRepo) RecordingPath(ctx context.Context, journalID string) (string, error) {
+	var path sql.NullString
+	err := r.DB.QueryRowContext(ctx, `SELECT recording_path FROM call_journals WHERE id = $1`, journalID).Scan(&path)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if !path.Valid || path.String == "" {
+		return "", ErrRecordingNotFound
+	}
+	return path.String, nil
+}
+
+func (r *CallJournalRepo) ClearRecording(ctx context.Context, journalID string) error {
+	const q = `UPDATE call_journals SET recording_path = NULL, recording_ms = NULL WHERE id = $1`
+	_, err := r.DB.ExecContext(ctx, q, journalID)
+	return err
+}
+
+// ExpiredRecordings — id и файлы записей звонков, начатых раньше before.
+func (r *CallJournalRepo) ExpiredRecordings(ctx context.Context, before time.Time) (map[string]string, error) {
+	const q = `SELECT id, recording_path FROM call_journals WHERE recording_path IS NOT NULL AND invite_at < $1`
+	rows, err := r.DB.QueryContext(ctx, q, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[string]string)
+	for rows.Next() {
+		var id, path string
+		if err := rows.Scan(&id, &path); err != nil {
+			return nil, err
+		}
+		res[id] = path
+	}
+	return res, rows.Err()
+}
+
+// MarkFirst18x — первый 180/183 от вызываемого.
+func (r *CallJournalRepo) MarkFirst18x(ctx context.Context, journalID int64, at time.Time) error {
+	_, err := r.DB.ExecContext(ctx,
+		`UPDATE call_journals SET first_18x_at = COALESCE(first_18x_at, $2) WHERE id = $1`,
+		journalID, at)
+	return err
+}
+
+// StartEarly — ранний диалог (18x с To tag). На 2xx MarkAnswered переводит его в active.
+func (r *CallJournalRepo) StartEarly(
+	ctx context.Context,
+	journalID int64,
+	callID, fromTag, toTag string,
+	remoteTarget string,
+	routeSetJSON []byte,
+	at time.Time,
+) error {
+
+	const q = `
+		INSERT INTO call_sessions (
+			journal_id, call_id, from_tag, to_tag,
+			state, remote_target, route_set, created_at
+		)
+		VALUES ($1,$2,$3,$4,'early',$5,$6,$7)
+		ON CONFLICT (call_id, from_tag, to_tag) DO NOTHING
+	`
+	_, err := r.DB.ExecContext(ctx, q,
+		journalID, callID, fromTag, toTag,
+		repository.NullIfEmpty(remoteTarget), routeSetJSON, at)
+	return err
+}
+
+// TerminateEarly закрывает ранние диалоги звонка, так и не получившие 2xx.
+// code == 0 — звонок ответили с другой ветки.
+func (r *CallJournalRepo) TerminateEarly(ctx context.Context, journalID int64, code int, reason string, at time.Time) error {
+	const q = `
+		UPDATE call_sessions
+		SET
+			state         = 'terminated',
+			terminated_at = COALESCE(terminated_at, $2),
+			term_code     = COALESCE(term_code, NULLIF($3, 0)),
+			term_reason   = COALESCE(term_reason, $4)
+		WHERE journal_id = $1
+		  AND state = 'early'
+	`
+	_, err := r.DB.ExecContext(ctx, q, journalID, at, code, repository.NullIfEmpty(reason))
+	return err
+}
+
 func (r *CallJournalRepo) MarkAnswered(
 	ctx context.Context,
 	journalID int64,
@@ -229,14 +490,15 @@ func (r *CallJournalRepo) EndByBye(
 		  AND from_tag = $2
 		  AND to_tag = $3
 		  AND state <> 'terminated'
-		RETURNING journal_id
+		RETURNING journal_id, hold_ms
 	`
 
 	var journalID int64
+	var holdMs int
 	err = tx.QueryRowContext(
 		ctx, qSession,
 		callID, fromTag, toTag, endAt, string(endedBy),
-	).Scan(&journalID)
+	).Scan(&journalID, &holdMs)
 
 	if err == sql.ErrNoRows {
 		// already terminated or session missing — идемпотентно
@@ -246,7 +508,12 @@ func (r *CallJournalRepo) EndByBye(
 		return err
 	}
 
-	// 2) Закрываем журнал
+	// 2) Закрываем журнал; время на удержании разговором не считается
+	talkMs -= holdMs
+	if talkMs < 0 {
+		talkMs = 0
+	}
+
 	const qJournal = `
 		UPDATE call_journals
 		SET
@@ -264,7 +531,15 @@ func (r *CallJournalRepo) EndByBye(
 }
 
 func (r *CallJournalRepo) List() ([]CallJournal, error) {
+	return r.list("")
+}
+
+// ListByUser — звонки, где login звонил или ему звонили.
+func (r *CallJournalRepo) ListByUser(login string) ([]CallJournal, error) {
+	return r.list(" WHERE caller_user = $1 OR callee_user = $1", login)
+}
 
+func (r *CallJournalRepo) list(where string, args ...any) ([]CallJournal, error) {
 	query := `
 SELECT
 	id,
@@ -276,6 +551,17 @@ SELECT
 	callee_user,
 	caller_uri,
 	callee_uri,
+	trunk_name,
+	did,
+	picked_up_by,
+	parent_id,
+	transfer_type,
+	park_slot,
+	parked_ms,
+	conference,
+	recording_path,
+	recording_ms,
+	codec,
 	invite_at,
 	first_18x_at,
 	answer_at,
@@ -289,9 +575,9 @@ SELECT
 	created_at,
 	updated_at
 FROM call_journals
-`
+` + where
 
-	rows, err := r.DB.Query(query)
+	rows, err := r.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -317,6 +603,17 @@ FROM call_journals
 			&cj.CalleeUser,
 			&cj.CallerURI,
 			&cj.CalleeURI,
+			&cj.TrunkName,
+			&cj.DID,
+			&cj.PickedUpBy,
+			&cj.ParentID,
+			&cj.TransferType,
+			&cj.ParkSlot,
+			&cj.ParkedMs,
+			&cj.Conference,
+			&cj.RecordingPath,
+			&cj.RecordingMs,
+			&cj.Codec,
 			&cj.InviteAt,
 			&first18x,
 			&answer,
@@ -0,0 +1,277 @@
+package conference
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"SipServer/internal/repository"
+)
+
+var (
+	ErrRoomNotFound        = errors.New("conference room not found")
+	ErrParticipantNotFound = errors.New("conference participant not found")
+)
+
+const queryRoom string = `
+SELECT
+	id,
+	name,
+	number,
+	COALESCE(pin, ''),
+	max_participants,
+	enabled,
+	created_at,
+	updated_at
+FROM conference_rooms
+`
+
+type Room struct {
+	Id              int       `json:"id"`
+	Name            string    `json:"name" validate:"required,max=128"`
+	Number          string    `json:"number" validate:"required,max=32"`
+	Pin             string    `json:"pin,omitempty" validate:"omitempty,numeric,max=16"`
+	MaxParticipants int       `json:"max_participants" validate:"min=2,max=100"`
+	Enabled         bool      `json:"enabled"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func NewRoom() *Room {
+	return &Room{
+		MaxParticipants: 10,
+		Enabled:         true,
+	}
+}
+
+// Participant — участник идущей конференции.
+type Participant struct {
+	Id         int64     `json:"id"`
+	RoomId     int       `json:"room_id"`
+	JournalID  *int64    `json:"journal_id,omitempty"`
+	CallID     string    `json:"call_id"`
+	CallerUser string    `json:"caller_user"`
+	Muted      bool      `json:"muted"`
+	Kicked     bool      `json:"-"`
+	JoinedAt   time.Time `json:"joined_at"`
+}
+
+type UpdateParticipantRequest struct {
+	Muted bool `json:"muted"`
+}
+
+type ConferenceRepo struct {
+	DB *sql.DB
+}
+
+func NewConferenceRepo(db *sql.DB) *ConferenceRepo {
+	return &ConferenceRepo{DB: db}
+}
+
+func (r *ConferenceRepo) List() ([]*Room, error) {
+	return r.query(context.Background(), queryRoom+" ORDER BY number")
+}
+
+func (r *ConferenceRepo) FindByID(id string) (*Room, error) {
+	return r.findOne(context.Background(), queryRoom+" WHERE id = $1", id)
+}
+
+// FindByNumber — только включённые комнаты, для маршрутизации.
+func (r *ConferenceRepo) FindByNumber(ctx context.Context, number string) (*Room, error) {
+	return r.findOne(ctx, queryRoom+" WHERE number = $1 AND enabled", number)
+}
+
+func (r *ConferenceRepo) Create(room *Room) (*Room, error) {
+	const q = `
+		INSERT INTO conference_rooms (name, number, pin, max_participants, enabled)
+		VALUES ($1,$2,$3,$4,$5)
+		RETURNING id, created_at, updated_at
+	`
+	err := r.DB.QueryRow(q, r.args(room)...).Scan(&room.Id, &room.CreatedAt, &room.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+func (r *ConferenceRepo) Update(id string, room *Room) (*Room, error) {
+	const q = `
+		UPDATE conference_rooms
+		SET
+			name             = $1,
+			number           = $2,
+			pin              = $3,
+			max_participants = $4,
+			enabled          = $5
+		WHERE id = $6
+		RETURNING id, created_at, updated_at
+	`
+	err := r.DB.QueryRow(q, append(r.args(room), id)...).Scan(&room.Id, &room.CreatedAt, &room.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRoomNotFound
+		}
+		return nil, err
+	}
+	return room, nil
+}
+
+func (r *ConferenceRepo) Delete(id string) error {
+	res, err := r.DB.Exec("DELETE FROM conference_rooms WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRoomNotFound
+	}
+	return nil
+}
+
+func (r *ConferenceRepo) Participants(roomID string) ([]*Participant, error) {
+	return r.participants(context.Background(), roomID)
+}
+
+// ParticipantStates — для SIP-сервера: muted/kicked, выставленные через API.
+func (r *ConferenceRepo) ParticipantStates(ctx context.Context, roomID int) ([]*Participant, error) {
+	return r.participants(ctx, roomID)
+}
+
+func (r *ConferenceRepo) SetMuted(roomID, id string, muted bool) (*Participant, error) {
+	return r.updateParticipant(`UPDATE conference_participants SET muted = $3 WHERE room_id = $1 AND id = $2`,
+		roomID, id, muted)
+}
+
+// Kick помечает участника; трубку кладёт SIP-сервер.
+func (r *ConferenceRepo) Kick(roomID, id string) error {
+	_, err := r.updateParticipant(`UPDATE conference_participants SET kicked = true WHERE room_id = $1 AND id = $2`,
+		roomID, id)
+	return err
+}
+
+func (r *ConferenceRepo) AddParticipant(ctx context.Context, p *Participant) (*Participant, error) {
+	const q = `
+		INSERT INTO conference_participants (room_id, journal_id, call_id, caller_user)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, joined_at`
+	if err := r.DB.QueryRowContext(ctx, q, p.RoomId, p.JournalID, p.CallID, p.CallerUser).Scan(&p.Id, &p.JoinedAt); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *ConferenceRepo) RemoveParticipant(ctx context.Context, id int64) error {
+	_, err := r.DB.ExecContext(ctx, `DELETE FROM conference_participants WHERE id = $1`, id)
+	return err
+}
+
+// ClearParticipants — при старте: после перезапуска конференций нет.
+func (r *ConferenceRepo) ClearParticipants(ctx context.Context) error {
+	_, err := r.DB.ExecContext(ctx, `DELETE FROM conference_participants`)
+	return err
+}
+
+func (r *ConferenceRepo) updateParticipant(q string, args ...any) (*Participant, error) {
+	res, err := r.DB.Exec(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrParticipantNotFound
+	}
+
+	items, err := r.queryParticipants(context.Background(), " WHERE id = $1", args[1])
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrParticipantNotFound
+	}
+	return items[0], nil
+}
+
+func (r *ConferenceRepo) participants(ctx context.Context, roomID any) ([]*Participant, error) {
+	return r.queryParticipants(ctx, " WHERE room_id = $1 ORDER BY joined_at", roomID)
+}
+
+func (r *ConferenceRepo) queryParticipants(ctx context.Context, where string, args ...any) ([]*Participant, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT id, room_id, journal_id, call_id, caller_user, muted, kicked, joined_at
+		FROM conference_participants`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*Participant, 0)
+	for rows.Next() {
+		p := &Participant{}
+		if err := rows.Scan(&p.Id, &p.RoomId, &p.JournalID, &p.CallID, &p.CallerUser, &p.Muted, &p.Kicked, &p.JoinedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, p)
+	}
+	return items, rows.Err()
+}
+
+func (r *ConferenceRepo) args(room *Room) []any {
+	return []any{
+		room.Name,
+		room.Number,
+		repository.NullIfEmpty(room.Pin),
+		room.MaxParticipants,
+		room.Enabled,
+	}
+}
+
+func (r *ConferenceRepo) findOne(ctx context.Context, query string, args ...any) (*Room, error) {
+	rooms, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(rooms) == 0 {
+		return nil, ErrRoomNotFound
+	}
+	return rooms[0], nil
+}
+
+func (r *ConferenceRepo) query(ctx context.Context, query string, args ...any) ([]*Room, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rooms := make([]*Room, 0)
+	for rows.Next() {
+		room := NewRoom()
+		err := rows.Scan(
+			&room.Id,
+			&room.Name,
+			&room.Number,
+			&room.Pin,
+			&room.MaxParticipants,
+			&room.Enabled,
+			&room.CreatedAt,
+			&room.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rooms, nil
+}
@@ -0,0 +1,244 @@
+package dialplan
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"SipServer/internal/repository"
+
+	"github.com/lib/pq"
+)
+
+type MatchType string
+
+const (
+	MatchPrefix MatchType = "prefix"
+	MatchRegex  MatchType = "regex"
+)
+
+type Action string
+
+const (
+	ActionLocalUser Action = "local_user"
+	ActionGroup     Action = "group"
+	ActionTrunk     Action = "trunk"
+	ActionReject    Action = "reject"
+)
+
+var ErrRuleNotFound = errors.New("dial plan rule not found")
+
+const queryRule string = `
+SELECT
+	id,
+	name,
+	priority,
+	enabled,
+	match_type,
+	pattern,
+	action,
+	COALESCE(target, ''),
+	COALESCE(reject_code, 0),
+	strip_digits,
+	prepend,
+	COALESCE(to_char(time_from, 'HH24:MI'), ''),
+	COALESCE(to_char(time_to, 'HH24:MI'), ''),
+	weekdays,
+	created_at,
+	updated_at
+FROM dial_plan_rules
+`
+
+type Rule struct {
+	Id          int       `json:"id"`
+	Name        string    `json:"name" validate:"required,max=128"`
+	Priority    int       `json:"priority"`
+	Enabled     bool      `json:"enabled"`
+	MatchType   MatchType `json:"match_type" validate:"required,oneof=prefix regex"`
+	Pattern     string    `json:"pattern" validate:"required,max=255"`
+	Action      Action    `json:"action" validate:"required,oneof=local_user group trunk reject"`
+	Target      string    `json:"target,omitempty" validate:"max=255"`
+	RejectCode  int       `json:"reject_code,omitempty" validate:"required_if=Action reject,omitempty,min=400,max=699"`
+	StripDigits int       `json:"strip_digits" validate:"min=0"`
+	Prepend     string    `json:"prepend" validate:"max=32"`
+	TimeFrom    string    `json:"time_from,omitempty" validate:"required_with=TimeTo,omitempty,datetime=15:04"`
+	TimeTo      string    `json:"time_to,omitempty" validate:"required_with=TimeFrom,omitempty,datetime=15:04"`
+	Weekdays    []int64   `json:"weekdays,omitempty" validate:"omitempty,dive,min=0,max=6"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func NewRule() *Rule {
+	return &Rule{
+		Priority:  100,
+		Enabled:   true,
+		MatchType: MatchPrefix,
+	}
+}
+
+type DialPlanRepo struct {
+	DB *sql.DB
+}
+
+func NewDialPlanRepo(db *sql.DB) *DialPlanRepo {
+	return &DialPlanRepo{DB: db}
+}
+
+func (r *DialPlanRepo) List() ([]*Rule, error) {
+	return r.query(context.Background(), queryRule+" ORDER BY priority, id")
+}
+
+// ListEnabled возвращает включённые правила в порядке применения.
+func (r *DialPlanRepo) ListEnabled(ctx context.Context) ([]*Rule, error) {
+	return r.query(ctx, queryRule+" WHERE enabled ORDER BY priority, id")
+}
+
+func (r *DialPlanRepo) FindByID(id string) (*Rule, error) {
+	rules, err := r.query(context.Background(), queryRule+" WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, ErrRuleNotFound
+	}
+	return rules[0], nil
+}
+
+func (r *DialPlanRepo) Create(rule *Rule) (*Rule, error) {
+	const q = `
+		INSERT INTO dial_plan_rules (
+			name, priority, enabled, match_type, pattern,
+			action, target, reject_code, strip_digits, prepend,
+			time_from, time_to, weekdays
+		)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11::time,$12::time,$13)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.DB.QueryRow(q, r.args(rule)...).Scan(&rule.Id, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *DialPlanRepo) Update(id string, rule *Rule) (*Rule, error) {
+	const q = `
+		UPDATE dial_plan_rules
+		SET
+			name         = $1,
+			priority     = $2,
+			enabled      = $3,
+			match_type   = $4,
+			pattern      = $5,
+			action       = $6,
+			target       = $7,
+			reject_code  = $8,
+			strip_digits = $9,
+			prepend      = $10,
+			time_from    = $11::time,
+			time_to      = $12::time,
+			weekdays     = $13
+		WHERE id = $14
+		RETURNING id, created_at, updated_at
+	`
+
+	args := append(r.args(rule), id)
+	err := r.DB.QueryRow(q, args...).Scan(&rule.Id, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRuleNotFound
+		}
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *DialPlanRepo) Delete(id string) error {
+	res, err := r.DB.Exec("DELETE FROM dial_plan_rules WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+func (r *DialPlanRepo) args(rule *Rule) []any {
+	var rejectCode any
+	if rule.RejectCode != 0 {
+		rejectCode = rule.RejectCode
+	}
+
+	var weekdays any
+	if len(rule.Weekdays) > 0 {
+		weekdays = pq.Array(rule.Weekdays)
+	}
+
+	return []any{
+		rule.Name,
+		rule.Priority,
+		rule.Enabled,
+		rule.MatchType,
+		rule.Pattern,
+		rule.Action,
+		repository.NullIfEmpty(rule.Target),
+		rejectCode,
+		rule.StripDigits,
+		rule.Prepend,
+		repository.NullIfEmpty(rule.TimeFrom),
+		repository.NullIfEmpty(rule.TimeTo),
+		weekdays,
+	}
+}
+
+func (r *DialPlanRepo) query(ctx context.Context, query string, args ...any) ([]*Rule, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]*Rule, 0)
+	for rows.Next() {
+		rule := NewRule()
+		var weekdays pq.Int64Array
+
+		err := rows.Scan(
+			&rule.Id,
+			&rule.Name,
+			&rule.Priority,
+			&rule.Enabled,
+			&rule.MatchType,
+			&rule.Pattern,
+			&rule.Action,
+			&rule.Target,
+			&rule.RejectCode,
+			&rule.StripDigits,
+			&rule.Prepend,
+			&rule.TimeFrom,
+			&rule.TimeTo,
+			&weekdays,
+			&rule.CreatedAt,
+			&rule.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		rule.Weekdays = weekdays
+
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
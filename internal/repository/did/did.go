@@ -0,0 +1,192 @@
+package did
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"SipServer/internal/repository"
+)
+
+type DestinationType string
+
+const (
+	DestinationUser  DestinationType = "user"
+	DestinationGroup DestinationType = "group"
+	DestinationIVR   DestinationType = "ivr"
+)
+
+var ErrDIDNotFound = errors.New("did not found")
+
+const queryDID string = `
+SELECT
+	id,
+	number,
+	trunk_id,
+	enabled,
+	destination_type,
+	destination,
+	COALESCE(description, ''),
+	created_at,
+	updated_at
+FROM dids
+`
+
+type DID struct {
+	Id              int             `json:"id"`
+	Number          string          `json:"number" validate:"required,max=32"`
+	TrunkId         *int            `json:"trunk_id,omitempty"`
+	Enabled         bool            `json:"enabled"`
+	DestinationType DestinationType `json:"destination_type" validate:"required,oneof=user group ivr"`
+	Destination     string          `json:"destination" validate:"required,max=64"`
+	Description     string          `json:"description,omitempty" validate:"max=255"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+func NewDID() *DID {
+	return &DID{
+		Enabled:         true,
+		DestinationType: DestinationUser,
+	}
+}
+
+type DIDRepo struct {
+	DB *sql.DB
+}
+
+func NewDIDRepo(db *sql.DB) *DIDRepo {
+	return &DIDRepo{DB: db}
+}
+
+func (r *DIDRepo) List() ([]*DID, error) {
+	return r.query(context.Background(), queryDID+" ORDER BY number")
+}
+
+func (r *DIDRepo) FindByID(id string) (*DID, error) {
+	return r.findOne(context.Background(), queryDID+" WHERE id = $1", id)
+}
+
+// FindInbound ищет включённый DID для звонка с транка trunkID.
+// Номер сравнивается как есть и без ведущего '+'.
+func (r *DIDRepo) FindInbound(ctx context.Context, number string, trunkID int) (*DID, error) {
+	return r.findOne(ctx, queryDID+`
+		WHERE enabled
+		  AND (number = $1 OR ltrim(number, '+') = ltrim($1, '+'))
+		  AND (trunk_id IS NULL OR trunk_id = $2)
+		ORDER BY (number = $1) DESC, trunk_id NULLS LAST
+		LIMIT 1`, number, trunkID)
+}
+
+func (r *DIDRepo) Create(d *DID) (*DID, error) {
+	const q = `
+		INSERT INTO dids (
+			number, trunk_id, enabled, destination_type, destination, description
+		)
+		VALUES ($1,$2,$3,$4,$5,$6)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.DB.QueryRow(q, r.args(d)...).Scan(&d.Id, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (r *DIDRepo) Update(id string, d *DID) (*DID, error) {
+	const q = `
+		UPDATE dids
+		SET
+			number           = $1,
+			trunk_id         = $2,
+			enabled          = $3,
+			destination_type = $4,
+			destination      = $5,
+			description      = $6
+		WHERE id = $7
+		RETURNING id, created_at, updated_at
+	`
+
+	args := append(r.args(d), id)
+	err := r.DB.QueryRow(q, args...).Scan(&d.Id, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDIDNotFound
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+func (r *DIDRepo) Delete(id string) error {
+	res, err := r.DB.Exec("DELETE FROM dids WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDIDNotFound
+	}
+	return nil
+}
+
+func (r *DIDRepo) args(d *DID) []any {
+	return []any{
+		d.Number,
+		d.TrunkId,
+		d.Enabled,
+		d.DestinationType,
+		d.Destination,
+		repository.NullIfEmpty(d.Description),
+	}
+}
+
+func (r *DIDRepo) findOne(ctx context.Context, query string, args ...any) (*DID, error) {
+	dids, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(dids) == 0 {
+		return nil, ErrDIDNotFound
+	}
+	return dids[0], nil
+}
+
+func (r *DIDRepo) query(ctx context.Context, query string, args ...any) ([]*DID, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dids := make([]*DID, 0)
+	for rows.Next() {
+		d := NewDID()
+		err := rows.Scan(
+			&d.Id,
+			&d.Number,
+			&d.TrunkId,
+			&d.Enabled,
+			&d.DestinationType,
+			&d.Destination,
+			&d.Description,
+			&d.CreatedAt,
+			&d.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		dids = append(dids, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return dids, nil
+}
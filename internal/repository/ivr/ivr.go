@@ -0,0 +1,227 @@
+package ivr
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"SipServer/internal/repository"
+)
+
+type Action string
+
+const (
+	ActionExtension Action = "extension"
+	ActionGroup     Action = "group"
+	ActionVoicemail Action = "voicemail"
+	ActionMenu      Action = "menu"
+	ActionHangup    Action = "hangup"
+)
+
+// DigitTimeout — пункт, куда уходит звонок, когда попытки ввода кончились.
+const DigitTimeout = "t"
+
+var ErrMenuNotFound = errors.New("ivr menu not found")
+
+const queryMenu string = `
+SELECT
+	id,
+	name,
+	extension,
+	enabled,
+	COALESCE(prompt, ''),
+	digit_timeout,
+	max_retries,
+	options,
+	created_at,
+	updated_at
+FROM ivr_menus
+`
+
+type Option struct {
+	Digit  string `json:"digit" validate:"required,oneof=0 1 2 3 4 5 6 7 8 9 * # t"`
+	Action Action `json:"action" validate:"required,oneof=extension group voicemail menu hangup"`
+	Target string `json:"target,omitempty" validate:"required_unless=Action hangup,max=64"`
+}
+
+type Menu struct {
+	Id           int       `json:"id"`
+	Name         string    `json:"name" validate:"required,max=128"`
+	Extension    string    `json:"extension" validate:"required,max=32"`
+	Enabled      bool      `json:"enabled"`
+	Prompt       string    `json:"prompt,omitempty" validate:"max=255"`
+	DigitTimeout int       `json:"digit_timeout" validate:"min=1,max=60"`
+	MaxRetries   int       `json:"max_retries" validate:"min=1,max=10"`
+	Options      []*Option `json:"options" validate:"dive"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func NewMenu() *Menu {
+	return &Menu{
+		Enabled:      true,
+		DigitTimeout: 5,
+		MaxRetries:   3,
+		Options:      make([]*Option, 0),
+	}
+}
+
+// Option возвращает пункт меню для digit, nil — такого нет.
+func (m *Menu) Option(digit string) *Option {
+	for _, o := range m.Options {
+		if o.Digit == digit {
+			return o
+		}
+	}
+	return nil
+}
+
+type IVRRepo struct {
+	DB *sql.DB
+}
+
+func NewIVRRepo(db *sql.DB) *IVRRepo {
+	return &IVRRepo{DB: db}
+}
+
+func (r *IVRRepo) List() ([]*Menu, error) {
+	return r.query(context.Background(), queryMenu+" ORDER BY extension")
+}
+
+func (r *IVRRepo) FindByID(id string) (*Menu, error) {
+	return r.findOne(context.Background(), queryMenu+" WHERE id = $1", id)
+}
+
+// FindByExtension — только включённые меню, для маршрутизации.
+func (r *IVRRepo) FindByExtension(ctx context.Context, extension string) (*Menu, error) {
+	return r.findOne(ctx, queryMenu+" WHERE extension = $1 AND enabled", extension)
+}
+
+func (r *IVRRepo) Create(m *Menu) (*Menu, error) {
+	const q = `
+		INSERT INTO ivr_menus (
+			name, extension, enabled, prompt, digit_timeout, max_retries, options
+		)
+		VALUES ($1,$2,$3,$4,$5,$6,$7)
+		RETURNING id, created_at, updated_at
+	`
+	args, err := r.args(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.DB.QueryRow(q, args...).Scan(&m.Id, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *IVRRepo) Update(id string, m *Menu) (*Menu, error) {
+	const q = `
+		UPDATE ivr_menus
+		SET
+			name          = $1,
+			extension     = $2,
+			enabled       = $3,
+			prompt        = $4,
+			digit_timeout = $5,
+			max_retries   = $6,
+			options       = $7
+		WHERE id = $8
+		RETURNING id, created_at, updated_at
+	`
+	args, err := r.args(m)
+	if err != nil {
+		return nil, err
+	}
+	err = r.DB.QueryRow(q, append(args, id)...).Scan(&m.Id, &m.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMenuNotFound
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *IVRRepo) Delete(id string) error {
+	res, err := r.DB.Exec("DELETE FROM ivr_menus WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrMenuNotFound
+	}
+	return nil
+}
+
+func (r *IVRRepo) args(m *Menu) ([]any, error) {
+	options, err := json.Marshal(m.Options)
+	if err != nil {
+		return nil, err
+	}
+	return []any{
+		m.Name,
+		m.Extension,
+		m.Enabled,
+		repository.NullIfEmpty(m.Prompt),
+		m.DigitTimeout,
+		m.MaxRetries,
+		options,
+	}, nil
+}
+
+func (r *IVRRepo) findOne(ctx context.Context, query string, args ...any) (*Menu, error) {
+	menus, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(menus) == 0 {
+		return nil, ErrMenuNotFound
+	}
+	return menus[0], nil
+}
+
+func (r *IVRRepo) query(ctx context.Context, query string, args ...any) ([]*Menu, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	menus := make([]*Menu, 0)
+	for rows.Next() {
+		m := NewMenu()
+		var options []byte
+		err := rows.Scan(
+			&m.Id,
+			&m.Name,
+			&m.Extension,
+			&m.Enabled,
+			&m.Prompt,
+			&m.DigitTimeout,
+			&m.MaxRetries,
+			&options,
+			&m.CreatedAt,
+			&m.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(options, &m.Options); err != nil {
+			return nil, err
+		}
+		menus = append(menus, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return menus, nil
+}
@@ -0,0 +1,114 @@
+package message
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+const queryMessage string = `
+SELECT
+	id,
+	from_user,
+	to_user,
+	content_type,
+	body,
+	status,
+	final_code,
+	attempts,
+	created_at,
+	delivered_at
+FROM sip_messages
+`
+
+type Message struct {
+	Id          int64      `json:"id"`
+	FromUser    string     `json:"from_user"`
+	ToUser      string     `json:"to_user"`
+	ContentType string     `json:"content_type"`
+	Body        string     `json:"body"`
+	Status      string     `json:"status"`
+	FinalCode   *int       `json:"final_code,omitempty"`
+	Attempts    int        `json:"attempts"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+type MessageRepo struct {
+	DB *sql.DB
+}
+
+func NewMessageRepo(db *sql.DB) *MessageRepo {
+	return &MessageRepo{DB: db}
+}
+
+// ListByUser — переписка пользователя (входящие и исходящие), новые сверху.
+// peer — только переписка с этим login, пусто — со всеми.
+func (r *MessageRepo) ListByUser(login, peer string, limit int) ([]*Message, error) {
+	if peer == "" {
+		return r.query(context.Background(),
+			queryMessage+" WHERE from_user = $1 OR to_user = $1 ORDER BY created_at DESC LIMIT $2",
+			login, limit)
+	}
+	return r.query(context.Background(),
+		queryMessage+` WHERE (from_user = $1 AND to_user = $2) OR (from_user = $2 AND to_user = $1)
+			ORDER BY created_at DESC LIMIT $3`,
+		login, peer, limit)
+}
+
+// Pending — недоставленные сообщения получателю, старые первыми.
+func (r *MessageRepo) Pending(ctx context.Context, toUser string) ([]*Message, error) {
+	return r.query(ctx, queryMessage+" WHERE to_user = $1 AND status = 'pending' ORDER BY created_at", toUser)
+}
+
+func (r *MessageRepo) Create(ctx context.Context, m *Message) (*Message, error) {
+	const q = `
+		INSERT INTO sip_messages (from_user, to_user, content_type, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, status, attempts, created_at`
+	err := r.DB.QueryRowContext(ctx, q, m.FromUser, m.ToUser, m.ContentType, m.Body).
+		Scan(&m.Id, &m.Status, &m.Attempts, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SetResult записывает попытку доставки: code — ответ получателя, 0 — ответа нет.
+func (r *MessageRepo) SetResult(ctx context.Context, id int64, status string, code int) error {
+	const q = `
+		UPDATE sip_messages
+		SET
+			status       = $2,
+			final_code   = NULLIF($3, 0),
+			attempts     = attempts + 1,
+			delivered_at = CASE WHEN $2 = 'delivered' THEN now() ELSE delivered_at END
+		WHERE id = $1`
+	_, err := r.DB.ExecContext(ctx, q, id, status, code)
+	return err
+}
+
+func (r *MessageRepo) query(ctx context.Context, q string, args ...any) ([]*Message, error) {
+	rows, err := r.DB.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*Message, 0)
+	for rows.Next() {
+		m := &Message{}
+		if err := rows.Scan(&m.Id, &m.FromUser, &m.ToUser, &m.ContentType, &m.Body, &m.Status,
+			&m.FinalCode, &m.Attempts, &m.CreatedAt, &m.DeliveredAt); err != nil {
+			return nil, err
+		}
+		items = append(items, m)
+	}
+	return items, rows.Err()
+}
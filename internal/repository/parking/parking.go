@@ -0,0 +1,75 @@
+package parking
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"SipServer/internal/repository"
+)
+
+type ParkedCall struct {
+	Slot       string    `json:"slot"`
+	JournalID  *int64    `json:"journal_id,omitempty"`
+	CallID     string    `json:"call_id"`
+	CallerUser string    `json:"caller_user"`
+	ParkedBy   string    `json:"parked_by,omitempty"`
+	ParkedAt   time.Time `json:"parked_at"`
+	TimeoutAt  time.Time `json:"timeout_at"`
+}
+
+type ParkingRepo struct {
+	DB *sql.DB
+}
+
+func NewParkingRepo(db *sql.DB) *ParkingRepo {
+	return &ParkingRepo{DB: db}
+}
+
+func (r *ParkingRepo) List() ([]*ParkedCall, error) {
+	rows, err := r.DB.Query(`
+		SELECT slot, journal_id, call_id, caller_user, COALESCE(parked_by, ''), parked_at, timeout_at
+		FROM parked_calls
+		ORDER BY slot`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*ParkedCall, 0)
+	for rows.Next() {
+		p := &ParkedCall{}
+		if err := rows.Scan(&p.Slot, &p.JournalID, &p.CallID, &p.CallerUser, &p.ParkedBy, &p.ParkedAt, &p.TimeoutAt); err != nil {
+			return nil, err
+		}
+		items = append(items, p)
+	}
+	return items, rows.Err()
+}
+
+func (r *ParkingRepo) Park(ctx context.Context, p *ParkedCall) error {
+	const q = `
+		INSERT INTO parked_calls (slot, journal_id, call_id, caller_user, parked_by, parked_at, timeout_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (slot) DO UPDATE SET
+			journal_id  = EXCLUDED.journal_id,
+			call_id     = EXCLUDED.call_id,
+			caller_user = EXCLUDED.caller_user,
+			parked_by   = EXCLUDED.parked_by,
+			parked_at   = EXCLUDED.parked_at,
+			timeout_at  = EXCLUDED.timeout_at`
+	_, err := r.DB.ExecContext(ctx, q, p.Slot, p.JournalID, p.CallID, p.CallerUser,
+		repository.NullIfEmpty(p.ParkedBy), p.ParkedAt, p.TimeoutAt)
+	return err
+}
+
+func (r *ParkingRepo) Unpark(ctx context.Context, slot string) error {
+	_, err := r.DB.ExecContext(ctx, `DELETE FROM parked_calls WHERE slot = $1`, slot)
+	return err
+}
+
+// Clear — после рестарта сервера припаркованных звонков уже нет.
+func (r *ParkingRepo) Clear(ctx context.Context) error {
+	_, err := r.DB.ExecContext(ctx, `DELETE FROM parked_calls`)
+	return err
+}
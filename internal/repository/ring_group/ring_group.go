@@ -0,0 +1,344 @@
+package ringgroup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"SipServer/internal/repository"
+
+	"github.com/lib/pq"
+)
+
+type Strategy string
+
+const (
+	StrategyRingAll     Strategy = "ringall"
+	StrategyLinear      Strategy = "linear"
+	StrategyRoundRobin  Strategy = "roundrobin"
+	StrategyLeastRecent Strategy = "leastrecent"
+)
+
+type OverflowType string
+
+const (
+	OverflowNone  OverflowType = "none"
+	OverflowUser  OverflowType = "user"
+	OverflowGroup OverflowType = "group"
+	OverflowTrunk OverflowType = "trunk"
+)
+
+var (
+	ErrGroupNotFound  = errors.New("ring group not found")
+	ErrMemberNotFound = errors.New("ring group member not found")
+)
+
+const queryGroup string = `
+SELECT
+	id,
+	name,
+	extension,
+	enabled,
+	strategy,
+	member_timeout,
+	overflow_type,
+	COALESCE(overflow_target, ''),
+	rr_position,
+	created_at,
+	updated_at
+FROM ring_groups
+`
+
+type Member struct {
+	Login      string     `json:"login" validate:"required"`
+	Position   int        `json:"position"`
+	LastCallAt *time.Time `json:"last_call_at,omitempty"`
+}
+
+type Group struct {
+	Id             int          `json:"id"`
+	Name           string       `json:"name" validate:"required,max=128"`
+	Extension      string       `json:"extension" validate:"required,max=32"`
+	Enabled        bool         `json:"enabled"`
+	Strategy       Strategy     `json:"strategy" validate:"required,oneof=ringall linear roundrobin leastrecent"`
+	MemberTimeout  int          `json:"member_timeout" validate:"min=5,max=300"`
+	OverflowType   OverflowType `json:"overflow_type" validate:"required,oneof=none user group trunk"`
+	OverflowTarget string       `json:"overflow_target,omitempty" validate:"required_unless=OverflowType none,max=255"`
+	RRPosition     int          `json:"rr_position"`
+	Members        []*Member    `json:"members" validate:"dive"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+func NewGroup() *Group {
+	return &Group{
+		Enabled:       true,
+		Strategy:      StrategyRingAll,
+		MemberTimeout: 20,
+		OverflowType:  OverflowNone,
+		Members:       make([]*Member, 0),
+	}
+}
+
+type RingGroupRepo struct {
+	DB *sql.DB
+}
+
+func NewRingGroupRepo(db *sql.DB) *RingGroupRepo {
+	return &RingGroupRepo{DB: db}
+}
+
+func (r *RingGroupRepo) List() ([]*Group, error) {
+	ctx := context.Background()
+
+	groups, err := r.query(ctx, queryGroup+" ORDER BY extension")
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		if g.Members, err = r.members(ctx, g.Id); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+func (r *RingGroupRepo) FindByID(id string) (*Group, error) {
+	return r.findOne(context.Background(), queryGroup+" WHERE id = $1", id)
+}
+
+// FindByExtension — только включённые группы, для маршрутизации.
+func (r *RingGroupRepo) FindByExtension(ctx context.Context, extension string) (*Group, error) {
+	return r.findOne(ctx, queryGroup+" WHERE extension = $1 AND enabled", extension)
+}
+
+func (r *RingGroupRepo) Create(g *Group) (*Group, error) {
+	ctx := context.Background()
+	tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	const q = `
+		INSERT INTO ring_groups (
+			name, extension, enabled, strategy, member_timeout,
+			overflow_type, overflow_target
+		)
+		VALUES ($1,$2,$3,$4,$5,$6,$7)
+		RETURNING id, created_at, updated_at
+	`
+	if err := tx.QueryRowContext(ctx, q, r.args(g)...).Scan(&g.Id, &g.CreatedAt, &g.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := r.replaceMembers(ctx, tx, g); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (r *RingGroupRepo) Update(id string, g *Group) (*Group, error) {
+	ctx := context.Background()
+	tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	const q = `
+		UPDATE ring_groups
+		SET
+			name            = $1,
+			extension       = $2,
+			enabled         = $3,
+			strategy        = $4,
+			member_timeout  = $5,
+			overflow_type   = $6,
+			overflow_target = $7
+		WHERE id = $8
+		RETURNING id, rr_position, created_at, updated_at
+	`
+	args := append(r.args(g), id)
+	err = tx.QueryRowContext(ctx, q, args...).Scan(&g.Id, &g.RRPosition, &g.CreatedAt, &g.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrGroupNotFound
+		}
+		return nil, err
+	}
+
+	if err := r.replaceMembers(ctx, tx, g); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (r *RingGroupRepo) Delete(id string) error {
+	res, err := r.DB.Exec("DELETE FROM ring_groups WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrGroupNotFound
+	}
+	return nil
+}
+
+// SetRRPosition сохраняет, с кого начинать следующий roundrobin.
+func (r *RingGroupRepo) SetRRPosition(ctx context.Context, groupID int, position int) error {
+	_, err := r.DB.ExecContext(ctx, "UPDATE ring_groups SET rr_position = $2 WHERE id = $1", groupID, position)
+	return err
+}
+
+// TouchMember отмечает, что участник ответил на звонок группы.
+func (r *RingGroupRepo) TouchMember(ctx context.Context, groupID int, login string, at time.Time) error {
+	const q = `
+		UPDATE ring_group_members m
+		SET last_call_at = $3
+		FROM users u
+		WHERE u.id = m.user_id AND m.group_id = $1 AND u.login = $2
+	`
+	_, err := r.DB.ExecContext(ctx, q, groupID, login, at)
+	return err
+}
+
+func (r *RingGroupRepo) replaceMembers(ctx context.Context, tx *sql.Tx, g *Group) error {
+	// last_call_at переживает редактирование состава
+	const qDelete = `
+		DELETE FROM ring_group_members m
+		USING users u
+		WHERE u.id = m.user_id AND m.group_id = $1 AND u.login <> ALL($2)
+	`
+	logins := make([]string, 0, len(g.Members))
+	for _, m := range g.Members {
+		logins = append(logins, m.Login)
+	}
+	if _, err := tx.ExecContext(ctx, qDelete, g.Id, pq.Array(logins)); err != nil {
+		return err
+	}
+
+	const qUpsert = `
+		INSERT INTO ring_group_members (group_id, user_id, position)
+		SELECT $1, u.id, $3 FROM users u WHERE u.login = $2
+		ON CONFLICT (group_id, user_id) DO UPDATE SET position = EXCLUDED.position
+	`
+	for i, m := range g.Members {
+		m.Position = i
+		res, err := tx.ExecContext(ctx, qUpsert, g.Id, m.Login, i)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("%w: %s", ErrMemberNotFound, m.Login)
+		}
+	}
+	return nil
+}
+
+func (r *RingGroupRepo) members(ctx context.Context, groupID int) ([]*Member, error) {
+	const q = `
+		SELECT u.login, m.position, m.last_call_at
+		FROM ring_group_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.group_id = $1
+		ORDER BY m.position, m.id
+	`
+	rows, err := r.DB.QueryContext(ctx, q, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]*Member, 0)
+	for rows.Next() {
+		m := &Member{}
+		var last sql.NullTime
+		if err := rows.Scan(&m.Login, &m.Position, &last); err != nil {
+			return nil, err
+		}
+		if last.Valid {
+			t := last.Time
+			m.LastCallAt = &t
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (r *RingGroupRepo) args(g *Group) []any {
+	return []any{
+		g.Name,
+		g.Extension,
+		g.Enabled,
+		g.Strategy,
+		g.MemberTimeout,
+		g.OverflowType,
+		repository.NullIfEmpty(g.OverflowTarget),
+	}
+}
+
+func (r *RingGroupRepo) findOne(ctx context.Context, query string, args ...any) (*Group, error) {
+	groups, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, ErrGroupNotFound
+	}
+	g := groups[0]
+	if g.Members, err = r.members(ctx, g.Id); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (r *RingGroupRepo) query(ctx context.Context, query string, args ...any) ([]*Group, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make([]*Group, 0)
+	for rows.Next() {
+		g := NewGroup()
+		err := rows.Scan(
+			&g.Id,
+			&g.Name,
+			&g.Extension,
+			&g.Enabled,
+			&g.Strategy,
+			&g.MemberTimeout,
+			&g.OverflowType,
+			&g.OverflowTarget,
+			&g.RRPosition,
+			&g.CreatedAt,
+			&g.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
@@ -29,6 +29,8 @@ type Session struct {
 	EndedBy       *repository.CallEndedBy `json:"ended_by,omitempty"`
 	TermCode      *int                    `json:"term_code,omitempty"`
 	TermReason    *string                 `json:"term_reason,omitempty"`
+	HoldCount     int                     `json:"hold_count"`
+	HoldMs        int                     `json:"hold_ms"`
 	UpdatedAt     time.Time               `json:"updated_at"`
 }
 
@@ -61,6 +63,8 @@ SELECT
 	ended_by,
 	term_code,
 	term_reason,
+	hold_count,
+	hold_ms,
 	updated_at
 FROM call_sessions
 `
@@ -94,6 +98,8 @@ FROM call_sessions
 			&s.EndedBy,
 			&s.TermCode,
 			&s.TermReason,
+			&s.HoldCount,
+			&s.HoldMs,
 			&s.UpdatedAt,
 		); err != nil {
 			return nil, err
@@ -0,0 +1,244 @@
+package trunk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"SipServer/internal/repository"
+
+	"github.com/lib/pq"
+)
+
+var ErrTrunkNotFound = errors.New("trunk not found")
+
+const queryTrunk string = `
+SELECT
+	id,
+	name,
+	enabled,
+	host,
+	port,
+	transport,
+	COALESCE(username, ''),
+	COALESCE(password, ''),
+	COALESCE(from_user, ''),
+	register,
+	register_expires,
+	max_channels,
+	topology_hiding,
+	header_allow,
+	header_deny,
+	created_at,
+	updated_at
+FROM trunks
+`
+
+type Trunk struct {
+	Id              int       `json:"id"`
+	Name            string    `json:"name" validate:"required,max=64"`
+	Enabled         bool      `json:"enabled"`
+	Host            string    `json:"host" validate:"required,hostname_rfc1123|ip"`
+	Port            int       `json:"port" validate:"min=1,max=65535"`
+	Transport       string    `json:"transport" validate:"required,oneof=udp tcp"`
+	Username        string    `json:"username,omitempty" validate:"required_if=Register true,max=128"`
+	Password        string    `json:"password,omitempty" validate:"max=128"`
+	FromUser        string    `json:"from_user,omitempty" validate:"max=64"`
+	Register        bool      `json:"register"`
+	RegisterExpires int       `json:"register_expires" validate:"min=60,max=86400"`
+	MaxChannels     int       `json:"max_channels" validate:"min=0"`
+	TopologyHiding  bool      `json:"topology_hiding"` // не показывать транку адреса нашей сети
+	HeaderAllow     []string  `json:"header_allow" validate:"dive,required,max=64"`
+	HeaderDeny      []string  `json:"header_deny" validate:"dive,required,max=64"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func NewTrunk() *Trunk {
+	return &Trunk{
+		Enabled:         true,
+		Port:            5060,
+		Transport:       "udp",
+		RegisterExpires: 300,
+		HeaderAllow:     []string{},
+		HeaderDeny:      []string{},
+	}
+}
+
+type TrunkRepo struct {
+	DB *sql.DB
+}
+
+func NewTrunkRepo(db *sql.DB) *TrunkRepo {
+	return &TrunkRepo{DB: db}
+}
+
+func (r *TrunkRepo) List() ([]*Trunk, error) {
+	return r.query(context.Background(), queryTrunk+" ORDER BY id")
+}
+
+func (r *TrunkRepo) ListEnabled(ctx context.Context) ([]*Trunk, error) {
+	return r.query(ctx, queryTrunk+" WHERE enabled ORDER BY id")
+}
+
+// ListRegistering — включённые транки, которым нужна исходящая регистрация.
+func (r *TrunkRepo) ListRegistering(ctx context.Context) ([]*Trunk, error) {
+	return r.query(ctx, queryTrunk+" WHERE enabled AND register ORDER BY id")
+}
+
+func (r *TrunkRepo) FindByID(id string) (*Trunk, error) {
+	return r.findOne(context.Background(), queryTrunk+" WHERE id = $1", id)
+}
+
+func (r *TrunkRepo) FindByName(ctx context.Context, name string) (*Trunk, error) {
+	return r.findOne(ctx, queryTrunk+" WHERE name = $1", name)
+}
+
+func (r *TrunkRepo) Create(t *Trunk) (*Trunk, error) {
+	const q = `
+		INSERT INTO trunks (
+			name, enabled, host, port, transport,
+			username, password, from_user,
+			register, register_expires, max_channels,
+			topology_hiding, header_allow, header_deny
+		)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.DB.QueryRow(q, r.args(t)...).Scan(&t.Id, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Update перезаписывает транк целиком. Пустой пароль оставляет прежний.
+func (r *TrunkRepo) Update(id string, t *Trunk) (*Trunk, error) {
+	const q = `
+		UPDATE trunks
+		SET
+			name             = $1,
+			enabled          = $2,
+			host             = $3,
+			port             = $4,
+			transport        = $5,
+			username         = $6,
+			password         = COALESCE($7, password),
+			from_user        = $8,
+			register         = $9,
+			register_expires = $10,
+			max_channels     = $11,
+			topology_hiding  = $12,
+			header_allow     = $13,
+			header_deny      = $14
+		WHERE id = $15
+		RETURNING id, created_at, updated_at
+	`
+
+	args := append(r.args(t), id)
+	err := r.DB.QueryRow(q, args...).Scan(&t.Id, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTrunkNotFound
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *TrunkRepo) Delete(id string) error {
+	res, err := r.DB.Exec("DELETE FROM trunks WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTrunkNotFound
+	}
+	return nil
+}
+
+func (r *TrunkRepo) args(t *Trunk) []any {
+	return []any{
+		t.Name,
+		t.Enabled,
+		t.Host,
+		t.Port,
+		t.Transport,
+		repository.NullIfEmpty(t.Username),
+		repository.NullIfEmpty(t.Password),
+		repository.NullIfEmpty(t.FromUser),
+		t.Register,
+		t.RegisterExpires,
+		t.MaxChannels,
+		t.TopologyHiding,
+		pq.Array(nonNil(t.HeaderAllow)),
+		pq.Array(nonNil(t.HeaderDeny)),
+	}
+}
+
+// nonNil — пустой список вместо NULL в TEXT[] NOT NULL.
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+func (r *TrunkRepo) findOne(ctx context.Context, query string, args ...any) (*Trunk, error) {
+	trunks, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(trunks) == 0 {
+		return nil, ErrTrunkNotFound
+	}
+	return trunks[0], nil
+}
+
+func (r *TrunkRepo) query(ctx context.Context, query string, args ...any) ([]*Trunk, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trunks := make([]*Trunk, 0)
+	for rows.Next() {
+		t := NewTrunk()
+		err := rows.Scan(
+			&t.Id,
+			&t.Name,
+			&t.Enabled,
+			&t.Host,
+			&t.Port,
+			&t.Transport,
+			&t.Username,
+			&t.Password,
+			&t.FromUser,
+			&t.Register,
+			&t.RegisterExpires,
+			&t.MaxChannels,
+			&t.TopologyHiding,
+			pq.Array(&t.HeaderAllow),
+			pq.Array(&t.HeaderDeny),
+			&t.CreatedAt,
+			&t.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		trunks = append(trunks, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return trunks, nil
+}
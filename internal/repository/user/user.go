@@ -7,13 +7,30 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+
+	"SipServer/internal/repository"
+
+	"github.com/lib/pq"
 )
 
 var ErrNoFieldsToUpdate = errors.New("no fields to update")
 
+var (
+	// алиас совпадает с логином или алиасом другого пользователя
+	ErrAliasTaken = errors.New("alias is already used")
+	// логин совпадает с чьим-то алиасом
+	ErrLoginTaken = errors.New("login is already used as an alias")
+)
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
 const (
-	queryUserWithConfig string = "SELECT u.id, u.login, u.role, uc.call_schema FROM users u LEFT JOIN user_configs uc ON uc.user_id = u.id"
+	queryUserWithConfig string = "SELECT u.id, u.login, u.role, u.enabled, COALESCE(u.sip_ha1, ''), u.display_name, u.caller_id_number, u.caller_id_name, u.email, ARRAY(SELECT a.alias FROM user_aliases a WHERE a.user_id = u.id ORDER BY a.alias), uc.call_schema, COALESCE(uc.pickup_group, ''), COALESCE(uc.record_calls, FALSE), COALESCE(uc.codecs, '{}'), COALESCE(uc.strip_video, FALSE), COALESCE(uc.force_pcma, FALSE), COALESCE(uc.max_outbound_calls, 0), COALESCE(uc.max_inbound_calls, 0), COALESCE(uc.max_calls, 0) FROM users u LEFT JOIN user_configs uc ON uc.user_id = u.id"
 )
 
 var ErrUserNotFound = errors.New("user not found")
@@ -26,28 +43,78 @@ type User struct {
 	Id           int         `json:"id"`
 	Login        string      `json:"login" validate:"required,min=4,max=64"`
 	Role         string      `json:"role" validate:"required,oneof=admin user"`
+	Enabled      bool        `json:"enabled"`
+	Password     string      `json:"password,omitempty" validate:"omitempty,min=8,max=72"` // только на вход
 	PasswordHash string      `json:"-"`
+	SIPHA1       string      `json:"-"` // пусто — SIP без digest
 	Config       *UserConfig `json:"config" validate:"required"`
+
+	// профиль: имя в звонках и номер для транков вместо того, что прислал телефон
+	DisplayName    string   `json:"display_name" validate:"max=128"`
+	CallerIDNumber string   `json:"caller_id_number" validate:"max=32"`
+	CallerIDName   string   `json:"caller_id_name" validate:"max=128"`
+	Email          string   `json:"email" validate:"omitempty,email,max=254"`
+	Aliases        []string `json:"aliases" validate:"unique,dive,required,max=64"` // другие номера пользователя
 }
 
 type UpdateUserRequest struct {
 	Id           int                      `json:"id"`
-	Login        string                   `json:"login" validate:"min=4,max=64"`
-	Role         string                   `json:"role" validate:"oneof=admin user"`
+	Login        string                   `json:"login" validate:"omitempty,min=4,max=64"`
+	Role         string                   `json:"role" validate:"omitempty,oneof=admin user"`
+	Enabled      *bool                    `json:"enabled"`
 	PasswordHash string                   `json:"-"`
 	Config       *UpdateUserConfigRequest `json:"config"`
+
+	DisplayName    *string  `json:"display_name" validate:"omitempty,max=128"`
+	CallerIDNumber *string  `json:"caller_id_number" validate:"omitempty,max=32"`
+	CallerIDName   *string  `json:"caller_id_name" validate:"omitempty,max=128"`
+	Email          *string  `json:"email" validate:"omitnil,max=254,len=0|email"`
+	Aliases        []string `json:"aliases" validate:"omitempty,unique,dive,required,max=64"` // nil — не менять, [] — убрать все
 }
 
 type UpdateUserConfigRequest struct {
-	CallSchema string `json:"call_schema" vlidate:"oneof=redirect proxy"`
+	CallSchema  string `json:"call_schema" validate:"omitempty,oneof=redirect proxy b2bua"`
+	PickupGroup string `json:"pickup_group" validate:"max=64"`
+	RecordCalls *bool  `json:"record_calls"`
+
+	Codecs     []string `json:"codecs" validate:"omitempty,dive,required,max=32"` // nil — не менять
+	StripVideo *bool    `json:"strip_video"`
+	ForcePCMA  *bool    `json:"force_pcma"`
+
+	MaxOutboundCalls *int `json:"max_outbound_calls" validate:"omitempty,min=0"`
+	MaxInboundCalls  *int `json:"max_inbound_calls" validate:"omitempty,min=0"`
+	MaxCalls         *int `json:"max_calls" validate:"omitempty,min=0"`
 }
 
 type UserConfig struct {
-	CallSchema string `json:"call_schema" validate:"required,oneof=redirect proxy"`
+	CallSchema  string `json:"call_schema" validate:"required,oneof=redirect proxy b2bua"`
+	PickupGroup string `json:"pickup_group,omitempty" validate:"max=64"`
+	RecordCalls bool   `json:"record_calls"` // писать разговоры (только через медиа сервера)
+
+	// политика кодеков: разрешённые в порядке предпочтения (пусто — любые)
+	Codecs     []string `json:"codecs" validate:"dive,required,max=32"`
+	StripVideo bool     `json:"strip_video"` // вырезать видео из SDP
+	ForcePCMA  bool     `json:"force_pcma"`  // только G.711 A-law
+
+	// одновременные звонки (с ещё не отвеченными), 0 — без ограничения
+	MaxOutboundCalls int `json:"max_outbound_calls" validate:"min=0"`
+	MaxInboundCalls  int `json:"max_inbound_calls" validate:"min=0"`
+	MaxCalls         int `json:"max_calls" validate:"min=0"`
 }
 
 func NewUser() *User {
-	return &User{Config: &UserConfig{}}
+	return &User{Enabled: true, Config: &UserConfig{}}
+}
+
+// scanDest — поля строки queryUserWithConfig.
+func (user *User) scanDest() []any {
+	c := user.Config
+	return []any{
+		&user.Id, &user.Login, &user.Role, &user.Enabled, &user.SIPHA1,
+		&user.DisplayName, &user.CallerIDNumber, &user.CallerIDName, &user.Email, pq.Array(&user.Aliases),
+		&c.CallSchema, &c.PickupGroup, &c.RecordCalls, pq.Array(&c.Codecs), &c.StripVideo, &c.ForcePCMA,
+		&c.MaxOutboundCalls, &c.MaxInboundCalls, &c.MaxCalls,
+	}
 }
 
 func NewUserUpdateReq() *UpdateUserRequest {
@@ -64,8 +131,8 @@ func NewUserRepo(db *sql.DB) *UserRepositoriy {
 
 func (u *UserRepositoriy) FindByLogin(login string) (*User, error) {
 	user := NewUser()
-	row := u.Db.QueryRow("SELECT id, login, role FROM users where login = $1", login)
-	err := row.Scan(&user.Id, &user.Login, &user.Role)
+	row := u.Db.QueryRow("SELECT id, login, role, enabled, COALESCE(sip_ha1, '') FROM users where login = $1", login)
+	err := row.Scan(&user.Id, &user.Login, &user.Role, &user.Enabled, &user.SIPHA1)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -80,7 +147,7 @@ func (u *UserRepositoriy) FindByLogin(login string) (*User, error) {
 func (u *UserRepositoriy) FindByLoginWithConfig(login string) (*User, error) {
 	user := NewUser()
 	row := u.Db.QueryRow(queryUserWithConfig+" where login = $1", login)
-	err := row.Scan(&user.Id, &user.Login, &user.Role, &user.Config.CallSchema)
+	err := row.Scan(user.scanDest()...)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -95,7 +162,7 @@ func (u *UserRepositoriy) FindByLoginWithConfig(login string) (*User, error) {
 func (u *UserRepositoriy) FindByIDWithConfig(id string) (*User, error) {
 	user := NewUser()
 	row := u.Db.QueryRow(queryUserWithConfig+" where u.id = $1", id)
-	err := row.Scan(&user.Id, &user.Login, &user.Role, &user.Config.CallSchema)
+	err := row.Scan(user.scanDest()...)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -110,14 +177,14 @@ func (u *UserRepositoriy) FindByIDWithConfig(id string) (*User, error) {
 func (u *UserRepositoriy) List() ([]*User, error) {
 	users := make([]*User, 0)
 
-	rows, err := u.Db.Query("SELECT u.id, u.login, u.role, uc.call_schema FROM users u LEFT JOIN user_configs uc ON uc.user_id = u.id")
+	rows, err := u.Db.Query(queryUserWithConfig)
 
 	if err != nil {
 		return nil, err
 	}
 	for rows.Next() {
 		u := NewUser()
-		err := rows.Scan(&u.Id, &u.Login, &u.Role, &u.Config.CallSchema)
+		err := rows.Scan(u.scanDest()...)
 
 		if err != nil {
 			return nil, err
@@ -140,32 +207,133 @@ func (u *UserRepositoriy) CreateUserWithConfig(user *User) (*User, error) {
 		tx.Rollback()
 	}()
 
-	hash, _ := u.GenerateRandomHash(1)
+	if err := u.insertUser(ctx, tx, user); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateUsers создаёт всех пользователей в одной транзакции: при ошибке не
+// создаётся никто, а failed — индекс строки, на которой она случилась.
+func (u *UserRepositoriy) CreateUsers(users []*User) (failed int, err error) {
+	ctx := context.Background()
+	tx, err := u.Db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return -1, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for i, user := range users {
+		if err := u.insertUser(ctx, tx, user); err != nil {
+			return i, err
+		}
+	}
+	return -1, tx.Commit()
+}
+
+func (u *UserRepositoriy) insertUser(ctx context.Context, tx *sql.Tx, user *User) error {
+	// без пароля войти в API нельзя: случайное значение не совпадёт ни с одним хешем
+	hash := user.PasswordHash
+	if hash == "" {
+		hash, _ = u.GenerateRandomHash(1)
+	}
+
+	if err := loginFree(ctx, tx, user.Login); err != nil {
+		return err
+	}
 
 	var userID int64
 
-	err = tx.QueryRowContext(ctx,
-		`INSERT INTO users(login, role, password_hash) VALUES($1,$2,$3) RETURNING id`,
-		user.Login, user.Role, hash,
+	err := tx.QueryRowContext(ctx,
+		`INSERT INTO users(login, role, enabled, password_hash, sip_ha1, display_name, caller_id_number, caller_id_name, email) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id`,
+		user.Login, user.Role, user.Enabled, hash, repository.NullIfEmpty(user.SIPHA1),
+		user.DisplayName, user.CallerIDNumber, user.CallerIDName, user.Email,
 	).Scan(&userID)
 
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	user.Aliases = nonNil(user.Aliases)
+	if err := setAliases(ctx, tx, userID, user.Aliases); err != nil {
+		return err
 	}
 
 	_, err = tx.ExecContext(
 		ctx,
-		`INSERT INTO user_configs(user_id, call_schema) VALUES($1,$2)`,
+		`INSERT INTO user_configs(user_id, call_schema, pickup_group, record_calls, codecs, strip_video, force_pcma, max_outbound_calls, max_inbound_calls, max_calls) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`,
 		userID,
 		user.Config.CallSchema,
+		repository.NullIfEmpty(user.Config.PickupGroup),
+		user.Config.RecordCalls,
+		pq.Array(nonNil(user.Config.Codecs)),
+		user.Config.StripVideo,
+		user.Config.ForcePCMA,
+		user.Config.MaxOutboundCalls,
+		user.Config.MaxInboundCalls,
+		user.Config.MaxCalls,
 	)
 
 	if err != nil {
-		return nil, err
+		return err
 	}
-	tx.Commit()
 	user.Id = int(userID)
-	return user, nil
+	return nil
+}
+
+// ExistingLogins — какие из logins уже заняты логинами или алиасами.
+func (u *UserRepositoriy) ExistingLogins(logins []string) ([]string, error) {
+	rows, err := u.Db.Query("SELECT login FROM users WHERE login = ANY($1) UNION SELECT alias FROM user_aliases WHERE alias = ANY($1)", pq.Array(logins))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make([]string, 0)
+	for rows.Next() {
+		var login string
+		if err := rows.Scan(&login); err != nil {
+			return nil, err
+		}
+		existing = append(existing, login)
+	}
+	return existing, rows.Err()
+}
+
+// SetPassword меняет пароль входа в API и SIP digest одновременно.
+func (u *UserRepositoriy) SetPassword(id string, hash, ha1 string) error {
+	res, err := u.Db.Exec("UPDATE users SET password_hash = $1, sip_ha1 = $2 WHERE id = $3", hash, ha1, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// Delete удаляет пользователя; конфиг, ящик голосовой почты, членство в группах
+// и токены API уходят каскадом, журнал звонков остаётся.
+func (u *UserRepositoriy) Delete(id string) error {
+	res, err := u.Db.Exec("DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
 }
 
 func (u *UserRepositoriy) UpdateUser(userID string, arg *UpdateUserRequest) error {
@@ -185,6 +353,26 @@ func (u *UserRepositoriy) UpdateUser(userID string, arg *UpdateUserRequest) erro
 	if arg.Role != "" {
 		userSets["role"] = arg.Role
 	}
+	if arg.Enabled != nil {
+		userSets["enabled"] = *arg.Enabled
+	}
+	if arg.DisplayName != nil {
+		userSets["display_name"] = *arg.DisplayName
+	}
+	if arg.CallerIDNumber != nil {
+		userSets["caller_id_number"] = *arg.CallerIDNumber
+	}
+	if arg.CallerIDName != nil {
+		userSets["caller_id_name"] = *arg.CallerIDName
+	}
+	if arg.Email != nil {
+		userSets["email"] = *arg.Email
+	}
+	if arg.Login != "" {
+		if err := loginFree(ctx, tx, arg.Login); err != nil {
+			return err
+		}
+	}
 
 	if len(userSets) > 0 {
 		qUsers, argsUsers, err := func() (string, []any, error) {
@@ -202,11 +390,36 @@ func (u *UserRepositoriy) UpdateUser(userID string, arg *UpdateUserRequest) erro
 	}
 
 	// 2) user_configs (опционально)
+	configSets := map[string]any{}
 	if arg.Config != nil && arg.Config.CallSchema != "" {
-		configSets := map[string]any{
-			"call_schema": arg.Config.CallSchema,
-		}
+		configSets["call_schema"] = arg.Config.CallSchema
+	}
+	if arg.Config != nil && arg.Config.PickupGroup != "" {
+		configSets["pickup_group"] = arg.Config.PickupGroup
+	}
+	if arg.Config != nil && arg.Config.RecordCalls != nil {
+		configSets["record_calls"] = *arg.Config.RecordCalls
+	}
+	if arg.Config != nil && arg.Config.Codecs != nil {
+		configSets["codecs"] = pq.Array(arg.Config.Codecs)
+	}
+	if arg.Config != nil && arg.Config.StripVideo != nil {
+		configSets["strip_video"] = *arg.Config.StripVideo
+	}
+	if arg.Config != nil && arg.Config.ForcePCMA != nil {
+		configSets["force_pcma"] = *arg.Config.ForcePCMA
+	}
+	if arg.Config != nil && arg.Config.MaxOutboundCalls != nil {
+		configSets["max_outbound_calls"] = *arg.Config.MaxOutboundCalls
+	}
+	if arg.Config != nil && arg.Config.MaxInboundCalls != nil {
+		configSets["max_inbound_calls"] = *arg.Config.MaxInboundCalls
+	}
+	if arg.Config != nil && arg.Config.MaxCalls != nil {
+		configSets["max_calls"] = *arg.Config.MaxCalls
+	}
 
+	if len(configSets) > 0 {
 		qCfg, argsCfg, err := func() (string, []any, error) {
 			where := fmt.Sprintf("user_id = $%d", len(configSets)+1)
 			return buildUpdate("user_configs", configSets, where, userID)
@@ -219,7 +432,16 @@ func (u *UserRepositoriy) UpdateUser(userID string, arg *UpdateUserRequest) erro
 			return err
 		}
 	}
-	if len(userSets) == 0 && (arg.Config == nil || arg.Config.CallSchema == "") {
+	if arg.Aliases != nil {
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			return ErrUserNotFound
+		}
+		if err := setAliases(ctx, tx, id, arg.Aliases); err != nil {
+			return err
+		}
+	}
+	if len(userSets) == 0 && len(configSets) == 0 && arg.Aliases == nil {
 		return ErrNoFieldsToUpdate // или return nil
 	}
 
@@ -229,6 +451,98 @@ func (u *UserRepositoriy) UpdateUser(userID string, arg *UpdateUserRequest) erro
 	return nil
 }
 
+// ResolveAlias — логин пользователя, у которого есть алиас alias.
+func (u *UserRepositoriy) ResolveAlias(alias string) (string, error) {
+	var login string
+	err := u.Db.QueryRow(
+		"SELECT u.login FROM user_aliases a JOIN users u ON u.id = a.user_id WHERE a.alias = $1", alias,
+	).Scan(&login)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrUserNotFound
+		}
+		return "", err
+	}
+	return login, nil
+}
+
+// setAliases заменяет алиасы пользователя. Алиас не может совпадать с чужим
+// алиасом или с чьим-либо логином: иначе звонок на номер неоднозначен.
+func setAliases(ctx context.Context, tx *sql.Tx, userID int64, aliases []string) error {
+	var taken string
+	err := tx.QueryRowContext(ctx,
+		`SELECT login FROM users WHERE login = ANY($1)
+		 UNION ALL
+		 SELECT alias FROM user_aliases WHERE alias = ANY($1) AND user_id <> $2
+		 LIMIT 1`,
+		pq.Array(aliases), userID,
+	).Scan(&taken)
+	if err == nil {
+		return fmt.Errorf("%w: %s", ErrAliasTaken, taken)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_aliases WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	for _, alias := range aliases {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO user_aliases(user_id, alias) VALUES($1, $2)", userID, alias); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loginFree(ctx context.Context, tx *sql.Tx, login string) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM user_aliases WHERE alias = $1)", login).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", ErrLoginTaken, login)
+	}
+	return nil
+}
+
+// FindCredentials — пользователь с хешем пароля для входа в API.
+func (u *UserRepositoriy) FindCredentials(login string) (*User, error) {
+	user := NewUser()
+	row := u.Db.QueryRow("SELECT id, login, role, enabled, password_hash FROM users WHERE login = $1", login)
+	err := row.Scan(&user.Id, &user.Login, &user.Role, &user.Enabled, &user.PasswordHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// LoginsByPickupGroup — все пользователи из одной группы перехвата.
+func (u *UserRepositoriy) LoginsByPickupGroup(group string) ([]string, error) {
+	rows, err := u.Db.Query(
+		"SELECT u.login FROM users u JOIN user_configs uc ON uc.user_id = u.id WHERE uc.pickup_group = $1",
+		group,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logins := make([]string, 0)
+	for rows.Next() {
+		var login string
+		if err := rows.Scan(&login); err != nil {
+			return nil, err
+		}
+		logins = append(logins, login)
+	}
+	return logins, rows.Err()
+}
+
 func (u *UserRepositoriy) GenerateRandomHash(n int) (string, error) {
 	b := make([]byte, n)
 	_, err := rand.Read(b)
@@ -270,3 +584,11 @@ func buildUpdate(table string, sets map[string]any, where string, whereArgs ...a
 	args = append(args, whereArgs...)
 	return sb.String(), args, nil
 }
+
+// nonNil — пустой массив вместо NULL для NOT NULL колонок TEXT[].
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
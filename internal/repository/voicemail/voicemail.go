@@ -0,0 +1,189 @@
+package voicemail
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrMessageNotFound = errors.New("voicemail message not found")
+
+const queryMessage string = `
+SELECT
+	id,
+	user_id,
+	journal_id,
+	caller_user,
+	file_path,
+	duration_ms,
+	heard,
+	created_at
+FROM voicemail_messages
+`
+
+type Message struct {
+	Id         int64     `json:"id"`
+	UserId     int       `json:"user_id"`
+	JournalId  *int64    `json:"journal_id,omitempty"`
+	CallerUser string    `json:"caller_user"`
+	FilePath   string    `json:"-"`
+	DurationMs int       `json:"duration_ms"`
+	Heard      bool      `json:"heard"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type VoicemailRepo struct {
+	DB *sql.DB
+}
+
+func NewVoicemailRepo(db *sql.DB) *VoicemailRepo {
+	return &VoicemailRepo{DB: db}
+}
+
+func (r *VoicemailRepo) ListByUser(userID string) ([]*Message, error) {
+	return r.query(context.Background(), queryMessage+" WHERE user_id = $1 ORDER BY created_at DESC", userID)
+}
+
+func (r *VoicemailRepo) FindByID(userID, id string) (*Message, error) {
+	items, err := r.query(context.Background(), queryMessage+" WHERE user_id = $1 AND id = $2", userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrMessageNotFound
+	}
+	return items[0], nil
+}
+
+// ListForPlayback — для прослушивания с телефона: сначала новые непрослушанные.
+func (r *VoicemailRepo) ListForPlayback(ctx context.Context, userID int) ([]*Message, error) {
+	return r.query(ctx, queryMessage+" WHERE user_id = $1 ORDER BY heard, created_at DESC", userID)
+}
+
+func (r *VoicemailRepo) Create(ctx context.Context, m *Message) (*Message, error) {
+	const q = `
+		INSERT INTO voicemail_messages (user_id, journal_id, caller_user, file_path, duration_ms)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, heard, created_at`
+	err := r.DB.QueryRowContext(ctx, q, m.UserId, m.JournalId, m.CallerUser, m.FilePath, m.DurationMs).
+		Scan(&m.Id, &m.Heard, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *VoicemailRepo) MarkHeard(ctx context.Context, id int64) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE voicemail_messages SET heard = true WHERE id = $1`, id)
+	return err
+}
+
+func (r *VoicemailRepo) query(ctx context.Context, q string, args ...any) ([]*Message, error) {
+	rows, err := r.DB.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*Message, 0)
+	for rows.Next() {
+		m := &Message{}
+		if err := rows.Scan(&m.Id, &m.UserId, &m.JournalId, &m.CallerUser, &m.FilePath, &m.DurationMs, &m.Heard, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, m)
+	}
+	return items, rows.Err()
+}
+
+// Counts — новые и прослушанные сообщения пользователя (для MWI).
+type Counts struct {
+	New int `json:"new"`
+	Old int `json:"old"`
+}
+
+func (r *VoicemailRepo) Counts(ctx context.Context, userID string) (Counts, error) {
+	const q = `
+		SELECT
+			COUNT(*) FILTER (WHERE NOT heard),
+			COUNT(*) FILTER (WHERE heard)
+		FROM voicemail_messages
+		WHERE user_id = $1`
+	var c Counts
+	err := r.DB.QueryRowContext(ctx, q, userID).Scan(&c.New, &c.Old)
+	return c, err
+}
+
+// CountsForLogin — то же по login (SIP-сторона знает только его).
+func (r *VoicemailRepo) CountsForLogin(ctx context.Context, login string) (Counts, error) {
+	const q = `
+		SELECT
+			COUNT(v.id) FILTER (WHERE NOT v.heard),
+			COUNT(v.id) FILTER (WHERE v.heard)
+		FROM voicemail_messages v
+		JOIN users u ON u.id = v.user_id
+		WHERE u.login = $1`
+	var c Counts
+	err := r.DB.QueryRowContext(ctx, q, login).Scan(&c.New, &c.Old)
+	return c, err
+}
+
+// CountsByLogin — счётчики всех пользователей.
+func (r *VoicemailRepo) CountsByLogin(ctx context.Context) (map[string]Counts, error) {
+	const q = `
+		SELECT
+			u.login,
+			COUNT(v.id) FILTER (WHERE NOT v.heard),
+			COUNT(v.id) FILTER (WHERE v.heard)
+		FROM users u
+		LEFT JOIN voicemail_messages v ON v.user_id = u.id
+		GROUP BY u.login`
+	rows, err := r.DB.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]Counts)
+	for rows.Next() {
+		var login string
+		var c Counts
+		if err := rows.Scan(&login, &c.New, &c.Old); err != nil {
+			return nil, err
+		}
+		counts[login] = c
+	}
+	return counts, rows.Err()
+}
+
+// RequestMWI просит SIP-сервер переотправить MWI пользователю.
+func (r *VoicemailRepo) RequestMWI(ctx context.Context, userID string) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE user_configs SET mwi_resend = true WHERE user_id = $1`, userID)
+	return err
+}
+
+// TakeMWIRequests сбрасывает запросы из API и возвращает, кому переотправить MWI.
+func (r *VoicemailRepo) TakeMWIRequests(ctx context.Context) ([]string, error) {
+	const q = `
+		UPDATE user_configs uc
+		SET mwi_resend = false
+		FROM users u
+		WHERE u.id = uc.user_id AND uc.mwi_resend
+		RETURNING u.login`
+	rows, err := r.DB.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logins := make([]string, 0)
+	for rows.Next() {
+		var login string
+		if err := rows.Scan(&login); err != nil {
+			return nil, err
+		}
+		logins = append(logins, login)
+	}
+	return logins, rows.Err()
+}
@@ -18,15 +18,85 @@ func NewRouter(s *httpserver.HttpServer, reg *prometheus.Registry) *mux.Router {
 	r.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{})).Methods("GET")
 	//api
 	api := r.PathPrefix("/api").Subrouter()
+	// auth: доступ к остальным маршрутам — по роли (Admin / Self / Authenticated)
+	api.HandleFunc("/auth/login", s.Login).Methods("POST")
+	api.HandleFunc("/auth/logout", s.Authenticated(s.Logout)).Methods("POST")
+	api.HandleFunc("/auth/me", s.Authenticated(s.Me)).Methods("GET")
+	api.HandleFunc("/api_keys", s.Admin(s.ListAPIKeys)).Methods("GET")
+	api.HandleFunc("/api_keys", s.Admin(s.CreateAPIKey)).Methods("POST")
+	api.HandleFunc("/api_keys/{id:[0-9]+}", s.Admin(s.DeleteAPIKey)).Methods("DELETE")
 	// users
-	api.HandleFunc("/users", s.ListUsers).Methods("GET")
-	api.HandleFunc("/users/{id:[0-9]+}", s.GetUser).Methods("GET")
-	api.HandleFunc("/users", s.CreateUser).Methods("POST")
-	api.HandleFunc("/users/{id:[0-9]+}", s.UpdateUser).Methods("PUT")
+	api.HandleFunc("/users", s.Admin(s.ListUsers)).Methods("GET")
+	api.HandleFunc("/users/{id:[0-9]+}", s.Self(s.GetUser)).Methods("GET")
+	api.HandleFunc("/users", s.Admin(s.CreateUser)).Methods("POST")
+	api.HandleFunc("/users/{id:[0-9]+}", s.Self(s.UpdateUser)).Methods("PUT")
+	api.HandleFunc("/users/{id:[0-9]+}", s.Admin(s.DeleteUser)).Methods("DELETE")
+	api.HandleFunc("/users/{id:[0-9]+}/password", s.Self(s.SetUserPassword)).Methods("PUT")
+	api.HandleFunc("/users/{id:[0-9]+}/password/reset", s.Admin(s.ResetUserPassword)).Methods("POST")
+	api.HandleFunc("/users/import", s.Admin(s.ImportUsers)).Methods("POST")
+	api.HandleFunc("/users/{id:[0-9]+}/voicemail", s.Self(s.ListVoicemail)).Methods("GET")
+	api.HandleFunc("/users/{id:[0-9]+}/voicemail/{msgId:[0-9]+}/audio", s.Self(s.DownloadVoicemail)).Methods("GET")
+	api.HandleFunc("/users/{id:[0-9]+}/mwi", s.Self(s.GetMWI)).Methods("GET")
+	api.HandleFunc("/users/{id:[0-9]+}/mwi", s.Self(s.TriggerMWI)).Methods("POST")
+	api.HandleFunc("/users/{id:[0-9]+}/messages", s.Self(s.ListUserMessages)).Methods("GET")
+	api.HandleFunc("/users/{id:[0-9]+}/calls", s.Self(s.GetUserCalls)).Methods("GET")
 	// sessions
-	api.HandleFunc("/sessions", s.ListSession).Methods("GET")
+	api.HandleFunc("/sessions", s.Admin(s.ListSession)).Methods("GET")
 	// call_journals
-	api.HandleFunc("/call_journals", s.ListCallJournal).Methods("GET")
+	api.HandleFunc("/call_journals", s.Authenticated(s.ListCallJournal)).Methods("GET")
+	api.HandleFunc("/call_journals/{id:[0-9]+}/recording", s.Admin(s.DownloadCallRecording)).Methods("GET")
+	api.HandleFunc("/call_journals/{id:[0-9]+}/recording", s.Admin(s.DeleteCallRecording)).Methods("DELETE")
+	// dial plan
+	api.HandleFunc("/dialplan", s.Admin(s.ListDialPlan)).Methods("GET")
+	api.HandleFunc("/dialplan/{id:[0-9]+}", s.Admin(s.GetDialPlanRule)).Methods("GET")
+	api.HandleFunc("/dialplan", s.Admin(s.CreateDialPlanRule)).Methods("POST")
+	api.HandleFunc("/dialplan/{id:[0-9]+}", s.Admin(s.UpdateDialPlanRule)).Methods("PUT")
+	api.HandleFunc("/dialplan/{id:[0-9]+}", s.Admin(s.DeleteDialPlanRule)).Methods("DELETE")
+	// trunks
+	api.HandleFunc("/trunks", s.Admin(s.ListTrunks)).Methods("GET")
+	api.HandleFunc("/trunks/{id:[0-9]+}", s.Admin(s.GetTrunk)).Methods("GET")
+	api.HandleFunc("/trunks", s.Admin(s.CreateTrunk)).Methods("POST")
+	api.HandleFunc("/trunks/{id:[0-9]+}", s.Admin(s.UpdateTrunk)).Methods("PUT")
+	api.HandleFunc("/trunks/{id:[0-9]+}", s.Admin(s.DeleteTrunk)).Methods("DELETE")
+	// dids
+	api.HandleFunc("/dids", s.Admin(s.ListDIDs)).Methods("GET")
+	api.HandleFunc("/dids/{id:[0-9]+}", s.Admin(s.GetDID)).Methods("GET")
+	api.HandleFunc("/dids", s.Admin(s.CreateDID)).Methods("POST")
+	api.HandleFunc("/dids/{id:[0-9]+}", s.Admin(s.UpdateDID)).Methods("PUT")
+	api.HandleFunc("/dids/{id:[0-9]+}", s.Admin(s.DeleteDID)).Methods("DELETE")
+	// ring groups
+	api.HandleFunc("/ring_groups", s.Admin(s.ListRingGroups)).Methods("GET")
+	api.HandleFunc("/ring_groups/{id:[0-9]+}", s.Admin(s.GetRingGroup)).Methods("GET")
+	api.HandleFunc("/ring_groups", s.Admin(s.CreateRingGroup)).Methods("POST")
+	api.HandleFunc("/ring_groups/{id:[0-9]+}", s.Admin(s.UpdateRingGroup)).Methods("PUT")
+	api.HandleFunc("/ring_groups/{id:[0-9]+}", s.Admin(s.DeleteRingGroup)).Methods("DELETE")
+	// ivr
+	api.HandleFunc("/ivr", s.Admin(s.ListIVRMenus)).Methods("GET")
+	api.HandleFunc("/ivr/{id:[0-9]+}", s.Admin(s.GetIVRMenu)).Methods("GET")
+	api.HandleFunc("/ivr", s.Admin(s.CreateIVRMenu)).Methods("POST")
+	api.HandleFunc("/ivr/{id:[0-9]+}", s.Admin(s.UpdateIVRMenu)).Methods("PUT")
+	api.HandleFunc("/ivr/{id:[0-9]+}", s.Admin(s.DeleteIVRMenu)).Methods("DELETE")
+	// conferences
+	api.HandleFunc("/conferences", s.Admin(s.ListConferences)).Methods("GET")
+	api.HandleFunc("/conferences/{id:[0-9]+}", s.Admin(s.GetConference)).Methods("GET")
+	api.HandleFunc("/conferences", s.Admin(s.CreateConference)).Methods("POST")
+	api.HandleFunc("/conferences/{id:[0-9]+}", s.Admin(s.UpdateConference)).Methods("PUT")
+	api.HandleFunc("/conferences/{id:[0-9]+}", s.Admin(s.DeleteConference)).Methods("DELETE")
+	api.HandleFunc("/conferences/{id:[0-9]+}/participants", s.Admin(s.ListConferenceParticipants)).Methods("GET")
+	api.HandleFunc("/conferences/{id:[0-9]+}/participants/{participantId:[0-9]+}", s.Admin(s.UpdateConferenceParticipant)).Methods("PUT")
+	api.HandleFunc("/conferences/{id:[0-9]+}/participants/{participantId:[0-9]+}", s.Admin(s.KickConferenceParticipant)).Methods("DELETE")
+	// parking
+	api.HandleFunc("/parking", s.Admin(s.ListParkedCalls)).Methods("GET")
+	// bans
+	api.HandleFunc("/bans", s.Admin(s.ListBans)).Methods("GET")
+	api.HandleFunc("/bans", s.Admin(s.CreateBan)).Methods("POST")
+	api.HandleFunc("/bans/{id:[0-9]+}", s.Admin(s.DeleteBan)).Methods("DELETE")
+	// acl
+	api.HandleFunc("/acls", s.Admin(s.ListACLRules)).Methods("GET")
+	api.HandleFunc("/acls/{id:[0-9]+}", s.Admin(s.GetACLRule)).Methods("GET")
+	api.HandleFunc("/acls", s.Admin(s.CreateACLRule)).Methods("POST")
+	api.HandleFunc("/acls/{id:[0-9]+}", s.Admin(s.UpdateACLRule)).Methods("PUT")
+	api.HandleFunc("/acls/{id:[0-9]+}", s.Admin(s.DeleteACLRule)).Methods("DELETE")
 
 	// web
 	dist := "./web/dist"
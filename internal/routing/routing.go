@@ -0,0 +1,180 @@
+// Package routing — движок dial plan: по номеру из Request-URI выбирает
+// первое подходящее правило и возвращает решение о маршрутизации.
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	dialplan "SipServer/internal/repository/dial_plan"
+)
+
+var ErrInvalidPattern = errors.New("invalid dial plan pattern")
+
+// RuleSource — откуда движок берёт правила (обычно DialPlanRepo).
+type RuleSource interface {
+	ListEnabled(ctx context.Context) ([]*dialplan.Rule, error)
+}
+
+type Decision struct {
+	RuleID     int
+	Action     dialplan.Action
+	Target     string // login / группа / транк
+	Number     string // номер после strip/prepend
+	RejectCode int
+}
+
+type Engine struct {
+	src RuleSource
+
+	mu sync.Mutex
+	re map[string]*regexp.Regexp
+}
+
+func New(src RuleSource) *Engine {
+	return &Engine{
+		src: src,
+		re:  make(map[string]*regexp.Regexp),
+	}
+}
+
+// Route загружает актуальные правила и применяет их к номеру.
+func (e *Engine) Route(ctx context.Context, number string, now time.Time) (*Decision, error) {
+	rules, err := e.src.ListEnabled(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return e.Match(rules, number, now), nil
+}
+
+// Match проходит правила по порядку. Если ни одно не подошло —
+// звонок локальному пользователю с тем же номером (поведение по умолчанию).
+func (e *Engine) Match(rules []*dialplan.Rule, number string, now time.Time) *Decision {
+	for _, r := range rules {
+		if r == nil || !r.Enabled {
+			continue
+		}
+		if !e.matchNumber(r, number) || !MatchTime(r, now) {
+			continue
+		}
+
+		rewritten := Rewrite(number, r.StripDigits, r.Prepend)
+		target := r.Target
+		if target == "" {
+			target = rewritten
+		}
+
+		return &Decision{
+			RuleID:     r.Id,
+			Action:     r.Action,
+			Target:     target,
+			Number:     rewritten,
+			RejectCode: r.RejectCode,
+		}
+	}
+
+	return &Decision{
+		Action: dialplan.ActionLocalUser,
+		Target: number,
+		Number: number,
+	}
+}
+
+func (e *Engine) matchNumber(r *dialplan.Rule, number string) bool {
+	switch r.MatchType {
+	case dialplan.MatchPrefix:
+		return strings.HasPrefix(number, r.Pattern)
+	case dialplan.MatchRegex:
+		re, err := e.compile(r.Pattern)
+		if err != nil {
+			log.Printf("[DIALPLAN] rule=%d bad regex %q: %v", r.Id, r.Pattern, err)
+			return false
+		}
+		return re.MatchString(number)
+	}
+	return false
+}
+
+func (e *Engine) compile(pattern string) (*regexp.Regexp, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if re, ok := e.re[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	e.re[pattern] = re
+	return re, nil
+}
+
+// ValidatePattern проверяет pattern до сохранения правила.
+func ValidatePattern(matchType dialplan.MatchType, pattern string) error {
+	if matchType != dialplan.MatchRegex {
+		return nil
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPattern, err)
+	}
+	return nil
+}
+
+// Rewrite отрезает strip первых символов и добавляет prepend.
+func Rewrite(number string, strip int, prepend string) string {
+	if strip >= len(number) {
+		number = ""
+	} else if strip > 0 {
+		number = number[strip:]
+	}
+	return prepend + number
+}
+
+// MatchTime проверяет день недели и интервал time_from..time_to.
+// Интервал через полночь (22:00-06:00) тоже поддерживается.
+func MatchTime(r *dialplan.Rule, now time.Time) bool {
+	if len(r.Weekdays) > 0 {
+		wd := int64(now.Weekday())
+		found := false
+		for _, d := range r.Weekdays {
+			if d == wd {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if r.TimeFrom == "" || r.TimeTo == "" {
+		return true
+	}
+
+	from, err1 := minutesOfDay(r.TimeFrom)
+	to, err2 := minutesOfDay(r.TimeTo)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	cur := now.Hour()*60 + now.Minute()
+	if from <= to {
+		return cur >= from && cur < to
+	}
+	return cur >= from || cur < to
+}
+
+func minutesOfDay(hhmm string) (int, error) {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
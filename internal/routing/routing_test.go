@@ -0,0 +1,175 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	dialplan "SipServer/internal/repository/dial_plan"
+)
+
+type staticRules []*dialplan.Rule
+
+func (s staticRules) ListEnabled(context.Context) ([]*dialplan.Rule, error) {
+	return s, nil
+}
+
+func rule(id, priority int, mt dialplan.MatchType, pattern string, action dialplan.Action, target string) *dialplan.Rule {
+	return &dialplan.Rule{
+		Id:        id,
+		Priority:  priority,
+		Enabled:   true,
+		MatchType: mt,
+		Pattern:   pattern,
+		Action:    action,
+		Target:    target,
+	}
+}
+
+// понедельник, 12:00
+var noon = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestMatch(t *testing.T) {
+	disabled := rule(1, 1, dialplan.MatchPrefix, "8", dialplan.ActionReject, "")
+	disabled.Enabled = false
+	disabled.RejectCode = 403
+
+	strip := rule(5, 50, dialplan.MatchPrefix, "9", dialplan.ActionTrunk, "prov")
+	strip.StripDigits = 1
+	strip.Prepend = "+7"
+
+	// правила приходят из репозитория уже в порядке priority
+	rules := []*dialplan.Rule{
+		disabled,
+		rule(2, 10, dialplan.MatchPrefix, "8800", dialplan.ActionTrunk, "free"),
+		rule(3, 20, dialplan.MatchPrefix, "8", dialplan.ActionTrunk, "long"),
+		rule(4, 30, dialplan.MatchRegex, `^1\d{2}$`, dialplan.ActionGroup, "sales"),
+		strip,
+		rule(6, 60, dialplan.MatchRegex, `^[`, dialplan.ActionReject, ""),
+	}
+
+	tests := []struct {
+		name   string
+		number string
+		ruleID int
+		action dialplan.Action
+		target string
+		num    string
+	}{
+		{"first match by priority wins", "88001234567", 2, dialplan.ActionTrunk, "free", "88001234567"},
+		{"disabled rule is skipped", "84951234567", 3, dialplan.ActionTrunk, "long", "84951234567"},
+		{"regex full match", "123", 4, dialplan.ActionGroup, "sales", "123"},
+		{"regex does not match longer number", "1234", 0, dialplan.ActionLocalUser, "1234", "1234"},
+		{"strip and prepend", "94951234567", 5, dialplan.ActionTrunk, "prov", "+74951234567"},
+		{"bad regex never matches, default local user", "alice", 0, dialplan.ActionLocalUser, "alice", "alice"},
+	}
+
+	e := New(staticRules(rules))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := e.Route(context.Background(), tt.number, noon)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.RuleID != tt.ruleID || d.Action != tt.action || d.Target != tt.target || d.Number != tt.num {
+				t.Errorf("got rule=%d action=%s target=%s number=%s, want rule=%d action=%s target=%s number=%s",
+					d.RuleID, d.Action, d.Target, d.Number, tt.ruleID, tt.action, tt.target, tt.num)
+			}
+		})
+	}
+}
+
+func TestMatchTargetDefaultsToRewrittenNumber(t *testing.T) {
+	r := rule(1, 1, dialplan.MatchPrefix, "7", dialplan.ActionLocalUser, "")
+	r.StripDigits = 1
+	d := New(nil).Match([]*dialplan.Rule{r}, "7101", noon)
+	if d.Target != "101" || d.Number != "101" {
+		t.Errorf("got target=%s number=%s, want 101", d.Target, d.Number)
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	tests := []struct {
+		mt      dialplan.MatchType
+		pattern string
+		wantErr bool
+	}{
+		{dialplan.MatchRegex, `^\+7\d{10}$`, false},
+		{dialplan.MatchRegex, `^(8|\+7`, true},
+		{dialplan.MatchRegex, `[`, true},
+		{dialplan.MatchPrefix, `[`, false}, // префикс — просто строка
+	}
+	for _, tt := range tests {
+		err := ValidatePattern(tt.mt, tt.pattern)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidatePattern(%s, %q) = %v, wantErr %v", tt.mt, tt.pattern, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidPattern) {
+			t.Errorf("ValidatePattern(%s, %q) error %v is not ErrInvalidPattern", tt.mt, tt.pattern, err)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	tests := []struct {
+		number  string
+		strip   int
+		prepend string
+		want    string
+	}{
+		{"84951234567", 1, "+7", "+74951234567"},
+		{"101", 0, "", "101"},
+		{"101", 0, "9", "9101"},
+		{"101", 3, "", ""},
+		{"101", 5, "", ""},
+		{"101", 5, "+7", "+7"},
+		{"", 0, "", ""},
+		{"", 2, "8", "8"},
+		{"101", -1, "", "101"},
+	}
+	for _, tt := range tests {
+		if got := Rewrite(tt.number, tt.strip, tt.prepend); got != tt.want {
+			t.Errorf("Rewrite(%q, %d, %q) = %q, want %q", tt.number, tt.strip, tt.prepend, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTime(t *testing.T) {
+	at := func(day, hour, min int) time.Time {
+		// 1 января 2024 — понедельник
+		return time.Date(2024, 1, day, hour, min, 0, 0, time.UTC)
+	}
+	workdays := []int64{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		name     string
+		weekdays []int64
+		from, to string
+		now      time.Time
+		want     bool
+	}{
+		{"no restrictions", nil, "", "", at(6, 3, 0), true},
+		{"weekday in mask", workdays, "", "", at(1, 12, 0), true},
+		{"saturday not in mask", workdays, "", "", at(6, 12, 0), false},
+		{"sunday is 0", []int64{0}, "", "", at(7, 12, 0), true},
+		{"inside day window", nil, "09:00", "18:00", at(1, 9, 0), true},
+		{"end of window is exclusive", nil, "09:00", "18:00", at(1, 18, 0), false},
+		{"before day window", nil, "09:00", "18:00", at(1, 8, 59), false},
+		{"overnight late evening", nil, "22:00", "06:00", at(1, 23, 30), true},
+		{"overnight early morning", nil, "22:00", "06:00", at(1, 5, 59), true},
+		{"overnight end exclusive", nil, "22:00", "06:00", at(1, 6, 0), false},
+		{"overnight daytime", nil, "22:00", "06:00", at(1, 12, 0), false},
+		{"weekday and window both required", workdays, "22:00", "06:00", at(6, 23, 0), false},
+		{"only from set ignores window", nil, "22:00", "", at(1, 12, 0), true},
+		{"bad time never matches", nil, "25:00", "06:00", at(1, 12, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &dialplan.Rule{Weekdays: tt.weekdays, TimeFrom: tt.from, TimeTo: tt.to}
+			if got := MatchTime(r, tt.now); got != tt.want {
+				t.Errorf("MatchTime = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package sdp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Format — payload type с rtpmap/fmtp.
+type Format struct {
+	PayloadType uint8
+	Name        string // PCMU, PCMA, telephone-event...
+	ClockRate   int
+	Channels    int
+	Params      string // a=fmtp
+}
+
+// статические payload type'ы RFC 3551, для которых rtpmap не обязателен
+var staticFormats = map[uint8]Format{
+	0:  {PayloadType: 0, Name: "PCMU", ClockRate: 8000},
+	3:  {PayloadType: 3, Name: "GSM", ClockRate: 8000},
+	4:  {PayloadType: 4, Name: "G723", ClockRate: 8000},
+	8:  {PayloadType: 8, Name: "PCMA", ClockRate: 8000},
+	9:  {PayloadType: 9, Name: "G722", ClockRate: 8000},
+	13: {PayloadType: 13, Name: "CN", ClockRate: 8000},
+	18: {PayloadType: 18, Name: "G729", ClockRate: 8000},
+}
+
+// RTPFormats — форматы m= в порядке предпочтения, с rtpmap и fmtp.
+// Payload type без rtpmap и вне статической таблицы получает пустое Name.
+func (m *Media) RTPFormats() []Format {
+	out := make([]Format, 0, len(m.Formats))
+	for _, f := range m.Formats {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 || n > 127 {
+			continue
+		}
+		pt := uint8(n)
+		format := staticFormats[pt]
+		format.PayloadType = pt
+
+		for _, a := range m.Attributes {
+			id, val, _ := strings.Cut(a.Value, " ")
+			if id != f {
+				continue
+			}
+			switch a.Key {
+			case "rtpmap":
+				format.Name, format.ClockRate, format.Channels = parseRTPMap(val)
+			case "fmtp":
+				format.Params = val
+			}
+		}
+		out = append(out, format)
+	}
+	return out
+}
+
+// "PCMA/8000" или "opus/48000/2"
+func parseRTPMap(val string) (string, int, int) {
+	parts := strings.Split(val, "/")
+	name := parts[0]
+	rate, channels := 0, 0
+	if len(parts) > 1 {
+		rate, _ = strconv.Atoi(parts[1])
+	}
+	if len(parts) > 2 {
+		channels, _ = strconv.Atoi(parts[2])
+	}
+	return name, rate, channels
+}
+
+// KeepFormats оставляет у m= только payload type'ы keep (в их порядке)
+// и убирает rtpmap/fmtp остальных.
+func (m *Media) KeepFormats(keep []uint8) {
+	ids := make(map[string]bool, len(keep))
+	m.Formats = m.Formats[:0]
+	for _, pt := range keep {
+		id := strconv.Itoa(int(pt))
+		ids[id] = true
+		m.Formats = append(m.Formats, id)
+	}
+
+	attrs := m.Attributes[:0]
+	for _, a := range m.Attributes {
+		if a.Key == "rtpmap" || a.Key == "fmtp" {
+			if id, _, _ := strings.Cut(a.Value, " "); !ids[id] {
+				continue
+			}
+		}
+		attrs = append(attrs, a)
+	}
+	m.Attributes = attrs
+}
+
+// Disable отклоняет поток (порт 0, RFC 3264 §6): m= остаётся,
+// чтобы в ответе было столько же строк, сколько в предложении.
+func (m *Media) Disable() {
+	m.Port = 0
+	m.NumPorts = 0
+}
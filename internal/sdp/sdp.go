@@ -0,0 +1,309 @@
+// Package sdp — разбор и сборка SDP (RFC 4566) для offer/answer (RFC 3264).
+package sdp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalid = errors.New("sdp: invalid session description")
+
+// направление медиа (RFC 3264)
+const (
+	SendRecv = "sendrecv"
+	SendOnly = "sendonly"
+	RecvOnly = "recvonly"
+	Inactive = "inactive"
+)
+
+// Session — описание сессии. Поля, которые мы не разбираем (i=, u=, b=, t=...),
+// хранятся как есть и выводятся обратно в том же порядке.
+type Session struct {
+	Version    int
+	Origin     Origin
+	Name       string
+	Connection *Connection
+	Fields     []Field
+	Attributes []Attribute
+	Media      []*Media
+}
+
+// Origin — строка o=.
+type Origin struct {
+	Username       string
+	SessionID      string
+	SessionVersion string
+	NetType        string
+	AddrType       string
+	Address        string
+}
+
+// Connection — строка c=.
+type Connection struct {
+	NetType  string
+	AddrType string
+	Address  string
+}
+
+// Field — строка SDP без отдельной структуры, например b=AS:64.
+type Field struct {
+	Type  byte
+	Value string
+}
+
+// Attribute — a=key или a=key:value.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Media — m= и всё, что относится к нему.
+type Media struct {
+	Type       string // audio, video...
+	Port       int
+	NumPorts   int // m=audio 49170/2 — 2, иначе 0
+	Proto      string
+	Formats    []string // payload type'ы (для RTP) в порядке предпочтения
+	Connection *Connection
+	Fields     []Field
+	Attributes []Attribute
+}
+
+// Parse разбирает SDP. Переводы строк — CRLF или LF.
+func Parse(body []byte) (*Session, error) {
+	s := &Session{}
+	var m *Media
+	seenV := false
+
+	for _, line := range strings.Split(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n") {
+		line = strings.TrimRight(line, " \r")
+		if line == "" {
+			continue
+		}
+		if len(line) < 2 || line[1] != '=' {
+			return nil, fmt.Errorf("%w: %q", ErrInvalid, line)
+		}
+		typ, val := line[0], line[2:]
+
+		switch typ {
+		case 'v':
+			v, err := strconv.Atoi(val)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q", ErrInvalid, line)
+			}
+			s.Version, seenV = v, true
+		case 'o':
+			f := strings.Fields(val)
+			if len(f) != 6 {
+				return nil, fmt.Errorf("%w: %q", ErrInvalid, line)
+			}
+			s.Origin = Origin{f[0], f[1], f[2], f[3], f[4], f[5]}
+		case 's':
+			s.Name = val
+		case 'c':
+			c, err := parseConnection(val)
+			if err != nil {
+				return nil, err
+			}
+			if m != nil {
+				m.Connection = c
+			} else {
+				s.Connection = c
+			}
+		case 'm':
+			media, err := parseMedia(val)
+			if err != nil {
+				return nil, err
+			}
+			m = media
+			s.Media = append(s.Media, m)
+		case 'a':
+			a := parseAttribute(val)
+			if m != nil {
+				m.Attributes = append(m.Attributes, a)
+			} else {
+				s.Attributes = append(s.Attributes, a)
+			}
+		default:
+			if m != nil {
+				m.Fields = append(m.Fields, Field{typ, val})
+			} else {
+				s.Fields = append(s.Fields, Field{typ, val})
+			}
+		}
+	}
+
+	if !seenV {
+		return nil, fmt.Errorf("%w: no v= line", ErrInvalid)
+	}
+	return s, nil
+}
+
+func parseConnection(val string) (*Connection, error) {
+	f := strings.Fields(val)
+	if len(f) != 3 {
+		return nil, fmt.Errorf("%w: c=%s", ErrInvalid, val)
+	}
+	return &Connection{f[0], f[1], f[2]}, nil
+}
+
+func parseMedia(val string) (*Media, error) {
+	f := strings.Fields(val)
+	if len(f) < 3 {
+		return nil, fmt.Errorf("%w: m=%s", ErrInvalid, val)
+	}
+	m := &Media{Type: f[0], Proto: f[2], Formats: append([]string(nil), f[3:]...)}
+
+	port, num, hasNum := strings.Cut(f[1], "/")
+	var err error
+	if m.Port, err = strconv.Atoi(port); err != nil {
+		return nil, fmt.Errorf("%w: m=%s", ErrInvalid, val)
+	}
+	if hasNum {
+		if m.NumPorts, err = strconv.Atoi(num); err != nil {
+			return nil, fmt.Errorf("%w: m=%s", ErrInvalid, val)
+		}
+	}
+	return m, nil
+}
+
+func parseAttribute(val string) Attribute {
+	k, v, _ := strings.Cut(val, ":")
+	return Attribute{Key: k, Value: v}
+}
+
+// Marshal собирает SDP в порядке полей RFC 4566 с CRLF.
+func (s *Session) Marshal() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "v=%d\r\n", s.Version)
+	o := s.Origin
+	fmt.Fprintf(&b, "o=%s %s %s %s %s %s\r\n", o.Username, o.SessionID, o.SessionVersion, o.NetType, o.AddrType, o.Address)
+	fmt.Fprintf(&b, "s=%s\r\n", s.Name)
+
+	// i= u= e= p= идут до c=, b= t= r= z= k= — после
+	writeFields(&b, s.Fields, "iuep")
+	writeConnection(&b, s.Connection)
+	writeFields(&b, s.Fields, "btrzk")
+	writeAttributes(&b, s.Attributes)
+
+	for _, m := range s.Media {
+		port := strconv.Itoa(m.Port)
+		if m.NumPorts > 0 {
+			port += "/" + strconv.Itoa(m.NumPorts)
+		}
+		fmt.Fprintf(&b, "m=%s %s %s", m.Type, port, m.Proto)
+		for _, f := range m.Formats {
+			b.WriteString(" " + f)
+		}
+		b.WriteString("\r\n")
+
+		writeFields(&b, m.Fields, "i")
+		writeConnection(&b, m.Connection)
+		writeFields(&b, m.Fields, "bk")
+		writeAttributes(&b, m.Attributes)
+	}
+	return []byte(b.String())
+}
+
+func writeFields(b *strings.Builder, fields []Field, types string) {
+	for _, f := range fields {
+		if strings.IndexByte(types, f.Type) >= 0 {
+			fmt.Fprintf(b, "%c=%s\r\n", f.Type, f.Value)
+		}
+	}
+	// неизвестные поля выводим вместе с последней группой
+	if types == "btrzk" || types == "bk" {
+		for _, f := range fields {
+			if strings.IndexByte("iuepbtrzk", f.Type) < 0 {
+				fmt.Fprintf(b, "%c=%s\r\n", f.Type, f.Value)
+			}
+		}
+	}
+}
+
+func writeConnection(b *strings.Builder, c *Connection) {
+	if c != nil {
+		fmt.Fprintf(b, "c=%s %s %s\r\n", c.NetType, c.AddrType, c.Address)
+	}
+}
+
+func writeAttributes(b *strings.Builder, attrs []Attribute) {
+	for _, a := range attrs {
+		if a.Value == "" {
+			fmt.Fprintf(b, "a=%s\r\n", a.Key)
+		} else {
+			fmt.Fprintf(b, "a=%s:%s\r\n", a.Key, a.Value)
+		}
+	}
+}
+
+// Attribute — значение первого атрибута key уровня сессии.
+func (s *Session) Attribute(key string) (string, bool) {
+	return findAttribute(s.Attributes, key)
+}
+
+// Attribute — значение первого атрибута key у m=.
+func (m *Media) Attribute(key string) (string, bool) {
+	return findAttribute(m.Attributes, key)
+}
+
+func findAttribute(attrs []Attribute, key string) (string, bool) {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// FirstMedia — первый m= данного типа с ненулевым портом.
+func (s *Session) FirstMedia(typ string) *Media {
+	for _, m := range s.Media {
+		if m.Type == typ && m.Port != 0 {
+			return m
+		}
+	}
+	return nil
+}
+
+// ConnectionFor — c= для m=: свой или уровня сессии.
+func (s *Session) ConnectionFor(m *Media) *Connection {
+	if m.Connection != nil {
+		return m.Connection
+	}
+	return s.Connection
+}
+
+// Direction — направление m=: своё, иначе уровня сессии, иначе sendrecv.
+func (s *Session) Direction(m *Media) string {
+	if d := direction(m.Attributes); d != "" {
+		return d
+	}
+	if d := direction(s.Attributes); d != "" {
+		return d
+	}
+	return SendRecv
+}
+
+func direction(attrs []Attribute) string {
+	for _, a := range attrs {
+		switch a.Key {
+		case SendRecv, SendOnly, RecvOnly, Inactive:
+			return a.Key
+		}
+	}
+	return ""
+}
+
+// SetDirection заменяет атрибут направления у m=.
+func (m *Media) SetDirection(dir string) {
+	attrs := m.Attributes[:0]
+	for _, a := range m.Attributes {
+		if a.Key != SendRecv && a.Key != SendOnly && a.Key != RecvOnly && a.Key != Inactive {
+			attrs = append(attrs, a)
+		}
+	}
+	m.Attributes = append(attrs, Attribute{Key: dir})
+}
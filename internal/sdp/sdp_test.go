@@ -0,0 +1,192 @@
+package sdp
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const offer = "v=0\r\n" +
+	"o=alice 2890844526 2890844526 IN IP4 10.0.0.1\r\n" +
+	"s=-\r\n" +
+	"c=IN IP4 10.0.0.1\r\n" +
+	"t=0 0\r\n" +
+	"a=sendrecv\r\n" +
+	"m=audio 49170 RTP/AVP 0 8 101 96\r\n" +
+	"a=rtpmap:0 PCMU/8000\r\n" +
+	"a=rtpmap:101 telephone-event/8000\r\n" +
+	"a=fmtp:101 0-15\r\n" +
+	"a=rtpmap:96 opus/48000/2\r\n" +
+	"a=ptime:20\r\n" +
+	"m=video 51372/2 RTP/AVP 31\r\n" +
+	"c=IN IP4 10.0.0.2\r\n" +
+	"b=AS:256\r\n" +
+	"a=recvonly\r\n"
+
+func TestParseMarshalRoundTrip(t *testing.T) {
+	s, err := Parse([]byte(offer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(s.Marshal()); got != offer {
+		t.Errorf("Marshal mismatch:\n%s\nwant:\n%s", got, offer)
+	}
+
+	// LF вместо CRLF и лишние пробелы в конце строк тоже разбираются
+	lf := strings.ReplaceAll(offer, "\r\n", " \n")
+	s2, err := Parse([]byte(lf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, s2) {
+		t.Errorf("LF parse differs from CRLF parse")
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	s, err := Parse([]byte(offer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Origin.Username != "alice" || s.Origin.Address != "10.0.0.1" {
+		t.Errorf("origin = %+v", s.Origin)
+	}
+	if len(s.Media) != 2 {
+		t.Fatalf("media count = %d, want 2", len(s.Media))
+	}
+	audio, video := s.Media[0], s.Media[1]
+	if audio.Port != 49170 || audio.NumPorts != 0 || audio.Proto != "RTP/AVP" {
+		t.Errorf("audio = %+v", audio)
+	}
+	if video.Port != 51372 || video.NumPorts != 2 {
+		t.Errorf("video port = %d/%d", video.Port, video.NumPorts)
+	}
+	if c := s.ConnectionFor(audio); c == nil || c.Address != "10.0.0.1" {
+		t.Errorf("audio connection = %+v, want session level", c)
+	}
+	if c := s.ConnectionFor(video); c == nil || c.Address != "10.0.0.2" {
+		t.Errorf("video connection = %+v, want media level", c)
+	}
+	if v, ok := audio.Attribute("ptime"); !ok || v != "20" {
+		t.Errorf("ptime = %q, %v", v, ok)
+	}
+	if d := s.Direction(audio); d != SendRecv {
+		t.Errorf("audio direction = %s, want session sendrecv", d)
+	}
+	if d := s.Direction(video); d != RecvOnly {
+		t.Errorf("video direction = %s, want recvonly", d)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no v=", "o=a 1 1 IN IP4 1.1.1.1\r\ns=-\r\n"},
+		{"bad version", "v=x\r\n"},
+		{"bad line", "v=0\r\nbogus\r\n"},
+		{"short origin", "v=0\r\no=a 1 IN IP4 1.1.1.1\r\n"},
+		{"short connection", "v=0\r\nc=IN IP4\r\n"},
+		{"short media", "v=0\r\nm=audio 4000\r\n"},
+		{"bad port", "v=0\r\nm=audio x RTP/AVP 0\r\n"},
+		{"bad port count", "v=0\r\nm=audio 4000/x RTP/AVP 0\r\n"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.body)); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: error = %v, want ErrInvalid", tt.name, err)
+		}
+	}
+}
+
+func TestRTPFormats(t *testing.T) {
+	m := &Media{
+		Formats: []string{"8", "0", "101", "96", "18", "120", "x", "200"},
+		Attributes: []Attribute{
+			{Key: "rtpmap", Value: "101 telephone-event/8000"},
+			{Key: "fmtp", Value: "101 0-16"},
+			{Key: "rtpmap", Value: "96 opus/48000/2"},
+			{Key: "fmtp", Value: "18 annexb=no"},
+		},
+	}
+	want := []Format{
+		{PayloadType: 8, Name: "PCMA", ClockRate: 8000},
+		{PayloadType: 0, Name: "PCMU", ClockRate: 8000},
+		{PayloadType: 101, Name: "telephone-event", ClockRate: 8000, Params: "0-16"},
+		{PayloadType: 96, Name: "opus", ClockRate: 48000, Channels: 2},
+		{PayloadType: 18, Name: "G729", ClockRate: 8000, Params: "annexb=no"},
+		{PayloadType: 120}, // динамический без rtpmap
+	}
+	if got := m.RTPFormats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("RTPFormats =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestKeepFormats(t *testing.T) {
+	s, err := Parse([]byte(offer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	audio := s.Media[0]
+	audio.KeepFormats([]uint8{8, 101})
+
+	if !reflect.DeepEqual(audio.Formats, []string{"8", "101"}) {
+		t.Errorf("formats = %v", audio.Formats)
+	}
+	want := []Attribute{
+		{Key: "rtpmap", Value: "101 telephone-event/8000"},
+		{Key: "fmtp", Value: "101 0-15"},
+		{Key: "ptime", Value: "20"},
+	}
+	if !reflect.DeepEqual(audio.Attributes, want) {
+		t.Errorf("attributes = %+v, want %+v", audio.Attributes, want)
+	}
+}
+
+func TestDisableAndFirstMedia(t *testing.T) {
+	s, err := Parse([]byte(offer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.FirstMedia("video") != s.Media[1] {
+		t.Fatal("video not found")
+	}
+	s.Media[1].Disable()
+	if s.FirstMedia("video") != nil {
+		t.Error("disabled video still returned")
+	}
+	if !strings.Contains(string(s.Marshal()), "m=video 0 RTP/AVP 31\r\n") {
+		t.Errorf("disabled m= line not kept:\n%s", s.Marshal())
+	}
+	if s.FirstMedia("image") != nil {
+		t.Error("unexpected image media")
+	}
+}
+
+func TestSetDirection(t *testing.T) {
+	tests := []struct {
+		attrs []Attribute
+		dir   string
+		want  []Attribute
+	}{
+		{nil, SendOnly, []Attribute{{Key: SendOnly}}},
+		{
+			[]Attribute{{Key: "ptime", Value: "20"}, {Key: SendRecv}},
+			Inactive,
+			[]Attribute{{Key: "ptime", Value: "20"}, {Key: Inactive}},
+		},
+		{
+			[]Attribute{{Key: RecvOnly}, {Key: "rtpmap", Value: "0 PCMU/8000"}, {Key: SendOnly}},
+			SendRecv,
+			[]Attribute{{Key: "rtpmap", Value: "0 PCMU/8000"}, {Key: SendRecv}},
+		},
+	}
+	for _, tt := range tests {
+		m := &Media{Attributes: tt.attrs}
+		m.SetDirection(tt.dir)
+		if !reflect.DeepEqual(m.Attributes, tt.want) {
+			t.Errorf("SetDirection(%s) = %+v, want %+v", tt.dir, m.Attributes, tt.want)
+		}
+	}
+}
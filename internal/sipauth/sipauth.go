@@ -0,0 +1,192 @@
+// Package sipauth — SIP digest (RFC 3261 §22, RFC 2617): HA1 пароля
+// пользователя, выдача nonce и проверка ответа телефона.
+package sipauth
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/icholy/digest"
+)
+
+const (
+	defaultRealm = "SipServer"
+	nonceTTL     = 5 * time.Minute
+)
+
+var (
+	ErrBadCredentials = errors.New("sip digest: bad credentials")
+	ErrStaleNonce     = errors.New("sip digest: stale nonce")
+)
+
+// Realm — SIP_REALM. HA1 считается с ним: после смены realm пароли надо задать заново.
+func Realm() string {
+	if v := os.Getenv("SIP_REALM"); v != "" {
+		return v
+	}
+	return defaultRealm
+}
+
+// HA1 — MD5(login:realm:password), хранится вместо пароля.
+func HA1(login, realm, password string) string {
+	return md5hex(login + ":" + realm + ":" + password)
+}
+
+// Authenticator выдаёт nonce без хранения: время выдачи, подписанное ключом процесса.
+// Принятые пары nonce/nc помнит, пока nonce жив: повтор перехваченного заголовка
+// не проходит.
+type Authenticator struct {
+	realm string
+	key   []byte
+
+	mu        sync.Mutex
+	used      map[string]map[int]bool // nonce -> принятые nc
+	lastPrune time.Time
+}
+
+func New() *Authenticator {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return newAuthenticator(Realm(), key)
+}
+
+func newAuthenticator(realm string, key []byte) *Authenticator {
+	return &Authenticator{realm: realm, key: key, used: make(map[string]map[int]bool)}
+}
+
+// Challenge — значение WWW-Authenticate / Proxy-Authenticate.
+func (a *Authenticator) Challenge(stale bool) string {
+	c := &digest.Challenge{
+		Realm:     a.realm,
+		Nonce:     a.nonce(time.Now()),
+		Algorithm: "MD5",
+		QOP:       []string{"auth"},
+		Stale:     stale,
+	}
+	return c.String()
+}
+
+// Verify проверяет Authorization / Proxy-Authorization запроса method на
+// requestURI от login.
+func (a *Authenticator) Verify(header, method, requestURI, login, ha1 string) error {
+	c, err := digest.ParseCredentials(header)
+	if err != nil {
+		return ErrBadCredentials
+	}
+	if c.Username != login || c.Realm != a.realm {
+		return ErrBadCredentials
+	}
+	// ответ, подсмотренный у другого запроса, не подходит к этому
+	if !sameURI(c.URI, requestURI) {
+		return ErrBadCredentials
+	}
+	if c.Algorithm != "" && !strings.EqualFold(c.Algorithm, "MD5") {
+		return ErrBadCredentials
+	}
+
+	ha2 := md5hex(method + ":" + c.URI)
+	var want string
+	switch c.QOP {
+	case "":
+		want = md5hex(ha1 + ":" + c.Nonce + ":" + ha2)
+	case "auth":
+		want = md5hex(fmt.Sprintf("%s:%s:%08x:%s:%s:%s", ha1, c.Nonce, c.Nc, c.Cnonce, c.QOP, ha2))
+	default:
+		return ErrBadCredentials
+	}
+	if subtle.ConstantTimeCompare([]byte(want), []byte(strings.ToLower(c.Response))) != 1 {
+		return ErrBadCredentials
+	}
+
+	// пароль верный, но nonce старый или уже использован с этим nc: телефон
+	// повторит запрос с новым nonce без вопросов к пользователю
+	now := time.Now()
+	if !a.validNonce(c.Nonce, now) || !a.use(c.Nonce, c.Nc, now) {
+		return ErrStaleNonce
+	}
+	return nil
+}
+
+// use отмечает пару nonce/nc; false — она уже была. Без qop nc = 0: такой
+// nonce одноразовый.
+func (a *Authenticator) use(nonce string, nc int, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if now.Sub(a.lastPrune) > nonceTTL {
+		for n := range a.used {
+			if !a.validNonce(n, now) {
+				delete(a.used, n)
+			}
+		}
+		a.lastPrune = now
+	}
+
+	ncs := a.used[nonce]
+	if ncs == nil {
+		ncs = make(map[int]bool)
+		a.used[nonce] = ncs
+	}
+	if ncs[nc] {
+		return false
+	}
+	ncs[nc] = true
+	return true
+}
+
+// sameURI — digest-uri указывает на тот же Request-URI: схема, user и host:port
+// без параметров; порт 5060 можно не писать.
+func sameURI(a, b string) bool {
+	return normalizeURI(a) == normalizeURI(b)
+}
+
+func normalizeURI(uri string) string {
+	uri = strings.ToLower(strings.TrimSpace(uri))
+	if i := strings.IndexAny(uri, ";?"); i >= 0 {
+		uri = uri[:i]
+	}
+	if strings.HasPrefix(uri, "sip:") {
+		uri = strings.TrimSuffix(uri, ":5060")
+	}
+	return uri
+}
+
+// nonce — "<unix-время hex>.<hmac>".
+func (a *Authenticator) nonce(now time.Time) string {
+	ts := strconv.FormatInt(now.Unix(), 16)
+	return ts + "." + a.sign(ts)
+}
+
+func (a *Authenticator) validNonce(nonce string, now time.Time) bool {
+	ts, sig, ok := strings.Cut(nonce, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(a.sign(ts))) {
+		return false
+	}
+	sec, err := strconv.ParseInt(ts, 16, 64)
+	if err != nil {
+		return false
+	}
+	return now.Sub(time.Unix(sec, 0)) <= nonceTTL
+}
+
+func (a *Authenticator) sign(ts string) string {
+	m := hmac.New(sha256.New, a.key)
+	m.Write([]byte(ts))
+	return hex.EncodeToString(m.Sum(nil))[:32]
+}
+
+func md5hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,193 @@
+package sipauth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icholy/digest"
+)
+
+func TestHA1(t *testing.T) {
+	// пример из RFC 2617 §3.5
+	if got := HA1("Mufasa", "testrealm@host.com", "Circle Of Life"); got != "939e7578ed9e3c518a452acee763bce9" {
+		t.Errorf("HA1 = %s", got)
+	}
+}
+
+func TestRealm(t *testing.T) {
+	t.Setenv("SIP_REALM", "")
+	if got := Realm(); got != defaultRealm {
+		t.Errorf("Realm() = %q, want %q", got, defaultRealm)
+	}
+	t.Setenv("SIP_REALM", "pbx.example.com")
+	if got := Realm(); got != "pbx.example.com" {
+		t.Errorf("Realm() = %q", got)
+	}
+}
+
+func newTestAuthenticator(key string) *Authenticator {
+	return newAuthenticator("pbx", []byte(key))
+}
+
+// answer — ответ телефона на вызов с nonce для запроса на sip:pbx; qop "" —
+// старый RFC 2069 без cnonce.
+func answer(t *testing.T, nonce, qop, method, login, realm, password string) string {
+	t.Helper()
+	return answerNC(t, nonce, qop, 1, method, login, realm, password)
+}
+
+func answerNC(t *testing.T, nonce, qop string, nc int, method, login, realm, password string) string {
+	t.Helper()
+	chal := &digest.Challenge{Realm: realm, Nonce: nonce, Algorithm: "MD5"}
+	if qop != "" {
+		chal.QOP = []string{qop}
+	}
+	cred, err := digest.Digest(chal, digest.Options{
+		Method:   method,
+		URI:      "sip:pbx",
+		Count:    nc,
+		Username: login,
+		Password: password,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cred.String()
+}
+
+func TestVerify(t *testing.T) {
+	a := newTestAuthenticator("test-key")
+	ha1 := HA1("101", "pbx", "secret")
+	fresh := a.nonce(time.Now())
+	expired := a.nonce(time.Now().Add(-nonceTTL - time.Minute))
+	ts, _, _ := strings.Cut(fresh, ".")
+	forged := ts + "." + strings.Repeat("0", 32)
+
+	tests := []struct {
+		name   string
+		header string
+		method string
+		want   error
+	}{
+		{"qop auth", answer(t, fresh, "auth", "REGISTER", "101", "pbx", "secret"), "REGISTER", nil},
+		{"no qop", answer(t, fresh, "", "INVITE", "101", "pbx", "secret"), "INVITE", nil},
+		{"wrong password", answer(t, fresh, "auth", "REGISTER", "101", "pbx", "guess"), "REGISTER", ErrBadCredentials},
+		{"wrong user", answer(t, fresh, "auth", "REGISTER", "102", "pbx", "secret"), "REGISTER", ErrBadCredentials},
+		{"wrong realm", answer(t, fresh, "auth", "REGISTER", "101", "other", "secret"), "REGISTER", ErrBadCredentials},
+		{"method mismatch", answer(t, fresh, "auth", "REGISTER", "101", "pbx", "secret"), "INVITE", ErrBadCredentials},
+		{"unsupported qop", answer(t, fresh, "auth-int", "REGISTER", "101", "pbx", "secret"), "REGISTER", ErrBadCredentials},
+		{"garbage header", "Basic MTAxOnNlY3JldA==", "REGISTER", ErrBadCredentials},
+		{"expired nonce", answer(t, expired, "auth", "REGISTER", "101", "pbx", "secret"), "REGISTER", ErrStaleNonce},
+		{"forged nonce", answer(t, forged, "auth", "REGISTER", "101", "pbx", "secret"), "REGISTER", ErrStaleNonce},
+		{"foreign nonce", answer(t, "abc", "auth", "REGISTER", "101", "pbx", "secret"), "REGISTER", ErrStaleNonce},
+	}
+	for _, tt := range tests {
+		if err := a.Verify(tt.header, tt.method, "sip:pbx", "101", ha1); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestVerifyRequestURI(t *testing.T) {
+	a := newTestAuthenticator("test-key")
+	ha1 := HA1("101", "pbx", "secret")
+	nonce := a.nonce(time.Now())
+
+	tests := []struct {
+		uri  string
+		want error
+	}{
+		{"sip:pbx", nil},
+		{"SIP:PBX:5060;transport=udp", nil},
+		{"sip:pbx?Subject=x", nil},
+		{"sip:200@pbx", ErrBadCredentials},
+		{"sip:pbx:5070", ErrBadCredentials},
+		{"sips:pbx", ErrBadCredentials},
+	}
+	for i, tt := range tests {
+		// у каждого запроса свой nc
+		header := answerNC(t, nonce, "auth", i+1, "INVITE", "101", "pbx", "secret")
+		if err := a.Verify(header, "INVITE", tt.uri, "101", ha1); !errors.Is(err, tt.want) {
+			t.Errorf("Verify(uri=%s) error = %v, want %v", tt.uri, err, tt.want)
+		}
+	}
+}
+
+func TestVerifyReplay(t *testing.T) {
+	a := newTestAuthenticator("test-key")
+	ha1 := HA1("101", "pbx", "secret")
+	nonce := a.nonce(time.Now())
+	first := answerNC(t, nonce, "auth", 1, "INVITE", "101", "pbx", "secret")
+	second := answerNC(t, nonce, "auth", 2, "INVITE", "101", "pbx", "secret")
+	noQOP := answer(t, a.nonce(time.Now()), "", "INVITE", "101", "pbx", "secret")
+
+	steps := []struct {
+		name   string
+		header string
+		want   error
+	}{
+		{"first use", first, nil},
+		{"replayed nc", first, ErrStaleNonce},
+		{"next nc", second, nil},
+		{"replayed next nc", second, ErrStaleNonce},
+		{"no qop first use", noQOP, nil},
+		{"no qop replay", noQOP, ErrStaleNonce},
+	}
+	for _, st := range steps {
+		if err := a.Verify(st.header, "INVITE", "sip:pbx", "101", ha1); !errors.Is(err, st.want) {
+			t.Errorf("%s: Verify error = %v, want %v", st.name, err, st.want)
+		}
+	}
+
+	// истёкшие nonce забываются
+	later := time.Now().Add(2 * nonceTTL)
+	a.use(a.nonce(later), 1, later)
+	if _, ok := a.used[nonce]; ok || len(a.used) != 1 {
+		t.Errorf("%d nonces kept after prune", len(a.used))
+	}
+}
+
+func TestValidNonce(t *testing.T) {
+	a := newTestAuthenticator("test-key")
+	// в nonce время с точностью до секунды
+	now := time.Now().Truncate(time.Second)
+	issued := a.nonce(now)
+
+	tests := []struct {
+		name  string
+		nonce string
+		at    time.Time
+		want  bool
+	}{
+		{"fresh", issued, now, true},
+		{"at ttl", issued, now.Add(nonceTTL), true},
+		{"after ttl", issued, now.Add(nonceTTL + time.Second), false},
+		{"other key", newTestAuthenticator("other").nonce(now), now, false},
+		{"no signature", strings.Split(issued, ".")[0], now, false},
+		{"bad timestamp", "zz." + a.sign("zz"), now, false},
+		{"empty", "", now, false},
+	}
+	for _, tt := range tests {
+		if got := a.validNonce(tt.nonce, tt.at); got != tt.want {
+			t.Errorf("%s: validNonce = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	a := newTestAuthenticator("test-key")
+	for _, stale := range []bool{false, true} {
+		c, err := digest.ParseChallenge(a.Challenge(stale))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Realm != "pbx" || c.Algorithm != "MD5" || !c.SupportsQOP("auth") || c.Stale != stale {
+			t.Errorf("Challenge(%v) = %+v", stale, c)
+		}
+		if !a.validNonce(c.Nonce, time.Now()) {
+			t.Errorf("Challenge(%v) nonce %q is not valid", stale, c.Nonce)
+		}
+	}
+}
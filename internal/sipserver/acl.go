@@ -0,0 +1,30 @@
+package sipserver
+
+import (
+	"log"
+
+	"SipServer/internal/metrics"
+	aclrule "SipServer/internal/repository/acl_rule"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// aclPermits проверяет сетевые ACL; false — запрос уже отклонён.
+// Транки проверяются при разборе INVITE (inboundTrunk): чужой адрес
+// просто не считается транком.
+func (s *Server) aclPermits(req *sip.Request, tx sip.ServerTransaction) bool {
+	if req.Method != sip.REGISTER {
+		return true
+	}
+	ip := sourceIP(req)
+	if s.acl.Allowed(aclrule.ScopeRegister, ip) {
+		return true
+	}
+
+	log.Printf("[ACL] REGISTER from %s denied (aor=%s)", ip, requestAOR(req))
+	metrics.ACLDenied.WithLabelValues(string(aclrule.ScopeRegister)).Inc()
+	if tx != nil {
+		_, _ = respond(req, tx, sip.StatusForbidden, "Forbidden")
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package sipserver
+
+import (
+	"context"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"SipServer/internal/metrics"
+	userrepo "SipServer/internal/repository/user"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// через сколько секунд звонящему стоит повторить звонок, упёршийся в лимит
+const admissionRetryAfter = 30
+
+// callAdmission — лимиты одновременных звонков. Звонок занимает слот с INVITE
+// (пока звонит, в том числе ранний диалог) и до BYE.
+type callAdmission struct {
+	maxCalls int // на весь сервер, 0 — без ограничения
+
+	mu      sync.Mutex
+	pending map[*InviteCtx]*pendingInvite // INVITE без финального ответа
+}
+
+// pendingInvite — кто звонит и на кого из пользователей уже заняты входящие слоты.
+type pendingInvite struct {
+	caller  string
+	callees []string
+}
+
+func newCallAdmission() *callAdmission {
+	a := &callAdmission{pending: make(map[*InviteCtx]*pendingInvite)}
+	if v, err := strconv.Atoi(os.Getenv("MAX_CALLS")); err == nil && v > 0 {
+		a.maxCalls = v
+	}
+	metrics.SIPCallLimit.Set(float64(a.maxCalls))
+	return a
+}
+
+// tryAdd занимает слот, если limit не против: проверка и резерв под одним a.mu,
+// чтобы одновременные INVITE не прошли по одному и тому же свободному месту.
+// limit получает INVITE без финального ответа (всего, от caller и к нему;
+// ringing — звонки, которые звонят caller сейчас) и возвращает сработавший
+// лимит; "" — слот занят.
+func (a *callAdmission) tryAdd(ictx *InviteCtx, caller string, ringing []*InviteCtx, limit func(total, out, in int) string) string {
+	a.mu.Lock()
+	if l := limit(a.countLocked(caller, ringing)); l != "" {
+		a.mu.Unlock()
+		return l
+	}
+	a.pending[ictx] = &pendingInvite{caller: caller}
+	n := len(a.pending)
+	a.mu.Unlock()
+	metrics.SIPPendingInvites.Set(float64(n))
+	return ""
+}
+
+// tryAddCallee — то же для вызываемого: входящий слот callee за уже принятым
+// ictx. Освобождается вместе со слотом звонка (remove).
+func (a *callAdmission) tryAddCallee(ictx *InviteCtx, callee string, ringing []*InviteCtx, limit func(out, in int) string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	_, out, in := a.countLocked(callee, ringing)
+	if l := limit(out, in); l != "" {
+		return l
+	}
+	if p := a.pending[ictx]; p != nil && !slices.Contains(p.callees, callee) {
+		p.callees = append(p.callees, callee)
+	}
+	return ""
+}
+
+func (a *callAdmission) remove(ictx *InviteCtx) {
+	a.mu.Lock()
+	delete(a.pending, ictx)
+	n := len(a.pending)
+	a.mu.Unlock()
+	metrics.SIPPendingInvites.Set(float64(n))
+}
+
+// count — INVITE без финального ответа: всего, от login и к login.
+// Отвеченный звонок уже учтён в диалогах, пока транзакция не закрылась.
+func (a *callAdmission) count(login string, ringing []*InviteCtx) (total, out, in int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.countLocked(login, ringing)
+}
+
+// countLocked: входящие — занятые слоты login и звонки из ringing; звонок на
+// login обычно есть и там, и там, считается один раз.
+func (a *callAdmission) countLocked(login string, ringing []*InviteCtx) (total, out, in int) {
+	reserved := make(map[*InviteCtx]bool)
+	for ictx, p := range a.pending {
+		if !ictx.unanswered() {
+			continue
+		}
+		total++
+		if login == "" {
+			continue
+		}
+		if p.caller == login {
+			out++
+		}
+		if slices.Contains(p.callees, login) {
+			reserved[ictx] = true
+		}
+	}
+	in = len(reserved)
+	for _, ictx := range ringing {
+		if !reserved[ictx] {
+			in++
+		}
+	}
+	return total, out, in
+}
+
+// unanswered — у INVITE ещё нет финального ответа и диалога.
+func (ictx *InviteCtx) unanswered() bool {
+	if ictx.Got2xx || ictx.DialogCreated.Load() {
+		return false
+	}
+	res := ictx.LastResp
+	return res == nil || res.StatusCode < 200
+}
+
+// userCalls — звонки пользователя сейчас: исходящие (включая ещё не отвеченные)
+// и входящие (звонящие и ранние диалоги).
+func (s *Server) userCalls(login string) (outbound, inbound int) {
+	_, out, in := s.admission.count(login, s.ringing.calls(login))
+	outbound, inbound = s.dialogCalls(login)
+	return outbound + out, inbound + in
+}
+
+// dialogCalls — звонки пользователя в установленных диалогах; ранние и
+// неотвеченные считает callAdmission.
+func (s *Server) dialogCalls(login string) (outbound, inbound int) {
+	seen := make(map[string]bool)
+	s.dialogs.Range(func(_, v any) bool {
+		dlg, ok := v.(*DialogCtx)
+		if !ok || dlg.Early || seen[dlg.CallID] {
+			return true
+		}
+		switch {
+		case dlg.Leg != nil:
+			// сторона звонка, соединённого сервером: INVITE на неё отправил сервер — входящий
+			if dlg.CallerUser != login {
+				return true
+			}
+			if dlg.Leg.Outgoing {
+				inbound++
+			} else {
+				outbound++
+			}
+		case dlg.CallerUser == login:
+			outbound++
+		case dlg.CalleeUser == login:
+			inbound++
+		default:
+			return true
+		}
+		seen[dlg.CallID] = true
+		return true
+	})
+	return outbound, inbound
+}
+
+// admitCall проверяет глобальный лимит и исходящие лимиты caller ("" — звонок не
+// от нашего пользователя) и занимает слот. false — INVITE уже отклонён.
+func (s *Server) admitCall(ictx *InviteCtx, caller string) bool {
+	var u *userrepo.User
+	var out, in int
+	var ringing []*InviteCtx
+	if caller != "" {
+		if found, err := s.userRepositoriy.FindByLoginWithConfig(caller); err == nil {
+			u = found
+			out, in = s.dialogCalls(caller)
+			ringing = s.ringing.calls(caller)
+		}
+	}
+
+	maxCalls := s.admission.maxCalls
+	limit := s.admission.tryAdd(ictx, caller, ringing, func(pending, pendingOut, pendingIn int) string {
+		out, in = out+pendingOut, in+pendingIn
+		if maxCalls > 0 && pending+int(atomic.LoadInt64(&s.activeDialog)) >= maxCalls {
+			return "global"
+		}
+		if u == nil {
+			return ""
+		}
+		return overLimit(u, out, in, true)
+	})
+	switch limit {
+	case "":
+	case "global":
+		log.Printf("[ADMISSION] global limit %d reached", maxCalls)
+		s.rejectOverLimit(ictx, limit, sip.StatusServiceUnavailable, "Service Unavailable")
+		return false
+	default:
+		log.Printf("[ADMISSION] caller=%s %s limit reached (out=%d in=%d)", caller, limit, out, in)
+		s.rejectOverLimit(ictx, limit, sip.StatusBusyHere, "Busy Here")
+		return false
+	}
+
+	if !ictx.ServerTx.OnTerminate(func(string, error) { s.admission.remove(ictx) }) {
+		s.admission.remove(ictx)
+	}
+	return true
+}
+
+// reserveCallee занимает входящий слот callee за ictx; "" — звонить можно,
+// иначе — сработавший лимит. Проверка и резерв атомарны, как у звонящего.
+func (s *Server) reserveCallee(ictx *InviteCtx, callee *userrepo.User) string {
+	out, in := s.dialogCalls(callee.Login)
+	limit := s.admission.tryAddCallee(ictx, callee.Login, s.ringing.calls(callee.Login), func(pendingOut, pendingIn int) string {
+		out, in = out+pendingOut, in+pendingIn
+		return overLimit(callee, out, in, false)
+	})
+	if limit != "" {
+		log.Printf("[ADMISSION] callee=%s %s limit reached (out=%d in=%d)", callee.Login, limit, out, in)
+	}
+	return limit
+}
+
+// overLimit — какой лимит пользователя не пускает ещё один звонок.
+func overLimit(u *userrepo.User, out, in int, outgoing bool) string {
+	c := u.Config
+	switch {
+	case outgoing && c.MaxOutboundCalls > 0 && out >= c.MaxOutboundCalls:
+		return "outbound"
+	case !outgoing && c.MaxInboundCalls > 0 && in >= c.MaxInboundCalls:
+		return "inbound"
+	case c.MaxCalls > 0 && out+in >= c.MaxCalls:
+		return "total"
+	}
+	return ""
+}
+
+// rejectOverLimit отклоняет звонок с Retry-After: 486 — занят пользователь,
+// 503 — сервер.
+func (s *Server) rejectOverLimit(ictx *InviteCtx, limit string, code int, reason string) {
+	metrics.SIPCallsRejected.WithLabelValues(limit).Inc()
+
+	if s.callJournalRepo != nil && ictx.JournalID != 0 {
+		if code == sip.StatusBusyHere {
+			_ = s.callJournalRepo.MarkRejected(context.Background(), ictx.JournalID, code, reason, time.Now())
+		} else {
+			_ = s.callJournalRepo.MarkFailed(context.Background(), ictx.JournalID, code, reason, time.Now())
+		}
+	}
+	s.rejectInvite(ictx, code, reason, sip.NewHeader("Retry-After", strconv.Itoa(admissionRetryAfter)))
+}
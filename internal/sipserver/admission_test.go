@@ -0,0 +1,155 @@
+package sipserver
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	userrepo "SipServer/internal/repository/user"
+)
+
+func TestCallAdmissionTryAddIsAtomic(t *testing.T) {
+	const limit, callers = 5, 50
+	a := &callAdmission{pending: make(map[*InviteCtx]*pendingInvite)}
+
+	var admitted atomic.Int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			l := a.tryAdd(NewInviteCtx(), "101", nil, func(total, out, in int) string {
+				if out >= limit {
+					return "outbound"
+				}
+				return ""
+			})
+			if l == "" {
+				admitted.Add(1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if n := admitted.Load(); n != limit {
+		t.Errorf("admitted %d concurrent INVITEs, want %d", n, limit)
+	}
+	if total, out, _ := a.count("101", nil); total != limit || out != limit {
+		t.Errorf("count = %d/%d, want %d/%d", total, out, limit, limit)
+	}
+}
+
+func TestCallAdmissionTryAddCalleeIsAtomic(t *testing.T) {
+	const limit, callers = 2, 50
+	a := &callAdmission{pending: make(map[*InviteCtx]*pendingInvite)}
+
+	var admitted atomic.Int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ictx := NewInviteCtx()
+			if l := a.tryAdd(ictx, "", nil, func(int, int, int) string { return "" }); l != "" {
+				t.Error("caller slot rejected")
+				return
+			}
+			<-start
+			l := a.tryAddCallee(ictx, "200", nil, func(out, in int) string {
+				if in >= limit {
+					return "inbound"
+				}
+				return ""
+			})
+			if l == "" {
+				admitted.Add(1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if n := admitted.Load(); n != limit {
+		t.Errorf("callee got %d concurrent INVITEs, want %d", n, limit)
+	}
+	if _, _, in := a.count("200", nil); in != limit {
+		t.Errorf("inbound = %d, want %d", in, limit)
+	}
+}
+
+func TestCallAdmissionCount(t *testing.T) {
+	a := &callAdmission{pending: make(map[*InviteCtx]*pendingInvite)}
+	none := func(int, int, int) string { return "" }
+	noneCallee := func(int, int) string { return "" }
+
+	ringing := NewInviteCtx()
+	answered := NewInviteCtx()
+	answered.Got2xx = true
+	fromTrunk := NewInviteCtx()
+	for ictx, caller := range map[*InviteCtx]string{ringing: "101", answered: "101", fromTrunk: ""} {
+		if l := a.tryAdd(ictx, caller, nil, none); l != "" {
+			t.Fatalf("tryAdd rejected with %s", l)
+		}
+	}
+	// 200 звонят с транка и от 101: слоты заняты, один звонок уже в ringing
+	a.tryAddCallee(fromTrunk, "200", nil, noneCallee)
+	a.tryAddCallee(ringing, "200", nil, noneCallee)
+	a.tryAddCallee(answered, "200", nil, noneCallee)
+	a.tryAddCallee(NewInviteCtx(), "200", nil, noneCallee) // не принят tryAdd — не резервируется
+	other := NewInviteCtx()                                // в ringing без резерва
+
+	tests := []struct {
+		login          string
+		ringing        []*InviteCtx
+		total, out, in int
+	}{
+		// отвеченный звонок уже учтён в диалогах
+		{"101", nil, 2, 1, 0},
+		{"200", nil, 2, 0, 2},
+		{"200", []*InviteCtx{ringing, other}, 2, 0, 3},
+		{"", nil, 2, 0, 0},
+	}
+	for _, tt := range tests {
+		total, out, in := a.count(tt.login, tt.ringing)
+		if total != tt.total || out != tt.out || in != tt.in {
+			t.Errorf("count(%q) = %d/%d/%d, want %d/%d/%d", tt.login, total, out, in, tt.total, tt.out, tt.in)
+		}
+	}
+
+	a.remove(ringing)
+	if total, out, in := a.count("101", nil); total != 1 || out != 0 || in != 0 {
+		t.Errorf("after remove count = %d/%d/%d, want 1/0/0", total, out, in)
+	}
+	if _, _, in := a.count("200", nil); in != 1 {
+		t.Errorf("after remove inbound = %d, want 1", in)
+	}
+}
+
+func TestOverLimit(t *testing.T) {
+	user := func(maxCalls, maxOut, maxIn int) *userrepo.User {
+		return &userrepo.User{Config: &userrepo.UserConfig{MaxCalls: maxCalls, MaxOutboundCalls: maxOut, MaxInboundCalls: maxIn}}
+	}
+	tests := []struct {
+		u        *userrepo.User
+		out, in  int
+		outgoing bool
+		want     string
+	}{
+		{user(0, 0, 0), 100, 100, true, ""},
+		{user(0, 2, 0), 1, 5, true, ""},
+		{user(0, 2, 0), 2, 0, true, "outbound"},
+		{user(0, 2, 0), 2, 0, false, ""},
+		{user(0, 0, 1), 0, 1, false, "inbound"},
+		{user(3, 0, 0), 2, 1, true, "total"},
+		{user(3, 0, 0), 1, 1, false, ""},
+	}
+	for i, tt := range tests {
+		if got := overLimit(tt.u, tt.out, tt.in, tt.outgoing); got != tt.want {
+			t.Errorf("case %d: overLimit = %q, want %q", i, got, tt.want)
+		}
+	}
+}
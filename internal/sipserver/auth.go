@@ -0,0 +1,69 @@
+package sipserver
+
+import (
+	"errors"
+	"log"
+
+	"github.com/emiago/sipgo/sip"
+
+	"SipServer/internal/metrics"
+	userrepo "SipServer/internal/repository/user"
+	"SipServer/internal/sipauth"
+)
+
+// authenticate проверяет digest запроса от u. Пользователь без пароля (sip_ha1
+// пуст) проходит как раньше. false — ответ 401/407 с вызовом уже отправлен.
+func (s *Server) authenticate(req *sip.Request, tx sip.ServerTransaction, u *userrepo.User) bool {
+	if u.SIPHA1 == "" {
+		return true
+	}
+
+	// REGISTER проверяет registrar (401), остальное — прокси (407)
+	authHeader, challengeHeader, code, reason := "Authorization", "WWW-Authenticate", sip.StatusUnauthorized, "Unauthorized"
+	if req.Method != sip.REGISTER {
+		authHeader, challengeHeader, code, reason = "Proxy-Authorization", "Proxy-Authenticate", sip.StatusProxyAuthRequired, "Proxy Authentication Required"
+	}
+
+	stale := false
+	if h := req.GetHeader(authHeader); h != nil {
+		err := s.auth.Verify(h.Value(), string(req.Method), req.Recipient.String(), u.Login, u.SIPHA1)
+		if err == nil {
+			return true
+		}
+		stale = errors.Is(err, sipauth.ErrStaleNonce)
+		if !stale {
+			metrics.SIPAuthFailures.WithLabelValues(string(req.Method)).Inc()
+			log.Printf("[AUTH] %s user=%s source=%s bad credentials", req.Method, u.Login, req.Source())
+		}
+	}
+
+	respond(req, tx, code, reason, sip.NewHeader(challengeHeader, s.auth.Challenge(stale)))
+	return false
+}
+
+// authorizeCaller — запрос от внутреннего абонента (INVITE, MESSAGE, PUBLISH,
+// SUBSCRIBE): From должен быть существующим включённым пользователем, с паролем —
+// ещё и пройти digest. Не транк и не пользователь — 403.
+func (s *Server) authorizeCaller(req *sip.Request, tx sip.ServerTransaction) (*userrepo.User, bool) {
+	login := ""
+	if from := req.From(); from != nil {
+		login = from.Address.User
+	}
+	u, err := s.userRepositoriy.FindByLoginWithConfig(login)
+	if err != nil {
+		if errors.Is(err, userrepo.ErrUserNotFound) {
+			log.Printf("[%s] from=%s unknown user, source=%s", req.Method, login, req.Source())
+			respond(req, tx, sip.StatusForbidden, "Forbidden")
+			return nil, false
+		}
+		log.Printf("[%s] from=%s lookup error %v", req.Method, login, err)
+		respond(req, tx, sip.StatusInternalServerError, "Internal Server Error")
+		return nil, false
+	}
+	if !u.Enabled {
+		log.Printf("[%s] from=%s disabled", req.Method, login)
+		respond(req, tx, sip.StatusForbidden, "Forbidden")
+		return nil, false
+	}
+	return u, s.authenticate(req, tx, u)
+}
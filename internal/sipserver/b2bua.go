@@ -0,0 +1,157 @@
+package sipserver
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"SipServer/internal/media"
+	"SipServer/internal/metrics"
+	userrepo "SipServer/internal/repository/user"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// inviteB2BUA — звонок через сервер как B2BUA: caller'у отвечает сам сервер,
+// к callee уходит новый INVITE со своим Call-ID, тегами и CSeq.
+// Адреса и заголовки одной стороны другой не видны.
+func (s *Server) inviteB2BUA(ictx *InviteCtx, callee *userrepo.User, target sip.Uri) {
+	req := ictx.OriginInvite
+	caller := strings.TrimSpace(req.From().Address.User)
+
+	out := s.newInvite(target,
+		sip.Uri{Scheme: "sip", User: caller, Host: s.host},
+		sip.Uri{Scheme: "sip", User: callee.Login, Host: s.host},
+		req.Body(),
+	)
+	out.From().DisplayName = req.From().DisplayName
+	s.assertIdentity(ictx, out, nil)
+	if err := ictx.Codecs.applyOffer(out); err != nil {
+		log.Printf("[B2BUA] callee=%s %v", callee.Login, err)
+		s.failInvite(ictx, sip.StatusNotAcceptableHere, "Not Acceptable Here")
+		return
+	}
+	ictx.LocalTag = sip.GenerateTagN(16)
+
+	if s.shouldRecord(ictx, callee) {
+		ictx.Recording = s.startRecording(ictx, out)
+	}
+
+	clTx, err := s.cl.TransactionRequest(context.Background(), out)
+	if err != nil {
+		s.discardRecording(ictx.Recording)
+		s.rejectInvite(ictx, sip.StatusServiceUnavailable, "User Unavailable")
+		return
+	}
+	ictx.ClientTx = clTx
+	ictx.OutInvite = out
+	s.ringing.add(callee.Login, ictx)
+
+	go s.proxyWithVoicemail(ictx, clTx, callee, func(resp *sip.Response) {
+		s.relayB2BUAResponse(ictx, callee, resp)
+	})
+}
+
+// relayB2BUAResponse переносит ответ callee на сторону caller'а.
+func (s *Server) relayB2BUAResponse(ictx *InviteCtx, callee *userrepo.User, resp *sip.Response) {
+	out := ictx.OutInvite
+
+	// звонок забрал сервер (pickup, voicemail)
+	if ictx.Diverted.Load() {
+		s.discardRecording(ictx.Recording)
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			s.hangupStray(out, resp)
+		}
+		return
+	}
+
+	code := int(resp.StatusCode)
+	switch {
+	case code == sip.StatusTrying:
+		// 100 caller'у уже отправлен
+
+	case code < 200:
+		body := ictx.Codecs.answer(resp.Body())
+		if ictx.Recording != nil && len(body) > 0 {
+			body = ictx.Recording.anchor(media.RelayB, body, s.host)
+		}
+		res := s.uasResponse(ictx, code, resp.Reason, body)
+		ictx.LastResp = res
+		_ = ictx.ServerTx.Respond(res)
+
+	case code < 300:
+		s.answerB2BUA(ictx, callee, resp)
+
+	default:
+		s.ringing.remove(ictx)
+		s.discardRecording(ictx.Recording)
+
+		if s.callJournalRepo != nil && ictx.JournalID != 0 {
+			switch code {
+			case sip.StatusBusyHere, sip.StatusGlobalDecline:
+				_ = s.callJournalRepo.MarkRejected(context.Background(), ictx.JournalID, code, resp.Reason, time.Now())
+			case sip.StatusRequestTerminated:
+				_ = s.callJournalRepo.MarkCancelled(context.Background(), ictx.JournalID, time.Now())
+			}
+		}
+		if ictx.Trunk != "" {
+			metrics.TrunkCalls.WithLabelValues(ictx.Trunk, "rejected").Inc()
+		}
+		s.rejectInvite(ictx, code, resp.Reason)
+	}
+}
+
+// answerB2BUA — callee ответил: подтверждаем его 200 OK, отвечаем caller'у
+// и связываем стороны как при pickup.
+func (s *Server) answerB2BUA(ictx *InviteCtx, callee *userrepo.User, resp *sip.Response) {
+	if !ictx.DialogCreated.CompareAndSwap(false, true) {
+		return
+	}
+	s.ringing.remove(ictx)
+
+	out := ictx.OutInvite
+	localTag, _ := out.From().Params.Get("tag")
+	remoteTag, _ := resp.To().Params.Get("tag")
+
+	legB := &bridgeLeg{
+		CallID:    out.CallID().Value(),
+		LocalTag:  localTag,
+		RemoteTag: remoteTag,
+		LocalURI:  out.From().Address,
+		RemoteURI: out.To().Address,
+		Target:    out.Recipient,
+		User:      callee.Login,
+		AnswerAt:  time.Now(),
+		Outgoing:  true,
+	}
+	if ct := resp.Contact(); ct != nil {
+		legB.Target = ct.Address
+	}
+	legB.cseq.Store(out.CSeq().SeqNo)
+
+	ack := s.legRequest(legB, sip.ACK)
+	ack.CSeq().SeqNo = out.CSeq().SeqNo
+	if err := s.cl.WriteRequest(ack); err != nil {
+		log.Printf("[B2BUA] ACK error: %v", err)
+	}
+
+	answer := ictx.Codecs.answer(resp.Body())
+	s.storeCodec(ictx, answer)
+	if ictx.Recording != nil {
+		answer = ictx.Recording.anchor(media.RelayB, answer, s.host)
+		ictx.Recording.answered()
+	}
+
+	// сторона caller'а пишет журнал звонка, сторона callee — нет
+	legA := s.answerLeg(ictx, answer)
+	s.linkLegs(legA, legB, ictx.Recording)
+
+	atomic.AddInt64(&s.activeDialog, 1)
+	sipActiveDialogsSet(atomic.LoadInt64(&s.activeDialog))
+
+	if ictx.Trunk != "" {
+		metrics.TrunkCalls.WithLabelValues(ictx.Trunk, "answered").Inc()
+	}
+}
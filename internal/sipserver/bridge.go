@@ -0,0 +1,309 @@
+package sipserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"SipServer/internal/repository"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// bridgeLeg — сторона диалога, где второй стороной для телефона выступает сам сервер.
+type bridgeLeg struct {
+	CallID    string
+	LocalTag  string // tag сервера
+	RemoteTag string // tag телефона
+	LocalURI  sip.Uri
+	RemoteURI sip.Uri
+	Target    sip.Uri // Contact телефона
+	User      string
+	JournalID int64
+	AnswerAt  time.Time
+	Trunk     string
+	Outgoing  bool // INVITE отправил сервер
+
+	cseq atomic.Uint32
+}
+
+func (l *bridgeLeg) keys() (string, string) {
+	return MakeDialogKey(l.CallID, l.RemoteTag, l.LocalTag)
+}
+
+// journalTags — from/to tag в том порядке, в каком сессия записана в call_sessions.
+func (l *bridgeLeg) journalTags() (string, string) {
+	if l.Outgoing {
+		return l.LocalTag, l.RemoteTag
+	}
+	return l.RemoteTag, l.LocalTag
+}
+
+// answerLeg отвечает 200 OK на входящий INVITE с заданным SDP.
+func (s *Server) answerLeg(ictx *InviteCtx, sdp []byte) *bridgeLeg {
+	inv := ictx.OriginInvite
+
+	res := s.uasResponse(ictx, sip.StatusOK, "OK", sdp)
+	ictx.LastResp = res
+	ictx.Got2xx = true
+	_ = ictx.ServerTx.Respond(res)
+
+	localTag, _ := res.To().Params.Get("tag")
+	remoteTag, _ := inv.From().Params.Get("tag")
+
+	leg := &bridgeLeg{
+		CallID:    inv.CallID().Value(),
+		LocalTag:  localTag,
+		RemoteTag: remoteTag,
+		LocalURI:  inv.To().Address,
+		RemoteURI: inv.From().Address,
+		User:      strings.TrimSpace(inv.From().Address.User),
+		JournalID: ictx.JournalID,
+		AnswerAt:  time.Now(),
+		Trunk:     ictx.Trunk,
+	}
+	if ct := inv.Contact(); ct != nil {
+		leg.Target = ct.Address
+	} else {
+		leg.Target = inv.From().Address
+	}
+	leg.cseq.Store(1)
+
+	s.markLegAnswered(leg, ictx.InviteAt)
+	return leg
+}
+
+// uasResponse — ответ caller'у от имени сервера (сервер — UAS).
+func (s *Server) uasResponse(ictx *InviteCtx, code int, reason string, sdp []byte) *sip.Response {
+	res := sip.NewResponseFromRequest(ictx.OriginInvite, code, reason, sdp)
+	if ictx.LocalTag != "" {
+		res.To().Params.Add("tag", ictx.LocalTag)
+	}
+	res.AppendHeader(&sip.ContactHeader{
+		Address: sip.Uri{Scheme: "sip", Host: s.host, Port: s.port},
+	})
+	if len(sdp) > 0 {
+		ct := sip.ContentTypeHeader("application/sdp")
+		res.AppendHeader(&ct)
+	}
+	return res
+}
+
+func (s *Server) markLegAnswered(leg *bridgeLeg, inviteAt time.Time) {
+	if s.callJournalRepo == nil || leg.JournalID == 0 {
+		return
+	}
+
+	fromTag, toTag := leg.journalTags()
+	err := s.callJournalRepo.MarkAnswered(
+		context.Background(),
+		leg.JournalID,
+		leg.CallID, fromTag, toTag,
+		leg.Target.String(),
+		nil,
+		leg.AnswerAt,
+		int(leg.AnswerAt.Sub(inviteAt).Milliseconds()),
+	)
+	if err != nil {
+		log.Printf("[BRIDGE] MarkAnswered failed: %v", err)
+	}
+}
+
+// bridgeCalls отвечает обоим телефонам и соединяет их медиа напрямую:
+// каждому уходит SDP другой стороны.
+func (s *Server) bridgeCalls(a, b *InviteCtx) {
+	legA := s.answerLeg(a, b.OriginInvite.Body())
+	legB := s.answerLeg(b, a.OriginInvite.Body())
+
+	s.linkLegs(legA, legB, nil)
+
+	atomic.AddInt64(&s.activeDialog, 1)
+	sipActiveDialogsSet(atomic.LoadInt64(&s.activeDialog))
+}
+
+// linkLegs связывает две стороны: BYE от одной завершает обе.
+// rec — запись звонка, если медиа идёт через сервер.
+func (s *Server) linkLegs(a, b *bridgeLeg, rec *callRecording) {
+	hold := &holdState{}
+	for _, p := range [][2]*bridgeLeg{{a, b}, {b, a}} {
+		leg, peer := p[0], p[1]
+		key, _ := leg.keys()
+		fromTag, toTag := leg.journalTags()
+
+		s.dialogs.Store(key, &DialogCtx{
+			Key:          key,
+			RemoteTarget: leg.Target,
+			JournalID:    leg.JournalID,
+			CallID:       leg.CallID,
+			FromTag:      fromTag,
+			ToTag:        toTag,
+			CallerUser:   leg.User,
+			AnswerAt:     leg.AnswerAt,
+			Trunk:        leg.Trunk,
+			Leg:          leg,
+			Peer:         peer,
+			Hold:         hold,
+			Recording:    rec,
+		})
+	}
+
+	log.Printf("[BRIDGE] %s <-> %s", a.CallID, b.CallID)
+}
+
+// byeBridged завершает обе стороны соединённого сервером звонка.
+func (s *Server) byeBridged(req *sip.Request, tx sip.ServerTransaction, dlg *DialogCtx) {
+	_ = tx.Respond(sip.NewResponseFromRequest(req, sip.StatusOK, "OK", nil))
+
+	key, _ := dlg.Leg.keys()
+	peerKey, _ := dlg.Peer.keys()
+
+	if _, loaded := s.dialogs.LoadAndDelete(key); !loaded {
+		return
+	}
+
+	var peer *DialogCtx
+	if v, ok := s.dialogs.LoadAndDelete(peerKey); ok {
+		peer = v.(*DialogCtx)
+	}
+
+	s.sendLegBye(dlg.Peer)
+	s.stopHold(dlg)
+	s.finishRecording(dlg.Recording)
+
+	endAt := time.Now()
+	s.endLegJournal(dlg.Leg, repository.CallEndedByCaller, endAt)
+	s.endLegJournal(dlg.Peer, repository.CallEndedByCallee, endAt)
+
+	atomic.AddInt64(&s.activeDialog, -1)
+	sipActiveDialogsSet(atomic.LoadInt64(&s.activeDialog))
+
+	if dlg.Trunk != "" {
+		s.trunkChannels.Release(dlg.Trunk)
+	}
+	if peer != nil && peer.Trunk != "" {
+		s.trunkChannels.Release(peer.Trunk)
+	}
+}
+
+func (s *Server) endLegJournal(leg *bridgeLeg, endedBy repository.CallEndedBy, endAt time.Time) {
+	if s.callJournalRepo == nil || leg.JournalID == 0 {
+		return
+	}
+	fromTag, toTag := leg.journalTags()
+	talkMs := int(endAt.Sub(leg.AnswerAt).Milliseconds())
+	if err := s.callJournalRepo.EndByBye(context.Background(), leg.CallID, fromTag, toTag,
+		endedBy, endAt, talkMs); err != nil {
+		log.Printf("[BRIDGE] EndByBye failed: %v", err)
+	}
+}
+
+// sendLegBye отправляет BYE телефону, не дожидаясь ответа.
+func (s *Server) sendLegBye(leg *bridgeLeg) {
+	bye := s.legRequest(leg, sip.BYE)
+	clTx, err := s.cl.TransactionRequest(context.Background(), bye)
+	if err != nil {
+		log.Printf("[BRIDGE] BYE to %s error: %v", leg.Target.String(), err)
+		return
+	}
+	go func() {
+		select {
+		case <-clTx.Responses():
+		case <-clTx.Done():
+		case <-time.After(3 * time.Second):
+		}
+		clTx.Terminate()
+	}()
+}
+
+// reinviteLeg меняет медиа телефона re-INVITE'ом и возвращает его 200 OK.
+func (s *Server) reinviteLeg(leg *bridgeLeg, sdp []byte) (*sip.Response, error) {
+	req := s.legRequest(leg, sip.INVITE)
+	req.AppendHeader(&sip.ContactHeader{
+		Address: sip.Uri{Scheme: "sip", Host: s.host, Port: s.port},
+	})
+	if len(sdp) > 0 {
+		req.SetBody(sdp)
+		ct := sip.ContentTypeHeader("application/sdp")
+		req.AppendHeader(&ct)
+	}
+
+	resp, err := s.waitFinal(req, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("re-INVITE rejected: %d %s", resp.StatusCode, resp.Reason)
+	}
+
+	ack := s.legRequest(leg, sip.ACK)
+	ack.CSeq().SeqNo = req.CSeq().SeqNo
+	if err := s.cl.WriteRequest(ack); err != nil {
+		log.Printf("[BRIDGE] ACK error: %v", err)
+	}
+	return resp, nil
+}
+
+// waitFinal отправляет запрос и ждёт финальный ответ.
+func (s *Server) waitFinal(req *sip.Request, timeout time.Duration) (*sip.Response, error) {
+	clTx, err := s.cl.TransactionRequest(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+	defer clTx.Terminate()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case resp := <-clTx.Responses():
+			if resp.StatusCode >= 200 {
+				return resp, nil
+			}
+		case <-clTx.Done():
+			return nil, errors.New("transaction terminated")
+		case <-timer.C:
+			if req.Method == sip.INVITE {
+				if cancel := buildCancel(req); cancel != nil {
+					_, _ = s.cl.TransactionRequest(context.Background(), cancel)
+				}
+			}
+			return nil, errors.New("timeout")
+		}
+	}
+}
+
+// legRequest строит in-dialog запрос от сервера к телефону.
+func (s *Server) legRequest(leg *bridgeLeg, method sip.RequestMethod) *sip.Request {
+	req := sip.NewRequest(method, leg.Target)
+
+	from := &sip.FromHeader{Address: leg.LocalURI, Params: sip.NewParams()}
+	from.Params.Add("tag", leg.LocalTag)
+	to := &sip.ToHeader{Address: leg.RemoteURI, Params: sip.NewParams()}
+	to.Params.Add("tag", leg.RemoteTag)
+	callID := sip.CallIDHeader(leg.CallID)
+	cseq := &sip.CSeqHeader{SeqNo: leg.cseq.Add(1), MethodName: method}
+	mf := sip.MaxForwardsHeader(70)
+
+	req.AppendHeader(from)
+	req.AppendHeader(to)
+	req.AppendHeader(&callID)
+	req.AppendHeader(cseq)
+	req.AppendHeader(&mf)
+	req.PrependHeader(s.newVia())
+
+	return req
+}
+
+func (s *Server) newVia() *sip.ViaHeader {
+	via := &sip.ViaHeader{Transport: "UDP", Host: s.host, Port: s.port, Params: sip.NewParams()}
+	via.Params.Add("branch", sip.GenerateBranch())
+	via.Params.Add("rport", "")
+	via.ProtocolName = "SIP"
+	via.ProtocolVersion = "2.0"
+	return via
+}
@@ -0,0 +1,219 @@
+package sipserver
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	userrepo "SipServer/internal/repository/user"
+	"SipServer/internal/sdp"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// служебные форматы: не голос, политика их не трогает
+var auxFormats = map[string]bool{"telephone-event": true, "cn": true}
+
+// codecPolicy — ограничения SDP звонка по настройкам пользователей.
+type codecPolicy struct {
+	restrict   bool     // allow задан: остальные кодеки вырезаем
+	allow      []string // в нижнем регистре, в порядке предпочтения
+	stripVideo bool
+}
+
+// userCodecPolicy — политика из конфига пользователя; nil, если ничего не задано.
+func userCodecPolicy(u *userrepo.User) *codecPolicy {
+	if u == nil || u.Config == nil {
+		return nil
+	}
+	c := u.Config
+	if len(c.Codecs) == 0 && !c.StripVideo && !c.ForcePCMA {
+		return nil
+	}
+	p := &codecPolicy{stripVideo: c.StripVideo}
+	if c.ForcePCMA {
+		p.restrict, p.allow = true, []string{"pcma"}
+	} else if len(c.Codecs) > 0 {
+		p.restrict = true
+		for _, name := range c.Codecs {
+			p.allow = append(p.allow, strings.ToLower(name))
+		}
+	}
+	return p
+}
+
+// mergeCodecPolicy — политика звонка caller -> callee: кодеки из обоих
+// списков в порядке callee (он принимает звонок), флаги — любой из двух.
+func mergeCodecPolicy(caller, callee *codecPolicy) *codecPolicy {
+	if caller == nil {
+		return callee
+	}
+	if callee == nil {
+		return caller
+	}
+	p := &codecPolicy{stripVideo: caller.stripVideo || callee.stripVideo}
+	switch {
+	case caller.restrict && callee.restrict:
+		p.restrict = true
+		for _, name := range callee.allow {
+			if containsString(caller.allow, name) {
+				p.allow = append(p.allow, name)
+			}
+		}
+	case caller.restrict:
+		p.restrict, p.allow = true, caller.allow
+	case callee.restrict:
+		p.restrict, p.allow = true, callee.allow
+	}
+	return p
+}
+
+// callCodecPolicy — политика для звонка ictx к callee (nil — транк или внешний номер).
+// Звонящий — проверенный профиль ictx.Caller, а не From: с транка его нет.
+func (s *Server) callCodecPolicy(ictx *InviteCtx, callee *userrepo.User) *codecPolicy {
+	return mergeCodecPolicy(userCodecPolicy(ictx.Caller), userCodecPolicy(callee))
+}
+
+// applyOffer применяет политику к SDP исходящего INVITE.
+// ErrNoCommonCodec — в аудио не осталось ни одного голосового кодека.
+func (p *codecPolicy) applyOffer(out *sip.Request) error {
+	body, err := p.offer(out.Body())
+	if err != nil {
+		return err
+	}
+	out.SetBody(body)
+	return nil
+}
+
+func (p *codecPolicy) offer(body []byte) ([]byte, error) {
+	if p == nil || len(body) == 0 {
+		return body, nil
+	}
+	sess, err := sdp.Parse(body)
+	if err != nil {
+		// чужой формат тела не наша забота: пусть разбирается callee
+		log.Printf("[CODEC] offer not parsed: %v", err)
+		return body, nil
+	}
+
+	audio := false
+	for _, m := range sess.Media {
+		switch {
+		case m.Port == 0:
+		case m.Type == "video" && p.stripVideo:
+			m.Disable()
+		case m.Type == "audio":
+			if p.filter(m, true) {
+				audio = true
+			} else {
+				m.Disable()
+			}
+		}
+	}
+	if !audio {
+		return nil, ErrNoCommonCodec
+	}
+	return sess.Marshal(), nil
+}
+
+// answer применяет политику к SDP ответа callee. Ответ, в котором не осталось
+// голосовых кодеков, не трогаем: caller сам решит, что с ним делать.
+func (p *codecPolicy) answer(body []byte) []byte {
+	if p == nil || len(body) == 0 {
+		return body
+	}
+	sess, err := sdp.Parse(body)
+	if err != nil {
+		return body
+	}
+	for _, m := range sess.Media {
+		switch {
+		case m.Port == 0:
+		case m.Type == "video" && p.stripVideo:
+			m.Disable()
+		case m.Type == "audio":
+			if !p.filter(m, false) {
+				return body
+			}
+		}
+	}
+	return sess.Marshal()
+}
+
+// filter оставляет в m= разрешённые кодеки и служебные форматы.
+// reorder — выстроить кодеки в порядке политики (для offer).
+// false — голосовых кодеков не осталось, m= не изменён.
+func (p *codecPolicy) filter(m *sdp.Media, reorder bool) bool {
+	formats := m.RTPFormats()
+
+	var voice, aux []uint8
+	for _, f := range formats {
+		name := strings.ToLower(f.Name)
+		switch {
+		case auxFormats[name]:
+			aux = append(aux, f.PayloadType)
+		case !p.restrict || containsString(p.allow, name):
+			voice = append(voice, f.PayloadType)
+		}
+	}
+	if len(voice) == 0 {
+		return false
+	}
+	if !p.restrict {
+		return true
+	}
+
+	if reorder {
+		voice = voice[:0]
+		for _, name := range p.allow {
+			for _, f := range formats {
+				if strings.ToLower(f.Name) == name {
+					voice = append(voice, f.PayloadType)
+				}
+			}
+		}
+	}
+	m.KeepFormats(append(voice, aux...))
+	return true
+}
+
+// storeCodec пишет в журнал кодек из SDP ответа.
+func (s *Server) storeCodec(ictx *InviteCtx, answer []byte) {
+	if s.callJournalRepo == nil || ictx.JournalID == 0 {
+		return
+	}
+	codec := negotiatedCodec(answer)
+	if codec == "" {
+		return
+	}
+	if err := s.callJournalRepo.SetCodec(context.Background(), ictx.JournalID, codec); err != nil {
+		log.Printf("[CODEC] SetCodec failed: %v", err)
+	}
+}
+
+// negotiatedCodec — первый голосовой кодек аудио в SDP ответа.
+func negotiatedCodec(body []byte) string {
+	sess, err := sdp.Parse(body)
+	if err != nil {
+		return ""
+	}
+	m := sess.FirstMedia("audio")
+	if m == nil {
+		return ""
+	}
+	for _, f := range m.RTPFormats() {
+		if f.Name != "" && !auxFormats[strings.ToLower(f.Name)] {
+			return f.Name
+		}
+	}
+	return ""
+}
+
+func containsString(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
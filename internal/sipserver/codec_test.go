@@ -0,0 +1,39 @@
+package sipserver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+
+	userrepo "SipServer/internal/repository/user"
+)
+
+func TestCallCodecPolicyUsesAuthenticatedCaller(t *testing.T) {
+	pcma := &userrepo.User{Login: "101", Config: &userrepo.UserConfig{ForcePCMA: true}}
+	video := &userrepo.User{Login: "200", Config: &userrepo.UserConfig{StripVideo: true}}
+
+	tests := []struct {
+		name   string
+		caller *userrepo.User
+		callee *userrepo.User
+		want   *codecPolicy
+	}{
+		{"caller policy", pcma, nil, &codecPolicy{restrict: true, allow: []string{"pcma"}}},
+		{"merged", pcma, video, &codecPolicy{restrict: true, allow: []string{"pcma"}, stripVideo: true}},
+		{"from trunk", nil, video, &codecPolicy{stripVideo: true}},
+		{"nothing set", nil, nil, nil},
+	}
+	for _, tt := range tests {
+		// From подделан: политика берётся только из ictx.Caller
+		req := sip.NewRequest(sip.INVITE, sip.Uri{Scheme: "sip", User: "200", Host: "pbx.local"})
+		req.AppendHeader(&sip.FromHeader{Address: sip.Uri{Scheme: "sip", User: "boss", Host: "pbx.local"}})
+		ictx := NewInviteCtx()
+		ictx.OriginInvite = req
+		ictx.Caller = tt.caller
+
+		if got := (&Server{}).callCodecPolicy(ictx, tt.callee); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: callCodecPolicy = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package sipserver
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"SipServer/internal/media"
+	"SipServer/internal/repository"
+	"SipServer/internal/repository/conference"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+const (
+	confPinAttempts = 3
+	confPinTimeout  = 5 * time.Second
+
+	// как часто забираем из БД mute/kick, выставленные через API
+	confPollInterval = time.Second
+)
+
+type confParticipant struct {
+	id     int64 // conference_participants.id
+	lc     *localCall
+	member *media.MixMember
+}
+
+type confRoom struct {
+	id     int
+	number string
+	mixer  *media.Mixer
+	cancel context.CancelFunc
+	parts  map[int64]*confParticipant // под conferences.mu
+}
+
+// conferences — идущие конференции по id комнаты.
+type conferences struct {
+	mu    sync.Mutex
+	rooms map[int]*confRoom
+	seats map[int]int // занятые места: участники и те, кто ещё вводит PIN
+}
+
+func newConferences() *conferences {
+	return &conferences{rooms: make(map[int]*confRoom), seats: make(map[int]int)}
+}
+
+// reserve занимает место в комнате; false — комната полна.
+func (c *conferences) reserve(roomID, limit int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.seats[roomID] >= limit {
+		return false
+	}
+	c.seats[roomID]++
+	return true
+}
+
+func (c *conferences) release(roomID int) {
+	c.mu.Lock()
+	c.releaseLocked(roomID)
+	c.mu.Unlock()
+}
+
+func (c *conferences) releaseLocked(roomID int) {
+	if c.seats[roomID]--; c.seats[roomID] <= 0 {
+		delete(c.seats, roomID)
+	}
+}
+
+// joinConference отвечает на звонок в комнату; PIN и вход — уже в медиа.
+// Место занимается сразу: одновременные звонки не переполнят комнату.
+func (s *Server) joinConference(ictx *InviteCtx, room *conference.Room) {
+	if !s.conferences.reserve(room.Id, room.MaxParticipants) {
+		log.Printf("[CONF] room=%s is full (%d)", room.Number, room.MaxParticipants)
+		s.failInvite(ictx, sip.StatusBusyHere, "Busy Here")
+		return
+	}
+
+	lc := s.answerLocalOrReject(ictx)
+	if lc == nil {
+		s.conferences.release(room.Id)
+		return
+	}
+	go s.enterConference(lc, room)
+}
+
+func (s *Server) enterConference(lc *localCall, room *conference.Room) {
+	joined := false
+	defer func() {
+		if !joined {
+			s.conferences.release(room.Id)
+		}
+	}()
+
+	if room.Pin != "" && !confCheckPin(lc, room.Pin) {
+		if lc.ctx.Err() == nil {
+			log.Printf("[CONF] room=%s call-id=%s wrong PIN", room.Number, lc.leg.CallID)
+			s.endLocal(lc, repository.CallEndedBySystem, true)
+		}
+		return
+	}
+
+	_ = lc.media.Play(lc.ctx, media.Tone(1000, 200*time.Millisecond, 8000), false)
+	if lc.ctx.Err() != nil {
+		return
+	}
+
+	var journalID *int64
+	if lc.leg.JournalID != 0 {
+		journalID = &lc.leg.JournalID
+	}
+	row, err := s.conferenceRepo.AddParticipant(context.Background(), &conference.Participant{
+		RoomId:     room.Id,
+		JournalID:  journalID,
+		CallID:     lc.leg.CallID,
+		CallerUser: lc.leg.User,
+	})
+	if err != nil {
+		log.Printf("[CONF] room=%s store participant failed: %v", room.Number, err)
+		s.endLocal(lc, repository.CallEndedBySystem, true)
+		return
+	}
+
+	p := &confParticipant{id: row.Id, lc: lc}
+	cr := s.conferenceJoin(room, p)
+	joined = true
+
+	lc.setReceiver(func(pkt *media.Packet) {
+		if codec, ok := media.CodecByPayloadType(pkt.PayloadType); ok {
+			p.member.Push(codec.Decode(pkt.Payload))
+		}
+	})
+	lc.setOnHangup(func() { s.leaveConference(cr, p) })
+	// положили трубку, пока входили
+	if lc.ended.Load() {
+		s.leaveConference(cr, p)
+	}
+
+	log.Printf("[CONF] room=%s +%s (participant=%d)", room.Number, lc.leg.User, p.id)
+
+	if s.callJournalRepo != nil && lc.leg.JournalID != 0 {
+		if err := s.callJournalRepo.SetConference(context.Background(), lc.leg.JournalID, room.Number); err != nil {
+			log.Printf("[CONF] SetConference failed: %v", err)
+		}
+	}
+}
+
+// conferenceJoin добавляет участника в микшер; первый участник открывает комнату.
+// Место под него уже занято reserve.
+func (s *Server) conferenceJoin(room *conference.Room, p *confParticipant) *confRoom {
+	s.conferences.mu.Lock()
+	defer s.conferences.mu.Unlock()
+
+	cr, ok := s.conferences.rooms[room.Id]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		cr = &confRoom{
+			id:     room.Id,
+			number: room.Number,
+			mixer:  media.NewMixer(),
+			cancel: cancel,
+			parts:  make(map[int64]*confParticipant),
+		}
+		s.conferences.rooms[room.Id] = cr
+		go cr.mixer.Run(ctx)
+		go s.pollConference(ctx, cr)
+		log.Printf("[CONF] room=%s opened", room.Number)
+	}
+
+	p.member = cr.mixer.Join(p.lc.media)
+	cr.parts[p.id] = p
+	return cr
+}
+
+// leaveConference убирает участника; последний закрывает комнату.
+func (s *Server) leaveConference(cr *confRoom, p *confParticipant) {
+	s.conferences.mu.Lock()
+	if cr.parts[p.id] != p {
+		s.conferences.mu.Unlock()
+		return
+	}
+	delete(cr.parts, p.id)
+	s.conferences.releaseLocked(cr.id)
+	cr.mixer.Leave(p.member)
+	if len(cr.parts) == 0 {
+		cr.cancel()
+		delete(s.conferences.rooms, cr.id)
+		log.Printf("[CONF] room=%s closed", cr.number)
+	}
+	s.conferences.mu.Unlock()
+
+	p.lc.setReceiver(nil)
+	log.Printf("[CONF] room=%s -%s (participant=%d)", cr.number, p.lc.leg.User, p.id)
+
+	if err := s.conferenceRepo.RemoveParticipant(context.Background(), p.id); err != nil {
+		log.Printf("[CONF] remove participant=%d failed: %v", p.id, err)
+	}
+}
+
+// pollConference применяет mute/kick, выставленные через API.
+func (s *Server) pollConference(ctx context.Context, cr *confRoom) {
+	ticker := time.NewTicker(confPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		states, err := s.conferenceRepo.ParticipantStates(ctx, cr.id)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[CONF] room=%s poll failed: %v", cr.number, err)
+			}
+			continue
+		}
+
+		for _, st := range states {
+			s.conferences.mu.Lock()
+			p := cr.parts[st.Id]
+			s.conferences.mu.Unlock()
+			if p == nil {
+				continue
+			}
+
+			p.member.SetMuted(st.Muted)
+			if st.Kicked {
+				log.Printf("[CONF] room=%s kick participant=%d", cr.number, p.id)
+				s.endLocal(p.lc, repository.CallEndedBySystem, true)
+				s.leaveConference(cr, p)
+			}
+		}
+	}
+}
+
+// confCheckPin спрашивает PIN: цифры подряд, "#" — конец ввода.
+func confCheckPin(lc *localCall, pin string) bool {
+	for attempt := 0; attempt < confPinAttempts; attempt++ {
+		prompt := append(media.Tone(800, 150*time.Millisecond, 4000), media.Silence(100*time.Millisecond)...)
+		prompt = append(prompt, media.Tone(800, 150*time.Millisecond, 4000)...)
+
+		entered := ""
+		for len(entered) < len(pin) {
+			digit, ok := waitDigit(lc, prompt, confPinTimeout)
+			if !ok || digit == "#" {
+				break
+			}
+			prompt = nil
+			entered += digit
+		}
+		if entered == pin {
+			return true
+		}
+		if lc.ctx.Err() != nil {
+			return false
+		}
+		_ = lc.media.Play(lc.ctx, media.Tone(300, 600*time.Millisecond, 4000), false)
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package sipserver
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConferencesReserveIsAtomic(t *testing.T) {
+	const limit, callers = 3, 50
+	c := newConferences()
+
+	var admitted atomic.Int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if c.reserve(1, limit) {
+				admitted.Add(1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if n := admitted.Load(); n != limit {
+		t.Errorf("admitted %d concurrent callers, want %d", n, limit)
+	}
+	if !c.reserve(2, limit) {
+		t.Error("other room affected by a full one")
+	}
+
+	// вышедший освобождает место
+	c.release(1)
+	if !c.reserve(1, limit) || c.reserve(1, limit) {
+		t.Error("released seat not reused exactly once")
+	}
+	for i := 0; i < limit; i++ {
+		c.release(1)
+	}
+	if _, ok := c.seats[1]; ok {
+		t.Error("empty room kept in seats")
+	}
+}
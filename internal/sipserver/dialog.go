@@ -17,4 +17,50 @@ type DialogCtx struct {
 	CallerUser   string
 	CalleeUser   string
 	AnswerAt     time.Time
+	Trunk        string // имя транка, если звонок идёт через него
+	CSeqOffset   uint32 // сдвиг CSeq для запросов caller -> callee
+	Early        bool   // ранний диалог (18x без 2xx): только PRACK
+
+	// диалоги, где второй стороной выступает сервер:
+	// Peer — соединён с другим телефоном (pickup, парковка), Local — звонок обслуживает сам сервер
+	Leg   *bridgeLeg
+	Peer  *bridgeLeg
+	Local *localCall
+
+	Hold      *holdState     // общий для обеих сторон диалога
+	Recording *callRecording // медиа идёт через сервер и пишется в файл
+
+	Policy      *headerPolicy // заголовки запросов к RemoteTarget (транк)
+	ReplyPolicy *headerPolicy // заголовки ответов отправителю запроса (транк)
+}
+
+// lookupDialog ищет диалог in-dialog запроса в обоих направлениях.
+func (s *Server) lookupDialog(req *sip.Request) (*DialogCtx, bool) {
+	fromTag, _ := req.From().Params.Get("tag")
+	toTag, _ := req.To().Params.Get("tag")
+
+	key1, key2 := MakeDialogKey(req.CallID().Value(), fromTag, toTag)
+
+	v, ok := s.dialogs.Load(key1)
+	if !ok {
+		v, ok = s.dialogs.Load(key2)
+		if !ok {
+			return nil, false
+		}
+	}
+	dlg, ok := v.(*DialogCtx)
+	return dlg, ok && dlg != nil
+}
+
+// hasDialogCallID — есть ли установленный диалог с таким Call-ID.
+func (s *Server) hasDialogCallID(callID string) bool {
+	found := false
+	s.dialogs.Range(func(_, v any) bool {
+		if dlg, ok := v.(*DialogCtx); ok && !dlg.Early && dlg.CallID == callID {
+			found = true
+			return false
+		}
+		return true
+	})
+	return found
 }
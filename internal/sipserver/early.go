@@ -0,0 +1,217 @@
+package sipserver
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// dialogPair строит контексты диалога в обе стороны по ответу callee на out.
+// false — в INVITE или ответе нет Contact.
+func (s *Server) dialogPair(ctx *InviteCtx, out *sip.Request, resp *sip.Response) (*DialogCtx, *DialogCtx, bool) {
+	callerCT := ctx.OriginInvite.Contact()
+	ct := resp.Contact()
+	if ct == nil || callerCT == nil {
+		return nil, nil, false
+	}
+
+	callID := resp.CallID().Value()
+	fromTag, _ := ctx.OriginInvite.From().Params.Get("tag")
+	toTag, _ := resp.To().Params.Get("tag")
+	keyAB, keyBA := MakeDialogKey(callID, fromTag, toTag)
+
+	routes := buildRouteSet(resp)
+
+	// caller/callee users (для ended_by в BYE — очень удобно)
+	callerUser := ""
+	if f := ctx.OriginInvite.From(); f != nil {
+		callerUser = strings.TrimSpace(f.Address.User)
+	}
+	calleeUser := ""
+	if t := ctx.OriginInvite.To(); t != nil {
+		calleeUser = strings.TrimSpace(t.Address.User)
+	}
+
+	answerAt := time.Now()
+
+	// после digest-повтора CSeq исходящего INVITE больше, чем у caller
+	var cseqOffset uint32
+	if out != nil && out.CSeq() != nil && ctx.OriginInvite.CSeq() != nil {
+		cseqOffset = out.CSeq().SeqNo - ctx.OriginInvite.CSeq().SeqNo
+	}
+
+	hold := &holdState{}
+
+	// A -> B (caller -> callee)
+	dlgAB := &DialogCtx{
+		Key:          keyAB,
+		RouteSet:     routes,
+		RemoteTarget: ct.Address, // callee contact
+
+		// --- для CDR / BYE ---
+		JournalID:  ctx.JournalID,
+		CallID:     callID,
+		FromTag:    fromTag,
+		ToTag:      toTag,
+		CallerUser: callerUser,
+		CalleeUser: calleeUser,
+		AnswerAt:   answerAt,
+		Trunk:      ctx.Trunk,
+		CSeqOffset: cseqOffset,
+		Hold:       hold,
+		Recording:  ctx.Recording,
+
+		Policy:      ctx.OutPolicy,
+		ReplyPolicy: ctx.InPolicy,
+	}
+
+	// B -> A (callee -> caller)
+	dlgBA := &DialogCtx{
+		Key:          keyBA,
+		RouteSet:     routes,
+		RemoteTarget: callerCT.Address, // caller contact
+
+		JournalID:  ctx.JournalID,
+		CallID:     callID,
+		FromTag:    toTag, // обратное направление
+		ToTag:      fromTag,
+		CallerUser: callerUser,
+		CalleeUser: calleeUser,
+		AnswerAt:   answerAt,
+		Trunk:      ctx.Trunk,
+		Hold:       hold,
+		Recording:  ctx.Recording,
+
+		Policy:      ctx.InPolicy,
+		ReplyPolicy: ctx.OutPolicy,
+	}
+
+	return dlgAB, dlgBA, true
+}
+
+// trackEarly — 18x от callee: отмечаем first_18x_at и заводим ранний диалог
+// на каждый новый To tag (по нему пойдут PRACK).
+func (s *Server) trackEarly(ctx *InviteCtx, out *sip.Request, resp *sip.Response) {
+	if resp.StatusCode <= sip.StatusTrying || resp.StatusCode >= 200 {
+		return
+	}
+
+	if ctx.Got18x.CompareAndSwap(false, true) && s.callJournalRepo != nil && ctx.JournalID != 0 {
+		if err := s.callJournalRepo.MarkFirst18x(context.Background(), ctx.JournalID, time.Now()); err != nil {
+			log.Printf("[EARLY] MarkFirst18x failed: %v", err)
+		}
+	}
+
+	toTag, _ := resp.To().Params.Get("tag")
+	if toTag == "" {
+		return
+	}
+
+	ctx.earlyMu.Lock()
+	if _, ok := ctx.early[toTag]; ok || ctx.DialogCreated.Load() {
+		ctx.earlyMu.Unlock()
+		return
+	}
+	dlgAB, dlgBA, ok := s.dialogPair(ctx, out, resp)
+	if !ok {
+		ctx.earlyMu.Unlock()
+		return
+	}
+	dlgAB.Early, dlgBA.Early = true, true
+	if ctx.early == nil {
+		ctx.early = make(map[string][2]*DialogCtx)
+	}
+	ctx.early[toTag] = [2]*DialogCtx{dlgAB, dlgBA}
+	ctx.earlyMu.Unlock()
+
+	s.dialogs.Store(dlgAB.Key, dlgAB)
+	s.dialogs.Store(dlgBA.Key, dlgBA)
+	log.Printf("[EARLY] callid=%s toTag=%s %d %s", dlgAB.CallID, toTag, resp.StatusCode, resp.Reason)
+
+	if s.callJournalRepo == nil || ctx.JournalID == 0 {
+		return
+	}
+	routeSetJSON, err := encodeRouteSet(dlgAB.RouteSet)
+	if err != nil {
+		log.Printf("[EARLY] route set: %v", err)
+	}
+	if err := s.callJournalRepo.StartEarly(context.Background(), ctx.JournalID,
+		dlgAB.CallID, dlgAB.FromTag, dlgAB.ToTag,
+		dlgAB.RemoteTarget.String(), routeSetJSON, time.Now()); err != nil {
+		log.Printf("[EARLY] StartEarly failed: %v", err)
+	}
+}
+
+// endEarly убирает ранние диалоги, не ставшие подтверждёнными.
+// code — финальный ответ на INVITE, 0 — ответила другая ветка или звонок забрал сервер.
+func (s *Server) endEarly(ctx *InviteCtx, code int, reason string) {
+	ctx.earlyMu.Lock()
+	early := ctx.early
+	ctx.early = nil
+	ctx.earlyMu.Unlock()
+
+	if len(early) == 0 {
+		return
+	}
+	for _, pair := range early {
+		for _, dlg := range pair {
+			// подтверждённый диалог с тем же ключом не трогаем
+			s.dialogs.CompareAndDelete(dlg.Key, dlg)
+		}
+	}
+
+	if s.callJournalRepo == nil || ctx.JournalID == 0 {
+		return
+	}
+	if err := s.callJournalRepo.TerminateEarly(context.Background(), ctx.JournalID, code, reason, time.Now()); err != nil {
+		log.Printf("[EARLY] TerminateEarly failed: %v", err)
+	}
+}
+
+// shiftRAck сдвигает CSeq в RAck "<rseq> <cseq> <method>" на offset;
+// непонятное значение возвращает как есть.
+func shiftRAck(value string, offset uint32) string {
+	f := strings.Fields(value)
+	if len(f) != 3 {
+		return value
+	}
+	cseq, err := strconv.ParseUint(f[1], 10, 32)
+	if err != nil {
+		return value
+	}
+	return f[0] + " " + strconv.FormatUint(cseq+uint64(offset), 10) + " " + f[2]
+}
+
+// onPrack — подтверждение надёжного 18x (RFC 3262): пересылаем по раннему диалогу.
+func (s *Server) onPrack(req *sip.Request, tx sip.ServerTransaction) {
+	start := time.Now()
+	sipIn(req.Method)
+	defer observeHandler(req.Method, start)
+
+	dlg, ok := s.lookupDialog(req)
+	if !ok {
+		respond(req, tx, sip.StatusCallTransactionDoesNotExists, "Call/Transaction Does Not Exist")
+		return
+	}
+	// второй стороной выступает сервер: надёжных 18x он не шлёт
+	if dlg.Leg != nil || dlg.Local != nil {
+		respond(req, tx, sip.StatusOK, "OK")
+		return
+	}
+
+	out := s.forwardInDialog(req, dlg)
+	resp, err := s.waitFinal(out, 5*time.Second)
+	if err != nil {
+		log.Printf("[PRACK] callid=%s relay error: %v", dlg.CallID, err)
+		respond(req, tx, sip.StatusRequestTimeout, "Request Timeout")
+		return
+	}
+
+	up := makeUpstreamResponse(req, resp)
+	dlg.ReplyPolicy.response(up, s.host, s.port)
+	_ = tx.Respond(up)
+}
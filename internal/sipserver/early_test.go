@@ -0,0 +1,22 @@
+package sipserver
+
+import "testing"
+
+func TestShiftRAck(t *testing.T) {
+	tests := []struct {
+		in     string
+		offset uint32
+		want   string
+	}{
+		{"776656 1 INVITE", 1, "776656 2 INVITE"},
+		{"1  314159   INVITE", 0, "1 314159 INVITE"},
+		{"1 4294967295 INVITE", 0, "1 4294967295 INVITE"},
+		{"1 x INVITE", 1, "1 x INVITE"},
+		{"1 2", 1, "1 2"},
+	}
+	for _, tt := range tests {
+		if got := shiftRAck(tt.in, tt.offset); got != tt.want {
+			t.Errorf("shiftRAck(%q, %d) = %q, want %q", tt.in, tt.offset, got, tt.want)
+		}
+	}
+}
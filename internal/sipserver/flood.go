@@ -0,0 +1,421 @@
+package sipserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"SipServer/internal/metrics"
+	aclrule "SipServer/internal/repository/acl_rule"
+	"SipServer/internal/repository/ban"
+
+	"github.com/emiago/sipgo"
+	"github.com/emiago/sipgo/sip"
+)
+
+const (
+	floodPollInterval = 5 * time.Second // перечитать баны и адреса транков
+	bucketIdleTTL     = 5 * time.Minute
+	maxBuckets        = 100000 // AOR берётся из From, его подделать ничего не стоит
+
+	defaultRateLimitIP  = "INVITE=10/20,REGISTER=5/20,*=50/100"
+	defaultRateLimitAOR = "INVITE=5/10,REGISTER=2/10,*=20/40"
+)
+
+// rateRule — token bucket: rate запросов в секунду, burst — запас.
+type rateRule struct {
+	rate  float64
+	burst float64
+}
+
+// rateRules — правила по методу, "*" — для остальных; nil — без ограничения.
+type rateRules map[string]rateRule
+
+// parseRateRules разбирает "INVITE=10/20,*=50/100"; "off" — выключено.
+func parseRateRules(v string) (rateRules, error) {
+	if strings.EqualFold(strings.TrimSpace(v), "off") {
+		return nil, nil
+	}
+	rules := make(rateRules)
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		method, spec, ok := strings.Cut(part, "=")
+		rateStr, burstStr, ok2 := strings.Cut(spec, "/")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("rate limit %q: want METHOD=rate/burst", part)
+		}
+		rate, err1 := strconv.ParseFloat(rateStr, 64)
+		burst, err2 := strconv.ParseFloat(burstStr, 64)
+		if err1 != nil || err2 != nil || rate <= 0 || burst < 1 {
+			return nil, fmt.Errorf("rate limit %q: bad rate/burst", part)
+		}
+		rules[strings.ToUpper(strings.TrimSpace(method))] = rateRule{rate: rate, burst: burst}
+	}
+	return rules, nil
+}
+
+func (r rateRules) rule(method string) (rateRule, bool) {
+	if rule, ok := r[method]; ok {
+		return rule, true
+	}
+	rule, ok := r["*"]
+	return rule, ok
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// tokenBuckets — по bucket на ключ (IP или AOR) и метод.
+type tokenBuckets struct {
+	rules rateRules
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func newTokenBuckets(env, def string) *tokenBuckets {
+	v, ok := os.LookupEnv(env)
+	if !ok {
+		v = def
+	}
+	rules, err := parseRateRules(v)
+	if err != nil {
+		log.Printf("[FLOOD] %s: %v, using %q", env, err, def)
+		rules, _ = parseRateRules(def)
+	}
+	return &tokenBuckets{rules: rules, buckets: make(map[string]*bucket)}
+}
+
+func (b *tokenBuckets) allow(key, method string, now time.Time) bool {
+	rule, ok := b.rules.rule(method)
+	if !ok || key == "" {
+		return true
+	}
+	k := method + " " + key
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bk, ok := b.buckets[k]
+	if !ok && len(b.buckets) >= maxBuckets {
+		// таблица полна: новые ключи делят один bucket на метод, пока prune не освободит место
+		k = method + " *overflow*"
+		bk, ok = b.buckets[k]
+	}
+	if !ok {
+		bk = &bucket{tokens: rule.burst, last: now}
+		b.buckets[k] = bk
+	}
+	bk.tokens = min(rule.burst, bk.tokens+now.Sub(bk.last).Seconds()*rule.rate)
+	bk.last = now
+	if bk.tokens < 1 {
+		return false
+	}
+	bk.tokens--
+	return true
+}
+
+// prune убирает давно не использованные bucket'ы.
+func (b *tokenBuckets) prune(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for k, bk := range b.buckets {
+		if now.Sub(bk.last) > bucketIdleTTL {
+			delete(b.buckets, k)
+		}
+	}
+}
+
+type failureCount struct {
+	n     int
+	since time.Time
+}
+
+// floodGuard — лимиты запросов по IP и AOR и баны после серии 401/404.
+type floodGuard struct {
+	byIP  *tokenBuckets
+	byAOR *tokenBuckets
+
+	banThreshold int // неудач за banWindow до бана, 0 — автобан выключен
+	banWindow    time.Duration
+	banTime      time.Duration // на сколько банить
+
+	mu       sync.Mutex
+	bans     map[string]time.Time // ip -> до какого времени, нулевое — бессрочно
+	pending  map[string]time.Time // автобаны, ещё не записанные в БД
+	failures map[string]*failureCount
+	trusted  map[string]bool // адреса транков: их не ограничиваем
+}
+
+func newFloodGuard() *floodGuard {
+	g := &floodGuard{
+		byIP:         newTokenBuckets("RATE_LIMIT_IP", defaultRateLimitIP),
+		byAOR:        newTokenBuckets("RATE_LIMIT_AOR", defaultRateLimitAOR),
+		banThreshold: 10,
+		banWindow:    time.Minute,
+		banTime:      10 * time.Minute,
+		bans:         make(map[string]time.Time),
+		pending:      make(map[string]time.Time),
+		failures:     make(map[string]*failureCount),
+		trusted:      make(map[string]bool),
+	}
+	if v, err := strconv.Atoi(os.Getenv("BAN_THRESHOLD")); err == nil && v >= 0 {
+		g.banThreshold = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("BAN_WINDOW")); err == nil && v > 0 {
+		g.banWindow = time.Duration(v) * time.Second
+	}
+	if v, err := strconv.Atoi(os.Getenv("BAN_TIME")); err == nil && v > 0 {
+		g.banTime = time.Duration(v) * time.Second
+	}
+	return g
+}
+
+func (g *floodGuard) banned(ip string, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	until, ok := g.bans[ip]
+	return ok && (until.IsZero() || now.Before(until))
+}
+
+func (g *floodGuard) isTrusted(ip string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.trusted[ip]
+}
+
+// failure учитывает неудачу с ip; true — пора банить.
+func (g *floodGuard) failure(ip string, now time.Time) bool {
+	if g.banThreshold == 0 {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f, ok := g.failures[ip]
+	if !ok || now.Sub(f.since) > g.banWindow {
+		f = &failureCount{since: now}
+		g.failures[ip] = f
+	}
+	f.n++
+	if f.n < g.banThreshold {
+		return false
+	}
+	delete(g.failures, ip)
+	g.bans[ip] = now.Add(g.banTime)
+	g.pending[ip] = g.bans[ip]
+	metrics.SIPBans.Set(float64(len(g.bans)))
+	return true
+}
+
+// stored — автобан ip до until записан в БД, дальше он приходит с перечитыванием.
+func (g *floodGuard) stored(ip string, until time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.pending[ip].Equal(until) {
+		delete(g.pending, ip)
+	}
+}
+
+// reload заменяет баны и доверенные адреса прочитанными из БД. Автобаны, которые
+// ещё не дошли до БД (или не записались), не теряются, пока не истекут.
+func (g *floodGuard) reload(bans map[string]time.Time, trusted map[string]bool, now time.Time) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for ip, until := range g.pending {
+		if !now.Before(until) {
+			delete(g.pending, ip)
+			continue
+		}
+		if prev, ok := bans[ip]; !ok || (!prev.IsZero() && prev.Before(until)) {
+			bans[ip] = until
+		}
+	}
+	g.bans = bans
+	g.trusted = trusted
+	for ip, f := range g.failures {
+		if now.Sub(f.since) > g.banWindow {
+			delete(g.failures, ip)
+		}
+	}
+	return len(bans)
+}
+
+// guard — обёртка хендлера: баны, лимиты и ACL до разбора запроса.
+// Лишние запросы отбрасываются без ответа: сканеру незачем знать, что его заметили.
+func (s *Server) guard(h sipgo.RequestHandler) sipgo.RequestHandler {
+	return func(req *sip.Request, tx sip.ServerTransaction) {
+		if reason := s.floodCheck(req); reason != "" {
+			metrics.SIPDropped.WithLabelValues(string(req.Method), reason).Inc()
+			return
+		}
+		if !s.aclPermits(req, tx) {
+			return
+		}
+		// у ACK транзакции нет
+		if tx != nil {
+			tx = &watchedTx{ServerTransaction: tx, req: req, s: s}
+		}
+		h(req, tx)
+	}
+}
+
+// floodCheck — причина отбросить запрос, "" — пропустить.
+func (s *Server) floodCheck(req *sip.Request) string {
+	ip := sourceIP(req)
+	now := time.Now()
+
+	if s.flood.isTrusted(ip) {
+		return ""
+	}
+	if s.flood.banned(ip, now) {
+		return "banned"
+	}
+	method := string(req.Method)
+	if !s.flood.byIP.allow(ip, method, now) {
+		return "rate_ip"
+	}
+	if !s.flood.byAOR.allow(requestAOR(req), method, now) {
+		return "rate_aor"
+	}
+	return ""
+}
+
+// watchedTx следит за ответами: серия 401/407 на запросы с авторизацией
+// или 404 с одного IP — перебор паролей или номеров.
+type watchedTx struct {
+	sip.ServerTransaction
+	req *sip.Request
+	s   *Server
+}
+
+func (t *watchedTx) Respond(res *sip.Response) error {
+	if isAuthFailure(t.req, res) {
+		t.s.noteFailure(t.req, res)
+	}
+	return t.ServerTransaction.Respond(res)
+}
+
+func isAuthFailure(req *sip.Request, res *sip.Response) bool {
+	switch res.StatusCode {
+	case sip.StatusNotFound:
+		return true
+	case sip.StatusUnauthorized, sip.StatusProxyAuthRequired:
+		// первый 401 без Authorization — обычный вызов digest
+		return req.GetHeader("Authorization") != nil || req.GetHeader("Proxy-Authorization") != nil
+	}
+	return false
+}
+
+func (s *Server) noteFailure(req *sip.Request, res *sip.Response) {
+	ip := sourceIP(req)
+	if ip == "" || s.flood.isTrusted(ip) || !s.flood.failure(ip, time.Now()) {
+		return
+	}
+
+	log.Printf("[FLOOD] ban ip=%s for %s after %d failures (last %s %d)",
+		ip, s.flood.banTime, s.flood.banThreshold, req.Method, res.StatusCode)
+	b := &ban.Ban{
+		IP:       ip,
+		Reason:   fmt.Sprintf("%d failures (last %s %d)", s.flood.banThreshold, req.Method, res.StatusCode),
+		Duration: int(s.flood.banTime.Seconds()),
+	}
+	s.flood.mu.Lock()
+	until := s.flood.bans[ip]
+	s.flood.mu.Unlock()
+	go func() {
+		if _, err := s.banRepo.Create(context.Background(), b); err != nil {
+			log.Printf("[FLOOD] store ban: %v", err)
+			return
+		}
+		s.flood.stored(ip, until)
+	}()
+}
+
+// RunFloodGuard перечитывает баны из БД (их добавляют и снимают через API)
+// и адреса транков (для лимитов и inboundTrunk), чистит старые bucket'ы.
+func (s *Server) RunFloodGuard(ctx context.Context) {
+	ticker := time.NewTicker(floodPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.reloadFloodState(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) reloadFloodState(ctx context.Context) {
+	now := time.Now()
+	s.flood.byIP.prune(now)
+	s.flood.byAOR.prune(now)
+	s.reloadTrunkAddrs(ctx)
+
+	if err := s.banRepo.DeleteExpired(ctx); err != nil {
+		log.Printf("[FLOOD] delete expired bans: %v", err)
+	}
+	list, err := s.banRepo.List(ctx)
+	if err != nil {
+		log.Printf("[FLOOD] list bans: %v", err)
+		return
+	}
+	bans := make(map[string]time.Time, len(list))
+	for _, b := range list {
+		var until time.Time
+		if b.ExpiresAt != nil {
+			until = *b.ExpiresAt
+		}
+		// из нескольких банов одного IP действует самый долгий
+		if prev, ok := bans[b.IP]; ok && (prev.IsZero() || (!until.IsZero() && prev.After(until))) {
+			continue
+		}
+		bans[b.IP] = until
+	}
+
+	trusted := make(map[string]bool)
+	for ip := range s.trunkAddrs.snapshot() {
+		if s.acl.Allowed(aclrule.ScopeTrunk, ip) {
+			trusted[ip] = true
+		}
+	}
+
+	metrics.SIPBans.Set(float64(s.flood.reload(bans, trusted, now)))
+}
+
+func sourceIP(req *sip.Request) string {
+	host, _, err := net.SplitHostPort(req.Source())
+	if err != nil {
+		return req.Source()
+	}
+	return host
+}
+
+// requestAOR — чей это запрос: To для REGISTER, иначе From.
+func requestAOR(req *sip.Request) string {
+	if req.Method == sip.REGISTER {
+		if to := req.To(); to != nil {
+			return to.Address.User + "@" + to.Address.Host
+		}
+		return ""
+	}
+	if from := req.From(); from != nil {
+		return from.Address.User + "@" + from.Address.Host
+	}
+	return ""
+}
@@ -0,0 +1,165 @@
+package sipserver
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRateRules(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    rateRules
+		wantErr bool
+	}{
+		{"INVITE=10/20,*=50/100", rateRules{"INVITE": {10, 20}, "*": {50, 100}}, false},
+		{" invite=0.5/1 , ,REGISTER=2/10", rateRules{"INVITE": {0.5, 1}, "REGISTER": {2, 10}}, false},
+		{"off", nil, false},
+		{" OFF ", nil, false},
+		{"", rateRules{}, false},
+		{"INVITE", nil, true},
+		{"INVITE=10", nil, true},
+		{"INVITE=x/20", nil, true},
+		{"INVITE=10/y", nil, true},
+		{"INVITE=0/20", nil, true},
+		{"INVITE=10/0.5", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRateRules(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRateRules(%q) error = %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseRateRules(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTokenBuckets(t *testing.T) {
+	rules, _ := parseRateRules("INVITE=2/3,*=1/1")
+	b := &tokenBuckets{rules: rules, buckets: make(map[string]*bucket)}
+	t0 := time.Now()
+
+	steps := []struct {
+		key    string
+		method string
+		at     time.Duration
+		want   bool
+	}{
+		// запас 3, потом пусто
+		{"1.1.1.1", "INVITE", 0, true},
+		{"1.1.1.1", "INVITE", 0, true},
+		{"1.1.1.1", "INVITE", 0, true},
+		{"1.1.1.1", "INVITE", 0, false},
+		// другой метод и другой ключ — свои bucket'ы
+		{"1.1.1.1", "OPTIONS", 0, true},
+		{"1.1.1.1", "OPTIONS", 0, false},
+		{"2.2.2.2", "INVITE", 0, true},
+		// 2 в секунду: через 500 мс набрался один
+		{"1.1.1.1", "INVITE", 500 * time.Millisecond, true},
+		{"1.1.1.1", "INVITE", 500 * time.Millisecond, false},
+		// запас не растёт выше burst
+		{"1.1.1.1", "INVITE", time.Hour, true},
+		{"1.1.1.1", "INVITE", time.Hour, true},
+		{"1.1.1.1", "INVITE", time.Hour, true},
+		{"1.1.1.1", "INVITE", time.Hour, false},
+		// без ключа не ограничиваем
+		{"", "INVITE", time.Hour, true},
+	}
+	for i, st := range steps {
+		if got := b.allow(st.key, st.method, t0.Add(st.at)); got != st.want {
+			t.Errorf("step %d: allow(%s, %s, +%s) = %v, want %v", i, st.key, st.method, st.at, got, st.want)
+		}
+	}
+
+	b.prune(t0.Add(time.Hour + bucketIdleTTL + time.Second))
+	if n := len(b.buckets); n != 0 {
+		t.Errorf("%d buckets left after prune", n)
+	}
+
+	off := &tokenBuckets{buckets: make(map[string]*bucket)}
+	for i := 0; i < 100; i++ {
+		if !off.allow("1.1.1.1", "INVITE", t0) {
+			t.Fatal("rate limit off still drops requests")
+		}
+	}
+}
+
+func TestTokenBucketsCap(t *testing.T) {
+	rules, _ := parseRateRules("*=1/2")
+	b := &tokenBuckets{rules: rules, buckets: make(map[string]*bucket)}
+	now := time.Now()
+
+	// случайные From не раздувают таблицу
+	for i := 0; i < maxBuckets; i++ {
+		b.allow(fmt.Sprintf("user%d@evil", i), "MESSAGE", now)
+	}
+	if !b.allow("new1@evil", "MESSAGE", now) || !b.allow("new2@evil", "MESSAGE", now) {
+		t.Fatal("overflow bucket must have its burst")
+	}
+	if b.allow("new3@evil", "MESSAGE", now) {
+		t.Error("new keys over the cap must share one bucket")
+	}
+	if n := len(b.buckets); n != maxBuckets+1 {
+		t.Errorf("%d buckets, want %d", n, maxBuckets+1)
+	}
+	// у уже известных ключей свой bucket
+	if !b.allow("user1@evil", "MESSAGE", now) {
+		t.Error("existing key limited by overflow bucket")
+	}
+}
+
+func TestFloodGuardReloadKeepsPendingBans(t *testing.T) {
+	g := &floodGuard{
+		banThreshold: 2,
+		banWindow:    time.Minute,
+		banTime:      10 * time.Minute,
+		bans:         make(map[string]time.Time),
+		pending:      make(map[string]time.Time),
+		failures:     make(map[string]*failureCount),
+		trusted:      make(map[string]bool),
+	}
+	now := time.Now()
+
+	if g.failure("203.0.113.7", now) || !g.failure("203.0.113.7", now) {
+		t.Fatal("ban expected on the second failure")
+	}
+	until := now.Add(g.banTime)
+
+	// БД ещё не знает об автобане, но в ней есть другие
+	db := map[string]time.Time{
+		"198.51.100.1": {},
+		"203.0.113.8":  now.Add(time.Hour),
+	}
+	if n := g.reload(db, map[string]bool{}, now.Add(time.Second)); n != 3 {
+		t.Errorf("reload kept %d bans, want 3", n)
+	}
+	if !g.banned("203.0.113.7", now.Add(time.Second)) {
+		t.Error("pending auto-ban dropped by reload")
+	}
+
+	// бан записан: дальше его держит только БД, снятие через API работает
+	g.stored("203.0.113.7", until)
+	g.reload(map[string]time.Time{}, map[string]bool{}, now.Add(2*time.Second))
+	if g.banned("203.0.113.7", now.Add(2*time.Second)) {
+		t.Error("stored ban survived removal from DB")
+	}
+
+	// незаписанный бан живёт до своего срока, не дольше
+	g.failure("203.0.113.9", now)
+	g.failure("203.0.113.9", now)
+	g.reload(map[string]time.Time{}, map[string]bool{}, now.Add(g.banTime))
+	if g.banned("203.0.113.9", now.Add(g.banTime)) || len(g.pending) != 0 {
+		t.Error("expired pending ban kept")
+	}
+
+	// в БД бессрочный бан того же IP — он важнее временного
+	g.failure("203.0.113.10", now)
+	g.failure("203.0.113.10", now)
+	g.reload(map[string]time.Time{"203.0.113.10": {}}, map[string]bool{}, now)
+	if !g.bans["203.0.113.10"].IsZero() {
+		t.Errorf("permanent DB ban replaced by pending one")
+	}
+}
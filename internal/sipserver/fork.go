@@ -0,0 +1,260 @@
+package sipserver
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"SipServer/internal/metrics"
+	userrepo "SipServer/internal/repository/user"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+type forkBranch struct {
+	login  string
+	out    *sip.Request
+	tx     sip.ClientTransaction
+	codecs *codecPolicy
+}
+
+type forkEvent struct {
+	branch *forkBranch
+	resp   *sip.Response
+}
+
+type forkResult struct {
+	Winner   string // login ответившего участника
+	LastCode int    // последний финальный код, если никто не ответил
+}
+
+// forkInvite параллельно звонит на зарегистрированные контакты logins.
+// Первый 2xx уходит caller'у, остальные ветки отменяются.
+func (s *Server) forkInvite(ictx *InviteCtx, logins []string, timeout time.Duration) forkResult {
+	res := forkResult{LastCode: sip.StatusTemporarilyUnavailable}
+
+	branches := make([]*forkBranch, 0, len(logins))
+	for _, login := range logins {
+		binding, ok := s.reg.Get(login)
+		if !ok {
+			continue
+		}
+
+		target := sip.Uri{
+			Scheme: "sip",
+			User:   login,
+			Host:   binding.Contact.Host,
+			Port:   binding.Contact.Port,
+		}
+		target.UriParams = sip.NewParams().Add("transport", "udp")
+
+		out := buildOutboundInvite(ictx.OriginInvite, &target, s.host, s.port)
+		s.assertIdentity(ictx, out, nil)
+
+		var callee *userrepo.User
+		if u, err := s.userRepositoriy.FindByLoginWithConfig(login); err == nil {
+			if !u.Enabled {
+				continue
+			}
+			callee = u
+			if limit := s.reserveCallee(ictx, u); limit != "" {
+				metrics.SIPCallsRejected.WithLabelValues(limit).Inc()
+				res.LastCode = sip.StatusBusyHere
+				continue
+			}
+		}
+		codecs := s.callCodecPolicy(ictx, callee)
+		if err := codecs.applyOffer(out); err != nil {
+			log.Printf("[FORK] login=%s %v", login, err)
+			res.LastCode = sip.StatusNotAcceptableHere
+			continue
+		}
+
+		clTx, err := s.cl.TransactionRequest(context.Background(), out)
+		if err != nil {
+			log.Printf("[FORK] login=%s send error: %v", login, err)
+			continue
+		}
+
+		ictx.addFork(out)
+		s.ringing.add(login, ictx)
+		branches = append(branches, &forkBranch{login: login, out: out, tx: clTx, codecs: codecs})
+	}
+
+	if len(branches) == 0 {
+		return res
+	}
+
+	events := make(chan forkEvent, 8)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, b := range branches {
+		wg.Add(1)
+		go func(b *forkBranch) {
+			defer wg.Done()
+			for {
+				select {
+				case resp := <-b.tx.Responses():
+					select {
+					case events <- forkEvent{branch: b, resp: resp}:
+					case <-stop:
+						return
+					}
+				case <-b.tx.Done():
+					return
+				}
+			}
+		}(b)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var winner *forkBranch
+	pending := len(branches)
+
+loop:
+	for pending > 0 {
+		select {
+		case ev := <-events:
+			code := int(ev.resp.StatusCode)
+
+			switch {
+			case code < 200:
+				if code > sip.StatusTrying && !ictx.Cancelled.Load() && !ictx.Diverted.Load() {
+					up := makeUpstreamResponse(ictx.OriginInvite, ev.resp)
+					if body := ev.resp.Body(); len(body) > 0 {
+						up.SetBody(ev.branch.codecs.answer(body))
+					}
+					ictx.InPolicy.response(up, s.host, s.port)
+					ictx.LastResp = up
+					_ = ictx.ServerTx.Respond(up)
+					s.trackEarly(ictx, ev.branch.out, ev.resp)
+				}
+			case code < 300:
+				if ictx.Diverted.Load() {
+					s.hangupStray(ev.branch.out, ev.resp)
+					pending--
+					continue
+				}
+				winner = ev.branch
+				res.Winner = winner.login
+				ictx.ClientTx = winner.tx
+				ictx.OutInvite = winner.out
+				ictx.Codecs = winner.codecs
+				s.relayInviteResponse(ictx, ev.resp)
+				break loop
+			default:
+				if code != sip.StatusRequestTerminated || res.LastCode == sip.StatusTemporarilyUnavailable {
+					res.LastCode = code
+				}
+				pending--
+			}
+
+		case <-timer.C:
+			log.Printf("[FORK] timeout after %s", timeout)
+			break loop
+		}
+	}
+
+	// остальные ветки отменяем; поздний 2xx от них закрываем ACK+BYE
+	for _, b := range branches {
+		if b == winner {
+			continue
+		}
+		if cancel := buildCancel(b.out); cancel != nil {
+			_, _ = s.cl.TransactionRequest(context.Background(), cancel)
+		}
+	}
+	ictx.clearForks()
+	s.ringing.remove(ictx)
+	if winner == nil {
+		s.endEarly(ictx, res.LastCode, "")
+	}
+
+	go s.drainFork(ictx, winner, events, finished, stop)
+
+	return res
+}
+
+func (s *Server) drainFork(ictx *InviteCtx, winner *forkBranch, events <-chan forkEvent, finished <-chan struct{}, stop chan struct{}) {
+	defer close(stop)
+
+	handle := func(ev forkEvent) {
+		code := ev.resp.StatusCode
+		if code < 200 || code >= 300 {
+			return
+		}
+		if ev.branch == winner {
+			s.relayInviteResponse(ictx, ev.resp)
+			return
+		}
+		s.hangupStray(ev.branch.out, ev.resp)
+	}
+
+	for {
+		select {
+		case ev := <-events:
+			handle(ev)
+		case <-finished:
+			// всё, что успело попасть в буфер
+			for {
+				select {
+				case ev := <-events:
+					handle(ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// hangupStray подтверждает лишний 2xx и сразу завершает этот диалог.
+func (s *Server) hangupStray(out *sip.Request, resp *sip.Response) {
+	ct := resp.Contact()
+	if ct == nil {
+		return
+	}
+	log.Printf("[FORK] stray 2xx from %s, sending ACK+BYE", ct.Address.String())
+
+	routes := stripSelfRoute(buildRouteSet(resp), s.host, s.port)
+
+	for _, method := range []sip.RequestMethod{sip.ACK, sip.BYE} {
+		req := sip.NewRequest(method, ct.Address)
+
+		copyFrom := *out.From()
+		copyTo := *resp.To()
+		copyCallID := *out.CallID()
+		cseq := *out.CSeq()
+		cseq.MethodName = method
+		if method == sip.BYE {
+			cseq.SeqNo++
+		}
+
+		req.AppendHeader(&copyFrom)
+		req.AppendHeader(&copyTo)
+		req.AppendHeader(&copyCallID)
+		req.AppendHeader(&cseq)
+		for _, r := range routes {
+			req.AppendHeader(r)
+		}
+
+		if method == sip.ACK {
+			if err := s.cl.WriteRequest(req); err != nil {
+				log.Printf("[FORK] stray ACK error: %v", err)
+			}
+			continue
+		}
+		if _, err := s.cl.TransactionRequest(context.Background(), req); err != nil {
+			log.Printf("[FORK] stray BYE error: %v", err)
+		}
+	}
+}
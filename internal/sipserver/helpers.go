@@ -58,6 +58,19 @@ func buildOutboundInvite(in *sip.Request, target *sip.Uri, myHost string, myPort
 		out.AppendHeader(h.Clone())
 	}
 
+	// перевод звонка: получатель должен увидеть, кого заменяет и кто перевёл
+	for _, name := range []string{"Replaces", "Referred-By"} {
+		if h := in.GetHeader(name); h != nil {
+			out.AppendHeader(sip.HeaderClone(h))
+		}
+	}
+	// 100rel: callee должен знать, что caller подтвердит надёжный 18x PRACK'ом
+	for _, name := range []string{"Supported", "Require"} {
+		for _, h := range in.GetHeaders(name) {
+			out.AppendHeader(sip.HeaderClone(h))
+		}
+	}
+
 	var mf uint32 = 70
 	if h := in.MaxForwards(); h != nil {
 		mf = h.Val()
@@ -151,6 +164,12 @@ func makeUpstreamResponse(orig *sip.Request, down *sip.Response) *sip.Response {
 	if c := down.Contact(); c != nil {
 		up.AppendHeader(c.Clone())
 	}
+	// надёжный 18x (RFC 3262): без них caller не пришлёт PRACK
+	for _, name := range []string{"Require", "RSeq"} {
+		for _, h := range down.GetHeaders(name) {
+			up.AppendHeader(sip.HeaderClone(h))
+		}
+	}
 
 	up.RemoveHeader("Content-Length")
 	up.RemoveHeader("Content-Type")
@@ -178,6 +197,40 @@ func makeUpstreamResponse(orig *sip.Request, down *sip.Response) *sip.Response {
 	return up
 }
 
+// buildCancel строит CANCEL на исходящий INVITE (тот же Via branch и CSeq).
+func buildCancel(out *sip.Request) *sip.Request {
+	v := out.Via()
+	if v == nil {
+		return nil
+	}
+
+	cancel := sip.NewRequest(sip.CANCEL, out.Recipient)
+
+	copyFrom := *out.From()
+	copyTo := *out.To()
+	copyCallID := *out.CallID()
+
+	cseq := *out.CSeq()
+	cseq.MethodName = sip.CANCEL
+
+	cancel.AppendHeader(&copyFrom)
+	cancel.AppendHeader(&copyTo)
+	cancel.AppendHeader(&copyCallID)
+	cancel.AppendHeader(&cseq)
+
+	for _, h := range out.GetHeaders("Route") {
+		cancel.AppendHeader(h)
+	}
+
+	vcopy := *v
+	cancel.PrependHeader(&vcopy)
+
+	mf := sip.MaxForwardsHeader(70)
+	cancel.AppendHeader(&mf)
+
+	return cancel
+}
+
 func MakeDialogKey(callID, tagA, tagB string) (string, string) {
 	key1 := callID + "|" + tagA + "|" + tagB
 	key2 := callID + "|" + tagB + "|" + tagA
@@ -241,3 +294,35 @@ func encodeRouteSet(routes []*sip.RouteHeader) ([]byte, error) {
 
 	return json.Marshal(out)
 }
+
+func reasonPhrase(code int) string {
+	switch code {
+	case sip.StatusBadRequest:
+		return "Bad Request"
+	case sip.StatusForbidden:
+		return "Forbidden"
+	case sip.StatusNotFound:
+		return "Not Found"
+	case sip.StatusRequestTimeout:
+		return "Request Timeout"
+	case sip.StatusGone:
+		return "Gone"
+	case sip.StatusTemporarilyUnavailable:
+		return "Temporarily Unavailable"
+	case sip.StatusBusyHere:
+		return "Busy Here"
+	case sip.StatusNotAcceptableHere:
+		return "Not Acceptable Here"
+	case sip.StatusInternalServerError:
+		return "Internal Server Error"
+	case sip.StatusServiceUnavailable:
+		return "Service Unavailable"
+	case sip.StatusGatewayTimeout:
+		return "Server Time-out"
+	case sip.StatusGlobalBusyEverywhere:
+		return "Busy Everywhere"
+	case sip.StatusGlobalDecline:
+		return "Decline"
+	}
+	return "Rejected"
+}
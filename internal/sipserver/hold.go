@@ -0,0 +1,282 @@
+package sipserver
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"SipServer/internal/media"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// holdState — удержание звонка; общий для обеих сторон диалога.
+type holdState struct {
+	mu    sync.Mutex
+	moh   *media.Session // музыка второй стороне, nil — не на удержании
+	since time.Time
+}
+
+func (h *holdState) active() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.moh != nil
+}
+
+func (h *holdState) begin(moh *media.Session) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.moh != nil {
+		return false
+	}
+	h.moh = moh
+	h.since = time.Now()
+	return true
+}
+
+// end останавливает музыку и возвращает, сколько длилось удержание.
+func (h *holdState) end() (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.moh == nil {
+		return 0, false
+	}
+	h.moh.Close()
+	h.moh = nil
+	return time.Since(h.since), true
+}
+
+// onReinvite — INVITE внутри существующего диалога (удержание, смена медиа).
+func (s *Server) onReinvite(req *sip.Request, tx sip.ServerTransaction) {
+	dlg, ok := s.lookupDialog(req)
+	if !ok {
+		log.Printf("[REINVITE] dialog not found callid=%s", req.CallID().Value())
+		respond(req, tx, sip.StatusCallTransactionDoesNotExists, "Call/Transaction Does Not Exist")
+		return
+	}
+
+	switch {
+	case dlg.Local != nil:
+		s.reinviteLocal(req, tx, dlg.Local)
+	case dlg.Peer != nil:
+		s.reinviteBridged(req, tx, dlg)
+	case dlg.Leg == nil:
+		s.reinviteProxied(req, tx, dlg)
+	default:
+		respond(req, tx, sip.StatusNotAcceptableHere, "Not Acceptable Here")
+	}
+}
+
+// reinviteLocal — звонок обслуживает сервер: переводим RTP на новый адрес телефона.
+func (s *Server) reinviteLocal(req *sip.Request, tx sip.ServerTransaction, lc *localCall) {
+	dir := media.DirSendRecv
+	if body := req.Body(); len(body) > 0 {
+		remote, err := media.ParseRemoteAudio(body)
+		if err != nil {
+			respond(req, tx, sip.StatusNotAcceptableHere, "Not Acceptable Here")
+			return
+		}
+		if codec, ok := remote.ChooseCodec(); ok {
+			lc.media.SetRemote(remote.Addr, codec)
+		}
+		if media.IsHold(body) {
+			dir = media.DirRecvOnly
+		}
+	}
+
+	s.respondSDP(req, tx, media.BuildSDPDirection(s.host, lc.media.LocalPort(), lc.media.Codec(), time.Now().Unix(), dir))
+}
+
+// reinviteProxied — звонок между двумя телефонами через прокси.
+// Удержание: второй стороне вместо собеседника ставим музыку с сервера.
+func (s *Server) reinviteProxied(req *sip.Request, tx sip.ServerTransaction, dlg *DialogCtx) {
+	if media.IsHold(req.Body()) && dlg.Hold != nil && !dlg.Hold.active() {
+		sess, codec, err := s.listenHold(req.Body())
+		if err != nil {
+			log.Printf("[HOLD] callid=%s: %v", dlg.CallID, err)
+			respond(req, tx, sip.StatusNotAcceptableHere, "Not Acceptable Here")
+			return
+		}
+
+		out := s.forwardInDialog(req, dlg)
+		out.SetBody(media.BuildSDPDirection(s.host, sess.LocalPort(), codec, time.Now().Unix(), media.DirSendOnly))
+
+		resp, err := s.waitFinal(out, 10*time.Second)
+		if err != nil {
+			sess.Close()
+			log.Printf("[HOLD] callid=%s re-INVITE error: %v", dlg.CallID, err)
+			respond(req, tx, sip.StatusRequestTimeout, "Request Timeout")
+			return
+		}
+
+		up := makeUpstreamResponse(req, resp)
+		dlg.ReplyPolicy.response(up, s.host, s.port)
+		if resp.StatusCode >= 300 {
+			sess.Close()
+			_ = tx.Respond(up)
+			return
+		}
+
+		s.startHold(dlg, sess, resp.Body())
+		up.SetBody(media.BuildSDPDirection(s.host, sess.LocalPort(), codec, time.Now().Unix(), media.DirRecvOnly))
+		_ = tx.Respond(up)
+		return
+	}
+
+	out := s.forwardInDialog(req, dlg)
+	// звонок пишется: медиа остаётся на сервере, меняется только адрес телефона
+	side := media.RelayA
+	if dlg.Recording != nil {
+		side = dlg.Recording.sideOf(req)
+		out.SetBody(dlg.Recording.anchor(side, req.Body(), s.host))
+	}
+
+	resp, err := s.waitFinal(out, 10*time.Second)
+	if err != nil {
+		log.Printf("[REINVITE] callid=%s relay error: %v", dlg.CallID, err)
+		respond(req, tx, sip.StatusRequestTimeout, "Request Timeout")
+		return
+	}
+	if resp.StatusCode < 300 && !media.IsHold(req.Body()) {
+		s.stopHold(dlg)
+	}
+
+	up := makeUpstreamResponse(req, resp)
+	dlg.ReplyPolicy.response(up, s.host, s.port)
+	if dlg.Recording != nil && resp.StatusCode < 300 {
+		up.SetBody(dlg.Recording.anchor(1-side, resp.Body(), s.host))
+	}
+	_ = tx.Respond(up)
+}
+
+// reinviteBridged — звонок соединил сервер (pickup, парковка): второй стороне
+// re-INVITE шлём сами.
+func (s *Server) reinviteBridged(req *sip.Request, tx sip.ServerTransaction, dlg *DialogCtx) {
+	if media.IsHold(req.Body()) && dlg.Hold != nil && !dlg.Hold.active() {
+		sess, codec, err := s.listenHold(req.Body())
+		if err != nil {
+			log.Printf("[HOLD] callid=%s: %v", dlg.CallID, err)
+			respond(req, tx, sip.StatusNotAcceptableHere, "Not Acceptable Here")
+			return
+		}
+
+		resp, err := s.reinviteLeg(dlg.Peer, media.BuildSDPDirection(s.host, sess.LocalPort(), codec, time.Now().Unix(), media.DirSendOnly))
+		if err != nil {
+			sess.Close()
+			log.Printf("[HOLD] callid=%s re-INVITE error: %v", dlg.CallID, err)
+			respond(req, tx, sip.StatusNotAcceptableHere, "Not Acceptable Here")
+			return
+		}
+
+		s.startHold(dlg, sess, resp.Body())
+		s.respondSDP(req, tx, media.BuildSDPDirection(s.host, sess.LocalPort(), codec, time.Now().Unix(), media.DirRecvOnly))
+		return
+	}
+
+	// звонок пишется: медиа остаётся на сервере (b2bua — caller на входящей стороне)
+	offer, side := req.Body(), media.RelayA
+	if dlg.Recording != nil {
+		if dlg.Leg.Outgoing {
+			side = media.RelayB
+		}
+		offer = dlg.Recording.anchor(side, offer, s.host)
+	}
+
+	resp, err := s.reinviteLeg(dlg.Peer, offer)
+	if err != nil {
+		log.Printf("[REINVITE] callid=%s: %v", dlg.CallID, err)
+		respond(req, tx, sip.StatusNotAcceptableHere, "Not Acceptable Here")
+		return
+	}
+	if !media.IsHold(req.Body()) {
+		s.stopHold(dlg)
+	}
+
+	answer := resp.Body()
+	if dlg.Recording != nil {
+		answer = dlg.Recording.anchor(1-side, answer, s.host)
+	}
+	s.respondSDP(req, tx, answer)
+}
+
+// listenHold открывает RTP-сессию для музыки с кодеком из SDP удерживающего.
+func (s *Server) listenHold(offer []byte) (*media.Session, media.Codec, error) {
+	remote, err := media.ParseRemoteAudio(offer)
+	if err != nil {
+		return nil, media.Codec{}, err
+	}
+	codec, ok := remote.ChooseCodec()
+	if !ok {
+		return nil, media.Codec{}, ErrNoCommonCodec
+	}
+	sess, err := media.Listen(s.host)
+	if err != nil {
+		return nil, media.Codec{}, err
+	}
+	return sess, codec, nil
+}
+
+// startHold включает музыку по SDP-ответу удерживаемого телефона.
+// ReadLoop не запускаем: слушать удерживаемого незачем.
+func (s *Server) startHold(dlg *DialogCtx, sess *media.Session, answer []byte) {
+	remote, err := media.ParseRemoteAudio(answer)
+	if err != nil {
+		log.Printf("[HOLD] callid=%s bad answer SDP: %v", dlg.CallID, err)
+		sess.Close()
+		return
+	}
+	codec, ok := remote.ChooseCodec()
+	if !ok {
+		codec = sess.Codec()
+	}
+	sess.SetRemote(remote.Addr, codec)
+
+	if !dlg.Hold.begin(sess) {
+		sess.Close()
+		return
+	}
+	log.Printf("[HOLD] callid=%s on hold", dlg.CallID)
+	go func() { _ = sess.Play(context.Background(), musicOnHold(), true) }()
+}
+
+// stopHold снимает удержание и дописывает его длительность в call_sessions.
+func (s *Server) stopHold(dlg *DialogCtx) {
+	if dlg.Hold == nil {
+		return
+	}
+	d, ok := dlg.Hold.end()
+	if !ok {
+		return
+	}
+	log.Printf("[HOLD] callid=%s resumed after %s", dlg.CallID, d.Round(time.Millisecond))
+
+	if s.callJournalRepo == nil {
+		return
+	}
+	sessions := [][3]string{{dlg.CallID, dlg.FromTag, dlg.ToTag}}
+	if dlg.Peer != nil {
+		fromTag, toTag := dlg.Peer.journalTags()
+		sessions = append(sessions, [3]string{dlg.Peer.CallID, fromTag, toTag})
+	}
+	for _, sess := range sessions {
+		if err := s.callJournalRepo.AddHold(context.Background(), sess[0], sess[1], sess[2], int(d.Milliseconds())); err != nil {
+			log.Printf("[HOLD] AddHold failed: %v", err)
+		}
+	}
+}
+
+// respondSDP — 200 OK от сервера как UA.
+func (s *Server) respondSDP(req *sip.Request, tx sip.ServerTransaction, sdp []byte) {
+	res := sip.NewResponseFromRequest(req, sip.StatusOK, "OK", sdp)
+	res.AppendHeader(&sip.ContactHeader{
+		Address: sip.Uri{Scheme: "sip", Host: s.host, Port: s.port},
+	})
+	if len(sdp) > 0 {
+		ct := sip.ContentTypeHeader("application/sdp")
+		res.AppendHeader(&ct)
+	}
+	_ = tx.Respond(res)
+}
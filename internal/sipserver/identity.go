@@ -0,0 +1,77 @@
+package sipserver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/emiago/sipgo/sip"
+
+	"SipServer/internal/repository/trunk"
+)
+
+// assertIdentity подписывает исходящий INVITE внутреннего звонящего данными
+// профиля: имя в From и P-Asserted-Identity вместо того, что прислал телефон.
+// t != nil — звонок уходит в транк: туда идёт caller_id_number, а не логин.
+func (s *Server) assertIdentity(ictx *InviteCtx, out *sip.Request, t *trunk.Trunk) {
+	// что бы ни прислал телефон или транк, личность звонящего определяет сервер
+	removeAll(out, "P-Asserted-Identity")
+	removeAll(out, "P-Preferred-Identity")
+
+	u := ictx.Caller
+	if u == nil {
+		return
+	}
+
+	name := u.DisplayName
+	uri := sip.Uri{Scheme: "sip", User: u.Login, Host: s.host}
+	if t != nil {
+		if u.CallerIDName != "" {
+			name = u.CallerIDName
+		}
+		uri = sip.Uri{Scheme: "sip", User: u.CallerIDNumber, Host: t.Host}
+	}
+	name = quotedName(name)
+
+	if from := out.From(); from != nil {
+		if name != "" {
+			from.DisplayName = name
+		}
+		// логин транка (from_user) важнее: провайдер по нему узнаёт аккаунт;
+		// внутренний логин провайдеру не уходит и в From
+		if t != nil && t.FromUser == "" {
+			from.Address.User = trunkCallerUser(u.CallerIDNumber, t)
+		}
+	}
+
+	// без номера для транка внутренний логин провайдеру не показываем
+	if uri.User == "" {
+		return
+	}
+	value := "<" + uri.String() + ">"
+	if name != "" {
+		value = fmt.Sprintf("\"%s\" %s", name, value)
+	}
+	out.AppendHeader(sip.NewHeader("P-Asserted-Identity", value))
+}
+
+// trunkCallerUser — From.User звонка в транк без from_user: номер звонящего,
+// иначе аккаунт транка, иначе anonymous (RFC 3323).
+func trunkCallerUser(number string, t *trunk.Trunk) string {
+	switch {
+	case number != "":
+		return number
+	case t.Username != "":
+		return t.Username
+	}
+	return "anonymous"
+}
+
+// quotedName убирает из имени символы, ломающие quoted-string в заголовке.
+func quotedName(name string) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r == '"' || r == '\\' || r < ' ' {
+			return -1
+		}
+		return r
+	}, name))
+}
@@ -0,0 +1,73 @@
+package sipserver
+
+import (
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+
+	"SipServer/internal/repository/trunk"
+	userrepo "SipServer/internal/repository/user"
+)
+
+func TestAssertIdentity(t *testing.T) {
+	alice := &userrepo.User{Login: "101", DisplayName: "Alice", CallerIDNumber: "74951234567", CallerIDName: "ACME"}
+	bob := &userrepo.User{Login: "102", DisplayName: "Bob"}
+	prov := &trunk.Trunk{Host: "sip.prov.example", Username: "acc42"}
+	provFromUser := &trunk.Trunk{Host: "sip.prov.example", FromUser: "login"}
+	noAccount := &trunk.Trunk{Host: "sip.prov.example"}
+
+	tests := []struct {
+		name     string
+		caller   *userrepo.User
+		trunk    *trunk.Trunk
+		fromUser string
+		fromName string
+		pai      string // "" — заголовка нет
+	}{
+		{"internal call", alice, nil, "101", "Alice", `"Alice" <sip:101@pbx.local>`},
+		{"trunk call", alice, prov, "74951234567", "ACME", `"ACME" <sip:74951234567@sip.prov.example>`},
+		{"trunk from_user kept", alice, provFromUser, "login", "ACME", `"ACME" <sip:74951234567@sip.prov.example>`},
+		{"no number falls back to trunk account", bob, prov, "acc42", "Bob", ""},
+		{"no number and no account", bob, noAccount, "anonymous", "Bob", ""},
+		{"call from trunk", nil, nil, "101", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{host: "pbx.local"}
+			out := sip.NewRequest(sip.INVITE, sip.Uri{Scheme: "sip", User: "200", Host: "pbx.local"})
+			fromUser := "101"
+			if tt.trunk != nil && tt.trunk.FromUser != "" {
+				fromUser = tt.trunk.FromUser // как в buildTrunkInvite
+			}
+			out.AppendHeader(&sip.FromHeader{Address: sip.Uri{Scheme: "sip", User: fromUser, Host: "pbx.local"}})
+			// подделка от телефона: несколько экземпляров и другой регистр
+			out.AppendHeader(sip.NewHeader("P-Asserted-Identity", "<sip:boss@pbx.local>"))
+			out.AppendHeader(sip.NewHeader("p-asserted-identity", "<sip:boss2@pbx.local>"))
+			out.AppendHeader(sip.NewHeader("P-Preferred-Identity", "<sip:boss@pbx.local>"))
+
+			ictx := NewInviteCtx()
+			ictx.Caller = tt.caller
+			s.assertIdentity(ictx, out, tt.trunk)
+
+			from := out.From()
+			if from.Address.User != tt.fromUser || from.DisplayName != tt.fromName {
+				t.Errorf("From = %q <%s>, want %q <%s>", from.DisplayName, from.Address.User, tt.fromName, tt.fromUser)
+			}
+			var pai []string
+			for _, h := range out.Headers() {
+				switch h.Name() {
+				case "P-Asserted-Identity", "p-asserted-identity":
+					pai = append(pai, h.Value())
+				case "P-Preferred-Identity":
+					t.Errorf("P-Preferred-Identity kept: %s", h.Value())
+				}
+			}
+			switch {
+			case tt.pai == "" && len(pai) != 0:
+				t.Errorf("P-Asserted-Identity = %v, want none", pai)
+			case tt.pai != "" && (len(pai) != 1 || pai[0] != tt.pai):
+				t.Errorf("P-Asserted-Identity = %v, want %s", pai, tt.pai)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package sipserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"maps"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	aclrule "SipServer/internal/repository/acl_rule"
+	"SipServer/internal/repository/did"
+	"SipServer/internal/repository/trunk"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+const resolveTTL = 5 * time.Minute
+
+// inboundTrunk определяет транк по IP источника запроса.
+// Адрес, закрытый ACL trunk, транком не считается.
+func (s *Server) inboundTrunk(src string) *trunk.Trunk {
+	host, _, err := net.SplitHostPort(src)
+	if err != nil {
+		return nil
+	}
+	if !s.acl.Allowed(aclrule.ScopeTrunk, host) {
+		return nil
+	}
+	return s.trunkAddrs.get(host)
+}
+
+// trunkAddrs — IP включённых транков. Запросы смотрят только сюда: БД и DNS
+// трогает перечитывание (reloadTrunkAddrs), а не каждый INVITE сканера.
+type trunkAddrs struct {
+	mu   sync.RWMutex
+	byIP map[string]*trunk.Trunk
+}
+
+func newTrunkAddrs() *trunkAddrs {
+	return &trunkAddrs{byIP: make(map[string]*trunk.Trunk)}
+}
+
+func (a *trunkAddrs) get(ip string) *trunk.Trunk {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.byIP[ip]
+}
+
+func (a *trunkAddrs) set(byIP map[string]*trunk.Trunk) {
+	a.mu.Lock()
+	a.byIP = byIP
+	a.mu.Unlock()
+}
+
+// snapshot — копия таблицы IP -> транк.
+func (a *trunkAddrs) snapshot() map[string]*trunk.Trunk {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return maps.Clone(a.byIP)
+}
+
+// reloadTrunkAddrs перечитывает включённые транки и их адреса.
+// При ошибке БД остаётся прежняя таблица.
+func (s *Server) reloadTrunkAddrs(ctx context.Context) {
+	trunks, err := s.trunkRepo.ListEnabled(ctx)
+	if err != nil {
+		log.Printf("[INBOUND] list trunks error: %v", err)
+		return
+	}
+	byIP := make(map[string]*trunk.Trunk)
+	for _, t := range trunks {
+		for _, ip := range s.resolver.Lookup(t.Host) {
+			if _, ok := byIP[ip]; !ok {
+				byIP[ip] = t
+			}
+		}
+	}
+	s.trunkAddrs.set(byIP)
+}
+
+// inviteFromTrunk маршрутизирует входящий с транка звонок по таблице DID.
+func (s *Server) inviteFromTrunk(req *sip.Request, tx sip.ServerTransaction, ictx *InviteCtx, t *trunk.Trunk) {
+	number := strings.TrimSpace(req.Recipient.User)
+	if number == "" {
+		number = strings.TrimSpace(req.To().Address.User)
+	}
+
+	d, err := s.didRepo.FindInbound(context.Background(), number, t.Id)
+	if errors.Is(err, did.ErrDIDNotFound) {
+		// номер без DID, но записанный пользователю алиасом
+		if login, aerr := s.userRepositoriy.ResolveAlias(number); aerr == nil {
+			d, err = &did.DID{Number: number, DestinationType: did.DestinationUser, Destination: login}, nil
+		}
+	}
+	if err != nil {
+		if errors.Is(err, did.ErrDIDNotFound) {
+			log.Printf("[INBOUND] trunk=%s did=%s not found", t.Name, number)
+			res := sip.NewResponseFromRequest(req, sip.StatusNotFound, "Not Found", nil)
+			_ = tx.Respond(res)
+			return
+		}
+		log.Printf("[INBOUND] trunk=%s did=%s error %v", t.Name, number, err)
+		res := sip.NewResponseFromRequest(req, sip.StatusInternalServerError, "InternalError", nil)
+		_ = tx.Respond(res)
+		return
+	}
+
+	log.Printf("[INBOUND] trunk=%s did=%s -> %s %s", t.Name, number, d.DestinationType, d.Destination)
+
+	// входящие тоже занимают каналы транка
+	if !s.trunkChannels.Acquire(t.Name, t.MaxChannels) {
+		log.Printf("[INBOUND] trunk=%s all %d channels busy", t.Name, t.MaxChannels)
+		res := sip.NewResponseFromRequest(req, sip.StatusServiceUnavailable, "Service Unavailable", nil)
+		_ = tx.Respond(res)
+		return
+	}
+	ictx.Trunk = t.Name
+	ictx.InPolicy = trunkPolicy(t)
+
+	if s.callJournalRepo != nil && ictx.JournalID != 0 {
+		if err := s.callJournalRepo.SetInbound(context.Background(), ictx.JournalID, t.Name, d.Number); err != nil {
+			log.Printf("[INBOUND] SetInbound failed: %v", err)
+		}
+	}
+
+	switch d.DestinationType {
+	case did.DestinationUser:
+		s.inviteLocalUser(req, tx, ictx, d.Destination)
+	case did.DestinationGroup:
+		s.inviteGroup(req, tx, ictx, d.Destination, 0)
+	case did.DestinationIVR:
+		menu, err := s.ivrRepo.FindByExtension(context.Background(), d.Destination)
+		if err != nil {
+			log.Printf("[INBOUND] ivr %s: %v", d.Destination, err)
+			s.failInvite(ictx, sip.StatusNotFound, "Not Found")
+			return
+		}
+		s.inviteIVR(ictx, menu)
+	default:
+		s.trunkChannels.Release(t.Name)
+		log.Printf("[INBOUND] destination %s is not supported", d.DestinationType)
+		res := sip.NewResponseFromRequest(req, sip.StatusServiceUnavailable, "Service Unavailable", nil)
+		_ = tx.Respond(res)
+	}
+}
+
+type resolved struct {
+	ips []string
+	at  time.Time
+}
+
+// hostResolver кэширует DNS-ответы для хостов транков.
+type hostResolver struct {
+	mu    sync.Mutex
+	cache map[string]resolved
+}
+
+func newHostResolver() *hostResolver {
+	return &hostResolver{cache: make(map[string]resolved)}
+}
+
+func (r *hostResolver) Lookup(host string) []string {
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{ip.String()}
+	}
+
+	r.mu.Lock()
+	c, ok := r.cache[host]
+	r.mu.Unlock()
+	if ok && time.Since(c.at) < resolveTTL {
+		return c.ips
+	}
+
+	ips, err := net.LookupHost(host)
+	if err != nil {
+		log.Printf("[INBOUND] resolve %s error: %v", host, err)
+		return c.ips
+	}
+
+	r.mu.Lock()
+	r.cache[host] = resolved{ips: ips, at: time.Now()}
+	r.mu.Unlock()
+	return ips
+}
@@ -0,0 +1,279 @@
+package sipserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"SipServer/internal/media"
+	"SipServer/internal/repository"
+	"SipServer/internal/repository/ivr"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+const (
+	// сколько звоним на внутренний номер из меню, если соединяем сами
+	ivrRingTimeout = 30 * time.Second
+
+	// защита от меню, ссылающихся друг на друга по кругу
+	ivrMaxHops = 10
+)
+
+// inviteIVR отвечает на звонок и ведёт звонящего по меню.
+func (s *Server) inviteIVR(ictx *InviteCtx, menu *ivr.Menu) {
+	lc := s.answerLocalOrReject(ictx)
+	if lc == nil {
+		return
+	}
+	log.Printf("[IVR] menu=%s call-id=%s", menu.Extension, lc.leg.CallID)
+	go s.runIVR(lc, menu)
+}
+
+func (s *Server) runIVR(lc *localCall, menu *ivr.Menu) {
+	for hop := 0; hop < ivrMaxHops; hop++ {
+		opt := s.ivrSelect(lc, menu)
+		if lc.ctx.Err() != nil {
+			return
+		}
+		if opt == nil || opt.Action == ivr.ActionHangup {
+			break
+		}
+
+		log.Printf("[IVR] menu=%s call-id=%s digit=%s -> %s %s",
+			menu.Extension, lc.leg.CallID, opt.Digit, opt.Action, opt.Target)
+
+		if opt.Action != ivr.ActionMenu {
+			s.ivrRoute(lc, opt)
+			return
+		}
+
+		next, err := s.ivrRepo.FindByExtension(lc.ctx, opt.Target)
+		if err != nil {
+			log.Printf("[IVR] submenu %s: %v", opt.Target, err)
+			break
+		}
+		menu = next
+	}
+
+	s.endLocal(lc, repository.CallEndedBySystem, true)
+}
+
+// ivrSelect проигрывает меню и ждёт цифру. Тишина и неверная цифра — повтор,
+// после MaxRetries попыток — пункт "t", nil — его нет.
+func (s *Server) ivrSelect(lc *localCall, menu *ivr.Menu) *ivr.Option {
+	prompt := ivrPrompt(menu)
+	timeout := time.Duration(menu.DigitTimeout) * time.Second
+
+	for attempt := 0; attempt < menu.MaxRetries; attempt++ {
+		digit, ok := waitDigit(lc, prompt, timeout)
+		if lc.ctx.Err() != nil {
+			return nil
+		}
+		if !ok {
+			log.Printf("[IVR] menu=%s call-id=%s no input", menu.Extension, lc.leg.CallID)
+			continue
+		}
+		if opt := menu.Option(digit); opt != nil {
+			return opt
+		}
+
+		log.Printf("[IVR] menu=%s call-id=%s invalid digit %s", menu.Extension, lc.leg.CallID, digit)
+		_ = lc.media.Play(lc.ctx, media.Tone(300, 600*time.Millisecond, 4000), false)
+	}
+	return menu.Option(ivr.DigitTimeout)
+}
+
+// waitDigit играет prompt и ждёт цифру: можно нажать не дослушав,
+// после конца prompt ждём ещё timeout.
+func waitDigit(lc *localCall, prompt []int16, timeout time.Duration) (string, bool) {
+	played, stop := lc.playAsync(prompt, false)
+	defer stop()
+
+	var expired <-chan time.Time
+	for {
+		select {
+		case digit := <-lc.dtmf:
+			return digit, true
+		case <-played:
+			played = nil
+			t := time.NewTimer(timeout)
+			defer t.Stop()
+			expired = t.C
+		case <-expired:
+			return "", false
+		case <-lc.ctx.Done():
+			return "", false
+		}
+	}
+}
+
+// ivrPrompt — WAV меню или, если его нет, короткий сигнал.
+func ivrPrompt(menu *ivr.Menu) []int16 {
+	if menu.Prompt != "" {
+		samples, err := media.ReadWAV(menu.Prompt)
+		if err == nil {
+			return samples
+		}
+		log.Printf("[IVR] menu=%s prompt %s: %v", menu.Extension, menu.Prompt, err)
+	}
+
+	var prompt []int16
+	for _, freq := range []float64{600, 800, 1000} {
+		prompt = append(prompt, media.Tone(freq, 150*time.Millisecond, 4000)...)
+		prompt = append(prompt, media.Silence(50*time.Millisecond)...)
+	}
+	return prompt
+}
+
+// ivrRoute уводит звонок из меню на выбранный пункт.
+func (s *Server) ivrRoute(lc *localCall, opt *ivr.Option) {
+	if opt.Action == ivr.ActionVoicemail {
+		owner, err := s.userRepositoriy.FindByLogin(opt.Target)
+		if err != nil {
+			log.Printf("[IVR] voicemail %s: %v", opt.Target, err)
+			s.endLocal(lc, repository.CallEndedBySystem, true)
+			return
+		}
+		s.recordVoicemail(lc, owner)
+		return
+	}
+
+	// телефон сам позвонит обычным путём: группы, voicemail, журнал.
+	// Транк REFER на наш внутренний номер выполнить не сможет.
+	if lc.leg.Trunk == "" && s.referLocal(lc, opt.Target) {
+		return
+	}
+
+	// REFER не принят — соединяем сами
+	_, stop := lc.playAsync(ringbackTone(), true)
+	logins, timeout := s.ivrTargets(opt)
+	for _, login := range logins {
+		if s.bridgeLocal(lc, login, timeout) {
+			stop()
+			return
+		}
+	}
+	stop()
+
+	if opt.Action == ivr.ActionExtension {
+		if owner, err := s.userRepositoriy.FindByLogin(opt.Target); err == nil {
+			s.recordVoicemail(lc, owner)
+			return
+		}
+	}
+	s.endLocal(lc, repository.CallEndedBySystem, true)
+}
+
+// ivrTargets — кого обзванивать по очереди, когда соединяем сами.
+func (s *Server) ivrTargets(opt *ivr.Option) ([]string, time.Duration) {
+	if opt.Action == ivr.ActionExtension {
+		return []string{opt.Target}, ivrRingTimeout
+	}
+
+	g, err := s.ringGroupRepo.FindByExtension(context.Background(), opt.Target)
+	if err != nil {
+		log.Printf("[IVR] group %s: %v", opt.Target, err)
+		return nil, 0
+	}
+	logins := make([]string, 0, len(g.Members))
+	for _, m := range g.Members {
+		logins = append(logins, m.Login)
+	}
+	return logins, time.Duration(g.MemberTimeout) * time.Second
+}
+
+// referLocal переводит телефон на number blind-REFER'ом: дальше он звонит сам.
+// false — телефон перевод не принял.
+func (s *Server) referLocal(lc *localCall, number string) bool {
+	req := s.legRequest(lc.leg, sip.REFER)
+	req.AppendHeader(sip.NewHeader("Refer-To", fmt.Sprintf("<sip:%s@%s>", number, s.host)))
+	req.AppendHeader(sip.NewHeader("Referred-By", fmt.Sprintf("<%s>", lc.leg.LocalURI.String())))
+	req.AppendHeader(&sip.ContactHeader{
+		Address: sip.Uri{Scheme: "sip", Host: s.host, Port: s.port},
+	})
+
+	resp, err := s.waitFinal(req, 5*time.Second)
+	if err != nil || resp.StatusCode >= 300 {
+		log.Printf("[IVR] call-id=%s REFER to %s not accepted", lc.leg.CallID, number)
+		return false
+	}
+
+	s.transfers.add(lc.leg.User, number, pendingTransfer{
+		ParentJournalID: lc.leg.JournalID,
+		Type:            TransferBlind,
+		ExpiresAt:       time.Now().Add(transferTTL),
+	})
+
+	if s.callJournalRepo != nil {
+		fromTag, toTag := lc.leg.journalTags()
+		if err := s.callJournalRepo.MarkTransferred(context.Background(), lc.leg.CallID, fromTag, toTag); err != nil {
+			log.Printf("[IVR] MarkTransferred failed: %v", err)
+		}
+	}
+
+	s.endLocal(lc, repository.CallEndedBySystem, true)
+	return true
+}
+
+// ringbackTone — КПВ: 425 Гц, 1 с звонка, 4 с тишины.
+func ringbackTone() []int16 {
+	return append(media.Tone(425, time.Second, 4000), media.Silence(4*time.Second)...)
+}
+
+// onInfo: DTMF (application/dtmf-relay, application/dtmf) для звонков,
+// которые обслуживает сервер; в проксируемых диалогах INFO идёт другой стороне.
+func (s *Server) onInfo(req *sip.Request, tx sip.ServerTransaction) {
+	start := time.Now()
+	sipIn(req.Method)
+	defer observeHandler(req.Method, start)
+
+	dlg, ok := s.lookupDialog(req)
+	if !ok {
+		respond(req, tx, sip.StatusCallTransactionDoesNotExists, "Call/Transaction Does Not Exist")
+		return
+	}
+
+	switch {
+	case dlg.Local != nil:
+		if digit, ok := parseDTMFInfo(req); ok {
+			dlg.Local.pushDigit(digit)
+		}
+		respond(req, tx, sip.StatusOK, "OK")
+	case dlg.Leg == nil:
+		s.relayInDialog(req, tx, dlg)
+	default:
+		respond(req, tx, sip.StatusNotImplemented, "Not Implemented")
+	}
+}
+
+// parseDTMFInfo достаёт цифру из тела INFO: "Signal=5" (dtmf-relay) или "5" (dtmf).
+func parseDTMFInfo(req *sip.Request) (string, bool) {
+	ct := req.ContentType()
+	if ct == nil {
+		return "", false
+	}
+	mime, _, _ := strings.Cut(strings.ToLower(ct.Value()), ";")
+	body := strings.TrimSpace(string(req.Body()))
+
+	switch strings.TrimSpace(mime) {
+	case "application/dtmf-relay":
+		signal := ""
+		for _, line := range strings.Split(body, "\n") {
+			k, v, ok := strings.Cut(line, "=")
+			if ok && strings.EqualFold(strings.TrimSpace(k), "signal") {
+				signal = strings.TrimSpace(v)
+				break
+			}
+		}
+		body = signal
+	case "application/dtmf":
+	default:
+		return "", false
+	}
+
+	digit := strings.ToUpper(body)
+	return digit, media.IsDTMFDigit(digit)
+}
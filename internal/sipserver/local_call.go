@@ -0,0 +1,310 @@
+package sipserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"SipServer/internal/media"
+	"SipServer/internal/repository"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+var ErrNoCommonCodec = errors.New("no common codec")
+
+// localCall — звонок, на который ответил сам сервер (парковка, voicemail...).
+type localCall struct {
+	ictx      *InviteCtx
+	leg       *bridgeLeg
+	media     *media.Session
+	remoteSDP []byte // SDP телефона: нужен, чтобы потом соединить его с другим
+
+	ctx    context.Context // отменяется, когда звонок заканчивается или уходит в bridge
+	cancel context.CancelFunc
+	ended  atomic.Bool
+
+	dtmf      chan string // цифры из RFC 4733 и SIP INFO
+	eventPT   uint8       // telephone-event телефона
+	lastEvent uint32      // timestamp последнего события: конец события телефон повторяет
+
+	mu       sync.Mutex
+	receive  func(p *media.Packet)
+	onHangup func() // телефон положил трубку
+}
+
+func (lc *localCall) setReceiver(fn func(p *media.Packet)) {
+	lc.mu.Lock()
+	lc.receive = fn
+	lc.mu.Unlock()
+}
+
+func (lc *localCall) setOnHangup(fn func()) {
+	lc.mu.Lock()
+	lc.onHangup = fn
+	lc.mu.Unlock()
+}
+
+func (lc *localCall) handlePacket(p *media.Packet) {
+	if p.PayloadType == media.TelephoneEventPT || (lc.eventPT != 0 && p.PayloadType == lc.eventPT) {
+		ev, err := media.ParseTelephoneEvent(p.Payload)
+		if err != nil || !ev.End || p.Timestamp == lc.lastEvent {
+			return
+		}
+		lc.lastEvent = p.Timestamp
+		if digit, ok := ev.Digit(); ok {
+			lc.pushDigit(digit)
+		}
+		return
+	}
+
+	lc.mu.Lock()
+	fn := lc.receive
+	lc.mu.Unlock()
+	if fn != nil {
+		fn(p)
+	}
+}
+
+// playAsync играет samples в фоне. stop прерывает проигрывание и ждёт его конца.
+func (lc *localCall) playAsync(samples []int16, loop bool) (done <-chan struct{}, stop func()) {
+	ctx, cancel := context.WithCancel(lc.ctx)
+	ch := make(chan struct{})
+	go func() {
+		defer close(ch)
+		_ = lc.media.Play(ctx, samples, loop)
+	}()
+	return ch, func() {
+		cancel()
+		<-ch
+	}
+}
+
+// pushDigit не блокирует: если цифры никто не ждёт, лишние теряются.
+func (lc *localCall) pushDigit(digit string) {
+	select {
+	case lc.dtmf <- digit:
+	default:
+	}
+}
+
+// answerLocal отвечает на INVITE медиа-сессией сервера.
+func (s *Server) answerLocal(ictx *InviteCtx) (*localCall, error) {
+	remote, err := media.ParseRemoteAudio(ictx.OriginInvite.Body())
+	if err != nil {
+		return nil, err
+	}
+	codec, ok := remote.ChooseCodec()
+	if !ok {
+		return nil, ErrNoCommonCodec
+	}
+
+	sess, err := media.Listen(s.host)
+	if err != nil {
+		return nil, err
+	}
+	sess.SetRemote(remote.Addr, codec)
+
+	sdp := media.BuildSDP(s.host, sess.LocalPort(), codec, time.Now().Unix())
+	leg := s.answerLeg(ictx, sdp)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lc := &localCall{
+		ictx:      ictx,
+		leg:       leg,
+		media:     sess,
+		remoteSDP: ictx.OriginInvite.Body(),
+		ctx:       ctx,
+		cancel:    cancel,
+		dtmf:      make(chan string, 16),
+		eventPT:   remote.EventPT,
+	}
+	go sess.ReadLoop(lc.handlePacket)
+
+	key, _ := leg.keys()
+	fromTag, toTag := leg.journalTags()
+	s.dialogs.Store(key, &DialogCtx{
+		Key:          key,
+		RemoteTarget: leg.Target,
+		JournalID:    leg.JournalID,
+		CallID:       leg.CallID,
+		FromTag:      fromTag,
+		ToTag:        toTag,
+		CallerUser:   leg.User,
+		AnswerAt:     leg.AnswerAt,
+		Trunk:        leg.Trunk,
+		Leg:          leg,
+		Local:        lc,
+	})
+	atomic.AddInt64(&s.activeDialog, 1)
+	sipActiveDialogsSet(atomic.LoadInt64(&s.activeDialog))
+
+	log.Printf("[LOCAL] answered call-id=%s codec=%s rtp=%d", leg.CallID, codec.Name, sess.LocalPort())
+	return lc, nil
+}
+
+// answerLocalOrReject — answerLocal с 488/500 при ошибке.
+func (s *Server) answerLocalOrReject(ictx *InviteCtx) *localCall {
+	lc, err := s.answerLocal(ictx)
+	if err == nil {
+		return lc
+	}
+
+	log.Printf("[LOCAL] answer failed: %v", err)
+	if errors.Is(err, ErrNoCommonCodec) || errors.Is(err, media.ErrNoAudio) {
+		s.failInvite(ictx, sip.StatusNotAcceptableHere, "Not Acceptable Here")
+	} else {
+		s.failInvite(ictx, sip.StatusInternalServerError, "InternalError")
+	}
+	return nil
+}
+
+// endLocal завершает звонок, обслуживаемый сервером. sendBye — вешает трубку сервер.
+func (s *Server) endLocal(lc *localCall, endedBy repository.CallEndedBy, sendBye bool) {
+	if !lc.ended.CompareAndSwap(false, true) {
+		return
+	}
+
+	lc.cancel()
+	lc.media.Close()
+	s.dropLeg(lc.leg, endedBy, sendBye)
+
+	lc.mu.Lock()
+	hook := lc.onHangup
+	lc.mu.Unlock()
+	if hook != nil && !sendBye {
+		hook()
+	}
+}
+
+// detachLocal останавливает медиа сервера перед тем, как соединить телефон с другим.
+// false — звонок уже закончился.
+func (s *Server) detachLocal(lc *localCall) bool {
+	if !lc.ended.CompareAndSwap(false, true) {
+		return false
+	}
+	lc.cancel()
+	lc.media.Close()
+	return true
+}
+
+// dropLeg закрывает диалог сервера с телефоном: журнал, счётчики, канал транка.
+func (s *Server) dropLeg(leg *bridgeLeg, endedBy repository.CallEndedBy, sendBye bool) {
+	key, _ := leg.keys()
+	s.dialogs.Delete(key)
+	if sendBye {
+		s.sendLegBye(leg)
+	}
+	s.endLegJournal(leg, endedBy, time.Now())
+
+	atomic.AddInt64(&s.activeDialog, -1)
+	sipActiveDialogsSet(atomic.LoadInt64(&s.activeDialog))
+
+	if leg.Trunk != "" {
+		s.trunkChannels.Release(leg.Trunk)
+	}
+}
+
+func (s *Server) byeLocal(req *sip.Request, tx sip.ServerTransaction, dlg *DialogCtx) {
+	_ = tx.Respond(sip.NewResponseFromRequest(req, sip.StatusOK, "OK", nil))
+	s.endLocal(dlg.Local, repository.CallEndedByCaller, false)
+}
+
+// bridgeLocal звонит login и соединяет его со звонком, который держит сервер.
+// false — login не ответил, звонок по-прежнему у сервера.
+func (s *Server) bridgeLocal(lc *localCall, login string, timeout time.Duration) bool {
+	from := sip.Uri{Scheme: "sip", User: lc.leg.User, Host: s.host}
+	leg, resp, err := s.callOut(login, from, lc.remoteSDP, timeout)
+	if err != nil {
+		log.Printf("[LOCAL] call %s failed: %v", login, err)
+		return false
+	}
+
+	// пока звонили, телефон мог положить трубку
+	if !s.detachLocal(lc) {
+		s.sendLegBye(leg)
+		s.endLegJournal(leg, repository.CallEndedBySystem, time.Now())
+		return true
+	}
+
+	if _, err := s.reinviteLeg(lc.leg, resp.Body()); err != nil {
+		log.Printf("[LOCAL] call-id=%s re-INVITE failed: %v", lc.leg.CallID, err)
+		s.dropLeg(lc.leg, repository.CallEndedBySystem, true)
+		s.sendLegBye(leg)
+		s.endLegJournal(leg, repository.CallEndedBySystem, time.Now())
+		return true
+	}
+
+	s.linkLegs(lc.leg, leg, nil)
+	return true
+}
+
+// callOut — сервер сам звонит пользователю и предлагает ему sdp.
+// from — кем представиться. Возвращает сторону диалога и 200 OK.
+func (s *Server) callOut(login string, from sip.Uri, sdp []byte, timeout time.Duration) (*bridgeLeg, *sip.Response, error) {
+	binding, ok := s.reg.Get(login)
+	if !ok {
+		return nil, nil, fmt.Errorf("user %s not registered", login)
+	}
+
+	target := sip.Uri{Scheme: "sip", User: login, Host: binding.Contact.Host, Port: binding.Contact.Port}
+	target.UriParams = sip.NewParams().Add("transport", "udp")
+	toURI := sip.Uri{Scheme: "sip", User: login, Host: s.host}
+
+	req := s.newInvite(target, from, toURI, sdp)
+	localTag, _ := req.From().Params.Get("tag")
+	callID := *req.CallID()
+
+	inviteAt := time.Now()
+	var journalID int64
+	if s.callJournalRepo != nil {
+		id, err := s.callJournalRepo.StartCallAttempt(context.Background(), callID.Value(),
+			extractTopViaBranch(req), from.User, login, inviteAt)
+		if err != nil {
+			log.Printf("[CDR] StartCallAttempt failed: %v", err)
+		}
+		journalID = id
+	}
+
+	resp, err := s.waitFinal(req, timeout)
+	if err != nil || resp.StatusCode >= 300 {
+		if s.callJournalRepo != nil && journalID != 0 {
+			_ = s.callJournalRepo.MarkNoAnswer(context.Background(), journalID, time.Now())
+		}
+		if err == nil {
+			err = fmt.Errorf("%d %s", resp.StatusCode, resp.Reason)
+		}
+		return nil, nil, err
+	}
+
+	remoteTag, _ := resp.To().Params.Get("tag")
+	leg := &bridgeLeg{
+		CallID:    callID.Value(),
+		LocalTag:  localTag,
+		RemoteTag: remoteTag,
+		LocalURI:  from,
+		RemoteURI: toURI,
+		Target:    target,
+		User:      login,
+		JournalID: journalID,
+		AnswerAt:  time.Now(),
+		Outgoing:  true,
+	}
+	if ct := resp.Contact(); ct != nil {
+		leg.Target = ct.Address
+	}
+	leg.cseq.Store(1)
+
+	ack := s.legRequest(leg, sip.ACK)
+	ack.CSeq().SeqNo = 1
+	if err := s.cl.WriteRequest(ack); err != nil {
+		log.Printf("[LOCAL] ACK error: %v", err)
+	}
+
+	s.markLegAnswered(leg, inviteAt)
+	return leg, resp, nil
+}
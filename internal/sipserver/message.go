@@ -0,0 +1,176 @@
+package sipserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"SipServer/internal/repository/message"
+	userrepo "SipServer/internal/repository/user"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+const (
+	// RFC 3428: больше по UDP не шлём, отвечаем 413
+	messageMaxBody = 1300
+	messageTimeout = 5 * time.Second
+)
+
+// onMessage — SIP MESSAGE (RFC 3428): доставка на контакт получателя,
+// офлайн — храним до следующего REGISTER.
+func (s *Server) onMessage(req *sip.Request, tx sip.ServerTransaction) {
+	start := time.Now()
+	sipIn(req.Method)
+	defer observeHandler(req.Method, start)
+
+	from := strings.TrimSpace(req.From().Address.User)
+	to := strings.TrimSpace(req.To().Address.User)
+	if from == "" || to == "" {
+		respond(req, tx, sip.StatusBadRequest, "Bad Request")
+		return
+	}
+	if len(req.Body()) > messageMaxBody {
+		respond(req, tx, sip.StatusRequestEntityTooLarge, "Request Entity Too Large")
+		return
+	}
+
+	// отправитель — как у INVITE: с транка не проверяем, иначе пользователь и digest
+	if s.inboundTrunk(req.Source()) == nil {
+		if _, ok := s.authorizeCaller(req, tx); !ok {
+			return
+		}
+	}
+
+	if _, err := s.userRepositoriy.FindByLogin(to); err != nil {
+		if errors.Is(err, userrepo.ErrUserNotFound) {
+			respond(req, tx, sip.StatusNotFound, "Not Found")
+		} else {
+			respond(req, tx, sip.StatusInternalServerError, "InternalError")
+		}
+		return
+	}
+
+	contentType := "text/plain"
+	if ct := req.ContentType(); ct != nil {
+		contentType = ct.Value()
+	}
+
+	msg, err := s.messageRepo.Create(context.Background(), &message.Message{
+		FromUser:    from,
+		ToUser:      to,
+		ContentType: contentType,
+		Body:        string(req.Body()),
+	})
+	if err != nil {
+		log.Printf("[MESSAGE] store failed: %v", err)
+		respond(req, tx, sip.StatusInternalServerError, "InternalError")
+		return
+	}
+
+	status, resp := s.deliverMessage(msg)
+	if status == message.StatusPending {
+		// получатель офлайн или не ответил: доставим после REGISTER
+		respond(req, tx, sip.StatusAccepted, "Accepted")
+		return
+	}
+	respond(req, tx, int(resp.StatusCode), resp.Reason)
+}
+
+// deliverMessage отправляет сообщение на контакт получателя и пишет результат.
+// pending — получатель офлайн или не ответил; иначе resp — его ответ.
+func (s *Server) deliverMessage(msg *message.Message) (string, *sip.Response) {
+	binding, ok := s.reg.Get(msg.ToUser)
+	if !ok {
+		return message.StatusPending, nil
+	}
+
+	target := sip.Uri{Scheme: "sip", User: msg.ToUser, Host: binding.Contact.Host, Port: binding.Contact.Port}
+	target.UriParams = sip.NewParams().Add("transport", "udp")
+
+	req := s.newRequest(sip.MESSAGE,
+		target,
+		sip.Uri{Scheme: "sip", User: msg.FromUser, Host: s.host},
+		sip.Uri{Scheme: "sip", User: msg.ToUser, Host: s.host},
+	)
+	ct := sip.ContentTypeHeader(msg.ContentType)
+	req.AppendHeader(&ct)
+	req.AppendHeader(sip.NewHeader("Date", msg.CreatedAt.UTC().Format(http.TimeFormat)))
+	req.SetBody([]byte(msg.Body))
+
+	resp, err := s.waitFinal(req, messageTimeout)
+	code := 0
+	status := message.StatusPending
+	switch {
+	case err != nil:
+		log.Printf("[MESSAGE] id=%d to=%s: %v", msg.Id, msg.ToUser, err)
+	case resp.StatusCode < 300:
+		code, status = int(resp.StatusCode), message.StatusDelivered
+	case resp.StatusCode == sip.StatusTemporarilyUnavailable || resp.StatusCode == sip.StatusRequestTimeout:
+		// телефон есть в регистрациях, но сейчас не принимает — попробуем позже
+	default:
+		code, status = int(resp.StatusCode), message.StatusFailed
+	}
+
+	if err := s.messageRepo.SetResult(context.Background(), msg.Id, status, code); err != nil {
+		log.Printf("[MESSAGE] id=%d SetResult failed: %v", msg.Id, err)
+	}
+	log.Printf("[MESSAGE] id=%d %s -> %s: %s", msg.Id, msg.FromUser, msg.ToUser, status)
+	return status, resp
+}
+
+// deliverPending отправляет накопленные сообщения пользователю после REGISTER.
+func (s *Server) deliverPending(login string) {
+	// REGISTER может прийти снова, пока идёт доставка
+	if _, busy := s.msgDelivering.LoadOrStore(login, struct{}{}); busy {
+		return
+	}
+	defer s.msgDelivering.Delete(login)
+
+	items, err := s.messageRepo.Pending(context.Background(), login)
+	if err != nil {
+		log.Printf("[MESSAGE] pending for %s: %v", login, err)
+		return
+	}
+	for _, msg := range items {
+		if status, _ := s.deliverMessage(msg); status == message.StatusPending {
+			// телефон опять не ответил — остальные не шлём до следующего REGISTER
+			return
+		}
+	}
+}
+
+// newInvite — INVITE от сервера в новом диалоге: свой Call-ID, tag и CSeq.
+func (s *Server) newInvite(target, from, to sip.Uri, sdp []byte) *sip.Request {
+	req := s.newRequest(sip.INVITE, target, from, to)
+	req.AppendHeader(&sip.ContactHeader{Address: sip.Uri{Scheme: "sip", Host: s.host, Port: s.port}})
+	if len(sdp) > 0 {
+		req.SetBody(sdp)
+		ct := sip.ContentTypeHeader("application/sdp")
+		req.AppendHeader(&ct)
+	}
+	return req
+}
+
+// newRequest — запрос вне диалога от имени from (MESSAGE, NOTIFY).
+func (s *Server) newRequest(method sip.RequestMethod, target, from, to sip.Uri) *sip.Request {
+	req := sip.NewRequest(method, target)
+
+	fromHdr := &sip.FromHeader{Address: from, Params: sip.NewParams()}
+	fromHdr.Params.Add("tag", sip.GenerateTagN(16))
+	callID := sip.CallIDHeader(fmt.Sprintf("%s@%s", sip.GenerateTagN(24), s.host))
+	mf := sip.MaxForwardsHeader(70)
+
+	req.AppendHeader(fromHdr)
+	req.AppendHeader(&sip.ToHeader{Address: to, Params: sip.NewParams()})
+	req.AppendHeader(&callID)
+	req.AppendHeader(&sip.CSeqHeader{SeqNo: 1, MethodName: method})
+	req.AppendHeader(&mf)
+	req.PrependHeader(s.newVia())
+
+	return req
+}
@@ -0,0 +1,36 @@
+package sipserver
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"SipServer/internal/media"
+)
+
+var (
+	mohOnce    sync.Once
+	mohSamples []int16
+)
+
+// musicOnHold — WAV из MOH_FILE (8 кГц моно), если его нет — сгенерированный сигнал.
+func musicOnHold() []int16 {
+	mohOnce.Do(func() {
+		if path := os.Getenv("MOH_FILE"); path != "" {
+			samples, err := media.ReadWAV(path)
+			if err == nil && len(samples) > 0 {
+				mohSamples = samples
+				return
+			}
+			log.Printf("[MOH] %s: %v, using generated tone", path, err)
+		}
+
+		for _, f := range []float64{523.25, 659.25, 783.99, 659.25} {
+			mohSamples = append(mohSamples, media.Tone(f, 400*time.Millisecond, 4000)...)
+			mohSamples = append(mohSamples, media.Silence(100*time.Millisecond)...)
+		}
+		mohSamples = append(mohSamples, media.Silence(time.Second)...)
+	})
+	return mohSamples
+}
@@ -0,0 +1,146 @@
+package sipserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"SipServer/internal/repository/voicemail"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// EventMessageSummary — лампа «есть сообщения» (RFC 3842).
+const EventMessageSummary = "message-summary"
+
+// как часто сверяем счётчики голосовой почты
+const mwiPollInterval = 5 * time.Second
+
+// mwiState — какие счётчики последними ушли пользователю без подписки.
+type mwiState struct {
+	mu   sync.Mutex
+	sent map[string]voicemail.Counts
+}
+
+func newMWIState() *mwiState {
+	return &mwiState{sent: make(map[string]voicemail.Counts)}
+}
+
+// RunMWI шлёт NOTIFY message-summary, когда меняются счётчики голосовой почты:
+// подписчикам — в их подписке, остальным зарегистрированным — вне диалога.
+func (s *Server) RunMWI(ctx context.Context) {
+	ticker := time.NewTicker(mwiPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		resend, err := s.voicemailRepo.TakeMWIRequests(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[MWI] requests: %v", err)
+			}
+			continue
+		}
+		counts, err := s.voicemailRepo.CountsByLogin(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[MWI] counts: %v", err)
+			}
+			continue
+		}
+
+		force := make(map[string]bool, len(resend))
+		for _, login := range resend {
+			force[login] = true
+		}
+
+		subscribed := make(map[string]bool)
+		for _, sub := range s.subscriptions.list() {
+			if sub.event != EventMessageSummary {
+				continue
+			}
+			subscribed[sub.user] = true
+			if force[sub.user] {
+				sub.mu.Lock()
+				sub.force = true
+				sub.mu.Unlock()
+			}
+			go s.sendNotify(sub, "")
+		}
+
+		for login, c := range counts {
+			if subscribed[login] {
+				continue
+			}
+			if _, ok := s.reg.Get(login); !ok {
+				// перерегистрируется — отправим заново
+				s.mwi.mu.Lock()
+				delete(s.mwi.sent, login)
+				s.mwi.mu.Unlock()
+				continue
+			}
+
+			s.mwi.mu.Lock()
+			last, known := s.mwi.sent[login]
+			s.mwi.sent[login] = c
+			s.mwi.mu.Unlock()
+
+			if known && last == c && !force[login] {
+				continue
+			}
+			// у кого никогда не было сообщений, лампу при регистрации не дёргаем
+			if !known && c == (voicemail.Counts{}) && !force[login] {
+				continue
+			}
+			go s.sendMWI(login, c)
+		}
+	}
+}
+
+// sendMWI — NOTIFY message-summary вне подписки на зарегистрированный контакт.
+func (s *Server) sendMWI(login string, c voicemail.Counts) {
+	binding, ok := s.reg.Get(login)
+	if !ok {
+		return
+	}
+
+	target := sip.Uri{Scheme: "sip", User: login, Host: binding.Contact.Host, Port: binding.Contact.Port}
+	target.UriParams = sip.NewParams().Add("transport", "udp")
+	account := sip.Uri{Scheme: "sip", User: login, Host: s.host}
+
+	req := s.newRequest(sip.NOTIFY, target, account, account)
+	ct := sip.ContentTypeHeader("application/simple-message-summary")
+	req.AppendHeader(sip.NewHeader("Event", EventMessageSummary))
+	req.AppendHeader(sip.NewHeader("Subscription-State", "active"))
+	req.AppendHeader(&ct)
+	req.SetBody([]byte(messageSummary(account.String(), c)))
+
+	resp, err := s.waitFinal(req, notifyTimeout)
+	if err != nil {
+		log.Printf("[MWI] %s: %v", login, err)
+		return
+	}
+	log.Printf("[MWI] %s new=%d old=%d -> %d", login, c.New, c.Old, resp.StatusCode)
+}
+
+// messageSummary — тело application/simple-message-summary.
+func messageSummary(account string, c voicemail.Counts) string {
+	waiting := "no"
+	if c.New > 0 {
+		waiting = "yes"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Messages-Waiting: %s\r\n", waiting)
+	fmt.Fprintf(&b, "Message-Account: %s\r\n", account)
+	fmt.Fprintf(&b, "Voice-Message: %d/%d (0/0)\r\n", c.New, c.Old)
+	return b.String()
+}
@@ -0,0 +1,236 @@
+package sipserver
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"SipServer/internal/repository"
+	"SipServer/internal/repository/parking"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+const (
+	defaultParkSlots   = "701-720"
+	defaultParkTimeout = 120 * time.Second
+
+	// сколько звоним тому, кто парковал, прежде чем сбросить звонок
+	parkRingbackTimeout = 30 * time.Second
+)
+
+type parkedCall struct {
+	Slot     string
+	Call     *localCall // nil, пока звонок ещё не отвечен
+	ParkedBy string
+	ParkedAt time.Time
+
+	timer *time.Timer
+}
+
+// parkingLot — слоты парковки (PARK_SLOTS, по умолчанию 701-720).
+type parkingLot struct {
+	from, to int
+	timeout  time.Duration
+
+	mu    sync.Mutex
+	slots map[string]*parkedCall
+}
+
+func newParkingLot() *parkingLot {
+	l := &parkingLot{
+		timeout: defaultParkTimeout,
+		slots:   make(map[string]*parkedCall),
+	}
+
+	slots := os.Getenv("PARK_SLOTS")
+	if slots == "" {
+		slots = defaultParkSlots
+	}
+	lo, hi, _ := strings.Cut(slots, "-")
+	l.from, _ = strconv.Atoi(strings.TrimSpace(lo))
+	l.to, _ = strconv.Atoi(strings.TrimSpace(hi))
+	if l.to < l.from {
+		l.to = l.from
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("PARK_TIMEOUT")); err == nil && v > 0 {
+		l.timeout = time.Duration(v) * time.Second
+	}
+	return l
+}
+
+func (l *parkingLot) isSlot(number string) bool {
+	n, err := strconv.Atoi(number)
+	return err == nil && l.from > 0 && n >= l.from && n <= l.to
+}
+
+// reserve занимает свободный слот. false — слот уже занят.
+func (l *parkingLot) reserve(slot string) (*parkedCall, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, busy := l.slots[slot]; busy {
+		return nil, false
+	}
+	pc := &parkedCall{Slot: slot}
+	l.slots[slot] = pc
+	return pc, true
+}
+
+func (l *parkingLot) activate(pc *parkedCall, lc *localCall, parkedBy string, onTimeout func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	pc.Call = lc
+	pc.ParkedBy = parkedBy
+	pc.ParkedAt = time.Now()
+	pc.timer = time.AfterFunc(l.timeout, onTimeout)
+}
+
+// release освобождает слот, если в нём всё ещё pc.
+func (l *parkingLot) release(pc *parkedCall) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.slots[pc.Slot] != pc {
+		return false
+	}
+	delete(l.slots, pc.Slot)
+	if pc.timer != nil {
+		pc.timer.Stop()
+	}
+	return true
+}
+
+// takeActive забирает из слота уже отвеченный звонок.
+func (l *parkingLot) takeActive(slot string) *parkedCall {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	pc, ok := l.slots[slot]
+	if !ok || pc.Call == nil {
+		return nil
+	}
+	delete(l.slots, slot)
+	if pc.timer != nil {
+		pc.timer.Stop()
+	}
+	return pc
+}
+
+// parkOrRetrieve: звонок на свободный слот паркуется, на занятый — забирает звонок из слота.
+func (s *Server) parkOrRetrieve(ictx *InviteCtx, slot string) {
+	pc, ok := s.parking.reserve(slot)
+	if !ok {
+		parked := s.parking.takeActive(slot)
+		if parked == nil {
+			s.failInvite(ictx, sip.StatusBusyHere, "Busy Here")
+			return
+		}
+		s.retrieveParked(ictx, parked)
+		return
+	}
+
+	lc := s.answerLocalOrReject(ictx)
+	if lc == nil {
+		s.parking.release(pc)
+		return
+	}
+
+	s.parking.activate(pc, lc, ictx.ReferredBy, func() { s.parkTimeout(pc) })
+	lc.setOnHangup(func() {
+		if s.parking.release(pc) {
+			s.unparked(pc)
+		}
+	})
+	go func() { _ = lc.media.Play(lc.ctx, musicOnHold(), true) }()
+
+	log.Printf("[PARK] slot=%s call-id=%s parked by=%q", slot, lc.leg.CallID, pc.ParkedBy)
+
+	if s.callJournalRepo != nil && ictx.JournalID != 0 {
+		if err := s.callJournalRepo.SetParked(context.Background(), ictx.JournalID, slot); err != nil {
+			log.Printf("[PARK] SetParked failed: %v", err)
+		}
+	}
+
+	var journalID *int64
+	if ictx.JournalID != 0 {
+		journalID = &ictx.JournalID
+	}
+	err := s.parkingRepo.Park(context.Background(), &parking.ParkedCall{
+		Slot:       slot,
+		JournalID:  journalID,
+		CallID:     lc.leg.CallID,
+		CallerUser: lc.leg.User,
+		ParkedBy:   pc.ParkedBy,
+		ParkedAt:   pc.ParkedAt,
+		TimeoutAt:  pc.ParkedAt.Add(s.parking.timeout),
+	})
+	if err != nil {
+		log.Printf("[PARK] store slot=%s failed: %v", slot, err)
+	}
+}
+
+// unparked — звонок ушёл из слота: чистим таблицу и считаем время на парковке.
+func (s *Server) unparked(pc *parkedCall) {
+	if err := s.parkingRepo.Unpark(context.Background(), pc.Slot); err != nil {
+		log.Printf("[PARK] unpark slot=%s failed: %v", pc.Slot, err)
+	}
+
+	if s.callJournalRepo != nil && pc.Call.leg.JournalID != 0 {
+		parkedMs := int(time.Since(pc.ParkedAt).Milliseconds())
+		if err := s.callJournalRepo.AddParkedTime(context.Background(), pc.Call.leg.JournalID, parkedMs); err != nil {
+			log.Printf("[PARK] AddParkedTime failed: %v", err)
+		}
+	}
+}
+
+// retrieveParked соединяет забирающего с припаркованным звонком.
+func (s *Server) retrieveParked(ictx *InviteCtx, pc *parkedCall) {
+	s.unparked(pc)
+
+	lc := pc.Call
+	if !s.detachLocal(lc) {
+		s.failInvite(ictx, sip.StatusNotFound, "Not Found")
+		return
+	}
+
+	resp, err := s.reinviteLeg(lc.leg, ictx.OriginInvite.Body())
+	if err != nil {
+		log.Printf("[PARK] slot=%s re-INVITE failed: %v", pc.Slot, err)
+		s.dropLeg(lc.leg, repository.CallEndedBySystem, true)
+		s.failInvite(ictx, sip.StatusTemporarilyUnavailable, "Temporarily Unavailable")
+		return
+	}
+
+	leg := s.answerLeg(ictx, resp.Body())
+	s.linkLegs(lc.leg, leg, nil)
+
+	log.Printf("[PARK] slot=%s retrieved by %s", pc.Slot, leg.User)
+}
+
+// parkTimeout — никто не забрал: звоним тому, кто парковал, иначе сбрасываем.
+func (s *Server) parkTimeout(pc *parkedCall) {
+	if !s.parking.release(pc) {
+		return
+	}
+	s.unparked(pc)
+
+	lc := pc.Call
+	if pc.ParkedBy == "" {
+		log.Printf("[PARK] slot=%s timeout, nobody to ring back", pc.Slot)
+		s.endLocal(lc, repository.CallEndedBySystem, true)
+		return
+	}
+
+	log.Printf("[PARK] slot=%s timeout, ringing back %s", pc.Slot, pc.ParkedBy)
+
+	if !s.bridgeLocal(lc, pc.ParkedBy, parkRingbackTimeout) {
+		s.endLocal(lc, repository.CallEndedBySystem, true)
+	}
+}
@@ -0,0 +1,184 @@
+package sipserver
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+const (
+	pickupGroupCode      = "*8"
+	directedPickupPrefix = "**"
+)
+
+// ringingCalls — звонки, которые сейчас звонят на телефоны пользователей.
+type ringingCalls struct {
+	mu      sync.Mutex
+	byLogin map[string][]*InviteCtx
+}
+
+func newRingingCalls() *ringingCalls {
+	return &ringingCalls{byLogin: make(map[string][]*InviteCtx)}
+}
+
+func (r *ringingCalls) add(login string, ictx *InviteCtx) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.byLogin[login] {
+		if c == ictx {
+			return
+		}
+	}
+	r.byLogin[login] = append(r.byLogin[login], ictx)
+}
+
+func (r *ringingCalls) remove(ictx *InviteCtx) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for login, calls := range r.byLogin {
+		kept := calls[:0]
+		for _, c := range calls {
+			if c != ictx {
+				kept = append(kept, c)
+			}
+		}
+		if len(kept) == 0 {
+			delete(r.byLogin, login)
+		} else {
+			r.byLogin[login] = kept
+		}
+	}
+}
+
+// count — сколько звонков сейчас звонит на login.
+// calls — звонки, которые сейчас звонят login (копия).
+func (r *ringingCalls) calls(login string) []*InviteCtx {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.byLogin[login])
+}
+
+// find возвращает самый давний звонок на любой из logins.
+func (r *ringingCalls) find(logins ...string) *InviteCtx {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var oldest *InviteCtx
+	for _, login := range logins {
+		for _, c := range r.byLogin[login] {
+			if oldest == nil || c.InviteAt.Before(oldest.InviteAt) {
+				oldest = c
+			}
+		}
+	}
+	return oldest
+}
+
+// findByCallID ищет звонок по Call-ID исходного INVITE или его веток.
+func (r *ringingCalls) findByCallID(callID string) *InviteCtx {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, calls := range r.byLogin {
+		for _, c := range calls {
+			if c.OriginInvite.CallID().Value() == callID {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+func isPickupCode(number string) bool {
+	return number == pickupGroupCode || (strings.HasPrefix(number, directedPickupPrefix) && len(number) > len(directedPickupPrefix))
+}
+
+// replacesCallID достаёт Call-ID из заголовка Replaces (RFC 3891).
+func replacesCallID(req *sip.Request) string {
+	h := req.GetHeader("Replaces")
+	if h == nil {
+		return ""
+	}
+	callID, _, _ := strings.Cut(h.Value(), ";")
+	return strings.TrimSpace(callID)
+}
+
+// pickup перехватывает звонящий вызов: *8 — своя pickup-группа,
+// **<ext> — конкретный пользователь, INVITE с Replaces — по Call-ID.
+func (s *Server) pickup(req *sip.Request, ictx *InviteCtx, number string) {
+	picker := strings.TrimSpace(req.From().Address.User)
+
+	var target *InviteCtx
+	switch {
+	case replacesCallID(req) != "":
+		target = s.ringing.findByCallID(replacesCallID(req))
+
+	case number == pickupGroupCode:
+		user, err := s.userRepositoriy.FindByLoginWithConfig(picker)
+		if err != nil || user.Config.PickupGroup == "" {
+			log.Printf("[PICKUP] user=%s has no pickup group", picker)
+			break
+		}
+		logins, err := s.userRepositoriy.LoginsByPickupGroup(user.Config.PickupGroup)
+		if err != nil {
+			log.Printf("[PICKUP] group=%s error %v", user.Config.PickupGroup, err)
+			s.failInvite(ictx, sip.StatusInternalServerError, "InternalError")
+			return
+		}
+		others := logins[:0]
+		for _, l := range logins {
+			if l != picker {
+				others = append(others, l)
+			}
+		}
+		target = s.ringing.find(others...)
+
+	default:
+		target = s.ringing.find(strings.TrimPrefix(number, directedPickupPrefix))
+	}
+
+	if target == nil || target == ictx || !target.Diverted.CompareAndSwap(false, true) {
+		log.Printf("[PICKUP] user=%s number=%s nothing to pick up", picker, number)
+		s.failInvite(ictx, sip.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.ringing.remove(target)
+	s.discardRecording(target.Recording)
+
+	// исходные ветки больше не нужны
+	outs := target.Forks()
+	if len(outs) == 0 && target.OutInvite != nil {
+		outs = append(outs, target.OutInvite)
+	}
+	for _, out := range outs {
+		if cancel := buildCancel(out); cancel != nil {
+			_, _ = s.cl.TransactionRequest(context.Background(), cancel)
+		}
+	}
+
+	log.Printf("[PICKUP] user=%s picked up call-id=%s", picker, target.OriginInvite.CallID().Value())
+
+	s.bridgeCalls(target, ictx)
+
+	if s.callJournalRepo != nil && target.JournalID != 0 {
+		if err := s.callJournalRepo.SetPickedUp(context.Background(), target.JournalID, picker); err != nil {
+			log.Printf("[PICKUP] SetPickedUp failed: %v", err)
+		}
+	}
+}
+
+// failInvite — rejectInvite с отметкой в журнале.
+func (s *Server) failInvite(ictx *InviteCtx, code int, reason string) {
+	if s.callJournalRepo != nil && ictx.JournalID != 0 {
+		_ = s.callJournalRepo.MarkFailed(context.Background(), ictx.JournalID, code, reason, time.Now())
+	}
+	s.rejectInvite(ictx, code, reason)
+}
@@ -0,0 +1,670 @@
+package sipserver
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	userrepo "SipServer/internal/repository/user"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// пакеты событий SUBSCRIBE
+const (
+	EventDialog   = "dialog"   // RFC 4235, BLF
+	EventPresence = "presence" // PIDF, RFC 3856
+)
+
+const (
+	subscribeDefaultExpires = 3600
+	subscribeMinExpires     = 60
+	subscribeMaxExpires     = 3600
+	notifyTimeout           = 5 * time.Second
+
+	// как часто сверяем состояние наблюдаемых пользователей
+	presencePollInterval = time.Second
+
+	// коды, которых нет в sipgo
+	statusConditionalRequestFailed = 412
+	statusBadEvent                 = 489
+)
+
+// состояние линии пользователя
+const (
+	LineOffline = "offline"
+	LineIdle    = "idle"
+	LineRinging = "ringing"
+	LineBusy    = "busy"
+)
+
+// lineDialog — звонок пользователя для dialog-info.
+type lineDialog struct {
+	id        string // Call-ID
+	state     string // early / confirmed
+	initiator bool
+	remote    string // login собеседника
+}
+
+// lineState — что видно наблюдателю: регистрация, звонки и опубликованный статус.
+type lineState struct {
+	status  string
+	dialogs []lineDialog
+	basic   string // из PUBLISH: open / closed, пусто — не публиковал
+	note    string
+}
+
+func (l lineState) signature() string {
+	return fmt.Sprintf("%s|%v|%s|%s", l.status, l.dialogs, l.basic, l.note)
+}
+
+// subscription — подписка (RFC 6665): диалог, в котором сервер шлёт NOTIFY.
+type subscription struct {
+	event     string
+	user      string // чьё состояние
+	callID    string
+	localTag  string
+	remoteTag string
+	localURI  sip.Uri
+	remoteURI sip.Uri
+	target    sip.Uri // Contact подписчика
+
+	mu        sync.Mutex
+	expiresAt time.Time
+	cseq      uint32
+	version   int    // версия тела NOTIFY (dialog-info)
+	last      string // подпись состояния, принятого подписчиком (2xx)
+	force     bool   // отправить текущее состояние, даже если оно не менялось
+
+	// не больше одного NOTIFY в полёте: пока он ждёт ответа, новые
+	// откладываются и отправляются после него одним NOTIFY
+	notifying bool
+	queued    bool
+	final     string // отложенный Subscription-State: terminated
+}
+
+func (sub *subscription) key() string {
+	return sub.callID + "|" + sub.remoteTag + "|" + sub.localTag
+}
+
+// subscriptions — активные подписки по Call-ID и тегам.
+type subscriptions struct {
+	mu sync.Mutex
+	m  map[string]*subscription
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{m: make(map[string]*subscription)}
+}
+
+func (s *subscriptions) put(sub *subscription) {
+	s.mu.Lock()
+	s.m[sub.key()] = sub
+	s.mu.Unlock()
+}
+
+func (s *subscriptions) get(key string) *subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m[key]
+}
+
+func (s *subscriptions) remove(sub *subscription) {
+	s.mu.Lock()
+	delete(s.m, sub.key())
+	s.mu.Unlock()
+}
+
+func (s *subscriptions) list() []*subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]*subscription, 0, len(s.m))
+	for _, sub := range s.m {
+		out = append(out, sub)
+	}
+	return out
+}
+
+// publication — статус, выставленный пользователем через PUBLISH.
+type publication struct {
+	etag      string
+	basic     string
+	note      string
+	expiresAt time.Time
+}
+
+type publications struct {
+	mu sync.Mutex
+	m  map[string]publication // login -> статус
+}
+
+func newPublications() *publications {
+	return &publications{m: make(map[string]publication)}
+}
+
+func (p *publications) get(login string) (publication, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pub, ok := p.m[login]
+	if ok && time.Now().After(pub.expiresAt) {
+		delete(p.m, login)
+		return publication{}, false
+	}
+	return pub, ok
+}
+
+func (s *Server) onSubscribe(req *sip.Request, tx sip.ServerTransaction) {
+	start := time.Now()
+	sipIn(req.Method)
+	defer observeHandler(req.Method, start)
+
+	event := eventPackage(req)
+	if !s.supportedEvent(event) {
+		respond(req, tx, statusBadEvent, "Bad Event", sip.NewHeader("Allow-Events", s.allowEvents()))
+		return
+	}
+
+	expires, ok := requestExpires(req)
+	if !ok {
+		respond(req, tx, sip.StatusIntervalToBrief, "Interval Too Brief",
+			sip.NewHeader("Min-Expires", strconv.Itoa(subscribeMinExpires)))
+		return
+	}
+	// чужое состояние видит только свой абонент
+	if _, ok := s.authorizeCaller(req, tx); !ok {
+		return
+	}
+
+	fromTag, _ := req.From().Params.Get("tag")
+	toTag, _ := req.To().Params.Get("tag")
+
+	var sub *subscription
+	if toTag != "" {
+		// обновление или отписка
+		sub = s.subscriptions.get(req.CallID().Value() + "|" + fromTag + "|" + toTag)
+		if sub == nil || sub.event != event {
+			respond(req, tx, sip.StatusCallTransactionDoesNotExists, "Call/Transaction Does Not Exist")
+			return
+		}
+	} else {
+		user := strings.TrimSpace(req.Recipient.User)
+		if user == "" {
+			user = strings.TrimSpace(req.To().Address.User)
+		}
+		if _, err := s.userRepositoriy.FindByLogin(user); err != nil {
+			if errors.Is(err, userrepo.ErrUserNotFound) {
+				respond(req, tx, sip.StatusNotFound, "Not Found")
+			} else {
+				respond(req, tx, sip.StatusInternalServerError, "InternalError")
+			}
+			return
+		}
+		contact := req.Contact()
+		if contact == nil {
+			respond(req, tx, sip.StatusBadRequest, "Missing Contact")
+			return
+		}
+
+		sub = &subscription{
+			event:     event,
+			user:      user,
+			callID:    req.CallID().Value(),
+			remoteTag: fromTag,
+			localURI:  req.To().Address,
+			remoteURI: req.From().Address,
+			target:    contact.Address,
+		}
+	}
+
+	res := sip.NewResponseFromRequest(req, sip.StatusOK, "OK", nil)
+	if sub.localTag == "" {
+		sub.localTag, _ = res.To().Params.Get("tag")
+	}
+	res.AppendHeader(sip.NewHeader("Expires", strconv.Itoa(expires)))
+	res.AppendHeader(&sip.ContactHeader{Address: sip.Uri{Scheme: "sip", Host: s.host, Port: s.port}})
+	sipResp(req.Method, sip.StatusOK)
+	sipOut(req.Method)
+	_ = tx.Respond(res)
+
+	sub.mu.Lock()
+	sub.expiresAt = time.Now().Add(time.Duration(expires) * time.Second)
+	sub.force = true // на SUBSCRIBE всегда отвечаем текущим состоянием
+	sub.mu.Unlock()
+
+	if expires == 0 {
+		s.subscriptions.remove(sub)
+		log.Printf("[SUBSCRIBE] %s -> %s event=%s terminated", sub.remoteURI.User, sub.user, event)
+		go s.sendNotify(sub, "terminated;reason=timeout")
+		return
+	}
+
+	s.subscriptions.put(sub)
+	log.Printf("[SUBSCRIBE] %s -> %s event=%s expires=%d", sub.remoteURI.User, sub.user, event, expires)
+	go s.sendNotify(sub, "")
+}
+
+// onPublish — пользователь сам выставляет статус (RFC 3903, Event: presence).
+func (s *Server) onPublish(req *sip.Request, tx sip.ServerTransaction) {
+	start := time.Now()
+	sipIn(req.Method)
+	defer observeHandler(req.Method, start)
+
+	if eventPackage(req) != EventPresence {
+		respond(req, tx, statusBadEvent, "Bad Event", sip.NewHeader("Allow-Events", EventPresence))
+		return
+	}
+	expires, ok := requestExpires(req)
+	if !ok {
+		respond(req, tx, sip.StatusIntervalToBrief, "Interval Too Brief",
+			sip.NewHeader("Min-Expires", strconv.Itoa(subscribeMinExpires)))
+		return
+	}
+
+	u, ok := s.authorizeCaller(req, tx)
+	if !ok {
+		return
+	}
+	// публиковать можно только своё состояние
+	login := u.Login
+	target := strings.TrimSpace(req.Recipient.User)
+	if target == "" {
+		target = strings.TrimSpace(req.To().Address.User)
+	}
+	if target != login {
+		log.Printf("[PUBLISH] user=%s tried to publish for %s", login, target)
+		respond(req, tx, sip.StatusForbidden, "Forbidden")
+		return
+	}
+
+	s.publications.mu.Lock()
+	pub, exists := s.publications.m[login]
+	if h := req.GetHeader("SIP-If-Match"); h != nil {
+		if !exists || pub.etag != strings.TrimSpace(h.Value()) {
+			s.publications.mu.Unlock()
+			respond(req, tx, statusConditionalRequestFailed, "Conditional Request Failed")
+			return
+		}
+	} else if len(req.Body()) == 0 {
+		s.publications.mu.Unlock()
+		respond(req, tx, sip.StatusBadRequest, "Missing Body")
+		return
+	}
+
+	if len(req.Body()) > 0 {
+		basic, note, err := parsePIDF(req.Body())
+		if err != nil {
+			s.publications.mu.Unlock()
+			respond(req, tx, sip.StatusBadRequest, "Bad PIDF")
+			return
+		}
+		pub.basic, pub.note = basic, note
+	}
+
+	if expires == 0 {
+		delete(s.publications.m, login)
+		s.publications.mu.Unlock()
+		respond(req, tx, sip.StatusOK, "OK", sip.NewHeader("Expires", "0"))
+		return
+	}
+
+	pub.etag = sip.GenerateTagN(12)
+	pub.expiresAt = time.Now().Add(time.Duration(expires) * time.Second)
+	s.publications.m[login] = pub
+	s.publications.mu.Unlock()
+
+	log.Printf("[PUBLISH] user=%s basic=%s note=%q", login, pub.basic, pub.note)
+	respond(req, tx, sip.StatusOK, "OK",
+		sip.NewHeader("SIP-ETag", pub.etag),
+		sip.NewHeader("Expires", strconv.Itoa(expires)))
+}
+
+// RunPresence рассылает NOTIFY при смене состояния наблюдаемых пользователей
+// и закрывает истёкшие подписки.
+func (s *Server) RunPresence(ctx context.Context) {
+	ticker := time.NewTicker(presencePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		states := make(map[string]lineState)
+		for _, sub := range s.subscriptions.list() {
+			sub.mu.Lock()
+			expired := now.After(sub.expiresAt)
+			sub.mu.Unlock()
+			if expired {
+				s.subscriptions.remove(sub)
+				go s.sendNotify(sub, "terminated;reason=timeout")
+				continue
+			}
+			if sub.event != EventDialog && sub.event != EventPresence {
+				continue
+			}
+
+			st, ok := states[sub.user]
+			if !ok {
+				st = s.lineState(sub.user)
+				states[sub.user] = st
+			}
+
+			sub.mu.Lock()
+			changed := sub.force || sub.last != st.signature()
+			sub.mu.Unlock()
+			if changed {
+				go s.sendNotify(sub, "")
+			}
+		}
+	}
+}
+
+// lineState собирает состояние из регистраций, звонящих и живых диалогов.
+func (s *Server) lineState(login string) lineState {
+	st := lineState{status: LineOffline}
+	if _, ok := s.reg.Get(login); ok {
+		st.status = LineIdle
+	}
+	if pub, ok := s.publications.get(login); ok {
+		st.basic, st.note = pub.basic, pub.note
+	}
+
+	if ictx := s.ringing.find(login); ictx != nil {
+		st.status = LineRinging
+		st.dialogs = append(st.dialogs, lineDialog{
+			id:     ictx.OriginInvite.CallID().Value(),
+			state:  "early",
+			remote: strings.TrimSpace(ictx.OriginInvite.From().Address.User),
+		})
+	}
+
+	seen := make(map[string]bool)
+	s.dialogs.Range(func(_, v any) bool {
+		dlg, ok := v.(*DialogCtx)
+		// ранние диалоги уже видны как ringing
+		if !ok || dlg.Early || seen[dlg.CallID] {
+			return true
+		}
+		var d lineDialog
+		switch login {
+		case dlg.CallerUser:
+			d = lineDialog{id: dlg.CallID, state: "confirmed", initiator: true, remote: dlg.CalleeUser}
+			// INVITE на эту сторону отправил сервер (pickup, парковка)
+			if dlg.Leg != nil && dlg.Leg.Outgoing {
+				d.initiator = false
+			}
+			if dlg.Peer != nil {
+				d.remote = dlg.Peer.User
+			}
+		case dlg.CalleeUser:
+			d = lineDialog{id: dlg.CallID, state: "confirmed", remote: dlg.CallerUser}
+		default:
+			return true
+		}
+		seen[dlg.CallID] = true
+		st.dialogs = append(st.dialogs, d)
+		st.status = LineBusy
+		return true
+	})
+
+	sort.Slice(st.dialogs, func(i, j int) bool { return st.dialogs[i].id < st.dialogs[j].id })
+	return st
+}
+
+// sendNotify отправляет NOTIFY подписчику; state — Subscription-State, пусто — active.
+// Если NOTIFY этой подписке уже в полёте, отправка откладывается до его ответа.
+func (s *Server) sendNotify(sub *subscription, state string) {
+	sub.mu.Lock()
+	if sub.notifying {
+		sub.queued = true
+		if state != "" {
+			sub.final = state
+		}
+		sub.mu.Unlock()
+		return
+	}
+	sub.notifying = true
+	sub.mu.Unlock()
+
+	for {
+		s.notify(sub, state)
+
+		sub.mu.Lock()
+		// после terminated подписки нет: отложенные active не нужны
+		if !sub.queued || state != "" {
+			sub.queued = false
+			sub.notifying = false
+			sub.mu.Unlock()
+			return
+		}
+		sub.queued = false
+		state, sub.final = sub.final, ""
+		sub.mu.Unlock()
+	}
+}
+
+// notify отправляет один NOTIFY и ждёт ответа. active без изменений с
+// последнего принятого состояния не отправляется.
+func (s *Server) notify(sub *subscription, state string) {
+	signature, contentType, render := s.eventState(sub)
+
+	sub.mu.Lock()
+	if state == "" {
+		if signature == sub.last && !sub.force {
+			sub.mu.Unlock()
+			return
+		}
+		left := int(time.Until(sub.expiresAt).Seconds())
+		state = fmt.Sprintf("active;expires=%d", max(left, 0))
+	}
+	sub.force = false
+	sub.cseq++
+	cseq := sub.cseq
+	body := render(sub.version)
+	sub.version++
+	sub.mu.Unlock()
+
+	req := sip.NewRequest(sip.NOTIFY, sub.target)
+	from := &sip.FromHeader{Address: sub.localURI, Params: sip.NewParams()}
+	from.Params.Add("tag", sub.localTag)
+	to := &sip.ToHeader{Address: sub.remoteURI, Params: sip.NewParams()}
+	to.Params.Add("tag", sub.remoteTag)
+	callID := sip.CallIDHeader(sub.callID)
+	mf := sip.MaxForwardsHeader(70)
+	ct := sip.ContentTypeHeader(contentType)
+
+	req.AppendHeader(from)
+	req.AppendHeader(to)
+	req.AppendHeader(&callID)
+	req.AppendHeader(&sip.CSeqHeader{SeqNo: cseq, MethodName: sip.NOTIFY})
+	req.AppendHeader(&mf)
+	req.AppendHeader(&sip.ContactHeader{Address: sip.Uri{Scheme: "sip", Host: s.host, Port: s.port}})
+	req.AppendHeader(sip.NewHeader("Event", sub.event))
+	req.AppendHeader(sip.NewHeader("Subscription-State", state))
+	req.AppendHeader(&ct)
+	req.PrependHeader(s.newVia())
+	req.SetBody([]byte(body))
+
+	resp, err := s.waitFinal(req, notifyTimeout)
+	if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		sub.mu.Lock()
+		sub.last = signature
+		sub.mu.Unlock()
+		return
+	}
+	// не принят: last остался прежним, изменённое состояние уйдёт на следующем тике
+	if err != nil {
+		log.Printf("[NOTIFY] %s event=%s to %s: %v", sub.user, sub.event, sub.target.String(), err)
+		return
+	}
+	// подписчик забыл подписку
+	if resp.StatusCode == sip.StatusCallTransactionDoesNotExists {
+		s.subscriptions.remove(sub)
+	}
+}
+
+// eventState — текущее состояние для подписки: подпись для сравнения,
+// Content-Type и тело NOTIFY по номеру версии.
+func (s *Server) eventState(sub *subscription) (string, string, func(version int) string) {
+	entity := sip.Uri{Scheme: "sip", User: sub.user, Host: s.host}
+
+	if sub.event == EventMessageSummary {
+		c, err := s.voicemailRepo.CountsForLogin(context.Background(), sub.user)
+		if err != nil {
+			log.Printf("[MWI] %s counts: %v", sub.user, err)
+		}
+		return fmt.Sprintf("%d/%d", c.New, c.Old), "application/simple-message-summary", func(int) string {
+			return messageSummary(entity.String(), c)
+		}
+	}
+
+	st := s.lineState(sub.user)
+
+	if sub.event == EventDialog {
+		return st.signature(), "application/dialog-info+xml", func(version int) string {
+			return dialogInfoXML(entity.String(), version, st, s.host)
+		}
+	}
+	return st.signature(), "application/pidf+xml", func(int) string {
+		return pidfXML(entity.String(), st)
+	}
+}
+
+func (s *Server) supportedEvent(event string) bool {
+	return event == EventDialog || event == EventPresence || event == EventMessageSummary
+}
+
+func (s *Server) allowEvents() string {
+	return EventDialog + ", " + EventPresence + ", " + EventMessageSummary
+}
+
+// dialogInfoXML — тело dialog-info (RFC 4235), всегда state="full".
+func dialogInfoXML(entity string, version int, st lineState, host string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(&b, "<dialog-info xmlns=\"urn:ietf:params:xml:ns:dialog-info\" version=\"%d\" state=\"full\" entity=\"%s\">\n",
+		version, xmlEscape(entity))
+	for _, d := range st.dialogs {
+		direction := "recipient"
+		if d.initiator {
+			direction = "initiator"
+		}
+		fmt.Fprintf(&b, "  <dialog id=\"%s\" call-id=\"%s\" direction=\"%s\">\n",
+			xmlEscape(d.id), xmlEscape(d.id), direction)
+		fmt.Fprintf(&b, "    <state>%s</state>\n", d.state)
+		if d.remote != "" {
+			remote := sip.Uri{Scheme: "sip", User: d.remote, Host: host}
+			fmt.Fprintf(&b, "    <remote><identity>%s</identity></remote>\n", xmlEscape(remote.String()))
+		}
+		fmt.Fprintf(&b, "  </dialog>\n")
+	}
+	fmt.Fprintf(&b, "</dialog-info>\n")
+	return b.String()
+}
+
+// pidfXML — тело PIDF (RFC 3863) с RPID on-the-phone, пока идёт звонок.
+func pidfXML(entity string, st lineState) string {
+	basic := "open"
+	if st.status == LineOffline || st.basic == "closed" {
+		basic = "closed"
+	}
+
+	note := st.note
+	if note == "" {
+		switch st.status {
+		case LineOffline:
+			note = "Offline"
+		case LineRinging:
+			note = "Ringing"
+		case LineBusy:
+			note = "On the phone"
+		default:
+			note = "Available"
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(&b, "<presence xmlns=\"urn:ietf:params:xml:ns:pidf\"")
+	fmt.Fprintf(&b, " xmlns:dm=\"urn:ietf:params:xml:ns:pidf:data-model\"")
+	fmt.Fprintf(&b, " xmlns:rpid=\"urn:ietf:params:xml:ns:pidf:rpid\" entity=\"%s\">\n", xmlEscape(entity))
+	fmt.Fprintf(&b, "  <tuple id=\"line\">\n")
+	fmt.Fprintf(&b, "    <status><basic>%s</basic></status>\n", basic)
+	fmt.Fprintf(&b, "    <note>%s</note>\n", xmlEscape(note))
+	fmt.Fprintf(&b, "  </tuple>\n")
+	if st.status == LineBusy || st.status == LineRinging {
+		fmt.Fprintf(&b, "  <dm:person id=\"p\"><rpid:activities><rpid:on-the-phone/></rpid:activities></dm:person>\n")
+	}
+	fmt.Fprintf(&b, "</presence>\n")
+	return b.String()
+}
+
+// parsePIDF достаёт из PIDF первого tuple basic и note.
+func parsePIDF(body []byte) (string, string, error) {
+	var doc struct {
+		Tuples []struct {
+			Basic string `xml:"status>basic"`
+			Note  string `xml:"note"`
+		} `xml:"tuple"`
+		Note string `xml:"note"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return "", "", err
+	}
+	if len(doc.Tuples) == 0 {
+		return "", "", errors.New("pidf: no tuple")
+	}
+
+	basic := strings.ToLower(strings.TrimSpace(doc.Tuples[0].Basic))
+	if basic != "open" && basic != "closed" {
+		return "", "", fmt.Errorf("pidf: bad basic %q", basic)
+	}
+	note := strings.TrimSpace(doc.Tuples[0].Note)
+	if note == "" {
+		note = strings.TrimSpace(doc.Note)
+	}
+	return basic, note, nil
+}
+
+// eventPackage — имя пакета из заголовка Event без параметров.
+func eventPackage(req *sip.Request) string {
+	h := req.GetHeader("Event")
+	if h == nil {
+		return ""
+	}
+	name, _, _ := strings.Cut(h.Value(), ";")
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// requestExpires — Expires запроса в допустимых пределах; false — меньше минимума.
+func requestExpires(req *sip.Request) (int, bool) {
+	expires := subscribeDefaultExpires
+	if h := req.GetHeader("Expires"); h != nil {
+		n, err := strconv.Atoi(strings.TrimSpace(h.Value()))
+		if err == nil && n >= 0 {
+			expires = n
+		}
+	}
+	if expires > 0 && expires < subscribeMinExpires {
+		return 0, false
+	}
+	return min(expires, subscribeMaxExpires), true
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,223 @@
+package sipserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"SipServer/internal/media"
+	userrepo "SipServer/internal/repository/user"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+const (
+	defaultRecordingDir = "./data/recordings"
+	// сколько дней храним записи, 0 — не удаляем
+	defaultRecordingRetentionDays = 90
+	recordingCleanupInterval      = time.Hour
+)
+
+type recordingConfig struct {
+	dir       string
+	retention time.Duration
+}
+
+func newRecordingConfig() recordingConfig {
+	cfg := recordingConfig{
+		dir:       defaultRecordingDir,
+		retention: defaultRecordingRetentionDays * 24 * time.Hour,
+	}
+	if v := os.Getenv("RECORDING_DIR"); v != "" {
+		cfg.dir = v
+	}
+	if v := os.Getenv("RECORDING_RETENTION_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.retention = time.Duration(n) * 24 * time.Hour
+		}
+	}
+	return cfg
+}
+
+// callRecording — записываемый звонок: RTP обеих сторон идёт через сервер.
+type callRecording struct {
+	journalID int64
+	path      string
+	callerTag string // From tag звонящего: по нему различаем стороны in-dialog запросов
+
+	relay    *media.Relay
+	rec      *media.Recorder
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+}
+
+// sideOf — чей это in-dialog запрос: звонящего (RelayA) или вызываемого (RelayB).
+func (r *callRecording) sideOf(req *sip.Request) int {
+	if tag, _ := req.From().Params.Get("tag"); tag == r.callerTag {
+		return media.RelayA
+	}
+	return media.RelayB
+}
+
+// anchor запоминает адрес телефона стороны side из его SDP и возвращает SDP
+// для другой стороны с адресом сервера.
+func (r *callRecording) anchor(side int, body []byte, host string) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	if remote, err := media.ParseRemoteAudio(body); err == nil {
+		r.relay.SetRemote(side, remote.Addr)
+	}
+	return media.RewriteSDP(body, host, r.relay.Port(1-side))
+}
+
+// shouldRecord — у звонящего (проверенного, не по From) или вызываемого включена запись.
+func (s *Server) shouldRecord(ictx *InviteCtx, callee *userrepo.User) bool {
+	if callee.Config.RecordCalls {
+		return true
+	}
+	caller := ictx.Caller
+	return caller != nil && caller.Config != nil && caller.Config.RecordCalls
+}
+
+// startRecording заворачивает медиа исходящего INVITE через сервер.
+// Файл пишется с ответа; до него пакеты только пересылаются.
+func (s *Server) startRecording(ictx *InviteCtx, out *sip.Request) *callRecording {
+	if ictx.JournalID == 0 || len(out.Body()) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(s.recording.dir, 0o755); err != nil {
+		log.Printf("[REC] mkdir %s: %v", s.recording.dir, err)
+		return nil
+	}
+	path := filepath.Join(s.recording.dir, fmt.Sprintf("%d.wav", ictx.JournalID))
+
+	relay, err := media.NewRelay(s.host)
+	if err != nil {
+		log.Printf("[REC] relay: %v", err)
+		return nil
+	}
+	rec, err := media.NewRecorder(path)
+	if err != nil {
+		relay.Close()
+		log.Printf("[REC] create %s: %v", path, err)
+		return nil
+	}
+
+	fromTag, _ := out.From().Params.Get("tag")
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &callRecording{
+		journalID: ictx.JournalID,
+		path:      path,
+		callerTag: fromTag,
+		relay:     relay,
+		rec:       rec,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+
+	out.SetBody(r.anchor(media.RelayA, out.Body(), s.host))
+	relay.Run(func(side int, p *media.Packet) {
+		if ctx.Err() != nil {
+			return
+		}
+		if codec, ok := media.CodecByPayloadType(p.PayloadType); ok {
+			rec.Push(side, codec.Decode(p.Payload))
+		}
+	})
+
+	log.Printf("[REC] journal=%d recording to %s", r.journalID, path)
+	return r
+}
+
+// answered — разговор начался, пишем файл.
+func (r *callRecording) answered() {
+	go r.rec.Run(r.ctx)
+}
+
+// finishRecording закрывает запись и сохраняет её в журнал.
+func (s *Server) finishRecording(r *callRecording) {
+	if r == nil {
+		return
+	}
+	r.stopOnce.Do(func() {
+		r.cancel()
+		r.relay.Close()
+
+		d, err := r.rec.Close()
+		if err != nil {
+			log.Printf("[REC] journal=%d close: %v", r.journalID, err)
+			return
+		}
+		log.Printf("[REC] journal=%d saved %s (%s)", r.journalID, r.path, d.Round(time.Second))
+
+		if s.callJournalRepo == nil {
+			return
+		}
+		if err := s.callJournalRepo.SetRecording(context.Background(), r.journalID, r.path, int(d.Milliseconds())); err != nil {
+			log.Printf("[REC] SetRecording failed: %v", err)
+		}
+	})
+}
+
+// discardRecording — звонок не состоялся (или его забрал сервер): файл не нужен.
+func (s *Server) discardRecording(r *callRecording) {
+	if r == nil {
+		return
+	}
+	r.stopOnce.Do(func() {
+		r.cancel()
+		r.relay.Close()
+		_, _ = r.rec.Close()
+		_ = os.Remove(r.path)
+	})
+}
+
+// RunRecordingRetention удаляет записи старше RECORDING_RETENTION_DAYS.
+func (s *Server) RunRecordingRetention(ctx context.Context) {
+	if s.recording.retention == 0 || s.callJournalRepo == nil {
+		return
+	}
+
+	ticker := time.NewTicker(recordingCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		s.cleanupRecordings(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) cleanupRecordings(ctx context.Context) {
+	expired, err := s.callJournalRepo.ExpiredRecordings(ctx, time.Now().Add(-s.recording.retention))
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[REC] retention query failed: %v", err)
+		}
+		return
+	}
+
+	for id, path := range expired {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("[REC] remove %s: %v", path, err)
+			continue
+		}
+		if err := s.callJournalRepo.ClearRecording(ctx, id); err != nil {
+			log.Printf("[REC] journal=%s clear failed: %v", id, err)
+			continue
+		}
+		log.Printf("[REC] journal=%s expired, %s removed", id, path)
+	}
+}
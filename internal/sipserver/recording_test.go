@@ -0,0 +1,38 @@
+package sipserver
+
+import (
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+
+	userrepo "SipServer/internal/repository/user"
+)
+
+func TestShouldRecord(t *testing.T) {
+	user := func(record bool) *userrepo.User {
+		return &userrepo.User{Config: &userrepo.UserConfig{RecordCalls: record}}
+	}
+	tests := []struct {
+		name   string
+		caller *userrepo.User
+		callee *userrepo.User
+		want   bool
+	}{
+		{"callee records", nil, user(true), true},
+		{"caller records", user(true), user(false), true},
+		{"nobody records", user(false), user(false), false},
+		{"from trunk", nil, user(false), false},
+	}
+	for _, tt := range tests {
+		// From указывает на пользователя с записью, но это не проверенный звонящий
+		req := sip.NewRequest(sip.INVITE, sip.Uri{Scheme: "sip", User: "200", Host: "pbx.local"})
+		req.AppendHeader(&sip.FromHeader{Address: sip.Uri{Scheme: "sip", User: "recorded", Host: "pbx.local"}})
+		ictx := NewInviteCtx()
+		ictx.OriginInvite = req
+		ictx.Caller = tt.caller
+
+		if got := (&Server{}).shouldRecord(ictx, tt.callee); got != tt.want {
+			t.Errorf("%s: shouldRecord = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package sipserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	ringgroup "SipServer/internal/repository/ring_group"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// глубина переходов overflow -> group, чтобы не зациклиться
+const maxGroupDepth = 3
+
+// inviteGroup обзванивает участников группы по её стратегии,
+// если никто не ответил — уходит на overflow. Обзвон идёт в своей горутине:
+// хендлер INVITE не ждёт таймаутов участников.
+func (s *Server) inviteGroup(req *sip.Request, tx sip.ServerTransaction, ictx *InviteCtx, extension string, depth int) {
+	g, err := s.ringGroupRepo.FindByExtension(context.Background(), extension)
+	if err != nil {
+		if errors.Is(err, ringgroup.ErrGroupNotFound) {
+			log.Printf("[GROUP] group=%s not found", extension)
+			s.rejectInvite(ictx, sip.StatusNotFound, "Not Found")
+			return
+		}
+		log.Printf("[GROUP] group=%s error %v", extension, err)
+		s.rejectInvite(ictx, sip.StatusInternalServerError, "InternalError")
+		return
+	}
+
+	log.Printf("[GROUP] group=%s strategy=%s members=%d", g.Extension, g.Strategy, len(g.Members))
+	go s.huntGroup(req, tx, ictx, g, depth)
+}
+
+// huntGroup — обзвон группы по шагам, затем overflow или отказ.
+func (s *Server) huntGroup(req *sip.Request, tx sip.ServerTransaction, ictx *InviteCtx, g *ringgroup.Group, depth int) {
+	timeout := time.Duration(g.MemberTimeout) * time.Second
+	lastCode := sip.StatusTemporarilyUnavailable
+
+	for _, step := range s.groupSteps(g) {
+		if ictx.Cancelled.Load() {
+			break
+		}
+
+		res := s.forkInvite(ictx, step, timeout)
+		if res.Winner != "" {
+			if err := s.ringGroupRepo.TouchMember(context.Background(), g.Id, res.Winner, time.Now()); err != nil {
+				log.Printf("[GROUP] TouchMember failed: %v", err)
+			}
+			return
+		}
+		if ictx.Diverted.Load() {
+			return
+		}
+		lastCode = res.LastCode
+	}
+
+	if ictx.Cancelled.Load() {
+		if s.callJournalRepo != nil && ictx.JournalID != 0 {
+			_ = s.callJournalRepo.MarkCancelled(context.Background(), ictx.JournalID, time.Now())
+		}
+		s.rejectInvite(ictx, sip.StatusRequestTerminated, "Request Terminated")
+		return
+	}
+
+	if s.groupOverflow(req, tx, ictx, g, depth) {
+		return
+	}
+
+	if s.callJournalRepo != nil && ictx.JournalID != 0 {
+		if lastCode == sip.StatusBusyHere {
+			_ = s.callJournalRepo.MarkRejected(context.Background(), ictx.JournalID, lastCode, reasonPhrase(lastCode), time.Now())
+		} else {
+			_ = s.callJournalRepo.MarkNoAnswer(context.Background(), ictx.JournalID, time.Now())
+		}
+	}
+	if lastCode != sip.StatusBusyHere {
+		lastCode = sip.StatusTemporarilyUnavailable
+	}
+	s.rejectInvite(ictx, lastCode, reasonPhrase(lastCode))
+}
+
+// groupSteps раскладывает участников на шаги обзвона.
+// ringall — один шаг со всеми, остальные стратегии — по одному участнику.
+func (s *Server) groupSteps(g *ringgroup.Group) [][]string {
+	members := append([]*ringgroup.Member(nil), g.Members...)
+	if len(members) == 0 {
+		return nil
+	}
+
+	switch g.Strategy {
+	case ringgroup.StrategyRingAll:
+		logins := make([]string, 0, len(members))
+		for _, m := range members {
+			logins = append(logins, m.Login)
+		}
+		return [][]string{logins}
+
+	case ringgroup.StrategyRoundRobin:
+		start := g.RRPosition % len(members)
+		members = append(members[start:], members[:start]...)
+		next := (start + 1) % len(members)
+		if err := s.ringGroupRepo.SetRRPosition(context.Background(), g.Id, next); err != nil {
+			log.Printf("[GROUP] SetRRPosition failed: %v", err)
+		}
+
+	case ringgroup.StrategyLeastRecent:
+		sort.SliceStable(members, func(i, j int) bool {
+			a, b := members[i].LastCallAt, members[j].LastCallAt
+			if a == nil || b == nil {
+				return a == nil && b != nil
+			}
+			return a.Before(*b)
+		})
+	}
+
+	steps := make([][]string, 0, len(members))
+	for _, m := range members {
+		steps = append(steps, []string{m.Login})
+	}
+	return steps
+}
+
+// groupOverflow отправляет звонок на overflow группы. false — overflow не задан.
+func (s *Server) groupOverflow(req *sip.Request, tx sip.ServerTransaction, ictx *InviteCtx, g *ringgroup.Group, depth int) bool {
+	if g.OverflowTarget == "" {
+		return false
+	}
+
+	log.Printf("[GROUP] group=%s overflow -> %s %s", g.Extension, g.OverflowType, g.OverflowTarget)
+
+	switch g.OverflowType {
+	case ringgroup.OverflowUser:
+		s.inviteLocalUser(req, tx, ictx, g.OverflowTarget)
+	case ringgroup.OverflowGroup:
+		if depth >= maxGroupDepth {
+			log.Printf("[GROUP] group=%s overflow depth exceeded", g.Extension)
+			return false
+		}
+		s.inviteGroup(req, tx, ictx, g.OverflowTarget, depth+1)
+	case ringgroup.OverflowTrunk:
+		s.inviteTrunks(req, tx, ictx, splitTargets(g.OverflowTarget), ictx.OriginInvite.To().Address.User)
+	default:
+		return false
+	}
+	return true
+}
+
+// inviteLocal — номер может быть login пользователя, extension группы, IVR-меню
+// или комната конференции.
+func (s *Server) inviteLocal(req *sip.Request, tx sip.ServerTransaction, ictx *InviteCtx, number string) {
+	if _, err := s.ringGroupRepo.FindByExtension(context.Background(), number); err == nil {
+		s.inviteGroup(req, tx, ictx, number, 0)
+		return
+	}
+	if menu, err := s.ivrRepo.FindByExtension(context.Background(), number); err == nil {
+		s.inviteIVR(ictx, menu)
+		return
+	}
+	if room, err := s.conferenceRepo.FindByNumber(context.Background(), number); err == nil {
+		s.joinConference(ictx, room)
+		return
+	}
+	s.inviteLocalUser(req, tx, ictx, number)
+}
@@ -17,12 +17,26 @@ import (
 	"github.com/emiago/sipgo"
 	"github.com/emiago/sipgo/sip"
 
+	"SipServer/internal/acl"
+	"SipServer/internal/media"
 	"SipServer/internal/metrics"
 	"SipServer/internal/registrar"
 	"SipServer/internal/repository"
+	"SipServer/internal/repository/ban"
 	calljournal "SipServer/internal/repository/call_journal"
+	"SipServer/internal/repository/conference"
+	dialplan "SipServer/internal/repository/dial_plan"
+	"SipServer/internal/repository/did"
+	"SipServer/internal/repository/ivr"
+	"SipServer/internal/repository/message"
+	"SipServer/internal/repository/parking"
+	ringgroup "SipServer/internal/repository/ring_group"
 	"SipServer/internal/repository/session"
+	"SipServer/internal/repository/trunk"
 	userrepo "SipServer/internal/repository/user"
+	"SipServer/internal/repository/voicemail"
+	"SipServer/internal/routing"
+	"SipServer/internal/sipauth"
 
 	"github.com/joho/godotenv"
 )
@@ -30,6 +44,7 @@ import (
 const (
 	CallSchemaProxy    = "proxy"
 	CallSchemaRedirect = "redirect"
+	CallSchemaB2BUA    = "b2bua"
 )
 
 type Server struct {
@@ -43,12 +58,40 @@ type Server struct {
 	transaction     sync.Map
 	dialogs         sync.Map
 	userRepositoriy *userrepo.UserRepositoriy
+	dialPlan        *routing.Engine
+	trunkRepo       *trunk.TrunkRepo
+	trunkChannels   *channelCounter
+	trunkRegistrar  *trunkRegistrar
+	didRepo         *did.DIDRepo
+	resolver        *hostResolver
+	trunkAddrs      *trunkAddrs
+	ringGroupRepo   *ringgroup.RingGroupRepo
+	ivrRepo         *ivr.IVRRepo
+	conferences     *conferences
+	conferenceRepo  *conference.ConferenceRepo
+	ringing         *ringingCalls
+	transfers       *transfers
+	parking         *parkingLot
+	parkingRepo     *parking.ParkingRepo
+	voicemail       voicemailConfig
+	voicemailRepo   *voicemail.VoicemailRepo
+	recording       recordingConfig
+	messageRepo     *message.MessageRepo
+	msgDelivering   sync.Map // login -> доставка офлайн-сообщений уже идёт
+	subscriptions   *subscriptions
+	publications    *publications
+	mwi             *mwiState
 	callJournalRepo *calljournal.CallJournalRepo
 	sessionRepo     *session.SessionRepo
 	activeDialog    int64
+	admission       *callAdmission
+	flood           *floodGuard
+	banRepo         *ban.BanRepo
+	acl             *acl.List
+	auth            *sipauth.Authenticator
 }
 
-func New(ua *sipgo.UserAgent, reg *registrar.Registrar, db *sql.DB) (*Server, error) {
+func New(ua *sipgo.UserAgent, reg *registrar.Registrar, db *sql.DB, acls *acl.List) (*Server, error) {
 	srv, err := sipgo.NewServer(ua)
 	if err != nil {
 		return nil, err
@@ -90,24 +133,66 @@ func New(ua *sipgo.UserAgent, reg *registrar.Registrar, db *sql.DB) (*Server, er
 		transaction:     sync.Map{},
 		dialogs:         sync.Map{},
 		userRepositoriy: userrepo.NewUserRepo(db),
+		dialPlan:        routing.New(dialplan.NewDialPlanRepo(db)),
+		trunkRepo:       trunk.NewTrunkRepo(db),
+		trunkChannels:   newChannelCounter(),
+		didRepo:         did.NewDIDRepo(db),
+		resolver:        newHostResolver(),
+		trunkAddrs:      newTrunkAddrs(),
+		ringGroupRepo:   ringgroup.NewRingGroupRepo(db),
+		ivrRepo:         ivr.NewIVRRepo(db),
+		conferences:     newConferences(),
+		conferenceRepo:  conference.NewConferenceRepo(db),
+		ringing:         newRingingCalls(),
+		transfers:       newTransfers(),
+		parking:         newParkingLot(),
+		parkingRepo:     parking.NewParkingRepo(db),
+		voicemail:       newVoicemailConfig(),
+		voicemailRepo:   voicemail.NewVoicemailRepo(db),
+		recording:       newRecordingConfig(),
+		messageRepo:     message.NewMessageRepo(db),
+		subscriptions:   newSubscriptions(),
+		publications:    newPublications(),
+		mwi:             newMWIState(),
 		callJournalRepo: calljournal.NewCallJournalRepo(db),
 		sessionRepo:     session.NewSessionRepo(db),
+		admission:       newCallAdmission(),
+		flood:           newFloodGuard(),
+		banRepo:         ban.NewBanRepo(db),
+		acl:             acls,
+		auth:            sipauth.New(),
+	}
+
+	s.trunkRegistrar = newTrunkRegistrar(cl, s.trunkRepo, host, portInt)
+
+	if err := s.parkingRepo.Clear(context.Background()); err != nil {
+		log.Printf("[PARK] clear parked calls: %v", err)
+	}
+	if err := s.conferenceRepo.ClearParticipants(context.Background()); err != nil {
+		log.Printf("[CONF] clear participants: %v", err)
 	}
 
 	// REGISTER / INVITE / BYE — ключевые методы для прототипа
-	srv.OnRegister(s.onRegister) // хендлеры вида func(req *sip.Request, tx sip.ServerTransaction) :contentReference[oaicite:1]{index=1}
-	srv.OnInvite(s.onInvite)
-	srv.OnBye(s.onBye)
-	srv.OnAck(s.onAck)
-	srv.OnCancel(s.onCancel)
+	srv.OnRegister(s.guard(s.onRegister)) // хендлеры вида func(req *sip.Request, tx sip.ServerTransaction) :contentReference[oaicite:1]{index=1}
+	srv.OnInvite(s.guard(s.onInvite))
+	srv.OnBye(s.guard(s.onBye))
+	srv.OnAck(s.guard(s.onAck))
+	srv.OnCancel(s.guard(s.onCancel))
+	srv.OnRefer(s.guard(s.onRefer))
+	srv.OnNotify(s.guard(s.onNotify))
+	srv.OnInfo(s.guard(s.onInfo))
+	srv.OnMessage(s.guard(s.onMessage))
+	srv.OnSubscribe(s.guard(s.onSubscribe))
+	srv.OnPublish(s.guard(s.onPublish))
+	srv.OnPrack(s.guard(s.onPrack))
 
 	// На всякий случай: если прилетит что-то ещё
-	srv.OnNoRoute(func(req *sip.Request, tx sip.ServerTransaction) {
+	srv.OnNoRoute(s.guard(func(req *sip.Request, tx sip.ServerTransaction) {
 		start := time.Now()
 		sipIn(req.Method)
 		defer observeHandler(req.Method, start)
 		respond(req, tx, sip.StatusMethodNotAllowed, "Method Not Allowed")
-	})
+	}))
 
 	return s, nil
 }
@@ -137,7 +222,7 @@ func (s *Server) onRegister(req *sip.Request, tx sip.ServerTransaction) {
 		return
 	}
 
-	_, err := s.userRepositoriy.FindByLogin(login)
+	usr, err := s.userRepositoriy.FindByLogin(login)
 
 	if err != nil {
 		if errors.Is(err, userrepo.ErrUserNotFound) {
@@ -152,6 +237,18 @@ func (s *Server) onRegister(req *sip.Request, tx sip.ServerTransaction) {
 		}
 	}
 
+	// выключенный пользователь не регистрируется; старую регистрацию снимаем
+	if !usr.Enabled {
+		s.reg.Delete(login)
+		log.Printf("[REGISTER] user=%s disabled", login)
+		respond(req, tx, sip.StatusForbidden, "Forbidden")
+		return
+	}
+
+	if !s.authenticate(req, tx, usr) {
+		return
+	}
+
 	src := req.Source()
 
 	reachable, ok := makeReachableContact(login, src)
@@ -177,6 +274,8 @@ func (s *Server) onRegister(req *sip.Request, tx sip.ServerTransaction) {
 
 	metrics.SIPRegistrations.Inc()
 	respond(req, tx, sip.StatusOK, "OK")
+
+	go s.deliverPending(login)
 }
 
 func (s *Server) onAck(req *sip.Request, tx sip.ServerTransaction) {
@@ -201,14 +300,20 @@ func (s *Server) onAck(req *sip.Request, tx sip.ServerTransaction) {
 	}
 	dlg := v.(*DialogCtx)
 
+	// диалоги, где второй стороной выступает сервер: ACK на наш 200 OK никуда не идёт
+	if dlg.Leg != nil {
+		return
+	}
+
 	ack := sip.NewRequest(sip.ACK, dlg.RemoteTarget)
 
-	log.Println("[ACK] From %v Request target %v", dlg.RemoteTarget, req)
+	log.Printf("[ACK] From %v Request target %v", dlg.RemoteTarget, req)
 
 	copyFrom := *req.From()
 	copyTo := *req.To()
 	copyCallId := *req.CallID()
 	copyCSeq := *req.CSeq()
+	copyCSeq.SeqNo += dlg.CSeqOffset
 
 	ack.AppendHeader(&copyFrom)
 	ack.AppendHeader(&copyTo)
@@ -269,6 +374,12 @@ func (s *Server) onInvite(req *sip.Request, tx sip.ServerTransaction) {
 		return
 	}
 
+	// re-INVITE: удержание и смена медиа в уже идущем звонке
+	if _, ok := to.Params.Get("tag"); ok {
+		s.onReinvite(req, tx)
+		return
+	}
+
 	key, ok := inviteKeyFromReq(req)
 
 	if !ok {
@@ -292,8 +403,20 @@ func (s *Server) onInvite(req *sip.Request, tx sip.ServerTransaction) {
 		return
 	}
 
+	// входящий с транка: номер — DID, а не login; звонящего не проверяем
+	t := s.inboundTrunk(req.Source())
+	var caller *userrepo.User
+	if t == nil {
+		u, ok := s.authorizeCaller(req, tx)
+		if !ok {
+			return
+		}
+		caller = u
+	}
+
 	newCtx := NewInviteCtx()
 	newCtx.OriginInvite = req
+	newCtx.Caller = caller
 	newCtx.ServerTx = tx
 	newCtx.InviteAt = time.Now()
 	s.transaction.Store(key, newCtx)
@@ -303,31 +426,120 @@ func (s *Server) onInvite(req *sip.Request, tx sip.ServerTransaction) {
 
 	tx.Respond(resp100)
 
-	callee := strings.TrimSpace(to.Address.User)
+	// номер — из Request-URI (RFC 3261 §16.5), To — только если там пусто
+	callee := strings.TrimSpace(req.Recipient.User)
+	if callee == "" {
+		callee = strings.TrimSpace(to.Address.User)
+	}
 
 	s.StartCallAttempt(req, newCtx, callee)
+	s.linkTransfer(req, newCtx, callee)
+
+	// лимиты звонящего к входящему с транка не относятся
+	callerLogin := ""
+	if t == nil {
+		callerLogin = strings.TrimSpace(req.From().Address.User)
+	}
+	if !s.admitCall(newCtx, callerLogin) {
+		return
+	}
+
+	if t != nil {
+		s.inviteFromTrunk(req, tx, newCtx, t)
+		return
+	}
+
+	// Replaces на установленный диалог — attended transfer, его проксируем как обычно
+	if rc := replacesCallID(req); isPickupCode(callee) || (rc != "" && !s.hasDialogCallID(rc)) {
+		s.pickup(req, newCtx, callee)
+		return
+	}
+
+	if s.parking.isSlot(callee) {
+		s.parkOrRetrieve(newCtx, callee)
+		return
+	}
+
+	if callee == VoicemailAccessCode {
+		s.voicemailAccess(newCtx)
+		return
+	}
+
+	// алиас (короткий номер, DID) — тот же пользователь, дальше маршрутизируем по логину
+	if login, err := s.userRepositoriy.ResolveAlias(callee); err == nil {
+		log.Printf("[INVITE] alias %s -> %s", callee, login)
+		callee = login
+	} else if !errors.Is(err, userrepo.ErrUserNotFound) {
+		log.Printf("[INVITE] callee=%s alias lookup error %v", callee, err)
+	}
+
+	decision, err := s.dialPlan.Route(context.Background(), callee, time.Now())
+	if err != nil {
+		log.Printf("[INVITE] callee=%s dial plan error %v", callee, err)
+		res := sip.NewResponseFromRequest(req, sip.StatusInternalServerError, "InternalError", nil)
+		_ = tx.Respond(res)
+		return
+	}
 
+	log.Printf("[INVITE] dial plan callee=%s rule=%d action=%s target=%s number=%s",
+		callee, decision.RuleID, decision.Action, decision.Target, decision.Number)
+
+	switch decision.Action {
+	case dialplan.ActionLocalUser:
+		s.inviteLocal(req, tx, newCtx, decision.Target)
+	case dialplan.ActionGroup:
+		s.inviteGroup(req, tx, newCtx, decision.Target, 0)
+	case dialplan.ActionTrunk:
+		s.inviteTrunks(req, tx, newCtx, splitTargets(decision.Target), decision.Number)
+	case dialplan.ActionReject:
+		code := decision.RejectCode
+		if code == 0 {
+			code = sip.StatusForbidden
+		}
+		reason := reasonPhrase(code)
+		if s.callJournalRepo != nil && newCtx.JournalID != 0 {
+			_ = s.callJournalRepo.MarkRejected(context.Background(), newCtx.JournalID, code, reason, time.Now())
+		}
+		res := sip.NewResponseFromRequest(req, code, reason, nil)
+		newCtx.LastResp = res
+		_ = tx.Respond(res)
+	default:
+		log.Printf("[INVITE] callee=%s action %s is not supported", callee, decision.Action)
+		res := sip.NewResponseFromRequest(req, sip.StatusServiceUnavailable, "Service Unavailable", nil)
+		_ = tx.Respond(res)
+	}
+}
+
+func (s *Server) inviteLocalUser(req *sip.Request, tx sip.ServerTransaction, newCtx *InviteCtx, callee string) {
 	user, err := s.userRepositoriy.FindByLoginWithConfig(callee)
 
 	if err != nil {
 		if errors.Is(err, userrepo.ErrUserNotFound) {
 			log.Printf("[INVITE] callee=%s not registered", callee)
-			res := sip.NewResponseFromRequest(req, sip.StatusNotFound, "Not Found", nil)
-			_ = tx.Respond(res)
+			s.rejectInvite(newCtx, sip.StatusNotFound, "Not Found")
 			return
 		} else {
 			log.Printf("[INVITE] callee=%s internal error %v", callee, err)
-			res := sip.NewResponseFromRequest(req, sip.StatusInternalServerError, "InternalError", nil)
-			_ = tx.Respond(res)
+			s.rejectInvite(newCtx, sip.StatusInternalServerError, "InternalError")
 			return
 		}
 	}
 
+	if !user.Enabled {
+		log.Printf("[INVITE] callee=%s disabled", callee)
+		s.rejectInvite(newCtx, sip.StatusTemporarilyUnavailable, "Temporarily Unavailable")
+		return
+	}
+
 	binding, ok := s.reg.Get(callee)
 	if !ok {
-		log.Printf("[INVITE] callee=%s not registered", callee)
-		res := sip.NewResponseFromRequest(req, sip.StatusNotFound, "Not Found", nil)
-		_ = tx.Respond(res)
+		log.Printf("[INVITE] callee=%s not registered, voicemail", callee)
+		s.toVoicemail(newCtx, user)
+		return
+	}
+
+	if limit := s.reserveCallee(newCtx, user); limit != "" {
+		s.rejectOverLimit(newCtx, limit, sip.StatusBusyHere, "Busy Here")
 		return
 	}
 
@@ -340,21 +552,43 @@ func (s *Server) onInvite(req *sip.Request, tx sip.ServerTransaction) {
 		Port:   binding.Contact.Port,
 	}
 	target.UriParams = sip.NewParams().Add("transport", "udp")
+	newCtx.Codecs = s.callCodecPolicy(newCtx, user)
 
-	if user.Config.CallSchema == CallSchemaProxy {
+	if user.Config.CallSchema == CallSchemaB2BUA {
+		log.Printf("[INVITE] B2BUA path callee: %s", callee)
+		s.inviteB2BUA(newCtx, user, target)
+		return
+	}
+
+	// звонок с транка всегда проксируем: 302 провайдеру бесполезен
+	if user.Config.CallSchema == CallSchemaProxy || newCtx.Trunk != "" {
 		log.Printf("[INVITE] Proxy path callee: %s", callee)
 		outBoundInvite := buildOutboundInvite(req, &target, s.host, s.port)
+		s.assertIdentity(newCtx, outBoundInvite, nil)
+		if err := newCtx.Codecs.applyOffer(outBoundInvite); err != nil {
+			log.Printf("[INVITE] callee=%s %v", callee, err)
+			s.failInvite(newCtx, sip.StatusNotAcceptableHere, "Not Acceptable Here")
+			return
+		}
+
+		if s.shouldRecord(newCtx, user) {
+			newCtx.Recording = s.startRecording(newCtx, outBoundInvite)
+		}
 
 		clTx, err := s.cl.TransactionRequest(context.Background(), outBoundInvite)
 
 		if err != nil {
-			res := sip.NewResponseFromRequest(req, sip.StatusServiceUnavailable, "User Unavailable", nil)
-			_ = tx.Respond(res)
+			s.discardRecording(newCtx.Recording)
+			s.rejectInvite(newCtx, sip.StatusServiceUnavailable, "User Unavailable")
+			return
 		}
 		newCtx.ClientTx = clTx
 		newCtx.OutInvite = outBoundInvite
+		s.ringing.add(callee, newCtx)
 
-		go s.proxyInviteResponses(newCtx, clTx)
+		go s.proxyWithVoicemail(newCtx, clTx, user, func(resp *sip.Response) {
+			s.relayInviteResponse(newCtx, resp)
+		})
 	} else {
 		// 302 + Contact: <sip:callee@ip:port>
 		log.Printf("[INVITE] Redirect path callee: %s", callee)
@@ -368,6 +602,23 @@ func (s *Server) onInvite(req *sip.Request, tx sip.ServerTransaction) {
 
 }
 
+// rejectInvite отвечает финальным кодом и освобождает канал транка.
+func (s *Server) rejectInvite(ictx *InviteCtx, code int, reason string, headers ...sip.Header) {
+	res := sip.NewResponseFromRequest(ictx.OriginInvite, code, reason, nil)
+	if ictx.LocalTag != "" {
+		res.To().Params.Add("tag", ictx.LocalTag)
+	}
+	for _, h := range headers {
+		res.AppendHeader(h)
+	}
+	ictx.LastResp = res
+	_ = ictx.ServerTx.Respond(res)
+
+	if ictx.Trunk != "" {
+		s.trunkChannels.Release(ictx.Trunk)
+	}
+}
+
 func (s *Server) StartCallAttempt(req *sip.Request, ctx *InviteCtx, callee string) {
 	caller := ""
 	if f := req.From(); f != nil {
@@ -419,12 +670,27 @@ func (s *Server) onBye(req *sip.Request, tx sip.ServerTransaction) {
 		return
 	}
 
+	if dlg.Local != nil {
+		s.byeLocal(req, tx, dlg)
+		return
+	}
+	if dlg.Peer != nil {
+		s.byeBridged(req, tx, dlg)
+		return
+	}
+	// BYE в раннем диалоге: callee ответит на INVITE 487, дальше как при CANCEL
+	if dlg.Early {
+		s.relayInDialog(req, tx, dlg)
+		return
+	}
+
 	bye := sip.NewRequest(sip.BYE, dlg.RemoteTarget)
 
 	copyFrom := *req.From()
 	copyTo := *req.To()
 	copyCallId := *req.CallID()
 	copyCSeq := *req.CSeq()
+	copyCSeq.SeqNo += dlg.CSeqOffset
 
 	bye.AppendHeader(&copyFrom)
 	bye.AppendHeader(&copyTo)
@@ -455,6 +721,9 @@ func (s *Server) onBye(req *sip.Request, tx sip.ServerTransaction) {
 		_ = tx.Respond(sip.NewResponseFromRequest(req, resp.StatusCode, resp.Reason, nil))
 
 		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			s.stopHold(dlg)
+			s.finishRecording(dlg.Recording)
+
 			if s.callJournalRepo != nil && dlg.JournalID != 0 && dlg.CallID != "" && dlg.FromTag != "" && dlg.ToTag != "" {
 				endedBy := repository.CallEndedBySystem
 
@@ -497,6 +766,10 @@ func (s *Server) onBye(req *sip.Request, tx sip.ServerTransaction) {
 		atomic.AddInt64(&s.activeDialog, -1)
 		sipActiveDialogsSet(s.activeDialog)
 
+		if dlg.Trunk != "" {
+			s.trunkChannels.Release(dlg.Trunk)
+		}
+
 	case <-time.After(3 * time.Second):
 		_ = tx.Respond(sip.NewResponseFromRequest(req, 504, "Server Time-out", nil))
 	}
@@ -525,134 +798,134 @@ func makeReachableContact(login string, src string) (sip.Uri, bool) {
 
 func (s *Server) proxyInviteResponses(ctx *InviteCtx, clTx sip.ClientTransaction) {
 	for resp := range clTx.Responses() {
-		up := makeUpstreamResponse(ctx.OriginInvite, resp)
-
-		ctx.LastResp = up
-		_ = ctx.ServerTx.Respond(up)
+		s.relayInviteResponse(ctx, resp)
+	}
+}
 
-		if resp.CSeq() == nil || resp.CSeq().MethodName != sip.INVITE {
-			continue
+func (s *Server) relayInviteResponse(ctx *InviteCtx, resp *sip.Response) {
+	// звонок забрал сервер (pickup, voicemail): ответы исходной ветки caller'у не нужны
+	if ctx.Diverted.Load() {
+		s.discardRecording(ctx.Recording)
+		if resp.StatusCode >= 200 {
+			s.endEarly(ctx, int(resp.StatusCode), resp.Reason)
 		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 && ctx.OutInvite != nil {
+			s.hangupStray(ctx.OutInvite, resp)
+		}
+		return
+	}
+	if resp.StatusCode >= 200 {
+		s.ringing.remove(ctx)
+	}
 
-		code := int(resp.StatusCode)
-
-		if code >= 200 && code < 300 {
-			callID := resp.CallID().Value()
+	up := makeUpstreamResponse(ctx.OriginInvite, resp)
+	if resp.StatusCode < 300 && len(resp.Body()) > 0 {
+		body := ctx.Codecs.answer(resp.Body())
+		if ctx.Recording != nil {
+			body = ctx.Recording.anchor(media.RelayB, body, s.host)
+		}
+		up.SetBody(body)
+	}
+	ctx.InPolicy.response(up, s.host, s.port)
 
-			fromTag, _ := ctx.OriginInvite.From().Params.Get("tag")
-			toTag, _ := resp.To().Params.Get("tag")
+	ctx.LastResp = up
+	_ = ctx.ServerTx.Respond(up)
 
-			callerCT := ctx.OriginInvite.Contact()
-			ct := resp.Contact()
-			if ct == nil || callerCT == nil {
-				continue
-			}
+	if resp.CSeq() == nil || resp.CSeq().MethodName != sip.INVITE {
+		return
+	}
 
-			log.Printf("[DIALOG STORE] callid=%s fromTag=%s toTag=%s remote=%s",
-				callID, fromTag, toTag, ct.Address,
-			)
+	code := int(resp.StatusCode)
 
-			routes := buildRouteSet(resp)
+	if code < 200 {
+		s.trackEarly(ctx, ctx.OutInvite, resp)
+		return
+	}
 
-			if ctx.DialogCreated.CompareAndSwap(false, true) {
-				ctx.Got2xx = true
+	if code < 300 {
+		dlgAB, dlgBA, ok := s.dialogPair(ctx, ctx.OutInvite, resp)
+		if !ok {
+			return
+		}
 
-				if s.callJournalRepo != nil && ctx.JournalID != 0 {
-					remoteTarget := ct.Address.String()
+		log.Printf("[DIALOG STORE] callid=%s fromTag=%s toTag=%s remote=%s",
+			dlgAB.CallID, dlgAB.FromTag, dlgAB.ToTag, dlgAB.RemoteTarget.String(),
+		)
 
-					routeSetJSON, err := encodeRouteSet(routes)
+		if ctx.DialogCreated.CompareAndSwap(false, true) {
+			ctx.Got2xx = true
 
-					if err != nil {
-						log.Printf("[MARKANSWER] ERROR: %v", err)
-					}
+			if s.callJournalRepo != nil && ctx.JournalID != 0 {
+				routeSetJSON, err := encodeRouteSet(dlgAB.RouteSet)
 
-					answerAt := time.Now()
+				if err != nil {
+					log.Printf("[MARKANSWER] ERROR: %v", err)
+				}
 
-					ringMs := int(answerAt.Sub(ctx.InviteAt).Milliseconds())
+				ringMs := int(dlgAB.AnswerAt.Sub(ctx.InviteAt).Milliseconds())
 
-					err = s.callJournalRepo.MarkAnswered(
-						context.Background(),
-						ctx.JournalID,
-						callID, fromTag, toTag,
-						remoteTarget,
-						routeSetJSON,
-						time.Now(),
-						ringMs,
-					)
+				err = s.callJournalRepo.MarkAnswered(
+					context.Background(),
+					ctx.JournalID,
+					dlgAB.CallID, dlgAB.FromTag, dlgAB.ToTag,
+					dlgAB.RemoteTarget.String(),
+					routeSetJSON,
+					time.Now(),
+					ringMs,
+				)
 
-					if err != nil {
-						log.Printf("[MARKANSWER] ERROR: %v", err)
-					}
+				if err != nil {
+					log.Printf("[MARKANSWER] ERROR: %v", err)
 				}
+			}
 
-				keyAB, keyBA := MakeDialogKey(callID, fromTag, toTag)
-
-				// caller/callee users (для ended_by в BYE — очень удобно)
-				callerUser := ""
-				if f := ctx.OriginInvite.From(); f != nil {
-					callerUser = strings.TrimSpace(f.Address.User)
-				}
-				calleeUser := ""
-				if t := ctx.OriginInvite.To(); t != nil {
-					calleeUser = strings.TrimSpace(t.Address.User)
-				}
+			s.storeCodec(ctx, resp.Body())
 
-				answerAt := time.Now()
-
-				// A -> B (caller -> callee)
-				dlgAB := &DialogCtx{
-					Key:          keyAB,
-					RouteSet:     routes,
-					RemoteTarget: ct.Address, // callee contact
-
-					// --- для CDR / BYE ---
-					JournalID:  ctx.JournalID,
-					CallID:     callID,
-					FromTag:    fromTag,
-					ToTag:      toTag,
-					CallerUser: callerUser,
-					CalleeUser: calleeUser,
-					AnswerAt:   answerAt,
-				}
+			if ctx.Recording != nil {
+				ctx.Recording.answered()
+			}
 
-				// B -> A (callee -> caller)
-				dlgBA := &DialogCtx{
-					Key:          keyBA,
-					RouteSet:     routes,
-					RemoteTarget: callerCT.Address, // caller contact
-
-					JournalID:  ctx.JournalID,
-					CallID:     callID,
-					FromTag:    toTag, // обратное направление
-					ToTag:      fromTag,
-					CallerUser: callerUser,
-					CalleeUser: calleeUser,
-					AnswerAt:   answerAt,
-				}
+			// ранний диалог с тем же To tag заменяется подтверждённым
+			s.dialogs.Store(dlgAB.Key, dlgAB)
+			s.dialogs.Store(dlgBA.Key, dlgBA)
+			atomic.AddInt64(&s.activeDialog, 1)
+			sipActiveDialogsSet(atomic.LoadInt64(&s.activeDialog))
+			log.Printf("SAVE DIALOG KEYS: %s %s", dlgAB.Key, dlgBA.Key)
 
-				s.dialogs.Store(keyAB, dlgAB)
-				s.dialogs.Store(keyBA, dlgBA)
-				atomic.AddInt64(&s.activeDialog, 1)
-				sipActiveDialogsSet(atomic.LoadInt64(&s.activeDialog))
-				log.Printf("SAVE DIALOG KEYS: %s %s", keyAB, keyBA)
+			if ctx.Trunk != "" {
+				metrics.TrunkCalls.WithLabelValues(ctx.Trunk, "answered").Inc()
 			}
 
-			continue
+			// остальные ранние диалоги (другие ветки) так и не ответили
+			s.endEarly(ctx, 0, "")
 		}
 
-		if code == sip.StatusBusyHere || code == sip.StatusGlobalDecline {
-			if s.callJournalRepo != nil && ctx.JournalID != 0 {
-				_ = s.callJournalRepo.MarkRejected(context.Background(), ctx.JournalID, code, resp.Reason, time.Now())
-			}
-			continue
+		return
+	}
+
+	s.endEarly(ctx, code, resp.Reason)
+
+	if code >= 300 {
+		s.discardRecording(ctx.Recording)
+	}
+
+	if code >= 300 && ctx.Trunk != "" {
+		metrics.TrunkCalls.WithLabelValues(ctx.Trunk, "rejected").Inc()
+		s.trunkChannels.Release(ctx.Trunk)
+	}
+
+	if code == sip.StatusBusyHere || code == sip.StatusGlobalDecline {
+		if s.callJournalRepo != nil && ctx.JournalID != 0 {
+			_ = s.callJournalRepo.MarkRejected(context.Background(), ctx.JournalID, code, resp.Reason, time.Now())
 		}
+		return
+	}
 
-		if code == sip.StatusRequestTerminated {
-			if s.callJournalRepo != nil && ctx.JournalID != 0 {
-				_ = s.callJournalRepo.MarkCancelled(context.Background(), ctx.JournalID, time.Now())
-			}
-			continue
+	if code == sip.StatusRequestTerminated {
+		if s.callJournalRepo != nil && ctx.JournalID != 0 {
+			_ = s.callJournalRepo.MarkCancelled(context.Background(), ctx.JournalID, time.Now())
 		}
+		return
 	}
 }
 
@@ -671,7 +944,7 @@ func (s *Server) onCancel(req *sip.Request, tx sip.ServerTransaction) {
 
 	v, exists := s.transaction.Load(key)
 	if !exists {
-		log.Println("[CANCEL] Transaction not found by key %s", key)
+		log.Printf("[CANCEL] Transaction not found by key %s", key)
 		return
 	}
 	ctx, ok := v.(*InviteCtx)
@@ -683,6 +956,19 @@ func (s *Server) onCancel(req *sip.Request, tx sip.ServerTransaction) {
 		return
 	}
 
+	ctx.Cancelled.Store(true)
+	s.ringing.remove(ctx)
+
+	// обзвон группы: отменяем все ветки
+	if forks := ctx.Forks(); len(forks) > 0 {
+		for _, out := range forks {
+			if cancel := buildCancel(out); cancel != nil {
+				_, _ = s.cl.TransactionRequest(context.Background(), cancel)
+			}
+		}
+		return
+	}
+
 	if ctx.OutInvite == nil {
 		_ = ctx.ServerTx.Respond(
 			sip.NewResponseFromRequest(ctx.OriginInvite, sip.StatusRequestTerminated, "Request Terminated", nil),
@@ -691,35 +977,12 @@ func (s *Server) onCancel(req *sip.Request, tx sip.ServerTransaction) {
 		return
 	}
 
-	cancel := sip.NewRequest(sip.CANCEL, ctx.OutInvite.Recipient)
-
-	copyFrom := *ctx.OutInvite.From()
-	copyTo := *ctx.OutInvite.To()
-	copyCallID := *ctx.OutInvite.CallID()
-
-	cseq := *ctx.OutInvite.CSeq()
-	cseq.MethodName = sip.CANCEL
-
-	cancel.AppendHeader(&copyFrom)
-	cancel.AppendHeader(&copyTo)
-	cancel.AppendHeader(&copyCallID)
-	cancel.AppendHeader(&cseq)
-
-	for _, h := range ctx.OutInvite.GetHeaders("Route") {
-		cancel.AppendHeader(h)
-	}
-
-	if v := ctx.OutInvite.Via(); v != nil {
-		vcopy := *v
-		cancel.PrependHeader(&vcopy)
-	} else {
+	cancel := buildCancel(ctx.OutInvite)
+	if cancel == nil {
 		log.Println("[CANCEL] OutInvite has no Via")
 		return
 	}
 
-	mf := sip.MaxForwardsHeader(70)
-	cancel.AppendHeader(&mf)
-
 	_, _ = s.cl.TransactionRequest(context.Background(), cancel)
 
 }
@@ -0,0 +1,126 @@
+package sipserver
+
+import (
+	"strings"
+
+	"SipServer/internal/repository/trunk"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// заголовки, без которых запрос не дойдёт или диалог развалится: deny их не трогает
+var protectedHeaders = map[string]bool{
+	"via": true, "from": true, "to": true, "call-id": true, "cseq": true,
+	"max-forwards": true, "contact": true, "route": true, "record-route": true,
+	"content-type": true, "content-length": true,
+}
+
+// headerPolicy — что транк видит из нашей сети.
+type headerPolicy struct {
+	hideTopology bool
+	allow        []string // заголовки caller'а, которые пропускаем дальше
+	deny         []string // заголовки, которые вырезаем
+}
+
+// trunkPolicy — правила транка; nil, если ничего не настроено.
+func trunkPolicy(t *trunk.Trunk) *headerPolicy {
+	if !t.TopologyHiding && len(t.HeaderAllow) == 0 && len(t.HeaderDeny) == 0 {
+		return nil
+	}
+	return &headerPolicy{
+		hideTopology: t.TopologyHiding,
+		allow:        t.HeaderAllow,
+		deny:         t.HeaderDeny,
+	}
+}
+
+// request чистит запрос out (копию in) перед отправкой в транк.
+// Via и чужие Record-Route caller'а не уходят: ответы вернутся на наш Via, а caller'у
+// makeUpstreamResponse восстановит его Via из исходного запроса.
+func (p *headerPolicy) request(in, out *sip.Request, host string, port int) {
+	if p == nil {
+		return
+	}
+
+	for _, name := range p.allow {
+		if out.GetHeader(name) != nil {
+			continue
+		}
+		for _, h := range in.GetHeaders(name) {
+			out.AppendHeader(sip.HeaderClone(h))
+		}
+	}
+	p.removeDenied(out)
+
+	if !p.hideTopology {
+		return
+	}
+	if via := out.Via(); via != nil {
+		top := via.Clone()
+		removeAll(out, "Via")
+		out.PrependHeader(top)
+	}
+	// из Record-Route остаётся только сервер: через него транк шлёт запросы диалога
+	var own []sip.Header
+	for _, h := range out.Headers() {
+		rr, ok := h.(*sip.RecordRouteHeader)
+		if ok && rr.Address.Host == host && rr.Address.Port == port {
+			own = append(own, rr)
+		}
+	}
+	removeAll(out, "Record-Route")
+	for _, h := range own {
+		out.AppendHeader(h)
+	}
+	if ct := out.Contact(); ct != nil {
+		out.ReplaceHeader(selfContact(ct.Address.User, host, port))
+	}
+}
+
+// response чистит ответ, уходящий в транк: Contact — сервер,
+// Record-Route нашей сети не нужны (запросы транка и так придут на сервер).
+func (p *headerPolicy) response(res *sip.Response, host string, port int) {
+	if p == nil {
+		return
+	}
+	p.removeDenied(res)
+
+	if !p.hideTopology {
+		return
+	}
+	removeAll(res, "Record-Route")
+	if ct := res.Contact(); ct != nil {
+		res.ReplaceHeader(selfContact(ct.Address.User, host, port))
+	}
+}
+
+type headerList interface {
+	Headers() []sip.Header
+	RemoveHeader(name string) bool
+}
+
+func (p *headerPolicy) removeDenied(msg headerList) {
+	for _, name := range p.deny {
+		if !protectedHeaders[strings.ToLower(name)] {
+			removeAll(msg, name)
+		}
+	}
+}
+
+// removeAll удаляет все заголовки name без учёта регистра
+// (RemoveHeader в sipgo убирает один и сравнивает имя как есть).
+func removeAll(msg headerList, name string) {
+	var found []string
+	for _, h := range msg.Headers() {
+		if strings.EqualFold(h.Name(), name) {
+			found = append(found, h.Name())
+		}
+	}
+	for _, n := range found {
+		msg.RemoveHeader(n)
+	}
+}
+
+func selfContact(user, host string, port int) *sip.ContactHeader {
+	return &sip.ContactHeader{Address: sip.Uri{Scheme: "sip", User: user, Host: host, Port: port}}
+}
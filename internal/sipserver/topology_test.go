@@ -0,0 +1,44 @@
+package sipserver
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+func TestHeaderPolicyRequestHidesRecordRoute(t *testing.T) {
+	rr := func(host string, port int) *sip.RecordRouteHeader {
+		return &sip.RecordRouteHeader{Address: sip.Uri{Scheme: "sip", Host: host, Port: port, UriParams: sip.NewParams().Add("lr", "")}}
+	}
+	tests := []struct {
+		name   string
+		policy *headerPolicy
+		want   []string
+	}{
+		{"hide topology", &headerPolicy{hideTopology: true}, []string{"<sip:pbx.local:5060;lr>"}},
+		{"no hiding", &headerPolicy{}, []string{"<sip:10.0.0.5:5060;lr>", "<sip:pbx.local:5060;lr>", "<sip:10.0.0.6:5070;lr>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := sip.NewRequest(sip.INVITE, sip.Uri{Scheme: "sip", User: "200", Host: "pbx.local"})
+			out := sip.NewRequest(sip.INVITE, sip.Uri{Scheme: "sip", User: "200", Host: "sip.prov.example"})
+			out.AppendHeader(rr("10.0.0.5", 5060))
+			out.AppendHeader(rr("pbx.local", 5060))
+			out.AppendHeader(sip.NewHeader("record-route", "<sip:10.0.0.6:5070;lr>"))
+
+			tt.policy.request(in, out, "pbx.local", 5060)
+
+			var got []string
+			for _, h := range out.Headers() {
+				if strings.EqualFold(h.Name(), "Record-Route") {
+					got = append(got, h.Value())
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Record-Route = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
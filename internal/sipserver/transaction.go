@@ -1,10 +1,13 @@
 package sipserver
 
 import (
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/emiago/sipgo/sip"
+
+	userrepo "SipServer/internal/repository/user"
 )
 
 type InviteCtx struct {
@@ -20,12 +23,47 @@ type InviteCtx struct {
 	Got2xx        bool
 	JournalID     int64
 	InviteAt      time.Time
+	Trunk         string
+	Cancelled     atomic.Bool
+	Diverted      atomic.Bool // звонок забрал сервер у исходной ветки (pickup, voicemail)
+	ReferredBy    string      // login того, кто перевёл звонок (REFER)
+	Recording     *callRecording
+	LocalTag      string         // To tag сервера в ответах caller'у (b2bua): один на все ответы
+	OutPolicy     *headerPolicy  // правила заголовков транка, куда ушёл звонок
+	InPolicy      *headerPolicy  // правила заголовков транка, откуда пришёл звонок
+	Codecs        *codecPolicy   // политика кодеков caller'а и callee
+	Caller        *userrepo.User // профиль внутреннего звонящего; nil — звонок с транка
+
+	forkMu sync.Mutex
+	forks  []*sip.Request // исходящие INVITE при параллельном обзвоне
+
+	Got18x  atomic.Bool
+	earlyMu sync.Mutex
+	early   map[string][2]*DialogCtx // ранние диалоги по To tag callee
 }
 
 func NewInviteCtx() *InviteCtx {
 	return &InviteCtx{}
 }
 
+func (c *InviteCtx) addFork(out *sip.Request) {
+	c.forkMu.Lock()
+	c.forks = append(c.forks, out)
+	c.forkMu.Unlock()
+}
+
+func (c *InviteCtx) clearForks() {
+	c.forkMu.Lock()
+	c.forks = nil
+	c.forkMu.Unlock()
+}
+
+func (c *InviteCtx) Forks() []*sip.Request {
+	c.forkMu.Lock()
+	defer c.forkMu.Unlock()
+	return append([]*sip.Request(nil), c.forks...)
+}
+
 func inviteKeyFromReq(req *sip.Request) (string, bool) {
 	via := req.Via()
 	if via == nil {
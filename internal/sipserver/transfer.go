@@ -0,0 +1,240 @@
+package sipserver
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+const (
+	TransferBlind    = "blind"
+	TransferAttended = "attended"
+
+	// сколько ждём INVITE от переводимого после REFER
+	transferTTL = 32 * time.Second
+)
+
+// заголовки, которые переносим при relay in-dialog запросов
+var inDialogHeaders = []string{
+	"Contact", "Refer-To", "Referred-By", "Replaces",
+	"Event", "Subscription-State", "Allow-Events", "Content-Type",
+	"RAck", "Require", "Supported",
+}
+
+type pendingTransfer struct {
+	ParentJournalID int64
+	Type            string
+	Referrer        string // кто перевёл
+	ExpiresAt       time.Time
+}
+
+// transfers — принятые REFER, ждущие INVITE от переводимого.
+// Ключ: login переводимого + номер, куда переводят.
+type transfers struct {
+	mu sync.Mutex
+	m  map[string]pendingTransfer
+}
+
+func newTransfers() *transfers {
+	return &transfers{m: make(map[string]pendingTransfer)}
+}
+
+func (t *transfers) add(referee, target string, p pendingTransfer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for k, v := range t.m {
+		if now.After(v.ExpiresAt) {
+			delete(t.m, k)
+		}
+	}
+	t.m[referee+"|"+target] = p
+}
+
+func (t *transfers) take(referee, target string) (pendingTransfer, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := referee + "|" + target
+	p, ok := t.m[key]
+	if !ok {
+		return p, false
+	}
+	delete(t.m, key)
+	return p, time.Now().Before(p.ExpiresAt)
+}
+
+func (s *Server) onRefer(req *sip.Request, tx sip.ServerTransaction) {
+	start := time.Now()
+	sipIn(req.Method)
+	defer observeHandler(req.Method, start)
+
+	dlg, ok := s.lookupDialog(req)
+	if !ok {
+		log.Printf("[REFER] dialog not found callid=%s", req.CallID().Value())
+		respond(req, tx, sip.StatusCallTransactionDoesNotExists, "Call/Transaction Does Not Exist")
+		return
+	}
+
+	// звонки, где второй стороной выступает сервер, перевести relay'ем нельзя
+	if dlg.Leg != nil {
+		respond(req, tx, sip.StatusNotImplemented, "Not Implemented")
+		return
+	}
+
+	h := req.GetHeader("Refer-To")
+	if h == nil {
+		respond(req, tx, sip.StatusBadRequest, "Missing Refer-To")
+		return
+	}
+
+	var target sip.Uri
+	raw := strings.Trim(strings.TrimSpace(h.Value()), "<>")
+	if i := strings.Index(raw, ">"); i >= 0 {
+		raw = raw[:i]
+	}
+	if err := sip.ParseUri(raw, &target); err != nil {
+		respond(req, tx, sip.StatusBadRequest, "Bad Refer-To")
+		return
+	}
+
+	transferType := TransferBlind
+	if strings.Contains(strings.ToLower(h.Value()), "replaces") {
+		transferType = TransferAttended
+	}
+
+	resp := s.relayInDialog(req, tx, dlg)
+	if resp == nil || resp.StatusCode >= 300 {
+		return
+	}
+
+	referee := strings.TrimSpace(req.To().Address.User)
+	log.Printf("[REFER] %s transfer: %s -> %s", transferType, referee, target.User)
+
+	s.transfers.add(referee, target.User, pendingTransfer{
+		ParentJournalID: dlg.JournalID,
+		Type:            transferType,
+		Referrer:        strings.TrimSpace(req.From().Address.User),
+		ExpiresAt:       time.Now().Add(transferTTL),
+	})
+
+	if s.callJournalRepo != nil {
+		if err := s.callJournalRepo.MarkTransferred(context.Background(), dlg.CallID, dlg.FromTag, dlg.ToTag); err != nil {
+			log.Printf("[REFER] MarkTransferred failed: %v", err)
+		}
+	}
+}
+
+// onNotify пропускает NOTIFY (sipfrag о ходе перевода) к другой стороне диалога.
+func (s *Server) onNotify(req *sip.Request, tx sip.ServerTransaction) {
+	start := time.Now()
+	sipIn(req.Method)
+	defer observeHandler(req.Method, start)
+
+	dlg, ok := s.lookupDialog(req)
+	if !ok || dlg.Leg != nil {
+		respond(req, tx, sip.StatusCallTransactionDoesNotExists, "Call/Transaction Does Not Exist")
+		return
+	}
+
+	s.relayInDialog(req, tx, dlg)
+}
+
+// linkTransfer связывает INVITE переводимого с исходным звонком.
+func (s *Server) linkTransfer(req *sip.Request, ictx *InviteCtx, callee string) {
+	caller := strings.TrimSpace(req.From().Address.User)
+	p, ok := s.transfers.take(caller, callee)
+	if !ok {
+		return
+	}
+	ictx.ReferredBy = p.Referrer
+
+	if s.callJournalRepo == nil || ictx.JournalID == 0 || p.ParentJournalID == 0 {
+		return
+	}
+
+	if err := s.callJournalRepo.SetParent(context.Background(), ictx.JournalID, p.ParentJournalID, p.Type); err != nil {
+		log.Printf("[REFER] SetParent failed: %v", err)
+	}
+}
+
+// relayInDialog пересылает in-dialog запрос другой стороне и отдаёт её финальный ответ.
+func (s *Server) relayInDialog(req *sip.Request, tx sip.ServerTransaction, dlg *DialogCtx) *sip.Response {
+	out := s.forwardInDialog(req, dlg)
+
+	clTx, err := s.cl.TransactionRequest(context.Background(), out)
+
+	if err != nil {
+		log.Printf("[%s] relay error: %v", req.Method, err)
+		respond(req, tx, sip.StatusBadGateway, "Bad Gateway")
+		return nil
+	}
+	defer clTx.Terminate()
+
+	timer := time.NewTimer(5 * time.Second)
+	defer timer.Stop()
+
+	for {
+		select {
+		case resp := <-clTx.Responses():
+			if resp.StatusCode < 200 {
+				continue
+			}
+			_ = tx.Respond(sip.NewResponseFromRequest(req, resp.StatusCode, resp.Reason, nil))
+			return resp
+		case <-clTx.Done():
+			respond(req, tx, sip.StatusRequestTimeout, "Request Timeout")
+			return nil
+		case <-timer.C:
+			respond(req, tx, sip.StatusGatewayTimeout, "Server Time-out")
+			return nil
+		}
+	}
+}
+
+// forwardInDialog — копия in-dialog запроса для другой стороны проксируемого диалога.
+func (s *Server) forwardInDialog(req *sip.Request, dlg *DialogCtx) *sip.Request {
+	out := sip.NewRequest(req.Method, dlg.RemoteTarget)
+
+	copyFrom := *req.From()
+	copyTo := *req.To()
+	copyCallID := *req.CallID()
+	copyCSeq := *req.CSeq()
+	copyCSeq.SeqNo += dlg.CSeqOffset
+
+	out.AppendHeader(&copyFrom)
+	out.AppendHeader(&copyTo)
+	out.AppendHeader(&copyCallID)
+	out.AppendHeader(&copyCSeq)
+
+	for _, r := range stripSelfRoute(dlg.RouteSet, s.host, s.port) {
+		out.AppendHeader(r)
+	}
+	for _, name := range inDialogHeaders {
+		for _, h := range req.GetHeaders(name) {
+			if name == "RAck" && dlg.CSeqOffset != 0 {
+				// RAck ссылается на CSeq INVITE — у вызываемого он сдвинут
+				out.AppendHeader(sip.NewHeader("RAck", shiftRAck(h.Value(), dlg.CSeqOffset)))
+				continue
+			}
+			out.AppendHeader(sip.HeaderClone(h))
+		}
+	}
+
+	var mf sip.MaxForwardsHeader = 70
+	out.AppendHeader(&mf)
+
+	out.PrependHeader(s.newVia())
+
+	if body := req.Body(); len(body) > 0 {
+		out.SetBody(body)
+	}
+	dlg.Policy.request(req, out, s.host, s.port)
+
+	return out
+}
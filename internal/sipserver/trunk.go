@@ -0,0 +1,258 @@
+package sipserver
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"SipServer/internal/metrics"
+	"SipServer/internal/repository/trunk"
+
+	"github.com/emiago/sipgo"
+	"github.com/emiago/sipgo/sip"
+)
+
+// сколько ждём от транка первого ответа (кроме 100 Trying) до failover
+var trunkAnswerTimeout = 10 * time.Second
+
+// trunkAnswer — итог обхода транков: ответивший транк с первым ответом и
+// транзакцией либо (trunk == nil) код последнего отказа.
+type trunkAnswer struct {
+	trunk  *trunk.Trunk
+	resp   *sip.Response
+	clTx   sip.ClientTransaction
+	code   int
+	reason string
+}
+
+// inviteTrunks отправляет INVITE в транки по порядку.
+// На 5xx, таймаут или занятые каналы пробуем следующий транк.
+// Обход идёт в отдельной горутине: каждый транк может думать до trunkAnswerTimeout.
+func (s *Server) inviteTrunks(req *sip.Request, tx sip.ServerTransaction, ictx *InviteCtx, names []string, number string) {
+	// политика caller'а одна на все транки: проверяем предложение сразу
+	ictx.Codecs = s.callCodecPolicy(ictx, nil)
+	if _, err := ictx.Codecs.offer(req.Body()); err != nil {
+		log.Printf("[TRUNK] number=%s %v", number, err)
+		s.failInvite(ictx, sip.StatusNotAcceptableHere, "Not Acceptable Here")
+		return
+	}
+
+	go func() {
+		a := s.dialTrunks(ictx, s.findTrunks(names), number)
+		if a.trunk == nil {
+			if ictx.Cancelled.Load() {
+				a.code, a.reason = sip.StatusRequestTerminated, "Request Terminated"
+			}
+			if s.callJournalRepo != nil && ictx.JournalID != 0 {
+				_ = s.callJournalRepo.MarkFailed(context.Background(), ictx.JournalID, a.code, a.reason, time.Now())
+			}
+			res := sip.NewResponseFromRequest(req, a.code, a.reason, nil)
+			ictx.LastResp = res
+			_ = tx.Respond(res)
+			return
+		}
+
+		ictx.Trunk = a.trunk.Name
+		ictx.ClientTx = a.clTx
+		if s.callJournalRepo != nil && ictx.JournalID != 0 {
+			if err := s.callJournalRepo.SetTrunk(context.Background(), ictx.JournalID, a.trunk.Name); err != nil {
+				log.Printf("[TRUNK] SetTrunk failed: %v", err)
+			}
+		}
+
+		s.relayInviteResponse(ictx, a.resp)
+		s.proxyInviteResponses(ictx, a.clTx)
+	}()
+}
+
+// findTrunks — включённые транки по именам из правила, в том же порядке.
+func (s *Server) findTrunks(names []string) []*trunk.Trunk {
+	trunks := make([]*trunk.Trunk, 0, len(names))
+	for _, name := range names {
+		t, err := s.trunkRepo.FindByName(context.Background(), name)
+		if err != nil {
+			log.Printf("[TRUNK] trunk=%s lookup error: %v", name, err)
+			continue
+		}
+		if t.Enabled {
+			trunks = append(trunks, t)
+		}
+	}
+	return trunks
+}
+
+// dialTrunks пробует транки по очереди до первого ответа не 5xx.
+// Канал ответившего транка остаётся занятым, его освобождает завершение звонка.
+func (s *Server) dialTrunks(ictx *InviteCtx, trunks []*trunk.Trunk, number string) trunkAnswer {
+	a := trunkAnswer{code: sip.StatusServiceUnavailable, reason: "Service Unavailable"}
+
+	for _, t := range trunks {
+		// caller отменил звонок, пока ждали предыдущий транк
+		if ictx.Cancelled.Load() {
+			return a
+		}
+
+		if !s.trunkChannels.Acquire(t.Name, t.MaxChannels) {
+			log.Printf("[TRUNK] trunk=%s all %d channels busy", t.Name, t.MaxChannels)
+			metrics.TrunkCalls.WithLabelValues(t.Name, "busy_channels").Inc()
+			continue
+		}
+
+		log.Printf("[TRUNK] trunk=%s number=%s", t.Name, number)
+
+		resp, clTx := s.tryTrunk(ictx, t, number)
+		if resp == nil || resp.StatusCode >= 500 {
+			s.trunkChannels.Release(t.Name)
+			metrics.TrunkCalls.WithLabelValues(t.Name, "failover").Inc()
+			if resp != nil {
+				a.code, a.reason = int(resp.StatusCode), resp.Reason
+			} else {
+				a.code, a.reason = sip.StatusGatewayTimeout, "Server Time-out"
+			}
+			log.Printf("[TRUNK] trunk=%s failed code=%d, failover", t.Name, a.code)
+			continue
+		}
+
+		a.trunk, a.resp, a.clTx = t, resp, clTx
+		return a
+	}
+	return a
+}
+
+// tryTrunk ждёт первый содержательный ответ транка. nil — таймаут, ошибка
+// транспорта или неудачная авторизация: во всех случаях пробуем следующий транк.
+func (s *Server) tryTrunk(ictx *InviteCtx, t *trunk.Trunk, number string) (*sip.Response, sip.ClientTransaction) {
+	out := buildTrunkInvite(ictx.OriginInvite, t, number, s.host, s.port)
+	s.assertIdentity(ictx, out, t)
+	ictx.OutInvite = out
+	ictx.OutPolicy = trunkPolicy(t)
+	_ = ictx.Codecs.applyOffer(out) // общий кодек проверен в inviteTrunks
+
+	clTx, err := s.cl.TransactionRequest(context.Background(), out)
+	if err != nil {
+		log.Printf("[TRUNK] trunk=%s send error: %v", t.Name, err)
+		return nil, nil
+	}
+
+	timer := time.NewTimer(trunkAnswerTimeout)
+	defer timer.Stop()
+
+	authTried := false
+	for {
+		select {
+		case resp := <-clTx.Responses():
+			if resp.StatusCode == sip.StatusTrying {
+				continue
+			}
+
+			code := int(resp.StatusCode)
+			if code == sip.StatusUnauthorized || code == sip.StatusProxyAuthRequired {
+				// вызов провайдера caller'у не передаём: ответить на него может только сервер
+				if authTried || t.Username == "" {
+					log.Printf("[TRUNK] trunk=%s auth rejected code=%d", t.Name, code)
+					clTx.Terminate()
+					return nil, nil
+				}
+				authTried = true
+				clTx.Terminate()
+
+				clTx, err = s.cl.TransactionDigestAuth(context.Background(), out, resp, sipgo.DigestAuth{
+					Username: t.Username,
+					Password: t.Password,
+				})
+				if err != nil {
+					log.Printf("[TRUNK] trunk=%s digest error: %v", t.Name, err)
+					return nil, nil
+				}
+				continue
+			}
+
+			return resp, clTx
+
+		case <-clTx.Done():
+			return nil, nil
+
+		case <-timer.C:
+			if cancel := buildCancel(out); cancel != nil {
+				_, _ = s.cl.TransactionRequest(context.Background(), cancel)
+			}
+			clTx.Terminate()
+			return nil, nil
+		}
+	}
+}
+
+func buildTrunkInvite(in *sip.Request, t *trunk.Trunk, number string, myHost string, myPort int) *sip.Request {
+	target := sip.Uri{
+		Scheme: "sip",
+		User:   number,
+		Host:   t.Host,
+		Port:   t.Port,
+	}
+	target.UriParams = sip.NewParams().Add("transport", t.Transport)
+
+	out := buildOutboundInvite(in, &target, myHost, myPort)
+	trunkPolicy(t).request(in, out, myHost, myPort)
+
+	if from := out.From(); from != nil {
+		from.Address.Host = t.Host
+		from.Address.Port = 0
+		if t.FromUser != "" {
+			from.Address.User = t.FromUser
+		}
+	}
+	if to := out.To(); to != nil {
+		to.Address.User = number
+		to.Address.Host = t.Host
+		to.Address.Port = 0
+	}
+
+	return out
+}
+
+// splitTargets разбирает target правила вида "prov1, prov2".
+func splitTargets(target string) []string {
+	parts := strings.Split(target, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// channelCounter считает занятые каналы по транкам.
+type channelCounter struct {
+	mu   sync.Mutex
+	used map[string]int
+}
+
+func newChannelCounter() *channelCounter {
+	return &channelCounter{used: make(map[string]int)}
+}
+
+// Acquire занимает канал. max == 0 — без ограничения.
+func (c *channelCounter) Acquire(name string, max int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if max > 0 && c.used[name] >= max {
+		return false
+	}
+	c.used[name]++
+	metrics.TrunkActiveChannels.WithLabelValues(name).Set(float64(c.used[name]))
+	return true
+}
+
+func (c *channelCounter) Release(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.used[name] > 0 {
+		c.used[name]--
+	}
+	metrics.TrunkActiveChannels.WithLabelValues(name).Set(float64(c.used[name]))
+}
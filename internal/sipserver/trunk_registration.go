@@ -0,0 +1,230 @@
+package sipserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"SipServer/internal/metrics"
+	"SipServer/internal/repository/trunk"
+
+	"github.com/emiago/sipgo"
+	"github.com/emiago/sipgo/sip"
+)
+
+const (
+	trunkReloadInterval = 30 * time.Second
+	trunkRetryInterval  = 30 * time.Second
+)
+
+type trunkRegistration struct {
+	trunk  trunk.Trunk
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// trunkRegistrar держит исходящие REGISTER к провайдерам.
+// Список транков перечитывается из БД, изменения подхватываются без рестарта.
+type trunkRegistrar struct {
+	cl   *sipgo.Client
+	repo *trunk.TrunkRepo
+	host string
+	port int
+
+	mu     sync.Mutex
+	active map[int]*trunkRegistration
+}
+
+func newTrunkRegistrar(cl *sipgo.Client, repo *trunk.TrunkRepo, host string, port int) *trunkRegistrar {
+	return &trunkRegistrar{
+		cl:     cl,
+		repo:   repo,
+		host:   host,
+		port:   port,
+		active: make(map[int]*trunkRegistration),
+	}
+}
+
+// RunTrunkRegistrations блокируется до отмены ctx.
+func (s *Server) RunTrunkRegistrations(ctx context.Context) {
+	s.trunkRegistrar.Run(ctx)
+}
+
+func (r *trunkRegistrar) Run(ctx context.Context) {
+	ticker := time.NewTicker(trunkReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		r.reload(ctx)
+
+		select {
+		case <-ctx.Done():
+			r.stopAll()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *trunkRegistrar) reload(ctx context.Context) {
+	trunks, err := r.repo.ListRegistering(ctx)
+	if err != nil {
+		log.Printf("[TRUNK REG] reload error: %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[int]bool, len(trunks))
+	for _, t := range trunks {
+		seen[t.Id] = true
+
+		if cur, ok := r.active[t.Id]; ok {
+			if sameRegistration(&cur.trunk, t) {
+				continue
+			}
+			// транк изменили — перерегистрируемся с новыми данными
+			cur.cancel()
+			<-cur.done
+		}
+
+		regCtx, cancel := context.WithCancel(ctx)
+		reg := &trunkRegistration{trunk: *t, cancel: cancel, done: make(chan struct{})}
+		r.active[t.Id] = reg
+		go r.loop(regCtx, reg)
+	}
+
+	for id, reg := range r.active {
+		if !seen[id] {
+			reg.cancel()
+			<-reg.done
+			delete(r.active, id)
+		}
+	}
+}
+
+// sameRegistration — у транка не менялось ничего, что влияет на REGISTER.
+func sameRegistration(a, b *trunk.Trunk) bool {
+	return a.Name == b.Name &&
+		a.Host == b.Host &&
+		a.Port == b.Port &&
+		a.Transport == b.Transport &&
+		a.Username == b.Username &&
+		a.Password == b.Password &&
+		a.FromUser == b.FromUser &&
+		a.RegisterExpires == b.RegisterExpires
+}
+
+func (r *trunkRegistrar) stopAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, reg := range r.active {
+		reg.cancel()
+		<-reg.done
+		delete(r.active, id)
+	}
+}
+
+func (r *trunkRegistrar) loop(ctx context.Context, reg *trunkRegistration) {
+	defer close(reg.done)
+
+	t := reg.trunk
+	req := r.buildRegister(&t)
+
+	for {
+		expires, err := r.register(ctx, req, &t, t.RegisterExpires)
+
+		wait := trunkRetryInterval
+		if err != nil {
+			log.Printf("[TRUNK REG] trunk=%s register failed: %v", t.Name, err)
+			metrics.TrunkRegistered.WithLabelValues(t.Name).Set(0)
+		} else {
+			log.Printf("[TRUNK REG] trunk=%s registered expires=%ds", t.Name, expires)
+			metrics.TrunkRegistered.WithLabelValues(t.Name).Set(1)
+			// обновляем заранее, не дожидаясь истечения
+			wait = time.Duration(expires) * time.Second * 8 / 10
+		}
+
+		select {
+		case <-ctx.Done():
+			unregCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			_, _ = r.register(unregCtx, req, &t, 0)
+			cancel()
+			metrics.TrunkRegistered.WithLabelValues(t.Name).Set(0)
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (r *trunkRegistrar) buildRegister(t *trunk.Trunk) *sip.Request {
+	recipient := sip.Uri{Scheme: "sip", Host: t.Host, Port: t.Port}
+	recipient.UriParams = sip.NewParams().Add("transport", t.Transport)
+
+	req := sip.NewRequest(sip.REGISTER, recipient)
+
+	aor := sip.Uri{Scheme: "sip", User: t.Username, Host: t.Host}
+
+	from := &sip.FromHeader{Address: aor, Params: sip.NewParams()}
+	from.Params.Add("tag", sip.GenerateTagN(16))
+	req.AppendHeader(from)
+	req.AppendHeader(&sip.ToHeader{Address: aor, Params: sip.NewParams()})
+
+	req.AppendHeader(&sip.ContactHeader{
+		Address: sip.Uri{Scheme: "sip", User: t.Username, Host: r.host, Port: r.port},
+	})
+
+	return req
+}
+
+// register отправляет REGISTER (с digest при 401/407) и возвращает выданный expires.
+func (r *trunkRegistrar) register(ctx context.Context, req *sip.Request, t *trunk.Trunk, expires int) (int, error) {
+	req.RemoveHeader("Expires")
+	req.RemoveHeader("Authorization")
+	req.RemoveHeader("Proxy-Authorization")
+	req.RemoveHeader("Via")
+	exp := sip.ExpiresHeader(expires)
+	req.AppendHeader(&exp)
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	res, err := r.cl.Do(ctx, req, sipgo.ClientRequestRegisterBuild)
+	if err != nil {
+		return 0, err
+	}
+
+	if res.StatusCode == sip.StatusUnauthorized || res.StatusCode == sip.StatusProxyAuthRequired {
+		res, err = r.cl.DoDigestAuth(ctx, req, res, sipgo.DigestAuth{
+			Username: t.Username,
+			Password: t.Password,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if res.StatusCode != sip.StatusOK {
+		return 0, fmt.Errorf("%d %s", res.StatusCode, res.Reason)
+	}
+
+	if h := res.GetHeader("Expires"); h != nil {
+		var granted int
+		if _, err := fmt.Sscanf(h.Value(), "%d", &granted); err == nil && granted > 0 {
+			return granted, nil
+		}
+	}
+	if ct := res.Contact(); ct != nil {
+		if v, ok := ct.Params.Get("expires"); ok {
+			var granted int
+			if _, err := fmt.Sscanf(v, "%d", &granted); err == nil && granted > 0 {
+				return granted, nil
+			}
+		}
+	}
+	return expires, nil
+}
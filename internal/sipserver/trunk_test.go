@@ -0,0 +1,299 @@
+package sipserver
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo"
+	"github.com/emiago/sipgo/sip"
+
+	"SipServer/internal/repository/trunk"
+)
+
+// fakeTrunk — провайдер на локальном UDP-порту; reply решает, что ответить на INVITE
+// (nil — молчать).
+type fakeTrunk struct {
+	t       *trunk.Trunk
+	invites atomic.Int32
+}
+
+func startFakeTrunk(t *testing.T, name string, reply func(req *sip.Request, n int32) *sip.Response) *fakeTrunk {
+	t.Helper()
+
+	ua, err := sipgo.NewUA(sipgo.WithUserAgent(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := sipgo.NewServer(ua)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ft := &fakeTrunk{}
+	srv.OnInvite(func(req *sip.Request, tx sip.ServerTransaction) {
+		n := ft.invites.Add(1)
+		if res := reply(req, n); res != nil {
+			_ = tx.Respond(res)
+		}
+	})
+	srv.OnCancel(func(req *sip.Request, tx sip.ServerTransaction) {
+		_ = tx.Respond(sip.NewResponseFromRequest(req, sip.StatusOK, "OK", nil))
+	})
+	srv.OnAck(func(*sip.Request, sip.ServerTransaction) {})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.ServeUDP(conn) }()
+	t.Cleanup(func() {
+		_ = srv.Close()
+		_ = ua.Close()
+	})
+
+	addr := conn.LocalAddr().(*net.UDPAddr)
+	ft.t = &trunk.Trunk{
+		Name:      name,
+		Host:      "127.0.0.1",
+		Port:      addr.Port,
+		Transport: "udp",
+		Enabled:   true,
+	}
+	return ft
+}
+
+func replyCode(code int, reason string) func(*sip.Request, int32) *sip.Response {
+	return func(req *sip.Request, _ int32) *sip.Response {
+		return sip.NewResponseFromRequest(req, code, reason, nil)
+	}
+}
+
+func newTrunkTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	ua, err := sipgo.NewUA(sipgo.WithUserAgent("pbx-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl, err := sipgo.NewClient(ua, sipgo.WithClientHostname("127.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cl.Close()
+		_ = ua.Close()
+	})
+	return &Server{
+		cl:            cl,
+		host:          "127.0.0.1",
+		port:          5060,
+		trunkChannels: newChannelCounter(),
+	}
+}
+
+func newTrunkTestCtx() *InviteCtx {
+	req := sip.NewRequest(sip.INVITE, sip.Uri{Scheme: "sip", User: "84951234567", Host: "127.0.0.1"})
+	req.AppendHeader(&sip.FromHeader{
+		Address: sip.Uri{Scheme: "sip", User: "101", Host: "127.0.0.1"},
+		Params:  sip.NewParams().Add("tag", sip.GenerateTagN(8)),
+	})
+	req.AppendHeader(&sip.ToHeader{Address: sip.Uri{Scheme: "sip", User: "84951234567", Host: "127.0.0.1"}})
+	callID := sip.CallIDHeader("trunk-test-" + sip.GenerateTagN(8))
+	req.AppendHeader(&callID)
+	req.AppendHeader(&sip.CSeqHeader{SeqNo: 1, MethodName: sip.INVITE})
+
+	ictx := NewInviteCtx()
+	ictx.OriginInvite = req
+	return ictx
+}
+
+func setTrunkTimeout(t *testing.T, d time.Duration) {
+	t.Helper()
+	prev := trunkAnswerTimeout
+	trunkAnswerTimeout = d
+	t.Cleanup(func() { trunkAnswerTimeout = prev })
+}
+
+func TestDialTrunks(t *testing.T) {
+	setTrunkTimeout(t, 300*time.Millisecond)
+
+	tests := []struct {
+		name     string
+		first    func(*sip.Request, int32) *sip.Response
+		second   func(*sip.Request, int32) *sip.Response
+		answered string // "" — все транки отказали
+		code     int    // код ответа или последнего отказа
+	}{
+		{
+			name:     "5xx fails over",
+			first:    replyCode(sip.StatusServiceUnavailable, "Service Unavailable"),
+			second:   replyCode(sip.StatusBusyHere, "Busy Here"),
+			answered: "second",
+			code:     sip.StatusBusyHere,
+		},
+		{
+			name:     "timeout fails over",
+			first:    func(*sip.Request, int32) *sip.Response { return nil },
+			second:   replyCode(sip.StatusRinging, "Ringing"),
+			answered: "second",
+			code:     sip.StatusRinging,
+		},
+		{
+			name:     "non-5xx answer stops failover",
+			first:    replyCode(sip.StatusNotFound, "Not Found"),
+			second:   replyCode(sip.StatusRinging, "Ringing"),
+			answered: "first",
+			code:     sip.StatusNotFound,
+		},
+		{
+			name:   "all trunks fail",
+			first:  replyCode(sip.StatusInternalServerError, "Server Error"),
+			second: replyCode(sip.StatusBadGateway, "Bad Gateway"),
+			code:   sip.StatusBadGateway,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTrunkTestServer(t)
+			first := startFakeTrunk(t, "first", tt.first)
+			second := startFakeTrunk(t, "second", tt.second)
+
+			a := s.dialTrunks(newTrunkTestCtx(), []*trunk.Trunk{first.t, second.t}, "84951234567")
+
+			got := ""
+			if a.trunk != nil {
+				got = a.trunk.Name
+				if a.resp == nil || a.clTx == nil {
+					t.Fatalf("answered trunk without response or transaction")
+				}
+				a.code = int(a.resp.StatusCode)
+				a.clTx.Terminate()
+			}
+			if got != tt.answered || a.code != tt.code {
+				t.Errorf("answered=%q code=%d, want answered=%q code=%d", got, a.code, tt.answered, tt.code)
+			}
+
+			// канал занят только у ответившего транка
+			for _, ft := range []*fakeTrunk{first, second} {
+				want := 0
+				if ft.t.Name == tt.answered {
+					want = 1
+				}
+				if used := s.trunkChannels.used[ft.t.Name]; used != want {
+					t.Errorf("trunk %s channels used=%d, want %d", ft.t.Name, used, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDialTrunksDigestRetry(t *testing.T) {
+	setTrunkTimeout(t, time.Second)
+
+	challenge := func(req *sip.Request, n int32) *sip.Response {
+		if req.GetHeader("Authorization") != nil {
+			return sip.NewResponseFromRequest(req, sip.StatusRinging, "Ringing", nil)
+		}
+		res := sip.NewResponseFromRequest(req, sip.StatusUnauthorized, "Unauthorized", nil)
+		res.AppendHeader(sip.NewHeader("WWW-Authenticate", `Digest realm="prov", nonce="abc", algorithm=MD5`))
+		return res
+	}
+	alwaysChallenge := func(req *sip.Request, n int32) *sip.Response {
+		res := sip.NewResponseFromRequest(req, sip.StatusUnauthorized, "Unauthorized", nil)
+		res.AppendHeader(sip.NewHeader("WWW-Authenticate", `Digest realm="prov", nonce="abc", algorithm=MD5`))
+		return res
+	}
+	// 401 без WWW-Authenticate: ответить на вызов нечем, TransactionDigestAuth падает
+	brokenChallenge := replyCode(sip.StatusUnauthorized, "Unauthorized")
+
+	tests := []struct {
+		name         string
+		reply        func(*sip.Request, int32) *sip.Response
+		username     string
+		answered     string
+		firstInvites int32
+	}{
+		{"retry with credentials", challenge, "acc", "first", 2},
+		{"only one retry", alwaysChallenge, "acc", "second", 2},
+		{"no credentials fails over", challenge, "", "second", 1},
+		{"digest error fails over", brokenChallenge, "acc", "second", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTrunkTestServer(t)
+			first := startFakeTrunk(t, "first", tt.reply)
+			first.t.Username, first.t.Password = tt.username, "secret"
+			second := startFakeTrunk(t, "second", replyCode(sip.StatusRinging, "Ringing"))
+
+			a := s.dialTrunks(newTrunkTestCtx(), []*trunk.Trunk{first.t, second.t}, "84951234567")
+			if a.trunk == nil {
+				t.Fatalf("no trunk answered, last code %d", a.code)
+			}
+			a.clTx.Terminate()
+			if a.trunk.Name != tt.answered {
+				t.Errorf("answered=%s, want %s", a.trunk.Name, tt.answered)
+			}
+			if a.resp.StatusCode == sip.StatusUnauthorized {
+				t.Errorf("401 relayed as an answer")
+			}
+			if n := first.invites.Load(); n != tt.firstInvites {
+				t.Errorf("first trunk got %d INVITEs, want %d", n, tt.firstInvites)
+			}
+		})
+	}
+}
+
+func TestDialTrunksBusyChannels(t *testing.T) {
+	s := newTrunkTestServer(t)
+	first := startFakeTrunk(t, "first", replyCode(sip.StatusRinging, "Ringing"))
+	second := startFakeTrunk(t, "second", replyCode(sip.StatusRinging, "Ringing"))
+	first.t.MaxChannels = 1
+
+	if !s.trunkChannels.Acquire("first", 1) {
+		t.Fatal("first channel not acquired")
+	}
+	a := s.dialTrunks(newTrunkTestCtx(), []*trunk.Trunk{first.t, second.t}, "84951234567")
+	if a.trunk == nil || a.trunk.Name != "second" {
+		t.Fatalf("answered %+v, want second", a.trunk)
+	}
+	a.clTx.Terminate()
+	if n := first.invites.Load(); n != 0 {
+		t.Errorf("busy trunk got %d INVITEs", n)
+	}
+}
+
+func TestChannelCounter(t *testing.T) {
+	c := newChannelCounter()
+
+	steps := []struct {
+		op   string
+		max  int
+		ok   bool
+		used int
+	}{
+		{"acquire", 2, true, 1},
+		{"acquire", 2, true, 2},
+		{"acquire", 2, false, 2},
+		{"release", 0, true, 1},
+		{"acquire", 2, true, 2},
+		{"release", 0, true, 1},
+		{"release", 0, true, 0},
+		{"release", 0, true, 0}, // лишний Release не уводит счётчик в минус
+		{"acquire", 0, true, 1}, // 0 — без ограничения
+	}
+	for i, st := range steps {
+		ok := true
+		if st.op == "acquire" {
+			ok = c.Acquire("prov", st.max)
+		} else {
+			c.Release("prov")
+		}
+		if ok != st.ok || c.used["prov"] != st.used {
+			t.Errorf("step %d %s: ok=%v used=%d, want ok=%v used=%d", i, st.op, ok, c.used["prov"], st.ok, st.used)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package sipserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"SipServer/internal/media"
+	"SipServer/internal/repository"
+	userrepo "SipServer/internal/repository/user"
+	"SipServer/internal/repository/voicemail"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+const (
+	VoicemailAccessCode = "*97"
+
+	defaultVoicemailDir      = "./data/voicemail"
+	defaultVoicemailNoAnswer = 25 * time.Second
+	voicemailMaxLength       = 3 * time.Minute
+)
+
+type voicemailConfig struct {
+	dir      string
+	greeting string
+	noAnswer time.Duration
+}
+
+func newVoicemailConfig() voicemailConfig {
+	c := voicemailConfig{
+		dir:      os.Getenv("VOICEMAIL_DIR"),
+		greeting: os.Getenv("VOICEMAIL_GREETING"),
+		noAnswer: defaultVoicemailNoAnswer,
+	}
+	if c.dir == "" {
+		c.dir = defaultVoicemailDir
+	}
+	if v, err := strconv.Atoi(os.Getenv("VOICEMAIL_NO_ANSWER")); err == nil && v > 0 {
+		c.noAnswer = time.Duration(v) * time.Second
+	}
+	return c
+}
+
+// isVoicemailCode — ответы, после которых звонок уходит в голосовую почту.
+func isVoicemailCode(code int) bool {
+	switch code {
+	case sip.StatusBusyHere, sip.StatusTemporarilyUnavailable, sip.StatusRequestTimeout, sip.StatusGlobalDecline:
+		return true
+	}
+	return false
+}
+
+// proxyWithVoicemail — как proxyInviteResponses, но занятость, отказ и
+// неответ за VOICEMAIL_NO_ANSWER уводят звонок в голосовую почту.
+// Остальные ответы отдаются relay.
+func (s *Server) proxyWithVoicemail(ictx *InviteCtx, clTx sip.ClientTransaction, owner *userrepo.User, relay func(*sip.Response)) {
+	timer := time.NewTimer(s.voicemail.noAnswer)
+	defer timer.Stop()
+
+	for {
+		select {
+		case resp := <-clTx.Responses():
+			code := int(resp.StatusCode)
+			if isVoicemailCode(code) && !ictx.Cancelled.Load() && !ictx.Diverted.Load() {
+				log.Printf("[VOICEMAIL] callee=%s answered %d, diverting", owner.Login, code)
+				s.toVoicemail(ictx, owner)
+				continue
+			}
+			relay(resp)
+
+		case <-timer.C:
+			if ictx.DialogCreated.Load() || ictx.Cancelled.Load() || ictx.Diverted.Load() {
+				continue
+			}
+			log.Printf("[VOICEMAIL] callee=%s no answer in %s, diverting", owner.Login, s.voicemail.noAnswer)
+			if cancel := buildCancel(ictx.OutInvite); cancel != nil {
+				_, _ = s.cl.TransactionRequest(context.Background(), cancel)
+			}
+			s.toVoicemail(ictx, owner)
+
+		case <-clTx.Done():
+			return
+		}
+	}
+}
+
+// toVoicemail отвечает caller'у голосовой почтой owner.
+func (s *Server) toVoicemail(ictx *InviteCtx, owner *userrepo.User) {
+	if !ictx.Diverted.CompareAndSwap(false, true) {
+		return
+	}
+	s.ringing.remove(ictx)
+	s.discardRecording(ictx.Recording)
+	s.endEarly(ictx, 0, "")
+
+	lc := s.answerLocalOrReject(ictx)
+	if lc == nil {
+		return
+	}
+	go s.recordVoicemail(lc, owner)
+}
+
+func (s *Server) recordVoicemail(lc *localCall, owner *userrepo.User) {
+	_ = lc.media.Play(lc.ctx, s.voicemailGreeting(owner.Login), false)
+	_ = lc.media.Play(lc.ctx, media.Tone(1000, 300*time.Millisecond, 8000), false)
+	if lc.ctx.Err() != nil {
+		// положили трубку во время приветствия
+		return
+	}
+
+	dir := filepath.Join(s.voicemail.dir, owner.Login)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("[VOICEMAIL] mkdir %s: %v", dir, err)
+		s.endLocal(lc, repository.CallEndedBySystem, true)
+		return
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%d.wav", time.Now().UnixNano()))
+	w, err := media.CreateWAV(path, 1)
+	if err != nil {
+		log.Printf("[VOICEMAIL] create %s: %v", path, err)
+		s.endLocal(lc, repository.CallEndedBySystem, true)
+		return
+	}
+
+	var mu sync.Mutex
+	lc.setReceiver(func(p *media.Packet) {
+		codec, ok := media.CodecByPayloadType(p.PayloadType)
+		if !ok {
+			return
+		}
+		mu.Lock()
+		_ = w.Write(codec.Decode(p.Payload))
+		mu.Unlock()
+	})
+
+	select {
+	case <-lc.ctx.Done():
+	case <-time.After(voicemailMaxLength):
+		s.endLocal(lc, repository.CallEndedBySystem, true)
+	}
+	lc.setReceiver(nil)
+
+	mu.Lock()
+	duration := w.Duration()
+	err = w.Close()
+	mu.Unlock()
+	if err != nil {
+		log.Printf("[VOICEMAIL] close %s: %v", path, err)
+		return
+	}
+
+	var journalID *int64
+	if lc.leg.JournalID != 0 {
+		journalID = &lc.leg.JournalID
+	}
+	msg, err := s.voicemailRepo.Create(context.Background(), &voicemail.Message{
+		UserId:     owner.Id,
+		JournalId:  journalID,
+		CallerUser: lc.leg.User,
+		FilePath:   path,
+		DurationMs: int(duration.Milliseconds()),
+	})
+	if err != nil {
+		log.Printf("[VOICEMAIL] store message failed: %v", err)
+		return
+	}
+	log.Printf("[VOICEMAIL] user=%s message=%d from=%s %s", owner.Login, msg.Id, msg.CallerUser, duration)
+}
+
+// voicemailGreeting: <VOICEMAIL_DIR>/greetings/<login>.wav, затем VOICEMAIL_GREETING,
+// иначе — короткий сигнал.
+func (s *Server) voicemailGreeting(login string) []int16 {
+	for _, path := range []string{
+		filepath.Join(s.voicemail.dir, "greetings", login+".wav"),
+		s.voicemail.greeting,
+	} {
+		if path == "" {
+			continue
+		}
+		if samples, err := media.ReadWAV(path); err == nil {
+			return samples
+		}
+	}
+
+	greeting := media.Tone(440, 400*time.Millisecond, 4000)
+	greeting = append(greeting, media.Silence(200*time.Millisecond)...)
+	return append(greeting, media.Tone(440, 400*time.Millisecond, 4000)...)
+}
+
+// voicemailAccess (*97) проигрывает сообщения звонящему: сначала новые.
+// Ящик — только проверенного digest пользователя: без пароля From подделывается.
+func (s *Server) voicemailAccess(ictx *InviteCtx) {
+	user := ictx.Caller
+	if user == nil || user.SIPHA1 == "" {
+		log.Printf("[VOICEMAIL] access denied: caller without digest credentials")
+		s.failInvite(ictx, sip.StatusForbidden, "Forbidden")
+		return
+	}
+	login := user.Login
+
+	lc := s.answerLocalOrReject(ictx)
+	if lc == nil {
+		return
+	}
+
+	go func() {
+		msgs, err := s.voicemailRepo.ListForPlayback(lc.ctx, user.Id)
+		if err != nil {
+			log.Printf("[VOICEMAIL] list user=%s: %v", login, err)
+		}
+		if len(msgs) == 0 {
+			_ = lc.media.Play(lc.ctx, media.Tone(300, time.Second, 4000), false)
+		}
+
+		for _, m := range msgs {
+			samples, err := media.ReadWAV(m.FilePath)
+			if err != nil {
+				log.Printf("[VOICEMAIL] read %s: %v", m.FilePath, err)
+				continue
+			}
+			_ = lc.media.Play(lc.ctx, media.Tone(1000, 200*time.Millisecond, 8000), false)
+			if err := lc.media.Play(lc.ctx, samples, false); err != nil {
+				return
+			}
+			if !m.Heard {
+				if err := s.voicemailRepo.MarkHeard(context.Background(), m.Id); err != nil {
+					log.Printf("[VOICEMAIL] MarkHeard %d: %v", m.Id, err)
+				}
+			}
+			_ = lc.media.Play(lc.ctx, media.Silence(700*time.Millisecond), false)
+		}
+
+		s.endLocal(lc, repository.CallEndedBySystem, true)
+	}()
+}
@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+
+	aclrule "SipServer/internal/repository/acl_rule"
+)
+
+// ACLUsecase — сетевые ACL. SIP- и HTTP-сервер перечитывают правила раз в несколько секунд.
+type ACLUsecase struct {
+	repo *aclrule.ACLRuleRepo
+}
+
+func NewACLUsecase(db *sql.DB) *ACLUsecase {
+	return &ACLUsecase{
+		repo: aclrule.NewACLRuleRepo(db),
+	}
+}
+
+func (a *ACLUsecase) List() ([]*aclrule.Rule, error) {
+	return a.repo.List(context.Background())
+}
+
+func (a *ACLUsecase) Get(id string) (*aclrule.Rule, error) {
+	return a.repo.FindByID(id)
+}
+
+func (a *ACLUsecase) Create(rule *aclrule.Rule) (*aclrule.Rule, error) {
+	return a.repo.Create(rule)
+}
+
+func (a *ACLUsecase) Update(id string, rule *aclrule.Rule) (*aclrule.Rule, error) {
+	return a.repo.Update(id, rule)
+}
+
+func (a *ACLUsecase) Delete(id string) error {
+	return a.repo.Delete(id)
+}
@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	apitoken "SipServer/internal/repository/api_token"
+	"SipServer/internal/repository/user"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const defaultSessionTTL = 12 * time.Hour
+
+var ErrInvalidCredentials = errors.New("invalid login or password")
+
+// на случай неизвестного логина: сравнение занимает столько же, сколько с настоящим хешем
+var dummyHash = []byte("$2a$10$GErKdLPVXJg9g8MdfyQbeucTx0YuVXzMqfZEFfk5wZtWXbvZyAUsW")
+
+// AuthUsecase — вход в HTTP API: сессии по логину и паролю и ключи API.
+type AuthUsecase struct {
+	userRepo   *user.UserRepositoriy
+	tokenRepo  *apitoken.APITokenRepo
+	sessionTTL time.Duration
+}
+
+// Session — выданный при входе токен.
+type Session struct {
+	Token     string              `json:"token"`
+	ExpiresAt time.Time           `json:"expires_at"`
+	User      *apitoken.Principal `json:"user"`
+}
+
+// APIKey — созданный ключ; сам токен показывается только один раз.
+type APIKey struct {
+	*apitoken.Token
+	Secret string `json:"token"`
+}
+
+func NewAuthUsecase(db *sql.DB) *AuthUsecase {
+	a := &AuthUsecase{
+		userRepo:   user.NewUserRepo(db),
+		tokenRepo:  apitoken.NewAPITokenRepo(db),
+		sessionTTL: defaultSessionTTL,
+	}
+	if v, err := strconv.Atoi(os.Getenv("SESSION_TTL")); err == nil && v > 0 {
+		a.sessionTTL = time.Duration(v) * time.Minute
+	}
+	return a
+}
+
+// HashPassword — bcrypt-хеш пароля для users.password_hash.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (a *AuthUsecase) Login(login, password string) (*Session, error) {
+	ctx := context.Background()
+
+	u, err := a.userRepo.FindCredentials(strings.TrimSpace(login))
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil || !u.Enabled {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := a.tokenRepo.DeleteExpired(ctx); err != nil {
+		log.Printf("[AUTH] delete expired tokens: %v", err)
+	}
+
+	secret, hash, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(a.sessionTTL)
+	t := &apitoken.Token{UserId: u.Id, Kind: apitoken.KindSession}
+	if t, err = a.tokenRepo.Create(ctx, t, hash, &expiresAt); err != nil {
+		return nil, err
+	}
+	return &Session{
+		Token:     secret,
+		ExpiresAt: expiresAt,
+		User:      &apitoken.Principal{TokenId: t.Id, Kind: t.Kind, UserId: u.Id, Login: u.Login, Role: u.Role},
+	}, nil
+}
+
+// Logout отзывает токен, с которым пришёл запрос.
+func (a *AuthUsecase) Logout(p *apitoken.Principal) error {
+	return a.tokenRepo.Delete(context.Background(), strconv.Itoa(p.TokenId), apitoken.KindSession)
+}
+
+// Authenticate — владелец токена из Authorization / X-API-Key.
+func (a *AuthUsecase) Authenticate(token string) (*apitoken.Principal, error) {
+	return a.tokenRepo.Use(context.Background(), hashToken(token))
+}
+
+func (a *AuthUsecase) ListAPIKeys() ([]*apitoken.Token, error) {
+	return a.tokenRepo.ListAPIKeys(context.Background())
+}
+
+func (a *AuthUsecase) CreateAPIKey(t *apitoken.Token) (*APIKey, error) {
+	secret, hash, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	var expiresAt *time.Time
+	if t.TTLDays > 0 {
+		at := time.Now().AddDate(0, 0, t.TTLDays)
+		expiresAt = &at
+	}
+	t.Kind = apitoken.KindAPIKey
+	if t, err = a.tokenRepo.Create(context.Background(), t, hash, expiresAt); err != nil {
+		return nil, err
+	}
+	return &APIKey{Token: t, Secret: secret}, nil
+}
+
+func (a *AuthUsecase) DeleteAPIKey(id string) error {
+	return a.tokenRepo.Delete(context.Background(), id, apitoken.KindAPIKey)
+}
+
+// Bootstrap создаёт администратора из ADMIN_LOGIN / ADMIN_PASSWORD, если такого
+// пользователя ещё нет: иначе в свежую установку не войти.
+func (a *AuthUsecase) Bootstrap() error {
+	login, password := os.Getenv("ADMIN_LOGIN"), os.Getenv("ADMIN_PASSWORD")
+	if login == "" || password == "" {
+		return nil
+	}
+	if _, err := a.userRepo.FindByLogin(login); err == nil || !errors.Is(err, user.ErrUserNotFound) {
+		return err
+	}
+
+	admin := user.NewUser()
+	admin.Login = login
+	admin.Role = user.RoleAdmin
+	admin.Password = password
+	admin.Config.CallSchema = "proxy"
+	if err := setCredentials(admin); err != nil {
+		return err
+	}
+	if _, err := a.userRepo.CreateUserWithConfig(admin); err != nil {
+		return err
+	}
+	log.Printf("[AUTH] admin %s created", login)
+	return nil
+}
+
+// newToken — случайный токен и его sha256 для хранения.
+func newToken() (secret, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret = hex.EncodeToString(b)
+	return secret, hashToken(secret), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
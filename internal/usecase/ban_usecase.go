@@ -0,0 +1,31 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+
+	"SipServer/internal/repository/ban"
+)
+
+// BanUsecase — баны SIP по IP. SIP-сервер перечитывает список раз в несколько секунд.
+type BanUsecase struct {
+	repo *ban.BanRepo
+}
+
+func NewBanUsecase(db *sql.DB) *BanUsecase {
+	return &BanUsecase{
+		repo: ban.NewBanRepo(db),
+	}
+}
+
+func (b *BanUsecase) List() ([]*ban.Ban, error) {
+	return b.repo.List(context.Background())
+}
+
+func (b *BanUsecase) Create(item *ban.Ban) (*ban.Ban, error) {
+	return b.repo.Create(context.Background(), item)
+}
+
+func (b *BanUsecase) Delete(id string) error {
+	return b.repo.Delete(context.Background(), id)
+}
@@ -1,7 +1,9 @@
 package usecase
 
 import (
+	"context"
 	"database/sql"
+	"os"
 
 	calljournal "SipServer/internal/repository/call_journal"
 )
@@ -19,3 +21,27 @@ func NewCallJournalUsecase(db *sql.DB) *CallJournalUsecase {
 func (c *CallJournalUsecase) List() ([]calljournal.CallJournal, error) {
 	return c.repo.List()
 }
+
+// ListByUser — история звонков одного пользователя.
+func (c *CallJournalUsecase) ListByUser(login string) ([]calljournal.CallJournal, error) {
+	return c.repo.ListByUser(login)
+}
+
+// Recording — путь к записи разговора.
+func (c *CallJournalUsecase) Recording(id string) (string, error) {
+	return c.repo.RecordingPath(context.Background(), id)
+}
+
+// DeleteRecording удаляет файл записи и ссылку на него из журнала.
+func (c *CallJournalUsecase) DeleteRecording(id string) error {
+	ctx := context.Background()
+
+	path, err := c.repo.RecordingPath(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return c.repo.ClearRecording(ctx, id)
+}
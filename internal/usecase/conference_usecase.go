@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"database/sql"
+
+	"SipServer/internal/repository/conference"
+)
+
+type ConferenceUsecase struct {
+	repo *conference.ConferenceRepo
+}
+
+func NewConferenceUsecase(db *sql.DB) *ConferenceUsecase {
+	return &ConferenceUsecase{
+		repo: conference.NewConferenceRepo(db),
+	}
+}
+
+func (c *ConferenceUsecase) List() ([]*conference.Room, error) {
+	return c.repo.List()
+}
+
+func (c *ConferenceUsecase) Get(id string) (*conference.Room, error) {
+	return c.repo.FindByID(id)
+}
+
+func (c *ConferenceUsecase) Create(room *conference.Room) (*conference.Room, error) {
+	return c.repo.Create(room)
+}
+
+func (c *ConferenceUsecase) Update(id string, room *conference.Room) (*conference.Room, error) {
+	return c.repo.Update(id, room)
+}
+
+func (c *ConferenceUsecase) Delete(id string) error {
+	return c.repo.Delete(id)
+}
+
+func (c *ConferenceUsecase) Participants(roomID string) ([]*conference.Participant, error) {
+	if _, err := c.repo.FindByID(roomID); err != nil {
+		return nil, err
+	}
+	return c.repo.Participants(roomID)
+}
+
+func (c *ConferenceUsecase) UpdateParticipant(roomID, id string, req *conference.UpdateParticipantRequest) (*conference.Participant, error) {
+	return c.repo.SetMuted(roomID, id, req.Muted)
+}
+
+func (c *ConferenceUsecase) Kick(roomID, id string) error {
+	return c.repo.Kick(roomID, id)
+}
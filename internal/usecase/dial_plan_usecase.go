@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"database/sql"
+
+	dialplan "SipServer/internal/repository/dial_plan"
+	"SipServer/internal/routing"
+)
+
+type DialPlanUsecase struct {
+	repo *dialplan.DialPlanRepo
+}
+
+func NewDialPlanUsecase(db *sql.DB) *DialPlanUsecase {
+	return &DialPlanUsecase{
+		repo: dialplan.NewDialPlanRepo(db),
+	}
+}
+
+func (d *DialPlanUsecase) List() ([]*dialplan.Rule, error) {
+	return d.repo.List()
+}
+
+func (d *DialPlanUsecase) Get(id string) (*dialplan.Rule, error) {
+	return d.repo.FindByID(id)
+}
+
+func (d *DialPlanUsecase) Create(rule *dialplan.Rule) (*dialplan.Rule, error) {
+	if err := routing.ValidatePattern(rule.MatchType, rule.Pattern); err != nil {
+		return nil, err
+	}
+	return d.repo.Create(rule)
+}
+
+func (d *DialPlanUsecase) Update(id string, rule *dialplan.Rule) (*dialplan.Rule, error) {
+	if err := routing.ValidatePattern(rule.MatchType, rule.Pattern); err != nil {
+		return nil, err
+	}
+	return d.repo.Update(id, rule)
+}
+
+func (d *DialPlanUsecase) Delete(id string) error {
+	return d.repo.Delete(id)
+}
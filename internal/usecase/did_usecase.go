@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"database/sql"
+
+	"SipServer/internal/repository/did"
+)
+
+type DIDUsecase struct {
+	repo *did.DIDRepo
+}
+
+func NewDIDUsecase(db *sql.DB) *DIDUsecase {
+	return &DIDUsecase{
+		repo: did.NewDIDRepo(db),
+	}
+}
+
+func (d *DIDUsecase) List() ([]*did.DID, error) {
+	return d.repo.List()
+}
+
+func (d *DIDUsecase) Get(id string) (*did.DID, error) {
+	return d.repo.FindByID(id)
+}
+
+func (d *DIDUsecase) Create(item *did.DID) (*did.DID, error) {
+	return d.repo.Create(item)
+}
+
+func (d *DIDUsecase) Update(id string, item *did.DID) (*did.DID, error) {
+	return d.repo.Update(id, item)
+}
+
+func (d *DIDUsecase) Delete(id string) error {
+	return d.repo.Delete(id)
+}
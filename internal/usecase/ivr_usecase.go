@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"database/sql"
+
+	"SipServer/internal/repository/ivr"
+)
+
+type IVRUsecase struct {
+	repo *ivr.IVRRepo
+}
+
+func NewIVRUsecase(db *sql.DB) *IVRUsecase {
+	return &IVRUsecase{
+		repo: ivr.NewIVRRepo(db),
+	}
+}
+
+func (i *IVRUsecase) List() ([]*ivr.Menu, error) {
+	return i.repo.List()
+}
+
+func (i *IVRUsecase) Get(id string) (*ivr.Menu, error) {
+	return i.repo.FindByID(id)
+}
+
+func (i *IVRUsecase) Create(m *ivr.Menu) (*ivr.Menu, error) {
+	return i.repo.Create(m)
+}
+
+func (i *IVRUsecase) Update(id string, m *ivr.Menu) (*ivr.Menu, error) {
+	return i.repo.Update(id, m)
+}
+
+func (i *IVRUsecase) Delete(id string) error {
+	return i.repo.Delete(id)
+}
@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"database/sql"
+
+	"SipServer/internal/repository/message"
+	"SipServer/internal/repository/user"
+)
+
+const (
+	defaultMessageLimit = 100
+	maxMessageLimit     = 1000
+)
+
+type MessageUsecase struct {
+	repo     *message.MessageRepo
+	userRepo *user.UserRepositoriy
+}
+
+func NewMessageUsecase(db *sql.DB) *MessageUsecase {
+	return &MessageUsecase{
+		repo:     message.NewMessageRepo(db),
+		userRepo: user.NewUserRepo(db),
+	}
+}
+
+// History — сообщения пользователя; peer — только переписка с этим login.
+func (m *MessageUsecase) History(userID, peer string, limit int) ([]*message.Message, error) {
+	u, err := m.userRepo.FindByIDWithConfig(userID)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxMessageLimit {
+		limit = defaultMessageLimit
+	}
+	return m.repo.ListByUser(u.Login, peer, limit)
+}
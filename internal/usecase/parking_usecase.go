@@ -0,0 +1,21 @@
+package usecase
+
+import (
+	"database/sql"
+
+	"SipServer/internal/repository/parking"
+)
+
+type ParkingUsecase struct {
+	repo *parking.ParkingRepo
+}
+
+func NewParkingUsecase(db *sql.DB) *ParkingUsecase {
+	return &ParkingUsecase{
+		repo: parking.NewParkingRepo(db),
+	}
+}
+
+func (p *ParkingUsecase) List() ([]*parking.ParkedCall, error) {
+	return p.repo.List()
+}
@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"database/sql"
+
+	ringgroup "SipServer/internal/repository/ring_group"
+)
+
+type RingGroupUsecase struct {
+	repo *ringgroup.RingGroupRepo
+}
+
+func NewRingGroupUsecase(db *sql.DB) *RingGroupUsecase {
+	return &RingGroupUsecase{
+		repo: ringgroup.NewRingGroupRepo(db),
+	}
+}
+
+func (r *RingGroupUsecase) List() ([]*ringgroup.Group, error) {
+	return r.repo.List()
+}
+
+func (r *RingGroupUsecase) Get(id string) (*ringgroup.Group, error) {
+	return r.repo.FindByID(id)
+}
+
+func (r *RingGroupUsecase) Create(g *ringgroup.Group) (*ringgroup.Group, error) {
+	return r.repo.Create(g)
+}
+
+func (r *RingGroupUsecase) Update(id string, g *ringgroup.Group) (*ringgroup.Group, error) {
+	return r.repo.Update(id, g)
+}
+
+func (r *RingGroupUsecase) Delete(id string) error {
+	return r.repo.Delete(id)
+}
@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"database/sql"
+
+	"SipServer/internal/repository/trunk"
+)
+
+type TrunkUsecase struct {
+	repo *trunk.TrunkRepo
+}
+
+func NewTrunkUsecase(db *sql.DB) *TrunkUsecase {
+	return &TrunkUsecase{
+		repo: trunk.NewTrunkRepo(db),
+	}
+}
+
+func (t *TrunkUsecase) List() ([]*trunk.Trunk, error) {
+	trunks, err := t.repo.List()
+	for _, tr := range trunks {
+		tr.Password = ""
+	}
+	return trunks, err
+}
+
+func (t *TrunkUsecase) Get(id string) (*trunk.Trunk, error) {
+	return hidePassword(t.repo.FindByID(id))
+}
+
+func (t *TrunkUsecase) Create(tr *trunk.Trunk) (*trunk.Trunk, error) {
+	return hidePassword(t.repo.Create(tr))
+}
+
+func (t *TrunkUsecase) Update(id string, tr *trunk.Trunk) (*trunk.Trunk, error) {
+	return hidePassword(t.repo.Update(id, tr))
+}
+
+func (t *TrunkUsecase) Delete(id string) error {
+	return t.repo.Delete(id)
+}
+
+// пароль транка наружу не отдаём
+func hidePassword(tr *trunk.Trunk, err error) (*trunk.Trunk, error) {
+	if tr != nil {
+		tr.Password = ""
+	}
+	return tr, err
+}
@@ -1,17 +1,73 @@
 package usecase
 
 import (
-	"SipServer/internal/repository/user"
+	"context"
+	"crypto/rand"
 	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	calljournal "SipServer/internal/repository/call_journal"
+	"SipServer/internal/repository/user"
+	"SipServer/internal/repository/voicemail"
+	"SipServer/internal/sipauth"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrWrongPassword = errors.New("current password is wrong")
+	// HA1 digest включает login: после переименования старый пароль в SIP не подойдёт
+	ErrLoginNeedsPassword = errors.New("login change requires a new password")
+)
+
+// Bindings — регистрации SIP (registrar), их снимаем при удалении и выключении.
+type Bindings interface {
+	Delete(login string)
+}
+
 type UserUsecase struct {
-	userRepo *user.UserRepositoriy
+	userRepo      *user.UserRepositoriy
+	journalRepo   *calljournal.CallJournalRepo
+	voicemailRepo *voicemail.VoicemailRepo
+	bindings      Bindings
+}
+
+// UserCalls — лимиты одновременных звонков пользователя и сколько их сейчас.
+type UserCalls struct {
+	MaxOutboundCalls int                     `json:"max_outbound_calls"`
+	MaxInboundCalls  int                     `json:"max_inbound_calls"`
+	MaxCalls         int                     `json:"max_calls"`
+	Active           calljournal.ActiveCalls `json:"active"`
+}
+
+// ImportError — ошибки импорта по строкам ("row 3" -> причина); не создан никто.
+type ImportError struct {
+	Rows map[string]string
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("import failed in %d rows", len(e.Rows))
 }
 
-func NewUserUseCase(db *sql.DB) *UserUsecase {
+// ImportRow — строка импорта: номер в исходном файле и пользователь из неё.
+type ImportRow struct {
+	Line int
+	User *user.User
+}
+
+func NewUserUseCase(db *sql.DB, bindings Bindings) *UserUsecase {
 	return &UserUsecase{
-		userRepo: user.NewUserRepo(db),
+		userRepo:      user.NewUserRepo(db),
+		journalRepo:   calljournal.NewCallJournalRepo(db),
+		voicemailRepo: voicemail.NewVoicemailRepo(db),
+		bindings:      bindings,
 	}
 }
 
@@ -24,9 +80,214 @@ func (u *UserUsecase) GetUser(id string) (*user.User, error) {
 }
 
 func (u *UserUsecase) CreateUser(user *user.User) (*user.User, error) {
+	if err := setCredentials(user); err != nil {
+		return nil, err
+	}
 	return u.userRepo.CreateUserWithConfig(user)
 }
 
 func (u *UserUsecase) UpdateUser(user_id string, arg *user.UpdateUserRequest) error {
-	return u.userRepo.UpdateUser(user_id, arg)
+	current, err := u.userRepo.FindByIDWithConfig(user_id)
+	if err != nil {
+		return err
+	}
+	if arg.Login != "" && arg.Login != current.Login && current.SIPHA1 != "" {
+		return ErrLoginNeedsPassword
+	}
+
+	if err := u.userRepo.UpdateUser(user_id, arg); err != nil {
+		return err
+	}
+	if arg.Enabled != nil && !*arg.Enabled {
+		u.bindings.Delete(current.Login)
+	}
+	return nil
+}
+
+// DeleteUser удаляет пользователя, его записи голосовой почты и регистрацию.
+// Журнал звонков остаётся.
+func (u *UserUsecase) DeleteUser(id string) error {
+	usr, err := u.userRepo.FindByIDWithConfig(id)
+	if err != nil {
+		return err
+	}
+	messages, err := u.voicemailRepo.ListByUser(id)
+	if err != nil {
+		return err
+	}
+
+	if err := u.userRepo.Delete(id); err != nil {
+		return err
+	}
+	u.bindings.Delete(usr.Login)
+
+	for _, m := range messages {
+		if err := os.Remove(m.FilePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("[USER] delete voicemail %s: %v", m.FilePath, err)
+		}
+	}
+	return nil
+}
+
+// SetPassword задаёт пароль (API и SIP digest). Пользователь меняет свой
+// пароль, только назвав текущий; администратору это не нужно.
+func (u *UserUsecase) SetPassword(id, password, current string, checkCurrent bool) error {
+	usr, err := u.userRepo.FindByIDWithConfig(id)
+	if err != nil {
+		return err
+	}
+	if checkCurrent {
+		creds, err := u.userRepo.FindCredentials(usr.Login)
+		if err != nil {
+			return err
+		}
+		if bcrypt.CompareHashAndPassword([]byte(creds.PasswordHash), []byte(current)) != nil {
+			return ErrWrongPassword
+		}
+	}
+
+	usr.Password = password
+	if err := setCredentials(usr); err != nil {
+		return err
+	}
+	return u.userRepo.SetPassword(id, usr.PasswordHash, usr.SIPHA1)
+}
+
+// ResetPassword задаёт случайный пароль и возвращает его (показывается один раз).
+func (u *UserUsecase) ResetPassword(id string) (string, error) {
+	password, err := randomPassword(12)
+	if err != nil {
+		return "", err
+	}
+	if err := u.SetPassword(id, password, "", false); err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// Import создаёт пользователей одной транзакцией. Строки проверяются все сразу:
+// повторы логинов в файле и уже занятые логины попадают в ImportError.
+func (u *UserUsecase) Import(rows []ImportRow) ([]*user.User, error) {
+	rowErrors := make(map[string]string)
+	seen := make(map[string]int)
+	logins := make([]string, 0, len(rows))
+	for _, r := range rows {
+		if prev, ok := seen[r.User.Login]; ok {
+			rowErrors[rowKey(r.Line)] = fmt.Sprintf("login %s duplicates row %d", r.User.Login, prev)
+			continue
+		}
+		seen[r.User.Login] = r.Line
+		logins = append(logins, r.User.Login)
+	}
+
+	existing, err := u.userRepo.ExistingLogins(logins)
+	if err != nil {
+		return nil, err
+	}
+	for _, login := range existing {
+		rowErrors[rowKey(seen[login])] = fmt.Sprintf("login %s already exists", login)
+	}
+	if len(rowErrors) > 0 {
+		return nil, &ImportError{Rows: rowErrors}
+	}
+
+	users := make([]*user.User, len(rows))
+	for i, r := range rows {
+		users[i] = r.User
+	}
+	if err := setCredentialsAll(users); err != nil {
+		return nil, err
+	}
+
+	if failed, err := u.userRepo.CreateUsers(users); err != nil {
+		if failed < 0 {
+			return nil, err
+		}
+		return nil, &ImportError{Rows: map[string]string{rowKey(rows[failed].Line): err.Error()}}
+	}
+	return users, nil
+}
+
+// Calls — лимиты пользователя и его незавершённые звонки по журналу.
+func (u *UserUsecase) Calls(id string) (*UserCalls, error) {
+	usr, err := u.userRepo.FindByIDWithConfig(id)
+	if err != nil {
+		return nil, err
+	}
+	active, err := u.journalRepo.ActiveCalls(context.Background(), usr.Login)
+	if err != nil {
+		return nil, err
+	}
+	return &UserCalls{
+		MaxOutboundCalls: usr.Config.MaxOutboundCalls,
+		MaxInboundCalls:  usr.Config.MaxInboundCalls,
+		MaxCalls:         usr.Config.MaxCalls,
+		Active:           active,
+	}, nil
+}
+
+// setCredentials превращает Password в bcrypt-хеш для API и HA1 для SIP digest.
+func setCredentials(u *user.User) error {
+	if u.Password == "" {
+		return nil
+	}
+	hash, err := HashPassword(u.Password)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash = hash
+	u.SIPHA1 = sipauth.HA1(u.Login, sipauth.Realm(), u.Password)
+	u.Password = ""
+	return nil
+}
+
+// setCredentialsAll — то же для импорта: bcrypt медленный, считаем параллельно.
+func setCredentialsAll(users []*user.User) error {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		first error
+	)
+	jobs := make(chan *user.User)
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for usr := range jobs {
+				if err := setCredentials(usr); err != nil {
+					mu.Lock()
+					if first == nil {
+						first = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, usr := range users {
+		jobs <- usr
+	}
+	close(jobs)
+	wg.Wait()
+	return first
+}
+
+// без похожих символов (0/O, 1/l/I): пароль диктуют и вводят на телефоне
+const passwordAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func randomPassword(n int) (string, error) {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	for range n {
+		i, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(passwordAlphabet[i.Int64()])
+	}
+	return sb.String(), nil
+}
+
+func rowKey(line int) string {
+	return fmt.Sprintf("row %d", line)
 }
@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+
+	"SipServer/internal/repository/user"
+	"SipServer/internal/repository/voicemail"
+)
+
+type VoicemailUsecase struct {
+	repo     *voicemail.VoicemailRepo
+	userRepo *user.UserRepositoriy
+}
+
+func NewVoicemailUsecase(db *sql.DB) *VoicemailUsecase {
+	return &VoicemailUsecase{
+		repo:     voicemail.NewVoicemailRepo(db),
+		userRepo: user.NewUserRepo(db),
+	}
+}
+
+func (v *VoicemailUsecase) List(userID string) ([]*voicemail.Message, error) {
+	return v.repo.ListByUser(userID)
+}
+
+// Audio возвращает сообщение для скачивания и отмечает его прослушанным.
+func (v *VoicemailUsecase) Audio(userID, id string) (*voicemail.Message, error) {
+	msg, err := v.repo.FindByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !msg.Heard {
+		if err := v.repo.MarkHeard(context.Background(), msg.Id); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// Counts — новые/прослушанные сообщения для лампы MWI.
+func (v *VoicemailUsecase) Counts(userID string) (voicemail.Counts, error) {
+	if _, err := v.userRepo.FindByIDWithConfig(userID); err != nil {
+		return voicemail.Counts{}, err
+	}
+	return v.repo.Counts(context.Background(), userID)
+}
+
+// TriggerMWI просит SIP-сервер переотправить NOTIFY message-summary пользователю.
+func (v *VoicemailUsecase) TriggerMWI(userID string) (voicemail.Counts, error) {
+	c, err := v.Counts(userID)
+	if err != nil {
+		return c, err
+	}
+	return c, v.repo.RequestMWI(context.Background(), userID)
+}
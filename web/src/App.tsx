@@ -1,31 +1,74 @@
-import { useMemo, useState } from "react";
+import { useEffect, useMemo, useState } from "react";
 import Users from "./pages/Users";
 import Sessions from "./pages/Sessions";
 import CallJournals from "./pages/CallJournals";
+import RingGroups from "./pages/RingGroups";
+import Parking from "./pages/Parking";
+import Login from "./pages/Login";
+import { apiFetch, getToken, logout, Me, setUnauthorizedHandler } from "./api";
 
-type Tab = "users" | "sessions" | "journals";
+type Tab = "users" | "groups" | "parking" | "sessions" | "journals";
 
 export default function App() {
+  const [me, setMe] = useState<Me | null>(null);
+  const [checking, setChecking] = useState(!!getToken());
   const [tab, setTab] = useState<Tab>("users");
   const title = useMemo(() => {
     if (tab === "users") return "Users";
+    if (tab === "groups") return "Ring groups";
+    if (tab === "parking") return "Parking";
     if (tab === "sessions") return "Sessions";
     return "Call journals";
   }, [tab]);
 
+  useEffect(() => {
+    setUnauthorizedHandler(() => setMe(null));
+    if (!getToken()) return;
+    apiFetch<Me>("/api/auth/me")
+      .then(onLogin)
+      .catch(() => setMe(null))
+      .finally(() => setChecking(false));
+  }, []);
+
+  function onLogin(u: Me) {
+    setMe(u);
+    // обычному пользователю доступна только своя история звонков
+    setTab(u.role === "admin" ? "users" : "journals");
+  }
+
+  async function onLogout() {
+    await logout().catch(() => {});
+    setMe(null);
+  }
+
+  if (checking) return null;
+  if (!me) return <Login onLogin={onLogin} />;
+
+  const admin = me.role === "admin";
+
   return (
     <div className="container">
-      <h1 style={{ marginTop: 8, marginBottom: 10 }}>SipServer Admin</h1>
+      <div className="row" style={{ justifyContent: "space-between", alignItems: "center" }}>
+        <h1 style={{ marginTop: 8, marginBottom: 10 }}>SipServer Admin</h1>
+        <div className="row" style={{ alignItems: "center" }}>
+          <small className="muted">{me.login} ({me.role})</small>
+          <button onClick={onLogout}>Logout</button>
+        </div>
+      </div>
       <div className="tabs">
-        <button className={`tab ${tab === "users" ? "active" : ""}`} onClick={() => setTab("users")}>Users</button>
-        <button className={`tab ${tab === "sessions" ? "active" : ""}`} onClick={() => setTab("sessions")}>Sessions</button>
+        {admin && <button className={`tab ${tab === "users" ? "active" : ""}`} onClick={() => setTab("users")}>Users</button>}
+        {admin && <button className={`tab ${tab === "groups" ? "active" : ""}`} onClick={() => setTab("groups")}>Ring groups</button>}
+        {admin && <button className={`tab ${tab === "parking" ? "active" : ""}`} onClick={() => setTab("parking")}>Parking</button>}
+        {admin && <button className={`tab ${tab === "sessions" ? "active" : ""}`} onClick={() => setTab("sessions")}>Sessions</button>}
         <button className={`tab ${tab === "journals" ? "active" : ""}`} onClick={() => setTab("journals")}>Call journals</button>
       </div>
 
       <div className="card">
         <h2 style={{ marginTop: 0 }}>{title}</h2>
-        {tab === "users" && <Users />}
-        {tab === "sessions" && <Sessions />}
+        {admin && tab === "users" && <Users />}
+        {admin && tab === "groups" && <RingGroups />}
+        {admin && tab === "parking" && <Parking />}
+        {admin && tab === "sessions" && <Sessions />}
         {tab === "journals" && <CallJournals />}
         <div style={{ marginTop: 14 }}>
           <small className="muted">
@@ -1,15 +1,43 @@
 export type ApiOk<T> = { data: T };
 export type ApiErr = { errors: Record<string, string> };
 
+export type Me = { id: number; login: string; role: "admin" | "user"; kind: string };
+
+const TOKEN_KEY = "token";
+
+export function getToken() {
+  return localStorage.getItem(TOKEN_KEY) || "";
+}
+
+export function setToken(token: string) {
+  if (token) localStorage.setItem(TOKEN_KEY, token);
+  else localStorage.removeItem(TOKEN_KEY);
+}
+
+// вызывается, когда сервер ответил 401: токен истёк или отозван
+let onUnauthorized = () => {};
+export function setUnauthorizedHandler(fn: () => void) {
+  onUnauthorized = fn;
+}
+
 export async function apiFetch<T>(path: string, init?: RequestInit): Promise<T> {
+  const token = getToken();
   const res = await fetch(path, {
-    headers: { "Content-Type": "application/json", ...(init?.headers || {}) },
     ...init,
+    headers: {
+      "Content-Type": "application/json",
+      ...(token ? { Authorization: `Bearer ${token}` } : {}),
+      ...(init?.headers || {}),
+    },
   });
 
   const text = await res.text();
   const json = text ? JSON.parse(text) : {};
 
+  if (res.status === 401 && token) {
+    setToken("");
+    onUnauthorized();
+  }
   if (!res.ok) {
     const msg = json?.errors ? JSON.stringify(json.errors, null, 2) : `HTTP ${res.status}`;
     throw new Error(msg);
@@ -17,6 +45,23 @@ export async function apiFetch<T>(path: string, init?: RequestInit): Promise<T>
   return (json as ApiOk<T>).data;
 }
 
+export async function login(login: string, password: string): Promise<Me> {
+  const s = await apiFetch<{ token: string; user: Me }>("/api/auth/login", {
+    method: "POST",
+    body: JSON.stringify({ login, password }),
+  });
+  setToken(s.token);
+  return s.user;
+}
+
+export async function logout() {
+  try {
+    await apiFetch("/api/auth/logout", { method: "POST" });
+  } finally {
+    setToken("");
+  }
+}
+
 export function pretty(v: any) {
   if (v === null || v === undefined) return "";
   if (typeof v === "object") return JSON.stringify(v);
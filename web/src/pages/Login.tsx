@@ -0,0 +1,42 @@
+import React, { useState } from "react";
+import { login, Me } from "../api";
+
+export default function Login({ onLogin }: { onLogin: (me: Me) => void }) {
+  const [form, setForm] = useState({ login: "", password: "" });
+  const [err, setErr] = useState("");
+  const [busy, setBusy] = useState(false);
+
+  async function submit(e: React.FormEvent) {
+    e.preventDefault();
+    setErr("");
+    setBusy(true);
+    try {
+      onLogin(await login(form.login.trim(), form.password));
+    } catch (e: any) {
+      setErr(e.message || "login error");
+    } finally {
+      setBusy(false);
+    }
+  }
+
+  return (
+    <div className="container">
+      <h1 style={{ marginTop: 8, marginBottom: 10 }}>SipServer Admin</h1>
+      <form className="card" onSubmit={submit} style={{ maxWidth: 360 }}>
+        <h2 style={{ marginTop: 0 }}>Sign in</h2>
+        <div style={{ marginBottom: 10 }}>
+          <label>login</label>
+          <input value={form.login} autoFocus autoComplete="username"
+            onChange={(e) => setForm({ ...form, login: e.target.value })} />
+        </div>
+        <div style={{ marginBottom: 14 }}>
+          <label>password</label>
+          <input type="password" value={form.password} autoComplete="current-password"
+            onChange={(e) => setForm({ ...form, password: e.target.value })} />
+        </div>
+        <button type="submit" disabled={busy || !form.login.trim() || !form.password}>Sign in</button>
+        {err && <pre className="error">{err}</pre>}
+      </form>
+    </div>
+  );
+}
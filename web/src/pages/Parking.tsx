@@ -0,0 +1,45 @@
+import { useEffect, useState } from "react";
+import { apiFetch } from "../api";
+import ResponsiveTable from "../components/ResponsiveTable";
+
+export default function Parking() {
+  const [rows, setRows] = useState<any[]>([]);
+  const [err, setErr] = useState("");
+  const [busy, setBusy] = useState(false);
+
+  async function load() {
+    setErr("");
+    setBusy(true);
+    try {
+      setRows(await apiFetch<any[]>("/api/parking"));
+    } catch (e: any) {
+      setErr(e.message || "load error");
+    } finally {
+      setBusy(false);
+    }
+  }
+
+  useEffect(() => {
+    load();
+    // слоты меняются часто — обновляем сами
+    const t = setInterval(load, 5000);
+    return () => clearInterval(t);
+  }, []);
+
+  return (
+    <div>
+      <div className="row">
+        <button onClick={load} disabled={busy}>Reload</button>
+      </div>
+
+      {err && <pre className="error">{err}</pre>}
+
+      <div style={{ marginTop: 14 }}>
+        <ResponsiveTable
+          rows={rows}
+          preferCols={["slot", "caller_user", "parked_by", "parked_at", "timeout_at", "call_id"]}
+        />
+      </div>
+    </div>
+  );
+}
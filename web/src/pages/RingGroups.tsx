@@ -0,0 +1,201 @@
+import { useEffect, useState } from "react";
+import { apiFetch } from "../api";
+
+type Strategy = "ringall" | "linear" | "roundrobin" | "leastrecent";
+type OverflowType = "none" | "user" | "group" | "trunk";
+
+type Member = { login: string; position?: number; last_call_at?: string };
+
+type RingGroup = {
+  id: number;
+  name: string;
+  extension: string;
+  enabled: boolean;
+  strategy: Strategy;
+  member_timeout: number;
+  overflow_type: OverflowType;
+  overflow_target?: string;
+  members: Member[];
+};
+
+const strategies: Strategy[] = ["ringall", "linear", "roundrobin", "leastrecent"];
+const overflowTypes: OverflowType[] = ["none", "user", "group", "trunk"];
+
+function parseMembers(s: string): Member[] {
+  return s
+    .split(",")
+    .map((x) => x.trim())
+    .filter(Boolean)
+    .map((login) => ({ login }));
+}
+
+export default function RingGroups() {
+  const [items, setItems] = useState<RingGroup[]>([]);
+  const [err, setErr] = useState("");
+  const [busy, setBusy] = useState(false);
+
+  const [form, setForm] = useState({
+    name: "",
+    extension: "",
+    strategy: "ringall" as Strategy,
+    member_timeout: 20,
+    members: "",
+    overflow_type: "none" as OverflowType,
+    overflow_target: "",
+  });
+
+  async function load() {
+    setErr("");
+    setBusy(true);
+    try {
+      setItems(await apiFetch<RingGroup[]>("/api/ring_groups"));
+    } catch (e: any) {
+      setErr(e.message || "load error");
+    } finally {
+      setBusy(false);
+    }
+  }
+
+  useEffect(() => { load(); }, []);
+
+  async function create() {
+    setErr("");
+    setBusy(true);
+    try {
+      await apiFetch<RingGroup>("/api/ring_groups", {
+        method: "POST",
+        body: JSON.stringify({
+          name: form.name.trim(),
+          extension: form.extension.trim(),
+          enabled: true,
+          strategy: form.strategy,
+          member_timeout: Number(form.member_timeout),
+          overflow_type: form.overflow_type,
+          overflow_target: form.overflow_type === "none" ? "" : form.overflow_target.trim(),
+          members: parseMembers(form.members),
+        }),
+      });
+      setForm({ ...form, name: "", extension: "", members: "", overflow_target: "" });
+      await load();
+    } catch (e: any) {
+      setErr(e.message || "create error");
+    } finally {
+      setBusy(false);
+    }
+  }
+
+  async function edit(g: RingGroup) {
+    const members = prompt("members (login, comma separated):", g.members.map((m) => m.login).join(", "));
+    if (members === null) return;
+    const strategy = (prompt(`strategy (${strategies.join("/")}):`, g.strategy) ?? g.strategy) as Strategy;
+
+    setErr("");
+    setBusy(true);
+    try {
+      await apiFetch<RingGroup>(`/api/ring_groups/${g.id}`, {
+        method: "PUT",
+        body: JSON.stringify({ ...g, strategy, members: parseMembers(members) }),
+      });
+      await load();
+    } catch (e: any) {
+      setErr(e.message || "update error");
+    } finally {
+      setBusy(false);
+    }
+  }
+
+  async function remove(g: RingGroup) {
+    if (!confirm(`Delete group ${g.extension}?`)) return;
+
+    setErr("");
+    setBusy(true);
+    try {
+      await apiFetch(`/api/ring_groups/${g.id}`, { method: "DELETE" });
+      await load();
+    } catch (e: any) {
+      setErr(e.message || "delete error");
+    } finally {
+      setBusy(false);
+    }
+  }
+
+  return (
+    <div>
+      <div className="row">
+        <div>
+          <label>name</label>
+          <input value={form.name} onChange={(e) => setForm({ ...form, name: e.target.value })} />
+        </div>
+        <div>
+          <label>extension</label>
+          <input value={form.extension} onChange={(e) => setForm({ ...form, extension: e.target.value })} />
+        </div>
+        <div>
+          <label>strategy</label>
+          <select value={form.strategy} onChange={(e) => setForm({ ...form, strategy: e.target.value as Strategy })}>
+            {strategies.map((s) => <option key={s} value={s}>{s}</option>)}
+          </select>
+        </div>
+        <div>
+          <label>timeout, s</label>
+          <input type="number" value={form.member_timeout} onChange={(e) => setForm({ ...form, member_timeout: Number(e.target.value) })} />
+        </div>
+        <div>
+          <label>members</label>
+          <input placeholder="1001, 1002" value={form.members} onChange={(e) => setForm({ ...form, members: e.target.value })} />
+        </div>
+        <div>
+          <label>overflow</label>
+          <select value={form.overflow_type} onChange={(e) => setForm({ ...form, overflow_type: e.target.value as OverflowType })}>
+            {overflowTypes.map((o) => <option key={o} value={o}>{o}</option>)}
+          </select>
+        </div>
+        {form.overflow_type !== "none" && (
+          <div>
+            <label>overflow target</label>
+            <input value={form.overflow_target} onChange={(e) => setForm({ ...form, overflow_target: e.target.value })} />
+          </div>
+        )}
+        <button onClick={create} disabled={busy || !form.name.trim() || !form.extension.trim()}>Create</button>
+        <button onClick={load} disabled={busy}>Reload</button>
+      </div>
+
+      {err && <pre className="error">{err}</pre>}
+
+      <table style={{ marginTop: 14 }}>
+        <thead>
+          <tr>
+            <th>id</th>
+            <th>extension</th>
+            <th>name</th>
+            <th>strategy</th>
+            <th>timeout</th>
+            <th>members</th>
+            <th>overflow</th>
+            <th />
+          </tr>
+        </thead>
+        <tbody>
+          {items.map((g) => (
+            <tr key={g.id}>
+              <td>{g.id}</td>
+              <td>{g.extension}</td>
+              <td>{g.name}</td>
+              <td>{g.strategy}</td>
+              <td>{g.member_timeout}</td>
+              <td>{g.members.map((m) => m.login).join(", ")}</td>
+              <td>{g.overflow_type === "none" ? "" : `${g.overflow_type}: ${g.overflow_target}`}</td>
+              <td>
+                <button onClick={() => edit(g)} disabled={busy}>Edit</button>
+                <button onClick={() => remove(g)} disabled={busy}>Delete</button>
+              </td>
+            </tr>
+          ))}
+          {!items.length && (
+            <tr><td colSpan={8}><small className="muted">No ring groups</small></td></tr>
+          )}
+        </tbody>
+      </table>
+    </div>
+  );
+}
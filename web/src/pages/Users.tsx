@@ -1,13 +1,35 @@
 import { useEffect, useState } from "react";
 import { apiFetch } from "../api";
 
+type CallSchema = "redirect" | "proxy" | "b2bua";
+
 type User = {
   id: number;
   login: string;
   role: "admin" | "user";
-  config: { call_schema: "redirect" | "proxy" };
+  enabled: boolean;
+  display_name: string;
+  caller_id_number: string;
+  caller_id_name: string;
+  email: string;
+  aliases: string[];
+  config: {
+    call_schema: CallSchema;
+    pickup_group?: string;
+    record_calls: boolean;
+    codecs: string[];
+    strip_video: boolean;
+    force_pcma: boolean;
+    max_outbound_calls: number;
+    max_inbound_calls: number;
+    max_calls: number;
+  };
 };
 
+function parseList(v: string): string[] {
+  return v.split(",").map((c) => c.trim()).filter(Boolean);
+}
+
 export default function Users() {
   const [items, setItems] = useState<User[]>([]);
   const [err, setErr] = useState("");
@@ -15,8 +37,14 @@ export default function Users() {
 
   const [form, setForm] = useState({
     login: "",
+    password: "",
     role: "user" as "user" | "admin",
-    call_schema: "redirect" as "redirect" | "proxy",
+    call_schema: "redirect" as CallSchema,
+    pickup_group: "",
+    record_calls: false,
+    codecs: "",
+    strip_video: false,
+    force_pcma: false,
   });
 
   async function load() {
@@ -41,11 +69,19 @@ export default function Users() {
         method: "POST",
         body: JSON.stringify({
           login: form.login.trim(),
+          password: form.password || undefined,
           role: form.role,
-          config: { call_schema: form.call_schema },
+          config: {
+            call_schema: form.call_schema,
+            pickup_group: form.pickup_group.trim(),
+            record_calls: form.record_calls,
+            codecs: parseList(form.codecs),
+            strip_video: form.strip_video,
+            force_pcma: form.force_pcma,
+          },
         }),
       });
-      setForm({ ...form, login: "" });
+      setForm({ ...form, login: "", password: "" });
       await load();
     } catch (e: any) {
       setErr(e.message || "create error");
@@ -54,10 +90,58 @@ export default function Users() {
     }
   }
 
+  async function run(fn: () => Promise<void>) {
+    setErr("");
+    setBusy(true);
+    try {
+      await fn();
+      await load();
+    } catch (e: any) {
+      setErr(e.message || "request error");
+    } finally {
+      setBusy(false);
+    }
+  }
+
+  function toggle(u: User) {
+    return run(async () => {
+      await apiFetch(`/api/users/${u.id}`, { method: "PUT", body: JSON.stringify({ enabled: !u.enabled }) });
+    });
+  }
+
+  function resetPassword(u: User) {
+    if (!confirm(`Reset password for ${u.login}?`)) return;
+    return run(async () => {
+      const r = await apiFetch<{ password: string }>(`/api/users/${u.id}/password/reset`, { method: "POST" });
+      // пароль показывается один раз
+      alert(`New password for ${u.login}: ${r.password}`);
+    });
+  }
+
+  function remove(u: User) {
+    if (!confirm(`Delete ${u.login}? Call history is kept.`)) return;
+    return run(async () => {
+      await apiFetch(`/api/users/${u.id}`, { method: "DELETE" });
+    });
+  }
+
   async function edit(u: User) {
     const login = prompt("login:", u.login) ?? u.login;
     const role = (prompt("role (admin/user):", u.role) ?? u.role) as any;
-    const schema = (prompt("call_schema (redirect/proxy):", u.config.call_schema) ?? u.config.call_schema) as any;
+    const displayName = prompt("display_name:", u.display_name ?? "") ?? u.display_name ?? "";
+    const callerIdNumber = prompt("caller_id_number (for trunks):", u.caller_id_number ?? "") ?? u.caller_id_number ?? "";
+    const email = prompt("email:", u.email ?? "") ?? u.email ?? "";
+    const aliases = prompt("aliases (comma separated):", (u.aliases ?? []).join(",")) ?? (u.aliases ?? []).join(",");
+    const schema = (prompt("call_schema (redirect/proxy/b2bua):", u.config.call_schema) ?? u.config.call_schema) as any;
+    const pickupGroup = prompt("pickup_group:", u.config.pickup_group ?? "") ?? u.config.pickup_group ?? "";
+    const recordCalls = confirm(`record_calls for ${login}? (OK = yes, Cancel = no)`);
+    const codecs = prompt("codecs (comma separated, empty = any):", (u.config.codecs ?? []).join(",")) ?? (u.config.codecs ?? []).join(",");
+    const stripVideo = confirm(`strip_video for ${login}? (OK = yes, Cancel = no)`);
+    const forcePCMA = confirm(`force_pcma for ${login}? (OK = yes, Cancel = no)`);
+    const limits = (prompt(
+      "concurrent calls limits outbound/inbound/total (0 = unlimited):",
+      `${u.config.max_outbound_calls ?? 0}/${u.config.max_inbound_calls ?? 0}/${u.config.max_calls ?? 0}`,
+    ) ?? "").split("/").map((v) => Number(v.trim()));
 
     setErr("");
     setBusy(true);
@@ -67,7 +151,21 @@ export default function Users() {
         body: JSON.stringify({
           login,
           role,
-          config: { call_schema: schema },
+          display_name: displayName.trim(),
+          caller_id_number: callerIdNumber.trim(),
+          email: email.trim(),
+          aliases: parseList(aliases),
+          config: {
+            call_schema: schema,
+            pickup_group: pickupGroup.trim(),
+            record_calls: recordCalls,
+            codecs: parseList(codecs),
+            strip_video: stripVideo,
+            force_pcma: forcePCMA,
+            ...(limits.length === 3 && limits.every((v) => Number.isInteger(v) && v >= 0)
+              ? { max_outbound_calls: limits[0], max_inbound_calls: limits[1], max_calls: limits[2] }
+              : {}),
+          },
         }),
       });
       await load();
@@ -85,6 +183,11 @@ export default function Users() {
           <label>login</label>
           <input value={form.login} onChange={(e) => setForm({ ...form, login: e.target.value })} />
         </div>
+        <div>
+          <label>password (API login)</label>
+          <input type="password" value={form.password} autoComplete="new-password"
+            onChange={(e) => setForm({ ...form, password: e.target.value })} />
+        </div>
         <div>
           <label>role</label>
           <select value={form.role} onChange={(e) => setForm({ ...form, role: e.target.value as any })}>
@@ -97,8 +200,45 @@ export default function Users() {
           <select value={form.call_schema} onChange={(e) => setForm({ ...form, call_schema: e.target.value as any })}>
             <option value="redirect">redirect</option>
             <option value="proxy">proxy</option>
+            <option value="b2bua">b2bua</option>
           </select>
         </div>
+        <div>
+          <label>pickup_group</label>
+          <input value={form.pickup_group} onChange={(e) => setForm({ ...form, pickup_group: e.target.value })} />
+        </div>
+        <div>
+          <label>record_calls</label>
+          <input
+            type="checkbox"
+            checked={form.record_calls}
+            onChange={(e) => setForm({ ...form, record_calls: e.target.checked })}
+          />
+        </div>
+        <div>
+          <label>codecs</label>
+          <input
+            value={form.codecs}
+            placeholder="PCMA,PCMU"
+            onChange={(e) => setForm({ ...form, codecs: e.target.value })}
+          />
+        </div>
+        <div>
+          <label>strip_video</label>
+          <input
+            type="checkbox"
+            checked={form.strip_video}
+            onChange={(e) => setForm({ ...form, strip_video: e.target.checked })}
+          />
+        </div>
+        <div>
+          <label>force_pcma</label>
+          <input
+            type="checkbox"
+            checked={form.force_pcma}
+            onChange={(e) => setForm({ ...form, force_pcma: e.target.checked })}
+          />
+        </div>
         <button onClick={create} disabled={busy || !form.login.trim()}>Create</button>
         <button onClick={load} disabled={busy}>Reload</button>
       </div>
@@ -110,8 +250,15 @@ export default function Users() {
           <tr>
             <th>id</th>
             <th>login</th>
+            <th>name</th>
+            <th>aliases</th>
             <th>role</th>
+            <th>enabled</th>
             <th>call_schema</th>
+            <th>pickup_group</th>
+            <th>record_calls</th>
+            <th>codecs</th>
+            <th>limits (out/in/total)</th>
             <th />
           </tr>
         </thead>
@@ -120,13 +267,29 @@ export default function Users() {
             <tr key={u.id}>
               <td>{u.id}</td>
               <td>{u.login}</td>
+              <td>{u.display_name}</td>
+              <td>{(u.aliases ?? []).join(", ")}</td>
               <td>{u.role}</td>
+              <td>{u.enabled ? "yes" : "no"}</td>
               <td>{u.config?.call_schema}</td>
-              <td><button onClick={() => edit(u)} disabled={busy}>Edit</button></td>
+              <td>{u.config?.pickup_group}</td>
+              <td>{u.config?.record_calls ? "yes" : ""}</td>
+              <td>
+                {(u.config?.codecs ?? []).join(", ")}
+                {u.config?.force_pcma ? " (PCMA only)" : ""}
+                {u.config?.strip_video ? " no video" : ""}
+              </td>
+              <td>{u.config?.max_outbound_calls ?? 0}/{u.config?.max_inbound_calls ?? 0}/{u.config?.max_calls ?? 0}</td>
+              <td>
+                <button onClick={() => edit(u)} disabled={busy}>Edit</button>
+                <button onClick={() => toggle(u)} disabled={busy}>{u.enabled ? "Disable" : "Enable"}</button>
+                <button onClick={() => resetPassword(u)} disabled={busy}>Reset password</button>
+                <button onClick={() => remove(u)} disabled={busy}>Delete</button>
+              </td>
             </tr>
           ))}
           {!items.length && (
-            <tr><td colSpan={5}><small className="muted">No users</small></td></tr>
+            <tr><td colSpan={12}><small className="muted">No users</small></td></tr>
           )}
         </tbody>
       </table>